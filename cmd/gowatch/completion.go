@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+
+	"github.com/spf13/cobra"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate a shell completion script",
+	Long: `Print a completion script for the given shell. Besides commands and
+flags, it completes config files for --config, profile names for --profile
+and task names, read from the config in the current directory.
+
+Bash:
+  # Current session
+  source <(gowatch completion bash)
+  # Every session, on Linux
+  gowatch completion bash > /etc/bash_completion.d/gowatch
+  # Every session, on macOS with Homebrew
+  gowatch completion bash > $(brew --prefix)/etc/bash_completion.d/gowatch
+
+Zsh:
+  # Enable completion once, if it isn't already
+  echo "autoload -U compinit; compinit" >> ~/.zshrc
+  gowatch completion zsh > "${fpath[1]}/_gowatch"
+
+Fish:
+  gowatch completion fish > ~/.config/fish/completions/gowatch.fish
+
+PowerShell:
+  gowatch completion powershell | Out-String | Invoke-Expression
+  # Add the line above to your profile to load it in every session`,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	DisableFlagsInUseLine: true,
+	RunE:                  generateCompletion,
+	SilenceUsage:          true,
+	SilenceErrors:         true,
+}
+
+func init() {
+	// The generated command is replaced by completionCmd
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.AddCommand(completionCmd)
+}
+
+// registerCompletions adds dynamic completions to the flags and arguments of
+// the other commands. It runs from main, once every init has defined them.
+func registerCompletions() {
+	for _, c := range []*cobra.Command{runCmd, execCmd, replayCmd, startCmd, testConfigCmd} {
+		c.RegisterFlagCompletionFunc("config", completeConfigFile)
+		c.RegisterFlagCompletionFunc("profile", completeProfiles)
+	}
+	historyCmd.RegisterFlagCompletionFunc("config", completeConfigFile)
+	for _, c := range []*cobra.Command{runCmd, tuiCmd, execCmd, startCmd, historyCmd} {
+		c.ValidArgsFunction = completeTasks
+	}
+	runCmd.RegisterFlagCompletionFunc("debounce-strategy", cobra.FixedCompletions(
+		[]string{config.DebounceTrailing, config.DebounceLeading, config.DebounceThrottle}, cobra.ShellCompDirectiveNoFileComp))
+	for _, c := range []*cobra.Command{runCmd, goCmd} {
+		c.RegisterFlagCompletionFunc("bell", cobra.FixedCompletions(
+			[]string{config.BellFailure, config.BellChange}, cobra.ShellCompDirectiveNoFileComp))
+		c.RegisterFlagCompletionFunc("path", completeDirs)
+	}
+	runCmd.RegisterFlagCompletionFunc("serve", completeDirs)
+	// Packages are directories
+	goCmd.ValidArgsFunction = completeDirs
+	for _, c := range []*cobra.Command{runCmd, execCmd} {
+		c.RegisterFlagCompletionFunc("group-output", cobra.FixedCompletions(logger.GroupStyles, cobra.ShellCompDirectiveNoFileComp))
+	}
+}
+
+// completeDirs offers directories
+func completeDirs(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveFilterDirs
+}
+
+func generateCompletion(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+	switch args[0] {
+	case "bash":
+		return cmd.Root().GenBashCompletionV2(out, true)
+	case "zsh":
+		return cmd.Root().GenZshCompletion(out)
+	case "fish":
+		return cmd.Root().GenFishCompletion(out, true)
+	case "powershell":
+		return cmd.Root().GenPowerShellCompletionWithDesc(out)
+	}
+	return fmt.Errorf("unsupported shell %q", args[0])
+}
+
+// completeConfigFile offers files in the supported config formats
+func completeConfigFile(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	exts := make([]string, 0, len(config.FileNames))
+	for _, name := range config.FileNames {
+		exts = append(exts, strings.TrimPrefix(filepath.Ext(name), "."))
+	}
+	return exts, cobra.ShellCompDirectiveFilterFileExt
+}
+
+// completeProfiles offers the profiles of the config
+func completeProfiles(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	cfg := completionConfig()
+	if cfg == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return cfg.ProfileNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTasks offers the tasks of the config that haven't been named yet,
+// also after a comma in a task list such as "build,te"
+func completeTasks(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg := completionConfig()
+	if cfg == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix = toComplete[:i+1]
+	}
+	named := make(map[string]bool)
+	for _, name := range parseTaskArgs(append(args, prefix)) {
+		named[name] = true
+	}
+
+	var names []string
+	for _, name := range cfg.TaskNames() {
+		if !named[name] {
+			names = append(names, prefix+name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completionConfig loads the config named by --config or found by
+// auto-discovery, or returns nil if there is none or it is invalid
+func completionConfig() *config.Config {
+	file := cfgFile
+	if len(cfgFiles) > 0 {
+		// gowatch run takes several
+		file = cfgFiles[0]
+	}
+	cfg, err := config.Load(file)
+	if err != nil {
+		return nil
+	}
+	return cfg
+}
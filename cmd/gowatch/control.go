@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gowatch/internal/api"
+)
+
+// controlTimeout bounds queries of a running instance
+const controlTimeout = 2 * time.Second
+
+// controlSocket returns the path of the Unix socket on which a gowatch run
+// in the working directory serves its control API. Sockets live in a
+// per-user runtime directory so that projects stay clean and other users
+// can't drive the session.
+func controlSocket() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	name := "gowatch"
+	if uid := os.Getuid(); uid >= 0 {
+		name += "-" + strconv.Itoa(uid)
+	}
+
+	sum := sha256.Sum256([]byte(wd))
+	return filepath.Join(dir, name, hex.EncodeToString(sum[:8])+".sock"), nil
+}
+
+// listenControl binds the control socket of the working directory. It
+// fails if another gowatch in the same directory already serves it.
+func listenControl() (net.Listener, error) {
+	path, err := controlSocket()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, controlTimeout); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another gowatch is already running in this directory")
+		}
+		// Left behind by an instance that didn't shut down cleanly
+		os.Remove(path)
+	}
+
+	return net.Listen("unix", path)
+}
+
+// queryControl fetches the status of the gowatch running in the working
+// directory over its control socket
+func queryControl() (*api.Status, error) {
+	path, err := controlSocket()
+	if err != nil {
+		return nil, err
+	}
+
+	client := http.Client{
+		Timeout: controlTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+	resp, err := client.Get("http://gowatch/status")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status request failed: %s", resp.Status)
+	}
+
+	var status api.Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gowatch/internal/api"
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+
+	"github.com/spf13/cobra"
+)
+
+// Files kept in the daemon's run directory
+const (
+	pidFileName   = "gowatch.pid"
+	logFileName   = "gowatch.log"
+	stateFileName = "state.json"
+)
+
+// daemonStopTimeout is how long stop waits for the daemon to exit
+const daemonStopTimeout = 10 * time.Second
+
+var (
+	runDir     string
+	stateFile  string
+	statusJSON bool
+)
+
+var startCmd = &cobra.Command{
+	Use:   "start [task[,task...]]",
+	Short: "Start the watcher in the background",
+	Long: `Start "gowatch run" as a background daemon that keeps running after the
+terminal is closed. Its PID file, log and last run are kept in the run
+directory (default: .gowatch).
+
+Examples:
+  gowatch start
+  gowatch start build --config gowatch.yaml
+  gowatch status
+  gowatch stop`,
+	RunE:          startDaemon,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var stopCmd = &cobra.Command{
+	Use:           "stop",
+	Short:         "Stop the background watcher",
+	RunE:          stopDaemon,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state of the running watcher",
+	Long: `Show the state of the gowatch running in this directory, in the
+foreground or in the background: uptime, watched directories, events
+processed, the last event and run, and the commands executing right now.
+Exits with 1 when gowatch is not running.`,
+	RunE:          daemonStatus,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(startCmd)
+	rootCmd.AddCommand(stopCmd)
+	rootCmd.AddCommand(statusCmd)
+
+	for _, c := range []*cobra.Command{startCmd, stopCmd, statusCmd} {
+		c.Flags().StringVar(&runDir, "run-dir", ".gowatch", "directory for the PID file, log and state")
+		c.Flags().BoolVar(&noColor, "no-color", false, "disable colored output")
+	}
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "print the status as JSON")
+	startCmd.Flags().StringVarP(&cfgFile, "config", "c", "", "config file path (default: gowatch.yaml, .yml, .toml or .json)")
+	startCmd.Flags().StringVar(&profile, "profile", "", "activate a profile from the config (default: $GOWATCH_PROFILE)")
+	startCmd.Flags().BoolVar(&config.NoUserConfig, "no-user-config", false, "ignore the user config in ~/.config/gowatch")
+	addLogFlags(startCmd)
+
+	// Written by the daemon so that status can report the last run
+	runCmd.Flags().StringVar(&stateFile, "state-file", "", "write the result of each run to this file")
+	runCmd.Flags().MarkHidden("state-file")
+}
+
+func startDaemon(cmd *cobra.Command, args []string) error {
+	log := logger.New(logger.LevelInfo, !noColor)
+
+	if pid, err := readPID(); err == nil && processAlive(pid) {
+		return fmt.Errorf("gowatch is already running (pid %d)", pid)
+	}
+
+	if err := resolveConfigFile(); err != nil {
+		return err
+	}
+	level, levelFlag, err := resolveLogLevel()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		return fmt.Errorf("failed to create run directory: %w", err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate gowatch executable: %w", err)
+	}
+
+	logPath := filepath.Join(runDir, logFileName)
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer logFile.Close()
+
+	statePath := filepath.Join(runDir, stateFileName)
+	os.Remove(statePath)
+
+	runArgs := []string{"run", "--no-color", "--config", cfgFile, "--state-file", statePath}
+	if profile != "" {
+		runArgs = append(runArgs, "--profile", profile)
+	}
+	if config.NoUserConfig {
+		runArgs = append(runArgs, "--no-user-config")
+	}
+	// GOWATCH_LOG_LEVEL is inherited by the daemon
+	if levelFlag {
+		runArgs = append(runArgs, "--log-level", level.String())
+	}
+	runArgs = append(runArgs, args...)
+	child := exec.Command(exe, runArgs...)
+	child.Stdout = logFile
+	child.Stderr = logFile
+	child.SysProcAttr = daemonSysProcAttr()
+
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("failed to start daemon: %w", err)
+	}
+	pid := child.Process.Pid
+
+	// Reap the child if it exits early so processAlive sees it gone
+	exited := make(chan struct{})
+	go func() {
+		child.Wait()
+		close(exited)
+	}()
+
+	// Give the watcher a moment to load its config and fail fast
+	select {
+	case <-exited:
+		return fmt.Errorf("daemon exited during startup, see %s", logPath)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(filepath.Join(runDir, pidFileName), []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write PID file: %w", err)
+	}
+
+	log.Success("gowatch started in the background (pid %d)", pid)
+	log.Info("Log: %s", logPath)
+	return nil
+}
+
+func stopDaemon(cmd *cobra.Command, args []string) error {
+	log := logger.New(logger.LevelInfo, !noColor)
+
+	pid, err := readPID()
+	if err != nil {
+		return fmt.Errorf("gowatch is not running: %w", err)
+	}
+	pidPath := filepath.Join(runDir, pidFileName)
+
+	if !processAlive(pid) {
+		os.Remove(pidPath)
+		log.Warn("gowatch was not running (removed stale PID file)")
+		return nil
+	}
+
+	if err := terminateProcess(pid); err != nil {
+		return fmt.Errorf("failed to stop gowatch (pid %d): %w", pid, err)
+	}
+
+	deadline := time.Now().Add(daemonStopTimeout)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("gowatch (pid %d) did not exit within %s", pid, daemonStopTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	os.Remove(pidPath)
+	log.Success("gowatch stopped (pid %d)", pid)
+	return nil
+}
+
+func daemonStatus(cmd *cobra.Command, args []string) error {
+	log := logger.New(logger.LevelInfo, !noColor)
+
+	// A running instance, in the foreground or as the daemon, answers on
+	// its control socket
+	if status, err := queryControl(); err == nil {
+		if statusJSON {
+			return printJSON(status)
+		}
+		printStatus(log, status)
+		return nil
+	}
+
+	pid, err := readPID()
+	if err != nil || !processAlive(pid) {
+		if statusJSON {
+			printJSON(map[string]bool{"running": false})
+		} else {
+			log.Warn("gowatch is not running")
+		}
+		return exitCodeError{code: 1}
+	}
+
+	var last *api.Result
+	data, err := os.ReadFile(filepath.Join(runDir, stateFileName))
+	if err == nil {
+		var result api.Result
+		if json.Unmarshal(data, &result) == nil {
+			last = &result
+		}
+	}
+	if statusJSON {
+		return printJSON(api.Status{PID: pid, LastRun: last})
+	}
+
+	log.Success("gowatch is running (pid %d)", pid)
+	if info, err := os.Stat(filepath.Join(runDir, pidFileName)); err == nil {
+		log.Info("Started: %s", info.ModTime().Format(time.RFC1123))
+	}
+	log.Info("Log: %s", filepath.Join(runDir, logFileName))
+	if last == nil {
+		log.Info("Last run: none yet")
+		return nil
+	}
+	printLastRun(log, last)
+	return nil
+}
+
+// printStatus shows the live status of a running instance
+func printStatus(log *logger.Logger, status *api.Status) {
+	state := "running"
+	if status.Paused {
+		state = "paused"
+	}
+	log.Success("gowatch is %s (pid %d, up %s)", state, status.PID, status.Uptime)
+	log.Info("Started: %s", status.StartedAt.Format(time.RFC1123))
+	log.Info("Tasks: %s", strings.Join(status.Tasks, ", "))
+	log.Info("Watched: %d directories", status.WatchedDirs)
+	log.Info("Events: %d processed, %d run(s), %d failed", status.Events, status.Runs, status.Failures)
+	if ws := status.Watcher; ws.Received > 0 {
+		log.Info("Changes: %d received, %d ignored, %d filtered, %d debounced", ws.Received, ws.Ignored, ws.Filtered, ws.Debounced)
+		if ws.Overflowed > 0 {
+			log.Warn("Event queue overflowed %d time(s)", ws.Overflowed)
+		}
+	}
+	if status.Goroutines > 0 {
+		log.Info("Goroutines: %d", status.Goroutines)
+	}
+	if ev := status.LastEvent; ev != nil {
+		ago := time.Since(ev.Time).Round(time.Second)
+		if ev.Path != "" {
+			log.Info("Last event: %s %s (%s, %s ago)", ev.Op, ev.Path, ev.Task, ago)
+		} else {
+			log.Info("Last event: %s (%s, %s ago)", ev.Op, ev.Task, ago)
+		}
+	}
+	printWatchRoots(log, status.Roots)
+
+	if len(status.Running) > 0 {
+		log.Section("Running")
+		for _, c := range status.Running {
+			log.Info("%s (%s, %s)", strings.Join(c.Command, " "), c.Task, c.Elapsed)
+		}
+	}
+
+	if status.LastRun == nil {
+		log.Info("Last run: none yet")
+		return
+	}
+	printLastRun(log, status.LastRun)
+}
+
+// printWatchRoots shows what each watch path covers and the rules that
+// ignored the most changes under it
+func printWatchRoots(log *logger.Logger, roots []api.WatchRoot) {
+	if len(roots) == 0 {
+		return
+	}
+	log.Section("Watch Paths")
+	for _, r := range roots {
+		log.Info("%s (%s): %d watched, %d file(s), %d change(s), %d ignored", r.Path, r.Task, r.Watched, r.Files, r.Events, r.Ignored)
+		rules := slices.Collect(maps.Keys(r.IgnoredBy))
+		slices.SortFunc(rules, func(a, b string) int {
+			return cmp.Or(cmp.Compare(r.IgnoredBy[b], r.IgnoredBy[a]), strings.Compare(a, b))
+		})
+		for _, rule := range rules[:min(len(rules), maxIgnoreRules)] {
+			log.Info("  %d ignored by %s", r.IgnoredBy[rule], rule)
+		}
+		if len(rules) > maxIgnoreRules {
+			log.Info("  ... and %d more rule(s)", len(rules)-maxIgnoreRules)
+		}
+	}
+}
+
+// maxIgnoreRules is how many ignore rules gowatch status lists per watch
+// path
+const maxIgnoreRules = 5
+
+func printLastRun(log *logger.Logger, last *api.Result) {
+	log.Section("Last Run")
+	log.Info("Time: %s (%s)", last.Start.Format(time.RFC1123), last.Duration)
+	if last.Path != "" {
+		log.Info("Trigger: %s %s", last.Event, last.Path)
+	}
+	for _, c := range last.Commands {
+		if c.ExitCode == 0 {
+			log.Success("%s", strings.Join(c.Command, " "))
+		} else {
+			log.Error("%s (exit: %d)", strings.Join(c.Command, " "), c.ExitCode)
+		}
+	}
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// readPID returns the PID recorded in the run directory
+func readPID() (int, error) {
+	data, err := os.ReadFile(filepath.Join(runDir, pidFileName))
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, errors.New("invalid PID file")
+	}
+	return pid, nil
+}
+
+// writeState records the last run for gowatch status. The file is replaced
+// atomically so readers never see a partial write.
+func writeState(path string, report runner.Report) error {
+	data, err := json.MarshalIndent(api.NewResult(report), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+	"gowatch/pkg/watcher"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment for problems",
+	Long: `Check that gowatch can work in this environment: the config is valid,
+native file notifications are available, the OS watch limit is high enough,
+watch paths are on filesystems that deliver notifications, and the commands
+and shell can be found. Every problem comes with a suggested fix.
+
+The exit code is 1 if any check failed, 0 if there were only warnings.`,
+	RunE:          runDoctor,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringVarP(&cfgFile, "config", "c", "", "config file path (default: gowatch.yaml, .yml, .toml or .json)")
+	doctorCmd.Flags().StringVar(&profile, "profile", "", "activate a profile from the config (default: $GOWATCH_PROFILE)")
+	doctorCmd.Flags().BoolVar(&config.NoUserConfig, "no-user-config", false, "ignore the user config in ~/.config/gowatch")
+	doctorCmd.Flags().BoolVar(&noColor, "no-color", false, "disable colored output")
+}
+
+// notifyBackends names the native notification API fsnotify uses per OS
+var notifyBackends = map[string]string{
+	"linux":   "inotify",
+	"darwin":  "kqueue",
+	"freebsd": "kqueue",
+	"openbsd": "kqueue",
+	"netbsd":  "kqueue",
+	"windows": "ReadDirectoryChangesW",
+	"illumos": "FEN",
+	"solaris": "FEN",
+}
+
+// doctor prints check results and counts the problems found
+type doctor struct {
+	log      *logger.Logger
+	warnings int
+	failures int
+}
+
+func (d *doctor) ok(format string, args ...interface{}) {
+	d.log.Success(format, args...)
+}
+
+func (d *doctor) warn(fix, format string, args ...interface{}) {
+	d.warnings++
+	d.log.Warn(format, args...)
+	d.suggest(fix)
+}
+
+func (d *doctor) fail(fix, format string, args ...interface{}) {
+	d.failures++
+	d.log.Error(format, args...)
+	d.suggest(fix)
+}
+
+func (d *doctor) suggest(fix string) {
+	for _, line := range strings.Split(fix, "\n") {
+		if line != "" {
+			d.log.Info("  → %s", line)
+		}
+	}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	d := &doctor{log: logger.New(logger.LevelInfo, !noColor)}
+
+	d.log.Section("Configuration")
+	pipelines := d.checkConfig()
+
+	d.log.Section("File Notifications")
+	d.checkBackend()
+	if pipelines != nil {
+		d.checkWatchLimit(pipelines)
+		d.checkFilesystems(pipelines)
+	}
+
+	d.log.Section("Commands")
+	d.checkShell()
+	if pipelines != nil {
+		d.checkCommands(pipelines)
+	}
+
+	d.log.Section("Summary")
+	switch {
+	case d.failures > 0:
+		d.log.Error("%d problem(s), %d warning(s)", d.failures, d.warnings)
+		return exitCodeError{code: 1}
+	case d.warnings > 0:
+		d.log.Warn("No problems, %d warning(s)", d.warnings)
+	default:
+		d.log.Success("No problems found")
+	}
+	return nil
+}
+
+// checkConfig loads the config and returns every pipeline it defines, or
+// nil if it can't be loaded
+func (d *doctor) checkConfig() map[string]*config.Config {
+	if err := resolveConfigFile(); err != nil {
+		d.fail("create one with `gowatch init` or pass --config", "%v", err)
+		return nil
+	}
+	cfg, err := config.LoadProfile(cfgFile, activeProfile())
+	if err != nil {
+		d.fail("fix the config, then check it with `gowatch test-config`", "%s: %v", cfgFile, err)
+		return nil
+	}
+
+	pipelines := make(map[string]*config.Config)
+	if cfg.HasPipeline() {
+		pipelines[defaultPipeline] = cfg
+	}
+	for _, name := range cfg.TaskNames() {
+		tc, err := cfg.ForTask(name)
+		if err != nil {
+			d.fail("fix the task in the config", "task %q: %v", name, err)
+			continue
+		}
+		pipelines[name] = tc
+	}
+
+	if cfg.Profile != "" {
+		d.ok("%s is valid (profile %s)", cfgFile, cfg.Profile)
+	} else {
+		d.ok("%s is valid", cfgFile)
+	}
+	return pipelines
+}
+
+// checkBackend makes sure native notifications can be set up
+func (d *doctor) checkBackend() {
+	name := notifyBackends[runtime.GOOS]
+	if name == "" {
+		name = runtime.GOOS
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = fsw.Add(os.TempDir())
+		fsw.Close()
+	}
+	if err != nil {
+		d.fail("set `backend: poll` (or pass --poll) to scan for changes instead",
+			"Native notifications (%s) unavailable: %v", name, err)
+		return
+	}
+	d.ok("Native notifications (%s) available", name)
+}
+
+// checkWatchLimit compares the watches the config needs with the OS limit
+func (d *doctor) checkWatchLimit(pipelines map[string]*config.Config) {
+	limit, ok := watcher.WatchLimit()
+	if !ok {
+		return
+	}
+
+	needed := 0
+	for _, name := range sortedNames(pipelines) {
+		w, err := watcher.New(pipelines[name], watcher.Options{})
+		if err != nil {
+			d.fail("fix the watch paths in the config", "%s: %v", name, err)
+			continue
+		}
+		n, err := w.CountWatches()
+		w.Close()
+		if err != nil {
+			d.fail("fix the watch paths in the config", "%s: %v", name, err)
+			continue
+		}
+		needed += n
+	}
+
+	fix := fmt.Sprintf("raise it: sudo sysctl fs.inotify.max_user_watches=%d\n"+
+		"keep it across reboots by adding the setting to /etc/sysctl.conf\n"+
+		"or ignore large directories, limit max_depth, or set poll_fallback: true",
+		watcher.SuggestedLimit(needed))
+	switch {
+	case needed > limit:
+		d.fail(fix, "%d inotify watches needed, fs.inotify.max_user_watches is %d", needed, limit)
+	case needed > limit*3/4:
+		// Editors and indexers draw on the same per-user limit
+		d.warn(fix, "%d inotify watches needed, close to fs.inotify.max_user_watches (%d)", needed, limit)
+	default:
+		d.ok("%d inotify watches needed, fs.inotify.max_user_watches is %d", needed, limit)
+	}
+}
+
+// checkFilesystems warns about watch paths on filesystems that may not
+// deliver native notifications
+func (d *doctor) checkFilesystems(pipelines map[string]*config.Config) {
+	seen := make(map[string]bool)
+	for _, name := range sortedNames(pipelines) {
+		cfg := pipelines[name]
+		for _, wp := range cfg.Watch {
+			if wp.Remote != "" {
+				if seen[wp.Remote] {
+					continue
+				}
+				seen[wp.Remote] = true
+				if _, err := exec.LookPath("ssh"); err != nil {
+					d.fail("install an OpenSSH client; remote watch paths are scanned over ssh",
+						"%s needs ssh, which was not found", wp.Remote)
+				} else {
+					d.ok("%s is scanned over SSH (needs GNU find on the host)", wp.Remote)
+				}
+				continue
+			}
+
+			absPath, err := filepath.Abs(wp.Path)
+			if err != nil || seen[absPath] {
+				continue
+			}
+			seen[absPath] = true
+
+			switch cfg.BackendFor(wp) {
+			case config.BackendPoll:
+				d.ok("%s is polled", absPath)
+				continue
+			case config.BackendWatchman:
+				if _, err := exec.LookPath("watchman"); err != nil {
+					d.fail("install Watchman (https://facebook.github.io/watchman/) or choose another backend",
+						"%s needs watchman, which was not found", absPath)
+				} else {
+					d.ok("%s is watched through Watchman", absPath)
+				}
+				continue
+			case config.BackendFanotify:
+				if err := watcher.CheckFanotify(absPath); err != nil {
+					d.warn("run gowatch as root, or choose another backend to silence this",
+						"%s falls back to fsnotify: %v", absPath, err)
+				} else {
+					d.ok("%s is watched through a fanotify filesystem mark", absPath)
+				}
+				continue
+			}
+
+			m, err := mountOf(absPath)
+			if err != nil {
+				d.log.Info("%s: filesystem unknown (%v)", absPath, err)
+				continue
+			}
+			switch {
+			case m.remote:
+				d.warn("set `backend: poll` on this watch path if changes go unnoticed",
+					"%s is on %s, which may not deliver change notifications", absPath, m.fsType)
+			case m.bind:
+				d.ok("%s is on %s (bind mount; changes made on the host may need `backend: poll`)", absPath, m.fsType)
+			default:
+				d.ok("%s is on %s", absPath, m.fsType)
+			}
+		}
+	}
+}
+
+// checkShell looks for the shell used by commands with shell syntax
+func (d *doctor) checkShell() {
+	shell := "sh"
+	if runtime.GOOS == "windows" {
+		shell = "cmd.exe"
+	}
+	path, err := exec.LookPath(shell)
+	if err != nil {
+		d.warn("install it or make sure it is on PATH; commands with pipes or redirects need it",
+			"Shell %s not found", shell)
+		return
+	}
+	d.ok("Shell: %s", path)
+}
+
+// checkCommands makes sure every command's program can be found
+func (d *doctor) checkCommands(pipelines map[string]*config.Config) {
+	seen := make(map[string]bool)
+	for _, name := range sortedNames(pipelines) {
+		cfg := pipelines[name]
+		commands := slices.Concat(cfg.Setup, cfg.OnChange.Commands, cfg.OnSuccess, cfg.OnFailure, cfg.OnExit)
+		for _, c := range commands {
+			if len(c.Cmd) == 0 && c.RestartService == "" {
+				continue
+			}
+			// Programs of container commands are looked up in the container,
+			// and services are restarted through docker compose
+			program := "docker"
+			if c.Container == "" && c.RestartService == "" {
+				program = c.Cmd[0]
+			}
+			// Placeholders are only known at run time
+			if seen[program] || strings.Contains(program, "{") {
+				continue
+			}
+			seen[program] = true
+
+			lookup := program
+			if c.Cwd != "" && strings.ContainsAny(program, `/\`) && !filepath.IsAbs(program) {
+				lookup = filepath.Join(c.Cwd, program)
+			}
+			path, err := exec.LookPath(lookup)
+			if err != nil {
+				d.fail("install it, add it to PATH, or fix the cmd in the config",
+					"%s: command %q not found", name, program)
+				continue
+			}
+			d.ok("%s → %s", program, path)
+		}
+	}
+}
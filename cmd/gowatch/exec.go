@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+
+	"github.com/spf13/cobra"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec [task[,task...]]",
+	Short: "Run the configured commands once without watching",
+	Long: `Load the configuration, run its commands once and exit.
+
+Tasks are selected as with "gowatch run". The exit code is that of the first
+failed command (1 if it has none), or 0 when every command succeeded, which
+makes exec suitable for CI and for checking a config before watching.
+Commands with mode: restart are skipped.
+
+Examples:
+  # Run the pipeline once
+  gowatch exec
+
+  # Run the test task only, one command at a time
+  gowatch exec test --sequential`,
+	RunE:          execOnce,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+// exitCodeError is returned to make the process exit with a specific code
+type exitCodeError struct {
+	code int
+	msg  string
+}
+
+func (e exitCodeError) Error() string {
+	return e.msg
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+
+	execCmd.Flags().StringVarP(&cfgFile, "config", "c", "", "config file path (default: gowatch.yaml, .yml, .toml or .json)")
+	execCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would run without executing")
+	addLogFlags(execCmd)
+	execCmd.Flags().StringVar(&resultsTo, "results-json", "", "write each command result as a JSON line to this file or file descriptor")
+	execCmd.Flags().StringVar(&groupOut, "group-output", "", "fold the output of each command in CI logs: github or gitlab")
+	execCmd.Flags().BoolVar(&sequential, "sequential", false, "run commands sequentially")
+	execCmd.Flags().BoolVar(&noColor, "no-color", false, "disable colored output")
+	execCmd.Flags().StringVar(&profile, "profile", "", "activate a profile from the config (default: $GOWATCH_PROFILE)")
+	execCmd.Flags().BoolVar(&config.NoUserConfig, "no-user-config", false, "ignore the user config in ~/.config/gowatch")
+}
+
+func execOnce(cmd *cobra.Command, args []string) error {
+	level, _, err := resolveLogLevel()
+	if err != nil {
+		return err
+	}
+	log := logger.New(level, !noColor)
+	if err := log.SetGroups(groupOut); err != nil {
+		return err
+	}
+
+	if err := resolveConfigFile(); err != nil {
+		return err
+	}
+	cfg, err := config.LoadProfile(cfgFile, activeProfile())
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Profile != "" {
+		log.Info("Profile: %s", cfg.Profile)
+	}
+
+	selected, err := selectPipelines(cfg, parseTaskArgs(args))
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var onResult func(task string, t runner.Trigger, r runner.RunResult)
+	if resultsTo != "" {
+		results, err := openResults(resultsTo)
+		if err != nil {
+			return err
+		}
+		defer results.Close()
+		onResult = func(task string, t runner.Trigger, r runner.RunResult) {
+			if err := results.write(task, t, r); err != nil {
+				log.Warn("Failed to write result: %v", err)
+			}
+		}
+	}
+
+	names := sortedNames(selected)
+	reports := make([]runner.Report, 0, len(names))
+	for _, name := range names {
+		if len(names) > 1 {
+			log.Section("Task: " + name)
+		}
+		reports = append(reports, execPipeline(ctx, log, name, selected[name], onResult))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// Aggregate summary
+	log.Section("Summary")
+	var failed int
+	code := 0
+	for _, report := range reports {
+		passed := 0
+		for _, result := range report.Results {
+			if result.ExitCode == 0 {
+				passed++
+				continue
+			}
+			failed++
+			if code == 0 {
+				code = result.ExitCode
+				if code < 0 {
+					code = 1
+				}
+			}
+		}
+
+		duration := report.Duration.Round(time.Millisecond)
+		if report.Success() {
+			log.Success("%s: %d/%d command(s) passed (%s)", report.Task, passed, len(report.Results), duration)
+		} else {
+			log.Error("%s: %d/%d command(s) passed (%s)", report.Task, passed, len(report.Results), duration)
+		}
+	}
+
+	if failed > 0 {
+		return exitCodeError{code: code, msg: fmt.Sprintf("%d command(s) failed", failed)}
+	}
+	return nil
+}
+
+// execPipeline runs one pipeline's commands once, skipping long-running ones.
+// onResult, if set, is called as each command finishes.
+func execPipeline(ctx context.Context, log *logger.Logger, name string, cfg *config.Config, onResult func(string, runner.Trigger, runner.RunResult)) runner.Report {
+	once := *cfg
+	once.OnChange.Commands = nil
+	for _, c := range cfg.OnChange.Commands {
+		if c.IsRestart() {
+			log.Warn("Skipping long-running command: %v", c.Cmd)
+			continue
+		}
+		once.OnChange.Commands = append(once.OnChange.Commands, c)
+	}
+
+	opts := runner.Options{Logger: log, Sequential: sequential, DryRun: dryRun}
+	if onResult != nil {
+		opts.OnResult = func(t runner.Trigger, r runner.RunResult) { onResult(name, t, r) }
+	}
+	r := runner.New(&once, opts)
+	defer r.Close()
+
+	trigger := runner.Trigger{Event: "EXEC", RunID: runner.NextRunID()}
+	start := time.Now()
+	results := r.RunTrigger(ctx, trigger)
+	report := runner.Report{
+		Task:     name,
+		Trigger:  trigger,
+		Start:    start,
+		Duration: time.Since(start),
+		Results:  results,
+	}
+	if !dryRun {
+		names := make([]string, len(once.OnChange.Commands))
+		for i, c := range once.OnChange.Commands {
+			names[i] = commandLabel(c)
+		}
+		writeJUnit(log, &once, report, names)
+	}
+	return report
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestExecOnce_ExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("GOWATCH_PROFILE", "")
+	t.Setenv("GOWATCH_LOG_LEVEL", "")
+	origFile := cfgFile
+	t.Cleanup(func() { cfgFile, sequential = origFile, false })
+	// Results are in command order when they run one at a time
+	sequential = true
+
+	cfgFile = filepath.Join(dir, "gowatch.yaml")
+	task := func(name string, scripts ...string) string {
+		s := "  " + name + ":\n    on_change:\n      commands:\n"
+		for _, script := range scripts {
+			s += "        - cmd: [\"sh\", \"-c\", \"" + script + "\"]\n"
+		}
+		return s
+	}
+	content := "watch:\n  - path: " + filepath.ToSlash(dir) + "\ntasks:\n" +
+		task("a", "exit 0", "exit 3") + task("b", "exit 5") + task("c", "exit 0") + task("d", "kill -9 $$")
+	if err := os.WriteFile(cfgFile, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tasks    []string
+		wantCode int
+		wantErr  string
+	}{
+		{tasks: []string{"c"}},
+		// The code is the first failed command's, in task order
+		{tasks: []string{"a,b"}, wantCode: 3, wantErr: "2 command(s) failed"},
+		{tasks: []string{"b", "a"}, wantCode: 3, wantErr: "2 command(s) failed"},
+		{tasks: []string{"b,c"}, wantCode: 5, wantErr: "1 command(s) failed"},
+		// A command killed by a signal has no exit code of its own
+		{tasks: []string{"d"}, wantCode: 1, wantErr: "1 command(s) failed"},
+	}
+	for _, tt := range tests {
+		err := execOnce(execCmd, tt.tasks)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("exec %v = %v, want nil", tt.tasks, err)
+			}
+			continue
+		}
+		var exitErr exitCodeError
+		if !errors.As(err, &exitErr) || exitErr.code != tt.wantCode || err.Error() != tt.wantErr {
+			t.Errorf("exec %v = %#v, want exit code %d: %s", tt.tasks, err, tt.wantCode, tt.wantErr)
+		}
+	}
+}
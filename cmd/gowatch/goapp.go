@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gowatch/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	goBuild  string
+	goExts   []string
+	goProxy  string
+	goTarget string
+	// goApp is the config gowatch go runs instead of a config file
+	goApp *config.Config
+)
+
+var goCmd = &cobra.Command{
+	Use:   "go [package] [-- args...]",
+	Short: "Build and run a Go program, rebuilding and restarting it on change",
+	Long: `Build a Go main package to a temporary binary and run it, then rebuild it
+whenever a Go file, go.mod or go.sum changes and restart it once the build
+succeeds. Build errors are shown as they come, and a failed build leaves the
+previous binary running. No config file is needed.
+
+The package defaults to the current directory. Arguments after -- are
+passed to the program.
+
+Examples:
+  # Run the server, restarting it on change
+  gowatch go ./cmd/server
+
+  # Build with the race detector and pass flags to the program
+  gowatch go ./cmd/server --build-flags "-race" -- -addr :8080
+
+  # Also rebuild when the embedded templates change
+  gowatch go --ext html,tmpl
+
+  # Serve the app on :3000, holding requests while it restarts
+  gowatch go ./cmd/server --proxy :3000 --proxy-target :8080`,
+	RunE: runGoApp,
+}
+
+func init() {
+	rootCmd.AddCommand(goCmd)
+
+	goCmd.Flags().StringVarP(&watchPath, "path", "p", "", "directory to watch (default: the current directory)")
+	goCmd.Flags().StringVar(&goBuild, "build-flags", "", "flags passed to go build, such as \"-race -tags dev\"")
+	goCmd.Flags().StringSliceVar(&goExts, "ext", nil, "other file extensions that trigger a rebuild, such as html,tmpl")
+	goCmd.Flags().StringVarP(&debounce, "debounce", "d", "250ms", "debounce duration")
+	addLogFlags(goCmd)
+	goCmd.Flags().BoolVar(&noColor, "no-color", false, "disable colored output")
+	goCmd.Flags().BoolVar(&noKeys, "no-keys", false, "disable interactive keyboard controls")
+	goCmd.Flags().BoolVar(&clearRuns, "clear", false, "clear the terminal before each run")
+	goCmd.Flags().BoolVar(&notifyOn, "notify", false, "send a desktop notification when a run finishes")
+	goCmd.Flags().StringVar(&bellOn, "bell", "", "ring the terminal bell when a run fails, or also when one recovers with --bell=change")
+	goCmd.Flags().Lookup("bell").NoOptDefVal = config.BellFailure
+	goCmd.Flags().StringVar(&liveReload, "livereload", "", "serve LiveReload on this address (e.g. :35729)")
+	goCmd.Flags().StringVar(&goProxy, "proxy", "", "serve a proxy to the program on this address (e.g. :3000), holding requests while it restarts")
+	goCmd.Flags().StringVar(&goTarget, "proxy-target", "", "address the program listens on, for --proxy (e.g. :8080)")
+	goCmd.MarkFlagsRequiredTogether("proxy", "proxy-target")
+}
+
+// runGoApp watches a Go program with the config of config.GoApp, building
+// it into a temporary directory removed on exit
+func runGoApp(cmd *cobra.Command, args []string) error {
+	app := config.GoApp{Dir: watchPath, BuildFlags: strings.Fields(goBuild), Extensions: goExts}
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		args, app.Args = args[:dash], args[dash:]
+	}
+	switch len(args) {
+	case 0:
+	case 1:
+		app.Package = args[0]
+	default:
+		return fmt.Errorf("expected one package, got %d (arguments for the program go after --)", len(args))
+	}
+
+	dir, err := os.MkdirTemp("", "gowatch-go-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	app.Binary = filepath.Join(dir, app.BinaryName())
+
+	goApp = app.Config()
+	goApp.Debounce = debounce
+	if liveReload != "" {
+		goApp.OnChange.Commands[1].Reload = true
+	}
+	goApp.Proxy = config.Proxy{Listen: goProxy, Target: goTarget}
+	return runWatch(cmd, nil)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gowatch/internal/history"
+	"gowatch/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultStatsWindow is the period history --stats covers without --since
+const defaultStatsWindow = 24 * time.Hour
+
+var (
+	historyFile    string
+	historySince   string
+	historyFailed  bool
+	historyCommand string
+	historyLimit   int
+	historyStats   bool
+	historyJSON    bool
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history [task[,task...]]",
+	Short: "List past runs and their results",
+	Long: `List the runs recorded by "gowatch run", "gowatch tui" and "gowatch start",
+newest first, with the change that triggered each and the exit code and
+duration of its commands.
+
+Runs are recorded in the file named by history in the config (default:
+.gowatch/history.jsonl); history: off disables recording.
+
+With --stats, show for each command how often it ran and failed, its
+average and longest duration and its failure streaks, over the last day
+unless --since says otherwise.
+
+Examples:
+  # The last 20 runs
+  gowatch history
+
+  # Failed runs of the test task in the last hour
+  gowatch history test --failed --since 1h
+
+  # How the tests did since yesterday
+  gowatch history --stats --command "go test"`,
+	RunE:          showHistory,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+
+	historyCmd.Flags().StringVarP(&cfgFile, "config", "c", "", "config file path (default: gowatch.yaml, .yml, .toml or .json)")
+	historyCmd.Flags().StringVar(&historyFile, "file", "", "history file to read (default: history from the config)")
+	historyCmd.Flags().StringVar(&historySince, "since", "", "only runs after this duration ago (e.g. 1h) or time (e.g. 2024-05-01)")
+	historyCmd.Flags().BoolVar(&historyFailed, "failed", false, "only failed runs")
+	historyCmd.Flags().StringVar(&historyCommand, "command", "", "only commands whose name or command line contains this text")
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "number of runs to list, 0 for all")
+	historyCmd.Flags().BoolVar(&historyStats, "stats", false, "show statistics per command instead of runs")
+	historyCmd.Flags().BoolVar(&historyJSON, "json", false, "print runs or statistics as JSON")
+}
+
+func showHistory(cmd *cobra.Command, args []string) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	runs, err := history.Load(path)
+	if err != nil {
+		return err
+	}
+
+	filter := history.Filter{
+		Tasks:   parseTaskArgs(args),
+		Failed:  historyFailed,
+		Command: historyCommand,
+	}
+	switch {
+	case historySince != "":
+		filter.Since, err = parseSince(historySince, time.Now())
+		if err != nil {
+			return err
+		}
+	case historyStats:
+		filter.Since = time.Now().Add(-defaultStatsWindow)
+	}
+	runs = history.Select(runs, filter)
+
+	if historyStats {
+		stats := history.Summarize(runs)
+		if historyJSON {
+			return printJSON(stats)
+		}
+		if len(stats) == 0 {
+			fmt.Println("No runs recorded in this period")
+			return nil
+		}
+		return printHistoryStats(stats, len(runs))
+	}
+
+	if historyLimit > 0 && len(runs) > historyLimit {
+		runs = runs[len(runs)-historyLimit:]
+	}
+	slices.Reverse(runs)
+	if historyJSON {
+		if runs == nil {
+			runs = []history.Run{}
+		}
+		return printJSON(runs)
+	}
+	if len(runs) == 0 {
+		fmt.Println("No runs recorded")
+		return nil
+	}
+	return printHistory(runs)
+}
+
+// historyPath returns the history file named by --file or the config. Without
+// a config, the default file is read.
+func historyPath() (string, error) {
+	if historyFile != "" {
+		return historyFile, nil
+	}
+	if err := resolveConfigFile(); err != nil {
+		return config.DefaultHistoryFile, nil
+	}
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return "", err
+	}
+	path := cfg.HistoryFile()
+	if path == "" {
+		return "", fmt.Errorf("run history is disabled in %s", cfgFile)
+	}
+	return path, nil
+}
+
+// parseSince accepts a duration before now or an absolute time
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: want a duration such as 2h or a time such as 2024-05-01", s)
+}
+
+// printHistory lists runs with the result of each command
+func printHistory(runs []history.Run) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STARTED\tTASK\tRESULT\tDURATION\tCHANGE\tCOMMANDS")
+	for _, run := range runs {
+		result := "ok"
+		if !run.Success {
+			result = "FAIL"
+		}
+		change := run.Event
+		if run.Path != "" {
+			change += " " + run.Path
+		}
+		if len(run.Files) > 1 {
+			change += fmt.Sprintf(" (+%d)", len(run.Files)-1)
+		}
+
+		commands := make([]string, 0, len(run.Commands))
+		for _, c := range run.Commands {
+			text := fmt.Sprintf("%s %s", c.Name, c.Duration())
+			switch {
+			case c.TimedOut:
+				text = fmt.Sprintf("%s timed out after %s", c.Name, c.Duration())
+			case c.ExitCode != 0:
+				text = fmt.Sprintf("%s exit %d", c.Name, c.ExitCode)
+			}
+			commands = append(commands, text)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			run.Start.Local().Format("2006-01-02 15:04:05"), run.Task, result,
+			run.Duration(), change, strings.Join(commands, ", "))
+	}
+	return tw.Flush()
+}
+
+// printHistoryStats shows the statistics of each command
+func printHistoryStats(stats []history.Stats, runs int) error {
+	period := "over the last day"
+	if historySince != "" {
+		period = "since " + historySince
+	}
+	fmt.Printf("%d run(s) %s\n\n", runs, period)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TASK\tCOMMAND\tRUNS\tFAILED\tPASS\tAVERAGE\tMAX\tSTREAK\tLONGEST STREAK")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.0f%%\t%s\t%s\t%d\t%d\n",
+			s.Task, s.Name, s.Runs, s.Failures, s.PassRate(),
+			s.Average(), s.Max(), s.Streak, s.LongestStreak)
+	}
+	return tw.Flush()
+}
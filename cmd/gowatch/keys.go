@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+
+	"gowatch/pkg/logger"
+
+	"github.com/mattn/go-isatty"
+)
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// clearTerminal wipes the screen, with cls on Windows where older consoles
+// don't understand ANSI sequences. Output that isn't a terminal, such as a
+// log file, is left alone.
+func clearTerminal() {
+	if fd := os.Stdout.Fd(); !isatty.IsTerminal(fd) && !isatty.IsCygwinTerminal(fd) {
+		return
+	}
+	if runtime.GOOS == "windows" {
+		cls := exec.Command("cmd", "/c", "cls")
+		cls.Stdout = os.Stdout
+		if cls.Run() == nil {
+			return
+		}
+	}
+	fmt.Print(clearScreen)
+}
+
+// keyboard reads the key commands typed while gowatch runs, and lends the
+// terminal to interactive commands: while one runs the terminal is back in
+// line mode and what is typed is forwarded to the command
+type keyboard struct {
+	fd int
+
+	mu sync.Mutex
+	// restore, set while keys are read, returns the terminal to line mode
+	restore func()
+	// lent is the pipe the current interactive command reads
+	lent *os.File
+}
+
+// lend hands the terminal to an interactive command. The command reads
+// stdin directly when keys aren't being read.
+func (k *keyboard) lend() (*os.File, func()) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.restore == nil {
+		return os.Stdin, func() {}
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, func() {}
+	}
+	k.restore()
+	k.lent = w
+
+	return r, func() {
+		r.Close()
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		if k.lent == w {
+			w.Close()
+			k.lent = nil
+		}
+		if k.restore != nil {
+			if restore, err := enableKeypresses(k.fd); err == nil {
+				k.restore = restore
+			}
+		}
+	}
+}
+
+// forward passes typed input to the interactive command holding the
+// terminal, if any. End of input closes the command's stdin.
+func (k *keyboard) forward(input []byte, eof bool) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.lent == nil {
+		return false
+	}
+	if len(input) > 0 {
+		k.lent.Write(input)
+	}
+	if eof {
+		k.lent.Close()
+		k.lent = nil
+	}
+	return true
+}
+
+// stop returns the terminal to line mode for good
+func (k *keyboard) stop() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.restore != nil {
+		k.restore()
+		k.restore = nil
+	}
+}
+
+// watchKeys handles single-key commands typed while gowatch runs. It returns
+// false without doing anything when stdin is not an interactive terminal.
+func watchKeys(ctx context.Context, sess *session, log *logger.Logger, quit context.CancelFunc) (restore func(), ok bool) {
+	k := sess.keys
+	k.fd = int(os.Stdin.Fd())
+	restore, err := enableKeypresses(k.fd)
+	if err != nil {
+		log.Debug("Keyboard controls disabled: %v", err)
+		return nil, false
+	}
+	k.mu.Lock()
+	k.restore = restore
+	k.mu.Unlock()
+
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if k.forward(buf[:n], err != nil) {
+				continue
+			}
+			if err != nil {
+				return
+			}
+
+			for _, key := range buf[:n] {
+				switch key {
+				case 'r', 'R':
+					sess.do(ctx, sess.rerun)
+				case 'p', 'P':
+					sess.do(ctx, func() { sess.setPaused(!sess.paused) })
+				case 'c', 'C':
+					clearTerminal()
+				case 'q', 'Q':
+					log.Info("")
+					log.Warn("Quit requested")
+					quit()
+					return
+				}
+			}
+		}
+	}()
+
+	return k.stop, true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+func TestClearTerminal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a Unix pseudo-terminal")
+	}
+	tests := []struct {
+		name string
+		open func(t *testing.T) (stdout *os.File, read func() string)
+		want string
+	}{
+		{name: "terminal", want: clearScreen, open: func(t *testing.T) (*os.File, func() string) {
+			ptmx, tty, err := pty.Open()
+			if err != nil {
+				t.Skipf("no pseudo-terminal: %v", err)
+			}
+			t.Cleanup(func() { ptmx.Close(); tty.Close() })
+			return tty, func() string {
+				buf := make([]byte, 64)
+				ptmx.SetReadDeadline(time.Now().Add(time.Second))
+				n, _ := ptmx.Read(buf)
+				return string(buf[:n])
+			}
+		}},
+		// Such as output redirected to a log file
+		{name: "file", open: func(t *testing.T) (*os.File, func() string) {
+			f, err := os.Create(filepath.Join(t.TempDir(), "out.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { f.Close() })
+			return f, func() string {
+				data, err := os.ReadFile(f.Name())
+				if err != nil {
+					t.Fatal(err)
+				}
+				return string(data)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, read := tt.open(t)
+			orig := os.Stdout
+			t.Cleanup(func() { os.Stdout = orig })
+			os.Stdout = stdout
+
+			clearTerminal()
+			if got := read(); got != tt.want {
+				t.Errorf("clearTerminal() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,16 +2,30 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"runtime"
+	"slices"
+	"sort"
+	"strings"
 	"syscall"
+	"time"
 
-	"gowatch/internal/config"
-	"gowatch/internal/logger"
-	"gowatch/internal/runner"
-	"gowatch/internal/watcher"
+	"gowatch/internal/api"
+	"gowatch/internal/history"
+	"gowatch/internal/junit"
+	"gowatch/internal/livereload"
+	"gowatch/internal/proxy"
+	"gowatch/internal/record"
+	"gowatch/internal/serve"
+	"gowatch/internal/stream"
+	"gowatch/internal/tui"
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+	"gowatch/pkg/watcher"
 
 	"github.com/spf13/cobra"
 )
@@ -23,14 +37,44 @@ var (
 	debounce   string
 	dryRun     bool
 	verbose    bool
+	quiet      bool
+	logLevel   string
 	sequential bool
 	noColor    bool
 	timeout    string
 	maxConcur  int
+	poll       bool
+	pollEvery  string
+	pollFall   bool
+	noReload   bool
+	apiAddr    string
+	notifyOn   bool
+	bellOn     string
+	noKeys     bool
+	liveReload string
+	serveDir   string
+	wsAddr     string
+	runOnStart bool
+	profile    string
+	clearRuns  bool
+	resultsTo  string
+	problemsOn bool
+	groupOut   string
+	strategy   string
+	maxFails   int
+	runFor     time.Duration
 )
 
 func main() {
+	registerCompletions()
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr exitCodeError
+		if errors.As(err, &exitErr) {
+			if exitErr.msg != "" {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(exitErr.code)
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -46,10 +90,14 @@ Perfect for development workflows, testing, and automation.`,
 }
 
 var runCmd = &cobra.Command{
-	Use:   "run",
+	Use:   "run [task[,task...]]",
 	Short: "Start watching files and running commands",
 	Long: `Start the file watcher and execute commands when changes are detected.
 
+Without arguments the top-level commands of the config are run, or every
+task if the config only defines tasks. Name one or more tasks to run only
+those.
+
 Examples:
   # Watch current directory and run tests
   gowatch run --path . --cmd "go test ./..."
@@ -57,6 +105,12 @@ Examples:
   # Use a config file
   gowatch run --config gowatch.yaml
 
+  # Run only the build and test tasks
+  gowatch run build,test
+
+  # Run the configs of two services side by side
+  gowatch run -c api/gowatch.yaml -c web/gowatch.yaml
+
   # Dry run to see what would execute
   gowatch run --config gowatch.yaml --dry-run`,
 	RunE: runWatch,
@@ -82,55 +136,184 @@ func init() {
 	rootCmd.AddCommand(testConfigCmd)
 
 	// Run command flags
-	runCmd.Flags().StringVarP(&cfgFile, "config", "c", "", "config file path (default: gowatch.yaml)")
+	runCmd.Flags().StringArrayVarP(&cfgFiles, "config", "c", nil, "config file path, repeat to run several configs side by side (default: gowatch.yaml, .yml, .toml or .json)")
 	runCmd.Flags().StringVarP(&watchPath, "path", "p", "", "path to watch")
 	runCmd.Flags().StringVar(&command, "cmd", "", "command to run on change")
 	runCmd.Flags().StringVarP(&debounce, "debounce", "d", "250ms", "debounce duration")
+	runCmd.Flags().StringVar(&strategy, "debounce-strategy", "", "when to run within the debounce interval: trailing, leading or throttle")
 	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would run without executing")
-	runCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "verbose logging")
+	addLogFlags(runCmd)
 	runCmd.Flags().BoolVar(&sequential, "sequential", false, "run commands sequentially")
 	runCmd.Flags().BoolVar(&noColor, "no-color", false, "disable colored output")
 	runCmd.Flags().StringVar(&timeout, "timeout", "60s", "command timeout")
 	runCmd.Flags().IntVar(&maxConcur, "max-concurrency", 2, "maximum concurrent commands")
+	runCmd.Flags().BoolVar(&poll, "poll", false, "use the polling backend for all watch paths (NFS, Docker volumes)")
+	runCmd.Flags().StringVar(&pollEvery, "poll-interval", "", "polling interval (default: 1s)")
+	runCmd.Flags().BoolVar(&pollFall, "poll-fallback", false, "poll directories that can't be watched once the OS watch limit is reached")
+	runCmd.Flags().BoolVar(&noReload, "no-reload", false, "don't reload the config file when it changes")
+	runCmd.Flags().BoolVar(&notifyOn, "notify", false, "send a desktop notification when a run finishes")
+	runCmd.Flags().StringVar(&bellOn, "bell", "", "ring the terminal bell when a run fails, or also when one recovers with --bell=change")
+	runCmd.Flags().Lookup("bell").NoOptDefVal = config.BellFailure
+	runCmd.Flags().BoolVar(&runOnStart, "run-on-start", false, "run the commands once when watching starts")
+	runCmd.Flags().BoolVar(&clearRuns, "clear", false, "clear the terminal before each run")
+	runCmd.Flags().BoolVar(&noKeys, "no-keys", false, "disable interactive keyboard controls")
+	runCmd.Flags().DurationVar(&runFor, "for", 0, "stop after watching this long (e.g. 30m), failing if any run failed")
+	runCmd.Flags().IntVar(&maxFails, "max-failures", 0, "stop with an error once a pipeline has failed this many times in a row (0: never)")
+	runCmd.Flags().StringVar(&apiAddr, "api", "", "serve the HTTP control API on this address (e.g. :7070)")
+	runCmd.Flags().StringVar(&wsAddr, "ws", "", "stream events and results over WebSocket on this address (e.g. :7071)")
+	runCmd.Flags().StringVar(&liveReload, "livereload", "", "serve LiveReload on this address (e.g. :35729)")
+	runCmd.Flags().StringVar(&serveDir, "serve", "", "serve the files of this directory on :8000, refreshing browsers after every successful run")
+	runCmd.Flags().StringVar(&resultsTo, "results-json", "", "write each command result as a JSON line to this file or file descriptor")
+	runCmd.Flags().StringVar(&groupOut, "group-output", "", "fold the output of each command in CI logs: github or gitlab")
+	runCmd.Flags().BoolVar(&problemsOn, "problem-matcher", false, "also print the errors in command output as file:line:col: message between run markers, for editor problem matchers")
 
 	// Test config flags
-	testConfigCmd.Flags().StringVarP(&cfgFile, "config", "c", "gowatch.yaml", "config file path")
+	testConfigCmd.Flags().StringVarP(&cfgFile, "config", "c", "", "config file path (default: gowatch.yaml, .yml, .toml or .json)")
+
+	for _, c := range []*cobra.Command{runCmd, testConfigCmd} {
+		c.Flags().StringVar(&profile, "profile", "", "activate a profile from the config (default: $GOWATCH_PROFILE)")
+		c.Flags().BoolVar(&config.NoUserConfig, "no-user-config", false, "ignore the user config in ~/.config/gowatch")
+		c.Flags().BoolVar(&config.Strict, "strict", false, "reject config keys gowatch doesn't know instead of ignoring them")
+	}
+}
+
+// resolveConfigFile finds the config file when --config was not given
+func resolveConfigFile() error {
+	if cfgFile != "" {
+		return nil
+	}
+	path, err := config.Find()
+	if err != nil {
+		return err
+	}
+	cfgFile = path
+	return nil
+}
+
+// addLogFlags registers the flags that select the log level
+func addLogFlags(c *cobra.Command) {
+	c.Flags().BoolVarP(&verbose, "verbose", "v", false, "verbose logging (same as --log-level debug)")
+	c.Flags().BoolVarP(&quiet, "quiet", "q", false, "only show command output and failures (same as --log-level error)")
+	c.Flags().StringVar(&logLevel, "log-level", "", "log level: debug, info, warn or error (default: $GOWATCH_LOG_LEVEL or info)")
+	c.MarkFlagsMutuallyExclusive("verbose", "quiet", "log-level")
+	c.RegisterFlagCompletionFunc("log-level", cobra.FixedCompletions(
+		[]string{"debug", "info", "warn", "error"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// resolveLogLevel returns the level selected with --log-level, --verbose,
+// --quiet or GOWATCH_LOG_LEVEL. The flag is reported as set if the level
+// came from the command line.
+func resolveLogLevel() (level logger.Level, flag bool, err error) {
+	switch {
+	case logLevel != "":
+		level, err = logger.ParseLevel(logLevel)
+		return level, true, err
+	case verbose:
+		return logger.LevelDebug, true, nil
+	case quiet:
+		return logger.LevelError, true, nil
+	}
+	if env := os.Getenv("GOWATCH_LOG_LEVEL"); env != "" {
+		level, err = logger.ParseLevel(env)
+		if err != nil {
+			return level, false, fmt.Errorf("GOWATCH_LOG_LEVEL: %w", err)
+		}
+		return level, false, nil
+	}
+	return logger.LevelInfo, false, nil
+}
+
+// activeProfile returns the profile selected with --profile or
+// GOWATCH_PROFILE
+func activeProfile() string {
+	if profile != "" {
+		return profile
+	}
+	return os.Getenv("GOWATCH_PROFILE")
 }
 
 func runWatch(cmd *cobra.Command, args []string) error {
+	if len(cfgFiles) > 1 || workspaceFile != "" {
+		return runWorkspace(cmd, args)
+	}
+	if len(cfgFiles) == 1 {
+		cfgFile = cfgFiles[0]
+	}
+
 	// Setup logger
-	logLevel := logger.LevelInfo
-	if verbose {
-		logLevel = logger.LevelDebug
+	level, _, err := resolveLogLevel()
+	if err != nil {
+		return err
+	}
+	if runFor < 0 {
+		return fmt.Errorf("--for must not be negative")
+	}
+	if statsEvery < 0 {
+		return fmt.Errorf("--stats must not be negative")
+	}
+	log := logger.New(level, !noColor)
+	if err := log.SetGroups(groupOut); err != nil {
+		return err
+	}
+	var dash *tui.Dashboard
+	if useTUI {
+		// Logs go to the dashboard's log pane and command output to the
+		// panes of the commands
+		dash = tui.New()
+		log = logger.NewWriter(dash, level, !noColor)
+		log.SetCommandOutput(false)
 	}
-	log := logger.New(logLevel, !noColor)
 
 	// Display banner
 	log.Banner("GoWatch - File Watcher & Auto-Runner", "1.0.0")
 
 	// Load or build config
 	var cfg *config.Config
-	var err error
 
-	if cfgFile != "" || (watchPath == "" && command == "") {
+	if goApp != nil {
+		log.Section("Configuration")
+		log.Info("Building and running a Go program")
+		cfg = goApp
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+	} else if cfgFile != "" || (watchPath == "" && command == "" && serveDir == "") {
 		// Load from file
-		if cfgFile == "" {
-			cfgFile = "gowatch.yaml"
+		if err := resolveConfigFile(); err != nil {
+			return err
 		}
 		log.Section("Configuration")
 		log.Info("Loading config from: %s", cfgFile)
-		cfg, err = config.Load(cfgFile)
+		cfg, err = config.LoadProfile(cfgFile, activeProfile())
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 		log.Success("Configuration loaded successfully")
+		for _, key := range cfg.Unknown {
+			log.Warn("Ignoring unknown config key %s; --strict rejects unknown keys", config.DescribeUnknown(key))
+		}
+		if cfg.UserConfig != "" {
+			log.Info("User config: %s", cfg.UserConfig)
+		}
+		if cfg.Profile != "" {
+			log.Info("Profile: %s", cfg.Profile)
+		}
+		if cfg.Color != nil && !*cfg.Color {
+			log.SetColors(false)
+		}
 	} else {
-		// Build from flags
+		if profile != "" {
+			return fmt.Errorf("--profile can only be used with a config file")
+		}
+		// Build from flags; --serve alone watches and serves a directory
 		if watchPath == "" {
 			watchPath = "."
+			if serveDir != "" {
+				watchPath = serveDir
+			}
 		}
-		if command == "" {
-			return fmt.Errorf("--cmd is required when not using a config file")
+		if command == "" && serveDir == "" {
+			return fmt.Errorf("--cmd or --serve is required when not using a config file")
 		}
 
 		log.Section("Configuration")
@@ -149,17 +332,19 @@ func runWatch(cmd *cobra.Command, args []string) error {
 					Recursive: true,
 				},
 			},
-			OnChange: config.OnChange{
-				Commands: []config.Command{
-					{
-						Cmd:     shellCmd,
-						Timeout: timeout,
-					},
-				},
-			},
+			Serve:          config.Serve{Dir: serveDir},
 			Debounce:       debounce,
 			MaxConcurrency: maxConcur,
 		}
+		if command != "" {
+			cfg.OnChange.Commands = []config.Command{
+				{
+					Cmd:     shellCmd,
+					Timeout: timeout,
+					Reload:  liveReload != "",
+				},
+			}
+		}
 
 		// Validate CLI-based config
 		if err := cfg.Validate(); err != nil {
@@ -168,49 +353,263 @@ func runWatch(cmd *cobra.Command, args []string) error {
 		log.Success("Configuration validated")
 	}
 
-	// Display configuration summary
-	log.Section("Watch Configuration")
-	for i, w := range cfg.Watch {
-		recursive := ""
-		if w.Recursive {
-			recursive = " (recursive)"
-		}
-		log.Info("Path %d: %s%s", i+1, w.Path, recursive)
-		if len(w.Ignore) > 0 {
-			log.Debug("  Ignoring: %v", w.Ignore)
-		}
+	if err := applyFlagOverrides(cfg); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	log.Section("Commands")
-	for i, c := range cfg.OnChange.Commands {
-		log.Info("Command %d: %v", i+1, c.Cmd)
-		if c.Timeout != "" {
-			log.Debug("  Timeout: %s", c.Timeout)
+	// Resolve which pipelines (top level or named tasks) to run
+	tasks := parseTaskArgs(args)
+	if len(tasks) > 0 && cfgFile == "" {
+		return fmt.Errorf("tasks can only be selected when using a config file")
+	}
+	selected, err := selectPipelines(cfg, tasks)
+	if err != nil {
+		return err
+	}
+	if useTUI {
+		// The dashboard reads the keyboard and draws the whole screen
+		for _, name := range sortedNames(selected) {
+			c := selected[name]
+			for _, cmd := range slices.Concat(c.Setup, c.OnChange.Commands, c.OnSuccess, c.OnFailure, c.OnExit) {
+				if cmd.Interactive {
+					return fmt.Errorf("command %q is interactive, which the dashboard doesn't support", commandLabel(cmd))
+				}
+			}
 		}
 	}
+	// Display configuration summary
+	for _, name := range sortedNames(selected) {
+		printPipeline(log, name, selected[name])
+	}
 
 	log.Section("Settings")
-	log.Info("Debounce: %s", cfg.Debounce)
-	log.Info("Max Concurrency: %d", cfg.MaxConcurrency)
 	log.Info("Sequential Mode: %v", sequential)
 	if dryRun {
 		log.Warn("DRY RUN MODE - Commands will not be executed")
 	}
 
-	// Create watcher
-	w, err := watcher.New(cfg, log)
-	if err != nil {
-		return fmt.Errorf("failed to create watcher: %w", err)
-	}
-
-	// Create runner
-	r := runner.New(cfg, log, sequential, dryRun)
-
 	// Setup context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Handle shutdown signals
+	handleSignals(log, cancel)
+
+	// One-time setup; no watching if it fails. Errors from here on aren't
+	// usage errors, and main reports them.
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	if err := runSetup(ctx, log, cfg, selected); err != nil {
+		return fmt.Errorf("%w (not watching)", err)
+	}
+
+	// Start watching
+	log.Section("Starting Watcher")
+	sess := newSession(log, tasks, cancel)
+	if recordTo != "" {
+		rec, err := record.Create(recordTo)
+		if err != nil {
+			return err
+		}
+		defer rec.Close()
+		sess.onWatch(func(task string, ev watcher.Event) {
+			if err := rec.Write(task, ev); err != nil {
+				log.Warn("Failed to record event: %v", err)
+			}
+		})
+		log.Info("Recording events to %s", recordTo)
+	}
+	if err := sess.start(ctx, cfg, selected); err != nil {
+		return err
+	}
+
+	// Watch the config file itself so edits apply without a restart
+	var reloads <-chan struct{}
+	if cfgFile != "" && !noReload {
+		reloads, err = watcher.WatchFile(ctx, cfgFile)
+		if err != nil {
+			log.Warn("Config hot-reload disabled: %v", err)
+		} else {
+			log.Debug("Watching config for changes: %s", cfgFile)
+		}
+	}
+
+	// Control API on a local socket, queried by gowatch status
+	if ln, err := listenControl(); err != nil {
+		log.Debug("Control socket disabled: %v", err)
+	} else {
+		ctrl := api.New("", sess, log)
+		ctrl.Serve(ln)
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			ctrl.Shutdown(shutdownCtx)
+		}()
+		sess.onReport(ctrl.Publish)
+		log.Debug("Control socket: %s", ln.Addr())
+	}
+
+	// Optional HTTP control API
+	if apiAddr != "" {
+		srv := api.New(apiAddr, sess, log)
+		if err := srv.Start(); err != nil {
+			return err
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdownCtx)
+		}()
+		sess.onReport(srv.Publish)
+	}
+
+	// Optional profiling of gowatch itself
+	if pprofAddr != "" {
+		srv, err := startPprof(log, pprofAddr)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdownCtx)
+		}()
+	}
+
+	// Optional WebSocket stream of events and results
+	if wsAddr != "" {
+		ws := stream.New(wsAddr, log)
+		if err := ws.Start(); err != nil {
+			return err
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			ws.Shutdown(shutdownCtx)
+		}()
+		sess.onEvent(func(task string, runID int64, ev watcher.Event) {
+			ws.Publish(stream.EventMessage(task, runID, ev))
+		})
+		sess.onResult(func(task string, t runner.Trigger, r runner.RunResult) {
+			ws.Publish(stream.ResultMessage(task, t, r))
+		})
+		sess.onReport(func(report runner.Report) {
+			ws.Publish(stream.RunMessage(report))
+		})
+	}
+
+	// Optional LiveReload server, refreshed after successful runs
+	if cfg.LiveReload != "" {
+		lr, err := startLiveReload(log, sess, cfg.LiveReload)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			lr.Shutdown(shutdownCtx)
+		}()
+	}
+
+	// Optional static server, refreshed after every successful run
+	if cfg.Serve.Enabled() {
+		srv, err := startServe(log, sess, cfg.Serve)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdownCtx)
+		}()
+	}
+
+	// Optional reverse proxy, holding requests while the app restarts
+	if cfg.Proxy.Enabled() {
+		px, err := startProxy(log, sess, cfg.Proxy)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			px.Shutdown(shutdownCtx)
+		}()
+	}
+
+	// SIGUSR1/SIGUSR2 pause and resume, e.g. around a git rebase
+	if pause, resume := pauseSignals(); pause != nil {
+		pauseCh := make(chan os.Signal, 1)
+		signal.Notify(pauseCh, pause, resume)
+		defer signal.Stop(pauseCh)
+		go func() {
+			for {
+				select {
+				case sig := <-pauseCh:
+					sess.do(ctx, func() { sess.setPaused(sig == pause) })
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	if resultsTo != "" {
+		results, err := openResults(resultsTo)
+		if err != nil {
+			return err
+		}
+		defer results.Close()
+		sess.onResult(func(task string, t runner.Trigger, r runner.RunResult) {
+			if err := results.write(task, t, r); err != nil {
+				log.Warn("Failed to write result: %v", err)
+			}
+		})
+	}
+
+	recordHistory(log, sess, cfg)
+	writeReports(log, sess)
+	reportProblems(log, sess)
+	stopStatus := writeStatus(log, sess, cfg)
+	defer stopStatus()
+
+	if stateFile != "" {
+		sess.onReport(func(report runner.Report) {
+			if err := writeState(stateFile, report); err != nil {
+				log.Warn("Failed to write state file: %v", err)
+			}
+		})
+	}
+
+	log.Success("Watcher started successfully")
+	if statsEvery > 0 {
+		go sess.logStats(ctx, statsEvery)
+	}
+	if runFor > 0 {
+		log.Info("Watching for %s", runFor)
+		sess.stopAfter(ctx, runFor)
+	}
+	if useTUI {
+		sess.runOnStart()
+		return runDashboard(ctx, cancel, dash, sess, selected, reloads)
+	}
+	log.Info("Watching for file changes... (Press Ctrl+C to stop)")
+	if !noKeys {
+		if restore, ok := watchKeys(ctx, sess, log, cancel); ok {
+			defer restore()
+			log.Info("Keys: r re-run · p pause/resume · c clear · q quit")
+		}
+	}
+	log.Separator()
+
+	// Process events
+	sess.runOnStart()
+	return sess.loop(ctx, reloads)
+}
+
+// handleSignals cancels the run on SIGINT or SIGTERM, and exits right away
+// on a second one
+func handleSignals(log *logger.Logger, cancel context.CancelFunc) {
 	sigCh := make(chan os.Signal, 1)
 	// Windows-compatible signal handling
 	if runtime.GOOS == "windows" {
@@ -223,56 +622,347 @@ func runWatch(cmd *cobra.Command, args []string) error {
 		sig := <-sigCh
 		log.Info("")
 		log.Warn("Received signal: %v", sig)
-		log.Info("Shutting down gracefully...")
+		log.Info("Shutting down gracefully... (press Ctrl+C again to exit immediately)")
 		cancel()
+
+		// A second signal skips what is left of the shutdown, such as
+		// on_exit hooks that hang
+		<-sigCh
+		log.Warn("Exiting immediately")
+		os.Exit(130)
 	}()
+}
 
-	// Start watching
-	log.Section("Starting Watcher")
-	events, err := w.Start(ctx)
+// startLiveReload serves LiveReload on addr, refreshing the browsers after
+// the runs of the session that ask for it
+func startLiveReload(log *logger.Logger, sess *session, addr string) (*livereload.Server, error) {
+	lr := livereload.New(addr, log)
+	if err := lr.Start(); err != nil {
+		return nil, err
+	}
+	sess.onReport(func(report runner.Report) {
+		if report.WantsReload() && !dryRun {
+			if n := lr.Reload(report.Trigger.Path); n > 0 {
+				log.Info("LiveReload: refreshed %d browser(s)", n)
+			}
+		}
+	})
+	return lr, nil
+}
+
+// startServe serves the static files of a config, refreshing the browsers
+// after every successful run of the session
+func startServe(log *logger.Logger, sess *session, sv config.Serve) (*serve.Server, error) {
+	srv := serve.New(sv.GetListen(), sv.Dir, log)
+	if err := srv.Start(); err != nil {
+		return nil, err
+	}
+	sess.onReport(func(report runner.Report) {
+		if report.Success() && !dryRun {
+			if n := srv.Reload(report.Trigger.Path); n > 0 {
+				log.Info("Refreshed %d browser(s)", n)
+			}
+		}
+	})
+	return srv, nil
+}
+
+// startProxy serves the reverse proxy of a config. Requests are held during
+// the runs of pipelines with mode: restart commands, until the app accepts
+// connections again.
+func startProxy(log *logger.Logger, sess *session, p config.Proxy) (*proxy.Server, error) {
+	target, err := p.TargetURL()
 	if err != nil {
-		return fmt.Errorf("failed to start watcher: %w", err)
+		return nil, err
+	}
+	px := proxy.New(p.Listen, target, p.GetTimeout(), log)
+	if err := px.Start(); err != nil {
+		return nil, err
 	}
+	if dryRun {
+		return px, nil
+	}
+	// Both run on the loop, one run at a time
+	held := false
+	sess.onEvent(func(task string, _ int64, _ watcher.Event) {
+		if sess.restarts(task) {
+			held = true
+			px.Hold()
+		}
+	})
+	sess.onReport(func(runner.Report) {
+		if held {
+			held = false
+			px.Release()
+		}
+	})
+	return px, nil
+}
 
-	log.Success("Watcher started successfully")
-	log.Info("Watching for file changes... (Press Ctrl+C to stop)")
-	log.Separator()
+// recordHistory adds the runs of a session to the run history of its
+// config, unless it is disabled
+func recordHistory(log *logger.Logger, sess *session, cfg *config.Config) {
+	path := cfg.HistoryFile()
+	if path == "" || dryRun {
+		return
+	}
+	store, err := history.Open(path, cfg.GetHistoryKeep())
+	if err != nil {
+		log.Warn("Run history disabled: %v", err)
+		return
+	}
+	// Reports are delivered on the loop goroutine, which owns the pipelines
+	sess.onReport(func(report runner.Report) {
+		if err := store.Add(history.NewRun(report, sess.commandNames(report.Task))); err != nil {
+			log.Warn("Failed to record run: %v", err)
+		}
+	})
+}
 
-	// Process events
-	eventCount := 0
-	for {
-		select {
-		case <-ctx.Done():
-			log.Info("")
-			log.Section("Shutdown")
-			log.Info("Events processed: %d", eventCount)
-			log.Success("Shutdown complete")
-			return nil
-
-		case event, ok := <-events:
-			if !ok {
-				log.Info("Event channel closed")
-				return nil
-			}
+// writeReports writes the report of every run whose config asks for one
+func writeReports(log *logger.Logger, sess *session) {
+	if dryRun {
+		return
+	}
+	// Reports are delivered on the loop goroutine, which owns the pipelines
+	sess.onReport(func(report runner.Report) {
+		if p := sess.pipelines[report.Task]; p != nil {
+			writeJUnit(log, p.cfg, report, sess.commandNames(report.Task))
+		}
+	})
+}
 
-			eventCount++
+// writeJUnit writes the JUnit report of a run, if its config asks for one.
+// names holds the labels of its on_change commands by index.
+func writeJUnit(log *logger.Logger, cfg *config.Config, report runner.Report, names []string) {
+	path := cfg.JUnitReport()
+	if path == "" {
+		return
+	}
+	if err := junit.Write(path, report, names); err != nil {
+		log.Warn("Failed to write JUnit report: %v", err)
+	}
+}
 
-			// Run commands
-			results := r.Run(ctx, event.Path, event.Op)
+// printPipeline displays the watch paths and commands of one pipeline
+func printPipeline(log *logger.Logger, name string, cfg *config.Config) {
+	suffix := ""
+	if name != defaultPipeline {
+		suffix = fmt.Sprintf(" [%s]", name)
+	}
 
-			// Check for failures
-			hasFailure := false
-			for _, result := range results {
-				if result.ExitCode != 0 {
-					hasFailure = true
-				}
+	log.Section("Watch Configuration" + suffix)
+	for i, w := range cfg.Watch {
+		recursive := ""
+		if w.Recursive {
+			recursive = " (recursive)"
+		}
+		if w.MaxDepth > 0 {
+			recursive = fmt.Sprintf(" (recursive, max depth %d)", w.MaxDepth)
+		}
+		log.Info("Path %d: %s%s", i+1, w.Location(), recursive)
+		if len(w.Ignore) > 0 {
+			log.Debug("  Ignoring: %v", w.Ignore)
+		}
+		if len(w.Include) > 0 {
+			log.Info("  Include: %v", w.Include)
+		}
+		if len(w.Extensions) > 0 {
+			log.Info("  Extensions: %v", w.Extensions)
+		}
+		if w.DebounceStrategy != "" {
+			log.Info("  Debounce strategy: %s", w.DebounceStrategy)
+		}
+		if w.MaxFileSize != "" {
+			log.Info("  Max file size: %s", w.MaxFileSize)
+		}
+		if w.BufferSize != "" {
+			log.Info("  Buffer size: %s", w.BufferSize)
+		}
+		switch backend := cfg.BackendFor(w); backend {
+		case config.BackendPoll, config.BackendRemote:
+			log.Info("  Backend: %s (every %s)", backend, cfg.GetPollInterval())
+		case config.BackendWatchman, config.BackendFanotify:
+			log.Info("  Backend: %s", backend)
+		}
+	}
+
+	log.Section("Commands" + suffix)
+	for i, c := range cfg.OnChange.Commands {
+		if c.Name != "" {
+			log.Info("Command %d (%s): %v", i+1, c.Name, commandLine(c))
+		} else {
+			log.Info("Command %d: %v", i+1, commandLine(c))
+		}
+		if c.Timeout != "" {
+			log.Debug("  Timeout: %s", c.Timeout)
+		}
+		if c.IsRestart() {
+			log.Debug("  Mode: %s", c.Mode)
+		}
+		if stage := cfg.OnChange.StageName(c); stage != "" {
+			log.Info("  Stage: %s", stage)
+		}
+		if len(c.DependsOn) > 0 {
+			log.Info("  Depends on: %s", strings.Join(c.DependsOn, ", "))
+		}
+		if len(c.Match) > 0 {
+			log.Info("  Match: %s", strings.Join(c.Match, ", "))
+		}
+		if c.Cooldown != "" {
+			log.Info("  Cooldown: %s", c.Cooldown)
+		}
+		if c.Container != "" {
+			log.Info("  Container: %s", c.Container)
+		}
+		if c.Interactive {
+			log.Info("  Interactive: true")
+		}
+		if c.Priority != 0 {
+			log.Info("  Priority: %d", c.Priority)
+		}
+		if c.Weight > 1 {
+			log.Info("  Weight: %d", c.Weight)
+		}
+		if c.Reload {
+			log.Debug("  Reload: true")
+		}
+		if len(c.Outputs) > 0 {
+			log.Debug("  Outputs: %s", strings.Join(c.Outputs, ", "))
+		}
+	}
+	for i, c := range cfg.Setup {
+		log.Info("Setup %d: %v", i+1, commandLine(c))
+	}
+	for i, c := range cfg.OnSuccess {
+		log.Info("On success %d: %v", i+1, commandLine(c))
+	}
+	for i, c := range cfg.OnFailure {
+		log.Info("On failure %d: %v", i+1, commandLine(c))
+	}
+	for i, c := range cfg.OnExit {
+		log.Info("On exit %d: %v", i+1, commandLine(c))
+	}
+	log.Info("Debounce: %s (%s)", cfg.Debounce, cfg.DebounceStrategyFor(config.WatchPath{}))
+	log.Info("Max Concurrency: %d", cfg.MaxConcurrency)
+	if cfg.MaxFileSize != "" {
+		log.Info("Max file size: %s", cfg.MaxFileSize)
+	}
+	if cfg.BufferSize != "" {
+		log.Info("Buffer size: %s", cfg.BufferSize)
+	}
+	if cfg.Notify != "" {
+		log.Info("Notify: %s", cfg.Notify)
+	}
+	if cfg.Bell != "" {
+		log.Info("Bell: %s", cfg.Bell)
+	}
+	if cfg.RunOnStart {
+		log.Info("Run on start: true")
+	}
+	if cfg.OutputDir != "" {
+		log.Info("Output: %s (last %d runs)", cfg.RunOutputDir(), cfg.GetOutputKeep())
+	}
+}
+
+// commandLine returns what a command runs, including the compose command
+// of restart_service commands
+func commandLine(c config.Command) []string {
+	if c.RestartService != "" {
+		return c.ComposeCommand()
+	}
+	return c.Cmd
+}
+
+// commandLabel names a command in displays: its name, or its command line
+func commandLabel(c config.Command) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return strings.Join(commandLine(c), " ")
+}
+
+func sortedNames(m map[string]*config.Config) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyFlagOverrides applies run flags that override config file settings.
+// It is reapplied after every config reload.
+func applyFlagOverrides(cfg *config.Config) error {
+	if clearRuns {
+		cfg.Clear = true
+	}
+	if runOnStart {
+		cfg.RunOnStart = true
+		for name, task := range cfg.Tasks {
+			task.RunOnStart = nil
+			cfg.Tasks[name] = task
+		}
+	}
+	if liveReload != "" {
+		cfg.LiveReload = liveReload
+	}
+	if serveDir != "" {
+		cfg.Serve.Dir = serveDir
+	}
+	if notifyOn {
+		cfg.Notify = config.NotifyDesktop
+		for name, task := range cfg.Tasks {
+			task.Notify = ""
+			cfg.Tasks[name] = task
+		}
+	}
+	if bellOn != "" {
+		cfg.Bell = bellOn
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// The debounce strategy from flags applies to every watch path and task
+	if strategy != "" {
+		cfg.DebounceStrategy = strategy
+		for i := range cfg.Watch {
+			cfg.Watch[i].DebounceStrategy = ""
+		}
+		for name, task := range cfg.Tasks {
+			task.DebounceStrategy = ""
+			for i := range task.Watch {
+				task.Watch[i].DebounceStrategy = ""
 			}
+			cfg.Tasks[name] = task
+		}
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+	}
 
-			if hasFailure && !dryRun {
-				log.Error("Execution completed with errors")
+	// Backend overrides from flags apply to every watch path, tasks included
+	if poll {
+		cfg.Backend = config.BackendPoll
+		for i := range cfg.Watch {
+			cfg.Watch[i].Backend = ""
+		}
+		for name, task := range cfg.Tasks {
+			for i := range task.Watch {
+				task.Watch[i].Backend = ""
 			}
+			cfg.Tasks[name] = task
 		}
 	}
+	if pollFall {
+		cfg.PollFallback = true
+	}
+	if pollEvery != "" {
+		cfg.PollInterval = pollEvery
+		return cfg.Validate()
+	}
+	return nil
 }
 
 func initConfig(cmd *cobra.Command, args []string) error {
@@ -283,9 +973,16 @@ func initConfig(cmd *cobra.Command, args []string) error {
 	// Detect project type
 	cwd, _ := os.Getwd()
 	projectType := config.DetectProjectType(cwd)
+	projects := config.DetectProjects(cwd)
+	monorepo := len(projects) > 1 || len(projects) == 1 && projects[0].Dir != "."
 
 	log.Section("Project Detection")
-	if projectType != config.ProjectUnknown {
+	if monorepo {
+		log.Success("Detected %d project(s):", len(projects))
+		for _, p := range projects {
+			log.Info("  %s: %s", p.Dir, config.GetProjectTypeName(p.Type))
+		}
+	} else if projectType != config.ProjectUnknown {
 		log.Success("Detected project type: %s", config.GetProjectTypeName(projectType))
 	} else {
 		log.Info("Could not detect project type, using default template")
@@ -316,7 +1013,11 @@ func initConfig(cmd *cobra.Command, args []string) error {
 		if err := config.WriteTemplateForProject(cwd); err != nil {
 			return fmt.Errorf("failed to write config: %w", err)
 		}
-		log.Success("Created: %s (optimized for %s)", configPath, config.GetProjectTypeName(projectType))
+		if monorepo {
+			log.Success("Created: %s (a task per project)", configPath)
+		} else {
+			log.Success("Created: %s (optimized for %s)", configPath, config.GetProjectTypeName(projectType))
+		}
 	}
 
 	if !ignoreExists {
@@ -333,7 +1034,10 @@ func initConfig(cmd *cobra.Command, args []string) error {
 		log.Info("3. Test your config: gowatch test-config")
 		log.Info("4. Start watching: gowatch run")
 
-		if projectType != config.ProjectUnknown {
+		if monorepo {
+			log.Info("")
+			log.Info("💡 Tip: Run a single project with: gowatch run <task>")
+		} else if projectType != config.ProjectUnknown {
 			log.Info("")
 			log.Info("💡 Tip: The config has been optimized for %s projects!", config.GetProjectTypeName(projectType))
 		}
@@ -350,15 +1054,35 @@ func testConfig(cmd *cobra.Command, args []string) error {
 
 	log.Banner("GoWatch Configuration Test", "1.0.0")
 	log.Section("Loading Configuration")
+	if err := resolveConfigFile(); err != nil {
+		log.Error("%v", err)
+		return err
+	}
 	log.Info("Config file: %s", cfgFile)
 
-	cfg, err := config.Load(cfgFile)
+	// The schema check points at the lines load errors come from, and at
+	// keys that loading ignores
+	issues, _ := config.CheckSchema(cfgFile)
+
+	cfg, err := config.LoadProfile(cfgFile, activeProfile())
 	if err != nil {
 		log.Error("Failed to load config: %v", err)
+		for _, issue := range issues {
+			log.Error("  %s", schemaIssue(cfgFile, issue))
+		}
 		return err
 	}
+	if cfg.UserConfig != "" {
+		log.Info("User config: %s", cfg.UserConfig)
+	}
+	if cfg.Profile != "" {
+		log.Info("Profile: %s", cfg.Profile)
+	}
 
 	log.Success("Configuration loaded successfully")
+	for _, issue := range issues {
+		log.Warn("%s", schemaIssue(cfgFile, issue))
+	}
 
 	log.Section("Watch Paths")
 	for i, w := range cfg.Watch {
@@ -366,7 +1090,16 @@ func testConfig(cmd *cobra.Command, args []string) error {
 		if w.Recursive {
 			recursive = " (recursive)"
 		}
-		log.Info("%d. %s%s", i+1, w.Path, recursive)
+		if w.MaxDepth > 0 {
+			recursive = fmt.Sprintf(" (recursive, max depth %d)", w.MaxDepth)
+		}
+		log.Info("%d. %s%s", i+1, w.Location(), recursive)
+		switch backend := cfg.BackendFor(w); backend {
+		case config.BackendPoll, config.BackendRemote:
+			log.Info("   Backend: %s (every %s)", backend, cfg.GetPollInterval())
+		case config.BackendWatchman, config.BackendFanotify:
+			log.Info("   Backend: %s", backend)
+		}
 		if len(w.Ignore) > 0 {
 			for _, pattern := range w.Ignore {
 				log.Debug("   Ignore: %s", pattern)
@@ -376,21 +1109,107 @@ func testConfig(cmd *cobra.Command, args []string) error {
 
 	log.Section("Commands")
 	for i, c := range cfg.OnChange.Commands {
-		log.Info("%d. %v", i+1, c.Cmd)
+		log.Info("%d. %v", i+1, commandLine(c))
+		if c.Name != "" {
+			log.Debug("   Name: %s", c.Name)
+		}
+		if stage := cfg.OnChange.StageName(c); stage != "" {
+			log.Info("   Stage: %s", stage)
+		}
+		if len(c.DependsOn) > 0 {
+			log.Info("   Depends on: %s", strings.Join(c.DependsOn, ", "))
+		}
+		if len(c.Match) > 0 {
+			log.Info("   Match: %s", strings.Join(c.Match, ", "))
+		}
+		if c.Cooldown != "" {
+			log.Info("   Cooldown: %s", c.Cooldown)
+		}
+		if c.RestartService != "" {
+			log.Info("   Restart service: %s (waits until healthy)", c.RestartService)
+		}
+		if c.Container != "" {
+			log.Info("   Container: %s", c.Container)
+			for _, m := range c.ContainerPaths {
+				log.Info("   Container path: %s", m)
+			}
+		}
 		if c.Timeout != "" {
 			log.Debug("   Timeout: %s", c.Timeout)
 		}
 		if c.Run != "" {
-			log.Debug("   Mode: %s", c.Run)
+			log.Debug("   Run: %s", c.Run)
+		}
+		if c.Mode != "" {
+			log.Debug("   Mode: %s", c.Mode)
+		}
+		if c.Reload {
+			log.Debug("   Reload: true")
+		}
+	}
+
+	if len(cfg.Tasks) > 0 {
+		log.Section("Tasks")
+		for _, name := range cfg.TaskNames() {
+			tc, _ := cfg.ForTask(name)
+			log.Info("%s: %d path(s), %d command(s)", name, len(tc.Watch), len(tc.OnChange.Commands))
+			for _, c := range tc.OnChange.Commands {
+				log.Debug("   %v", commandLine(c))
+			}
 		}
 	}
 
 	log.Section("Settings")
-	log.Info("Debounce: %s", cfg.Debounce)
+	log.Info("Debounce: %s (%s)", cfg.Debounce, cfg.DebounceStrategyFor(config.WatchPath{}))
 	log.Info("Max Concurrency: %d", cfg.MaxConcurrency)
+	if cfg.MaxFileSize != "" {
+		log.Info("Max file size: %s", cfg.MaxFileSize)
+	}
+	if cfg.BufferSize != "" {
+		log.Info("Buffer size: %s", cfg.BufferSize)
+	}
+	if cfg.Notify != "" {
+		log.Info("Notify: %s", cfg.Notify)
+	}
+	if cfg.Bell != "" {
+		log.Info("Bell: %s", cfg.Bell)
+	}
+	if cfg.LiveReload != "" {
+		log.Info("LiveReload: %s", cfg.LiveReload)
+	}
+	if cfg.Proxy.Enabled() {
+		log.Info("Proxy: %s → %s", cfg.Proxy.Listen, cfg.Proxy.Target)
+	}
+	if cfg.Serve.Enabled() {
+		log.Info("Serve: %s on %s", cfg.Serve.Dir, cfg.Serve.GetListen())
+	}
+	if cfg.OutputDir != "" {
+		log.Info("Output: %s (last %d runs)", cfg.RunOutputDir(), cfg.GetOutputKeep())
+	}
+	if cfg.RunOnStart {
+		log.Info("Run on start: true")
+	}
+	if len(cfg.Profiles) > 0 {
+		log.Info("Profiles: %s", strings.Join(cfg.ProfileNames(), ", "))
+	}
+
+	if len(simulatePaths) > 0 {
+		if err := simulate(log, cfg); err != nil {
+			log.Error("Simulation failed: %v", err)
+			return err
+		}
+	}
 
 	log.Section("Validation")
 	log.Success("All configuration checks passed!")
 
 	return nil
 }
+
+// schemaIssue prefixes a schema issue with the file it was found in
+func schemaIssue(file string, issue config.SchemaIssue) string {
+	if issue.Line == 0 {
+		return file + ": " + issue.String()
+	}
+	return file + ":" + issue.String()
+}
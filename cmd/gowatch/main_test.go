@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+)
+
+func TestApplyFlagOverrides_Poll(t *testing.T) {
+	t.Cleanup(func() { poll = false })
+	poll = true
+
+	cfg := &config.Config{
+		Backend: config.BackendFSNotify,
+		Watch:   []config.WatchPath{{Path: ".", Backend: config.BackendFSNotify}},
+		Tasks: map[string]config.Task{
+			"api": {Watch: []config.WatchPath{{Path: "api", Backend: config.BackendFSNotify}, {Path: "proto"}}},
+			"web": {},
+		},
+	}
+	if err := applyFlagOverrides(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every watch path polls, those of tasks included
+	for _, name := range append([]string{""}, cfg.TaskNames()...) {
+		pcfg := cfg
+		if name != "" {
+			var err error
+			if pcfg, err = cfg.ForTask(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, w := range pcfg.Watch {
+			if got := pcfg.BackendFor(w); got != config.BackendPoll {
+				t.Errorf("task %q: backend of %s = %q, want %q", name, w.Path, got, config.BackendPoll)
+			}
+		}
+	}
+}
+
+func TestResolveLogLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		logLevel string
+		verbose  bool
+		quiet    bool
+		env      string
+		want     logger.Level
+		wantFlag bool
+		wantErr  string
+	}{
+		{name: "default", want: logger.LevelInfo},
+		{name: "env", env: "warning", want: logger.LevelWarn},
+		{name: "--quiet", quiet: true, want: logger.LevelError, wantFlag: true},
+		{name: "--verbose", verbose: true, want: logger.LevelDebug, wantFlag: true},
+		{name: "--log-level", logLevel: "WARN", want: logger.LevelWarn, wantFlag: true},
+		// Flags take precedence over the environment, --log-level over the others
+		{name: "--quiet over env", quiet: true, env: "debug", want: logger.LevelError, wantFlag: true},
+		{name: "--verbose over --quiet", verbose: true, quiet: true, want: logger.LevelDebug, wantFlag: true},
+		{name: "--log-level over --verbose", logLevel: "error", verbose: true, env: "debug", want: logger.LevelError, wantFlag: true},
+		{name: "invalid --log-level", logLevel: "loud", wantFlag: true, wantErr: `invalid log level "loud" (expected debug, info, warn, error)`},
+		{name: "invalid env", env: "loud", wantErr: `GOWATCH_LOG_LEVEL: invalid log level "loud" (expected debug, info, warn, error)`},
+		{name: "invalid env with a flag", quiet: true, env: "loud", want: logger.LevelError, wantFlag: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { logLevel, verbose, quiet = "", false, false })
+			logLevel, verbose, quiet = tt.logLevel, tt.verbose, tt.quiet
+			t.Setenv("GOWATCH_LOG_LEVEL", tt.env)
+
+			level, flag, err := resolveLogLevel()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("resolveLogLevel() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveLogLevel() error = %v", err)
+			}
+			if level != tt.want || flag != tt.wantFlag {
+				t.Errorf("resolveLogLevel() = %v, %v, want %v, %v", level, flag, tt.want, tt.wantFlag)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import "strings"
+
+// mountInfo describes the filesystem a watch path is on
+type mountInfo struct {
+	fsType string
+	// remote filesystems (network shares, VM and container file sharing)
+	// often deliver no change notifications
+	remote bool
+	// bind is set for bind mounts, e.g. Docker volumes
+	bind bool
+}
+
+// remoteFilesystems are filesystem types known to deliver no or unreliable
+// native change notifications
+var remoteFilesystems = map[string]bool{
+	"nfs":       true,
+	"nfs4":      true,
+	"cifs":      true,
+	"smb3":      true,
+	"smbfs":     true,
+	"afpfs":     true,
+	"webdav":    true,
+	"9p":        true,
+	"virtiofs":  true,
+	"vboxsf":    true,
+	"fakeowner": true,
+	"ceph":      true,
+	"glusterfs": true,
+}
+
+// isRemoteFS reports whether a filesystem type is a network or shared one
+func isRemoteFS(fsType string) bool {
+	fsType = strings.ToLower(fsType)
+	return remoteFilesystems[fsType] || strings.HasPrefix(fsType, "fuse") ||
+		strings.HasPrefix(fsType, "osxfuse") || strings.HasPrefix(fsType, "macfuse")
+}
@@ -0,0 +1,18 @@
+//go:build darwin || freebsd
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// mountOf reads the filesystem type of path with statfs
+func mountOf(path string) (mountInfo, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return mountInfo{}, err
+	}
+	fsType := unix.ByteSliceToString(st.Fstypename[:])
+	return mountInfo{
+		fsType: fsType,
+		remote: isRemoteFS(fsType) || uint64(st.Flags)&unix.MNT_LOCAL == 0,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// mountOf finds the mount containing path in /proc/self/mountinfo
+func mountOf(path string) (mountInfo, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return mountInfo{}, err
+	}
+
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return mountInfo{}, err
+	}
+	defer f.Close()
+
+	var best mountInfo
+	bestLen := -1
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 36 35 98:0 /root /mnt rw,noatime master:1 - ext4 /dev/sda1 rw
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, field := range fields {
+			if field == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 5 || sep+1 >= len(fields) {
+			continue
+		}
+
+		root := unescapeMount(fields[3])
+		point := unescapeMount(fields[4])
+		if !within(path, point) || len(point) <= bestLen {
+			continue
+		}
+		fsType := fields[sep+1]
+		best = mountInfo{
+			fsType: fsType,
+			remote: isRemoteFS(fsType),
+			bind:   root != "/",
+		}
+		bestLen = len(point)
+	}
+	if err := scanner.Err(); err != nil {
+		return mountInfo{}, err
+	}
+	if bestLen < 0 {
+		return mountInfo{}, fmt.Errorf("no mount found for %s", path)
+	}
+	return best, nil
+}
+
+// within reports whether path is dir or below it
+func within(path, dir string) bool {
+	if dir == "/" {
+		return true
+	}
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// unescapeMount decodes the octal escapes (\040 for space) of mountinfo
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package main
+
+import "errors"
+
+// mountOf can't tell filesystems apart on this platform
+func mountOf(string) (mountInfo, error) {
+	return mountInfo{}, errors.New("filesystem detection is not supported on this platform")
+}
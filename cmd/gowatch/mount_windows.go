@@ -0,0 +1,20 @@
+package main
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// mountOf tells network drives and UNC shares from local volumes
+func mountOf(path string) (mountInfo, error) {
+	volume := filepath.VolumeName(path)
+	root, err := windows.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return mountInfo{}, err
+	}
+	if len(volume) > 2 && volume[:2] == `\\` || windows.GetDriveType(root) == windows.DRIVE_REMOTE {
+		return mountInfo{fsType: "network drive", remote: true}, nil
+	}
+	return mountInfo{fsType: "local drive"}, nil
+}
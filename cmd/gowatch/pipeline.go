@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gowatch/internal/ignore"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+	"gowatch/pkg/watcher"
+)
+
+// selfTriggerSlack allows for the delay between a command writing a file
+// and the watcher seeing the change, when matching changes to the run that
+// made them
+const selfTriggerSlack = 100 * time.Millisecond
+
+// defaultPipeline names the pipeline built from the top level of the config
+const defaultPipeline = "default"
+
+// pipeline is one watcher/runner pair, either the top-level config or a
+// named task
+type pipeline struct {
+	name    string
+	cfg     *config.Config
+	runner  *runner.Runner
+	watcher *watcher.Watcher
+	stop    context.CancelFunc
+	// retired is set once the pipeline has been replaced on reload so that
+	// events already in flight from its watcher are dropped
+	retired bool
+	// outputs matches files written by the commands, and lastRunStart and
+	// lastRunEnd bound their last run; together they suppress self-triggered
+	// runs
+	outputs      *ignore.Matcher
+	lastRunStart time.Time
+	lastRunEnd   time.Time
+	// cooldown fires when changes held back by a command's cooldown can run
+	cooldown *time.Timer
+	// failures counts the runs that failed in a row, for --max-failures
+	failures int
+	// failed is set while the last run failed, for the bell on recovery
+	failed bool
+}
+
+// pipelineEvent is a watcher event tagged with the pipeline it came from
+type pipelineEvent struct {
+	pipeline *pipeline
+	event    watcher.Event
+	// manual events are requested explicitly and run even while paused
+	manual bool
+	// cooldown events run the changes held back by command cooldowns
+	cooldown bool
+}
+
+// parseTaskArgs accepts task names as separate arguments and/or
+// comma-separated lists
+func parseTaskArgs(args []string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		for _, name := range strings.Split(arg, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// selectPipelines resolves which pipelines to run. Without task names the
+// top-level pipeline runs if the config defines one, otherwise every task.
+func selectPipelines(cfg *config.Config, tasks []string) (map[string]*config.Config, error) {
+	selected := make(map[string]*config.Config)
+
+	if len(tasks) == 0 {
+		if cfg.HasPipeline() || len(cfg.Tasks) == 0 {
+			selected[defaultPipeline] = cfg
+			return selected, nil
+		}
+		tasks = cfg.TaskNames()
+	}
+
+	for _, name := range tasks {
+		tc, err := cfg.ForTask(name)
+		if err != nil {
+			return nil, err
+		}
+		selected[name] = tc
+	}
+
+	return selected, nil
+}
+
+// runSetup runs the setup commands of the top-level config and then of each
+// selected task, before anything is watched. The first failure aborts it.
+func runSetup(ctx context.Context, log *logger.Logger, cfg *config.Config, selected map[string]*config.Config) error {
+	configs := []*config.Config{cfg}
+	for _, name := range sortedNames(selected) {
+		if tc := selected[name]; tc != cfg {
+			configs = append(configs, tc)
+		}
+	}
+
+	section := false
+	for _, c := range configs {
+		if len(c.Setup) == 0 {
+			continue
+		}
+		if !section {
+			log.Section("Setup")
+			section = true
+		}
+		err := runner.New(c, runner.Options{Logger: log, DryRun: dryRun}).Setup(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return fmt.Errorf("setup interrupted")
+		case err != nil && c.Task != "":
+			return fmt.Errorf("task %q: %w", c.Task, err)
+		case err != nil:
+			return err
+		}
+	}
+	return nil
+}
+
+// pipelineHooks receive the events of a pipeline's watcher as they are
+// emitted and the commands of its runs as they start, print and finish,
+// tagged with the pipeline name. terminal lends the terminal to interactive
+// commands.
+type pipelineHooks struct {
+	onWatch  func(task string, ev watcher.Event)
+	onStart  func(task string, t runner.Trigger, command []string)
+	onResult func(task string, t runner.Trigger, r runner.RunResult)
+	onOutput func(task string, t runner.Trigger, line string, isError bool)
+	terminal func() (*os.File, func())
+}
+
+// startPipeline creates the runner and starts the watcher for one pipeline,
+// forwarding its events to out until the watcher stops. On reload, prev is
+// the pipeline it replaces, whose runner it takes over instead.
+func startPipeline(ctx context.Context, name string, cfg *config.Config, log *logger.Logger, out chan<- pipelineEvent, hooks pipelineHooks, prev *pipeline) (*pipeline, error) {
+	w, events, stop, err := startWatcher(ctx, cfg, log)
+	if err != nil {
+		if name != defaultPipeline {
+			return nil, fmt.Errorf("task %q: %w", name, err)
+		}
+		return nil, err
+	}
+
+	var p *pipeline
+	if prev != nil {
+		p = prev.successor(cfg)
+	} else {
+		p = newPipeline(name, cfg, log, hooks)
+	}
+	p.watcher, p.stop = w, stop
+
+	go func() {
+		for ev := range events {
+			if hooks.onWatch != nil {
+				hooks.onWatch(name, ev)
+			}
+			select {
+			case out <- pipelineEvent{pipeline: p, event: ev}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return p, nil
+}
+
+// newPipeline creates the runner of a pipeline, without a watcher
+func newPipeline(name string, cfg *config.Config, log *logger.Logger, hooks pipelineHooks) *pipeline {
+	opts := runner.Options{Logger: log, Sequential: sequential, DryRun: dryRun, Terminal: hooks.terminal}
+	if hooks.onStart != nil {
+		opts.OnStart = func(t runner.Trigger, command []string) { hooks.onStart(name, t, command) }
+	}
+	if hooks.onResult != nil {
+		opts.OnResult = func(t runner.Trigger, r runner.RunResult) { hooks.onResult(name, t, r) }
+	}
+	if hooks.onOutput != nil {
+		opts.OnOutput = func(t runner.Trigger, line string, isError bool) { hooks.onOutput(name, t, line, isError) }
+	}
+
+	return &pipeline{
+		name:    name,
+		cfg:     cfg,
+		runner:  runner.New(cfg, opts),
+		stop:    func() {},
+		outputs: outputMatcher(cfg),
+	}
+}
+
+// successor creates the pipeline that replaces p on reload with cfg. It
+// keeps the runner, and with it the long-running processes, and what the
+// past runs left: the failures in a row, whether the last one failed and
+// when it ran.
+func (p *pipeline) successor(cfg *config.Config) *pipeline {
+	return &pipeline{
+		name:         p.name,
+		cfg:          cfg,
+		runner:       p.runner,
+		stop:         func() {},
+		outputs:      outputMatcher(cfg),
+		lastRunStart: p.lastRunStart,
+		lastRunEnd:   p.lastRunEnd,
+		failures:     p.failures,
+		failed:       p.failed,
+	}
+}
+
+// outputMatcher compiles the outputs patterns of a pipeline's commands, or
+// returns nil if there are none
+func outputMatcher(cfg *config.Config) *ignore.Matcher {
+	var patterns []string
+	for _, c := range cfg.OnChange.Commands {
+		patterns = append(patterns, c.Outputs...)
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	m := ignore.New()
+	if wd, err := cfg.WorkDir(); err == nil {
+		m.Add(wd, "outputs", patterns)
+	}
+	return m
+}
+
+// ownChanges reports whether an event only contains changes the pipeline's
+// own last run made, which must not trigger it again: changes made while it
+// ran to its outputs, or to any file with ignore_during_run. They are
+// removed from events that also contain other changes, such as files saved
+// after the run ended, along with their event types and tags.
+func (p *pipeline) ownChanges(pe *pipelineEvent) bool {
+	if pe.manual || p.lastRunEnd.IsZero() || !p.cfg.IgnoreDuringRun && p.outputs == nil {
+		return false
+	}
+
+	ev := pe.event
+	files := ev.Files
+	if len(files) == 0 {
+		files = []string{ev.Path}
+	}
+	var keep []int
+	for i, f := range files {
+		at := ev.ChangedAt(i)
+		duringRun := !at.Before(p.lastRunStart) && !at.After(p.lastRunEnd.Add(selfTriggerSlack))
+		if duringRun && (p.cfg.IgnoreDuringRun || p.outputs.MatchPath(f)) {
+			continue
+		}
+		keep = append(keep, i)
+	}
+	switch len(keep) {
+	case 0:
+		return true
+	case len(files):
+		return false
+	}
+
+	// Events that don't record them per file keep their event types and tags
+	kept := watcher.Event{Op: ev.Op, Timestamp: ev.Timestamp, Ops: ev.Ops, Tags: ev.Tags}
+	if len(ev.FileOps) > 0 {
+		kept.Ops = nil
+	}
+	if len(ev.FileTags) > 0 {
+		kept.Tags = nil
+	}
+	for _, i := range keep {
+		kept.Path = files[i]
+		kept.Files = append(kept.Files, files[i])
+		kept.Changed = append(kept.Changed, ev.ChangedAt(i))
+		if i < len(ev.FileOps) {
+			kept.Op = strings.Join(ev.FileOps[i], "|")
+			kept.Ops = mergeNames(kept.Ops, ev.FileOps[i])
+			kept.FileOps = append(kept.FileOps, ev.FileOps[i])
+		}
+		if i < len(ev.FileTags) {
+			kept.Tags = mergeNames(kept.Tags, ev.FileTags[i])
+			kept.FileTags = append(kept.FileTags, ev.FileTags[i])
+		}
+	}
+	pe.event = kept
+	return false
+}
+
+// mergeNames adds the names missing from names
+func mergeNames(names, add []string) []string {
+	for _, name := range add {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// startWatcher creates and starts a watcher bound to a child context so that
+// it can be replaced when the configuration is reloaded
+func startWatcher(ctx context.Context, cfg *config.Config, log *logger.Logger) (*watcher.Watcher, <-chan watcher.Event, context.CancelFunc, error) {
+	w, err := watcher.New(cfg, watcher.Options{Logger: log})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+
+	wctx, cancel := context.WithCancel(ctx)
+	events, err := w.Start(wctx)
+	if err != nil {
+		cancel()
+		w.Close()
+		return nil, nil, nil, fmt.Errorf("failed to start watcher: %w", err)
+	}
+
+	return w, events, cancel, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+	"gowatch/pkg/watcher"
+)
+
+func TestOutputMatcher(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Dir: dir}
+	cfg.OnChange.Commands = []config.Command{{Cmd: []string{"make"}}}
+	if m := outputMatcher(cfg); m != nil {
+		t.Errorf("outputMatcher() without outputs = %v, want nil", m)
+	}
+
+	// The outputs of every command count, relative to the working directory
+	cfg.OnChange.Commands = []config.Command{
+		{Cmd: []string{"go", "generate"}, Outputs: []string{"*.gen.go"}},
+		{Cmd: []string{"go", "build"}, Outputs: []string{"/bin/"}},
+	}
+	m := outputMatcher(cfg)
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"api.gen.go", true},
+		{"pkg/api.gen.go", true},
+		{"bin/server", true},
+		{"main.go", false},
+		{"pkg/bin/tool", false},
+	}
+	for _, tt := range tests {
+		if got := m.MatchPath(filepath.Join(dir, filepath.FromSlash(tt.path))); got != tt.want {
+			t.Errorf("MatchPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPipeline_OwnChanges(t *testing.T) {
+	dir := t.TempDir()
+	gen := filepath.Join(dir, "api.gen.go")
+	edit := filepath.Join(dir, "main.go")
+	start := time.Now()
+	end := start.Add(time.Second)
+	during, before, after := start.Add(500*time.Millisecond), start.Add(-time.Second), end.Add(500*time.Millisecond)
+
+	tests := []struct {
+		name            string
+		ignoreDuringRun bool
+		files           []string
+		changed         []time.Time
+		manual          bool
+		noRun           bool
+		want            bool
+		wantFiles       []string
+	}{
+		{name: "outputs written by the run", files: []string{gen}, changed: []time.Time{during}, want: true},
+		{name: "outputs filtered from mixed events", files: []string{gen, edit}, changed: []time.Time{during, during}, wantFiles: []string{edit}},
+		{name: "output changed before the run", files: []string{gen}, changed: []time.Time{before}, wantFiles: []string{gen}},
+		// Flushed together with the run's own changes, but saved after it
+		{name: "output saved after the run", files: []string{gen, edit}, changed: []time.Time{during, after}, wantFiles: []string{edit}},
+		{name: "edit after the run", files: []string{gen}, changed: []time.Time{after}, wantFiles: []string{gen}},
+		{name: "ignore_during_run", ignoreDuringRun: true, files: []string{gen, edit}, changed: []time.Time{during, during}, want: true},
+		{name: "ignore_during_run keeps later edits", ignoreDuringRun: true, files: []string{gen, edit}, changed: []time.Time{during, after}, wantFiles: []string{edit}},
+		{name: "ignore_during_run keeps earlier edits", ignoreDuringRun: true, files: []string{edit}, changed: []time.Time{before}, wantFiles: []string{edit}},
+		{name: "manual trigger", ignoreDuringRun: true, files: []string{gen}, changed: []time.Time{during}, manual: true, wantFiles: []string{gen}},
+		{name: "no run yet", files: []string{gen}, changed: []time.Time{during}, noRun: true, wantFiles: []string{gen}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A long debounce, which doesn't widen what counts as the run
+			cfg := &config.Config{Dir: dir, Debounce: "5s", IgnoreDuringRun: tt.ignoreDuringRun}
+			cfg.OnChange.Commands = []config.Command{{Cmd: []string{"go", "generate"}, Outputs: []string{"*.gen.go"}}}
+			p := &pipeline{cfg: cfg, outputs: outputMatcher(cfg)}
+			if !tt.noRun {
+				p.lastRunStart, p.lastRunEnd = start, end
+			}
+			pe := pipelineEvent{
+				pipeline: p,
+				event: watcher.Event{
+					Path:      tt.files[len(tt.files)-1],
+					Files:     tt.files,
+					Changed:   tt.changed,
+					Timestamp: end.Add(100 * time.Millisecond),
+				},
+				manual: tt.manual,
+			}
+
+			if got := p.ownChanges(&pe); got != tt.want {
+				t.Errorf("ownChanges() = %v, want %v", got, tt.want)
+			}
+			if tt.want {
+				return
+			}
+			if !slices.Equal(pe.event.Files, tt.wantFiles) || pe.event.Path != tt.wantFiles[len(tt.wantFiles)-1] {
+				t.Errorf("event = %s %v, want %v", pe.event.Path, pe.event.Files, tt.wantFiles)
+			}
+			if len(pe.event.Changed) != len(pe.event.Files) {
+				t.Errorf("Changed = %v, want a time per file", pe.event.Changed)
+			}
+		})
+	}
+}
+
+func TestPipeline_OwnChangesRouting(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses true")
+	}
+	dir := t.TempDir()
+	gen := filepath.Join(dir, "bin", "app")
+	edit := filepath.Join(dir, "main.go")
+	start := time.Now()
+	end := start.Add(time.Second)
+
+	cfg := &config.Config{Dir: dir, MaxConcurrency: 1}
+	cfg.OnChange.Commands = []config.Command{
+		{Name: "build", Cmd: []string{"true"}, Outputs: []string{"/bin/"}},
+		{Name: "on create", Cmd: []string{"true"}, Events: []string{"create"}},
+		{Name: "on gen", Cmd: []string{"true"}, WhenTags: []string{"gen"}},
+	}
+
+	tests := []struct {
+		name string
+		run  bool
+		want []int
+	}{
+		// The build created bin/app, but the user only wrote main.go
+		{"output suppressed", true, []int{0}},
+		{"no run yet", false, []int{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPipeline(defaultPipeline, cfg, logger.Discard(), pipelineHooks{})
+			defer p.runner.Close()
+			if tt.run {
+				p.lastRunStart, p.lastRunEnd = start, end
+			}
+			pe := pipelineEvent{
+				pipeline: p,
+				event: watcher.Event{
+					Path:      edit,
+					Op:        "WRITE",
+					Timestamp: end.Add(time.Second),
+					Files:     []string{gen, edit},
+					Changed:   []time.Time{start.Add(500 * time.Millisecond), end.Add(500 * time.Millisecond)},
+					Ops:       []string{"CREATE", "WRITE"},
+					Tags:      []string{"gen", "src"},
+					FileOps:   [][]string{{"CREATE"}, {"WRITE"}},
+					FileTags:  [][]string{{"gen"}, {"src"}},
+				},
+			}
+			if p.ownChanges(&pe) {
+				t.Fatal("ownChanges() = true, want the edit kept")
+			}
+
+			var ran []int
+			for _, r := range p.runner.RunTrigger(context.Background(), newTrigger(pe.event)) {
+				ran = append(ran, r.Index)
+			}
+			slices.Sort(ran)
+			if !slices.Equal(ran, tt.want) {
+				t.Errorf("ran %v, want %v", ran, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"gowatch/pkg/logger"
+)
+
+// pprofAddr is the address of --pprof, "" when disabled
+var pprofAddr string
+
+func init() {
+	runCmd.Flags().StringVar(&pprofAddr, "pprof", "", "serve net/http/pprof on this address for profiling gowatch itself (e.g. localhost:6060)")
+}
+
+// startPprof serves the profiles of net/http/pprof under /debug/pprof/ in
+// the background. Its own mux keeps them off the other servers.
+func startPprof(log *logger.Logger, addr string) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	srv := &http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("pprof server error: %v", err)
+		}
+	}()
+	log.Success("pprof listening on http://%s/debug/pprof/", ln.Addr())
+	return srv, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"gowatch/internal/problems"
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+	"gowatch/pkg/watcher"
+)
+
+// reportProblems writes the problems that command output reports as
+// file:line:col: message lines between a marker at the start and at the
+// end of each run, for gowatch to run as a VS Code background task with a
+// problem matcher
+func reportProblems(log *logger.Logger, sess *session) {
+	if !problemsOn {
+		return
+	}
+	sess.onEvent(func(string, int64, watcher.Event) {
+		log.Plain(problems.BeginMarker)
+	})
+	sess.onOutput(func(task string, t runner.Trigger, line string, isError bool) {
+		if p, ok := problems.Parse(line); ok {
+			log.Plain(p.Resolve(sess.workDir(task)).String())
+		}
+	})
+	sess.onReport(func(runner.Report) {
+		log.Plain(problems.EndMarker)
+	})
+}
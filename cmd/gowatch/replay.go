@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	"gowatch/internal/record"
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	recordTo    string
+	replaySpeed float64
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <events.jsonl> [task[,task...]]",
+	Short: "Run the commands for recorded file changes",
+	Long: `Feed the events recorded with "gowatch run --record" back through the
+runners, spaced as they were recorded, without watching anything.
+
+Every pipeline that recorded events is replayed, or only the named tasks.
+Events go through the same handling as when watching: cooldowns, ignored
+changes made by the last run, and the command's events and match rules.
+The exit code is 1 if any run failed.
+
+Examples:
+  # Record a session, then replay it
+  gowatch run --record events.jsonl
+  gowatch replay events.jsonl
+
+  # Replay ten times faster, or as fast as the commands allow
+  gowatch replay events.jsonl --speed 10
+  gowatch replay events.jsonl --speed 0
+
+  # See what each event would run
+  gowatch replay events.jsonl --dry-run`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: replayEvents,
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	runCmd.Flags().StringVar(&recordTo, "record", "", "record every event the watchers emit to this file, for gowatch replay")
+
+	replayCmd.Flags().StringVarP(&cfgFile, "config", "c", "", "config file path (default: gowatch.yaml, .yml, .toml or .json)")
+	replayCmd.Flags().Float64Var(&replaySpeed, "speed", 1, "replay this many times faster than recorded (0: without waiting)")
+	replayCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would run without executing")
+	addLogFlags(replayCmd)
+	replayCmd.Flags().BoolVar(&sequential, "sequential", false, "run commands sequentially")
+	replayCmd.Flags().BoolVar(&noColor, "no-color", false, "disable colored output")
+	replayCmd.Flags().StringVar(&profile, "profile", "", "activate a profile from the config (default: $GOWATCH_PROFILE)")
+	replayCmd.Flags().BoolVar(&config.NoUserConfig, "no-user-config", false, "ignore the user config in ~/.config/gowatch")
+}
+
+func replayEvents(cmd *cobra.Command, args []string) error {
+	level, _, err := resolveLogLevel()
+	if err != nil {
+		return err
+	}
+	log := logger.New(level, !noColor)
+
+	entries, err := record.Read(args[0])
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no events recorded in %s", args[0])
+	}
+
+	if err := resolveConfigFile(); err != nil {
+		return err
+	}
+	cfg, err := config.LoadProfile(cfgFile, activeProfile())
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Color != nil && !*cfg.Color {
+		log.SetColors(false)
+	}
+
+	tasks := parseTaskArgs(args[1:])
+	if len(tasks) == 0 {
+		for _, e := range entries {
+			if !slices.Contains(tasks, e.Task) {
+				tasks = append(tasks, e.Task)
+			}
+		}
+	}
+	selected, err := replayPipelines(cfg, tasks)
+	if err != nil {
+		return err
+	}
+	var replayed []record.Entry
+	for _, e := range entries {
+		if selected[e.Task] != nil {
+			replayed = append(replayed, e)
+		}
+	}
+	if len(replayed) == 0 {
+		return fmt.Errorf("no events recorded for %s", strings.Join(tasks, ", "))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	if err := runSetup(ctx, log, cfg, selected); err != nil {
+		return err
+	}
+
+	sess := newSession(log, tasks, cancel)
+	sess.ctx, sess.root = ctx, cfg
+	for name, c := range selected {
+		sess.pipelines[name] = newPipeline(name, c, log, sess.hooks())
+	}
+
+	log.Section("Replay")
+	pace := "without waiting"
+	if replaySpeed > 0 {
+		pace = fmt.Sprintf("at %gx speed", replaySpeed)
+	}
+	log.Info("Replaying %d event(s) from %s %s", len(replayed), args[0], pace)
+	if dryRun {
+		log.Warn("DRY RUN MODE - Commands will not be executed")
+	}
+	log.Separator()
+
+	go sess.replay(ctx, replayed, replaySpeed)
+	return sess.loop(ctx, nil)
+}
+
+// replayPipelines resolves the pipelines of recorded events, named like
+// the pipelines of gowatch run
+func replayPipelines(cfg *config.Config, tasks []string) (map[string]*config.Config, error) {
+	selected := make(map[string]*config.Config)
+	for _, name := range tasks {
+		if name == defaultPipeline && (cfg.HasPipeline() || len(cfg.Tasks) == 0) {
+			selected[name] = cfg
+			continue
+		}
+		tc, err := cfg.ForTask(name)
+		if err != nil {
+			return nil, err
+		}
+		selected[name] = tc
+	}
+	return selected, nil
+}
+
+// replay feeds recorded events to their pipelines, spaced as recorded
+// divided by speed, and stops the session once the loop has handled them
+func (s *session) replay(ctx context.Context, entries []record.Entry, speed float64) {
+	// The pipelines don't change during a replay
+	events := make([]pipelineEvent, len(entries))
+	for i, e := range entries {
+		events[i] = pipelineEvent{pipeline: s.pipelines[e.Task], event: e.Event()}
+	}
+
+	for i, wait := range record.Pace(entries, speed) {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+		// Runs compare event times with when the last run ended
+		events[i].event.Timestamp = time.Now()
+		select {
+		case s.events <- events[i]:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	// The loop has received the last event and handles it before this
+	s.do(ctx, func() {
+		s.log.Info("")
+		s.log.Success("Replayed %d event(s)", len(entries))
+		s.finish()
+	})
+}
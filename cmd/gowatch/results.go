@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gowatch/pkg/runner"
+)
+
+// resultRecord is one line of the --results-json stream, written as each
+// command finishes
+type resultRecord struct {
+	Task       string    `json:"task"`
+	RunID      int64     `json:"run_id"`
+	Command    []string  `json:"command"`
+	ExitCode   int       `json:"exit_code"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	TimedOut   bool      `json:"timed_out,omitempty"`
+	Canceled   bool      `json:"canceled,omitempty"`
+	Attempts   int       `json:"attempts,omitempty"`
+	Duration   string    `json:"duration"`
+	DurationMS int64     `json:"duration_ms"`
+	Event      string    `json:"event"`
+	Path       string    `json:"path,omitempty"`
+	Files      []string  `json:"files,omitempty"`
+	Triggered  time.Time `json:"triggered"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+}
+
+// resultsWriter writes command results as newline-delimited JSON
+type resultsWriter struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// openResults opens the target of --results-json: a file descriptor
+// inherited from the parent process if target is a number, a file to append
+// to otherwise
+func openResults(target string) (*resultsWriter, error) {
+	var f *os.File
+	if fd, err := strconv.Atoi(target); err == nil {
+		f = os.NewFile(uintptr(fd), "fd "+target)
+		if f == nil {
+			return nil, fmt.Errorf("invalid file descriptor %s", target)
+		}
+		if _, err := f.Stat(); err != nil {
+			return nil, fmt.Errorf("file descriptor %s is not open", target)
+		}
+	} else {
+		f, err = os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open results file: %w", err)
+		}
+	}
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	return &resultsWriter{file: f, enc: enc}, nil
+}
+
+// write appends the result of one command. It is called from runner
+// goroutines concurrently.
+func (w *resultsWriter) write(task string, t runner.Trigger, r runner.RunResult) error {
+	finished := time.Now()
+	record := resultRecord{
+		Task:       task,
+		RunID:      t.RunID,
+		Command:    r.Command,
+		ExitCode:   r.ExitCode,
+		Success:    r.ExitCode == 0,
+		TimedOut:   r.TimedOut,
+		Canceled:   r.Canceled,
+		Attempts:   r.Attempts,
+		Duration:   r.Duration.String(),
+		DurationMS: r.Duration.Milliseconds(),
+		Event:      t.Event,
+		Path:       t.Path,
+		Files:      t.Files,
+		Triggered:  t.Time,
+		Started:    finished.Add(-r.Duration),
+		Finished:   finished,
+	}
+	if r.Error != nil {
+		record.Error = r.Error.Error()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(record)
+}
+
+func (w *resultsWriter) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gowatch/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+var schemaOut string
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the config format",
+	Long: `Print the JSON Schema of gowatch.yaml, for editors to validate and
+complete the config as it is written.
+
+Examples:
+  # Point the YAML language server at a local copy
+  gowatch schema -o gowatch.schema.json
+  # then start gowatch.yaml with:
+  # yaml-language-server: $schema=./gowatch.schema.json`,
+	Args:          cobra.NoArgs,
+	RunE:          printSchema,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+	schemaCmd.Flags().StringVarP(&schemaOut, "output", "o", "", "write the schema to this file instead of stdout")
+}
+
+func printSchema(cmd *cobra.Command, args []string) error {
+	schema, err := config.Schema()
+	if err != nil {
+		return err
+	}
+	schema = append(schema, '\n')
+
+	if schemaOut == "" {
+		_, err := os.Stdout.Write(schema)
+		return err
+	}
+	if err := os.WriteFile(schemaOut, schema, 0644); err != nil {
+		return fmt.Errorf("failed to write schema: %w", err)
+	}
+	return nil
+}
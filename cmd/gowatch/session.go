@@ -0,0 +1,842 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gowatch/internal/api"
+	"gowatch/internal/notify"
+	"gowatch/internal/webhook"
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+	"gowatch/pkg/watcher"
+)
+
+// exitTimeout bounds how long the on_exit hooks may run on shutdown
+const exitTimeout = time.Minute
+
+// session owns the pipelines of a `gowatch run` invocation and the event
+// loop that drives them. All pipeline state is confined to the loop
+// goroutine; other goroutines (API handlers, signal handlers) act on it by
+// submitting functions through control.
+type session struct {
+	ctx   context.Context
+	log   *logger.Logger
+	tasks []string
+	// quit stops the session; err is then what loop returns
+	quit context.CancelFunc
+	err  error
+
+	// root is the loaded config, whose top-level on_exit is run on shutdown
+	// when only tasks are running
+	root *config.Config
+	// file is the config file, which load loads again when it changes
+	file string
+	load func() (*config.Config, error)
+	// keys is the keyboard, lent to interactive commands
+	keys *keyboard
+
+	// events is unbuffered: changes wait in the queues of the watchers,
+	// which apply queue_overflow when the loop falls behind
+	events    chan pipelineEvent
+	control   chan func()
+	reporters []func(runner.Report)
+	// eventHandlers, startHandlers, resultHandlers, outputHandlers and
+	// pauseHandlers are registered before the loop starts and only read
+	// afterwards; watchHandlers before the pipelines start
+	watchHandlers  []func(task string, ev watcher.Event)
+	eventHandlers  []func(task string, runID int64, ev watcher.Event)
+	startHandlers  []func(task string, t runner.Trigger, command []string)
+	resultHandlers []func(task string, t runner.Trigger, r runner.RunResult)
+	outputHandlers []func(task string, t runner.Trigger, line string, isError bool)
+	pauseHandlers  []func(paused bool)
+
+	started time.Time
+	summary sessionSummary
+	// deadline stops the session once --for has elapsed
+	deadline *time.Timer
+	// lastEvent is the event of the most recent run, for re-running it
+	lastEvent *pipelineEvent
+
+	// mu guards the fields below so that Status can read them while the
+	// loop is busy running commands. Only the loop writes pipelines,
+	// paused, stats, last and lastChange, always holding mu, so the loop
+	// itself reads them without it.
+	mu         sync.Mutex
+	pipelines  map[string]*pipeline
+	paused     bool
+	stats      sessionStats
+	last       *runner.Report
+	lastChange *api.Event
+	running    map[runningKey]api.RunningCommand
+}
+
+// runningKey identifies an executing command
+type runningKey struct {
+	task    string
+	runID   int64
+	command string
+}
+
+// sessionStats counts activity over the lifetime of a session
+type sessionStats struct {
+	Events   int
+	Runs     int
+	Failures int
+}
+
+func newSession(log *logger.Logger, tasks []string, quit context.CancelFunc) *session {
+	return &session{
+		log:       log,
+		tasks:     tasks,
+		quit:      quit,
+		file:      cfgFile,
+		load:      func() (*config.Config, error) { return config.LoadProfile(cfgFile, activeProfile()) },
+		keys:      &keyboard{},
+		events:    make(chan pipelineEvent),
+		control:   make(chan func()),
+		pipelines: make(map[string]*pipeline),
+		running:   make(map[runningKey]api.RunningCommand),
+		started:   time.Now(),
+	}
+}
+
+// start launches a pipeline for each config selected from root
+func (s *session) start(ctx context.Context, root *config.Config, selected map[string]*config.Config) error {
+	s.ctx = ctx
+	s.root = root
+	for _, name := range sortedNames(selected) {
+		p, err := startPipeline(ctx, name, selected[name], s.log, s.events, s.hooks(), nil)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.pipelines[name] = p
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+func (s *session) hooks() pipelineHooks {
+	return pipelineHooks{onWatch: s.watched, onStart: s.commandStarted, onResult: s.publishResult, onOutput: s.publishOutput, terminal: s.keys.lend}
+}
+
+// onReport registers a function called with the report of every run
+func (s *session) onReport(fn func(runner.Report)) {
+	s.reporters = append(s.reporters, fn)
+}
+
+// onWatch registers a function called with every event a watcher emits,
+// including those that start no run. It is called from the goroutines of
+// the watchers concurrently.
+func (s *session) onWatch(fn func(task string, ev watcher.Event)) {
+	s.watchHandlers = append(s.watchHandlers, fn)
+}
+
+// onEvent registers a function called with the event that starts each run
+func (s *session) onEvent(fn func(task string, runID int64, ev watcher.Event)) {
+	s.eventHandlers = append(s.eventHandlers, fn)
+}
+
+// onStart registers a function called as each command starts, with its
+// expanded command line. It may be called from runner goroutines
+// concurrently.
+func (s *session) onStart(fn func(task string, t runner.Trigger, command []string)) {
+	s.startHandlers = append(s.startHandlers, fn)
+}
+
+// onResult registers a function called as each command finishes. It may be
+// called from runner goroutines concurrently.
+func (s *session) onResult(fn func(task string, t runner.Trigger, r runner.RunResult)) {
+	s.resultHandlers = append(s.resultHandlers, fn)
+}
+
+// onOutput registers a function called with each line of command output.
+// It is called from runner goroutines concurrently.
+func (s *session) onOutput(fn func(task string, t runner.Trigger, line string, isError bool)) {
+	s.outputHandlers = append(s.outputHandlers, fn)
+}
+
+// onPause registers a function called when watching is paused or resumed
+func (s *session) onPause(fn func(paused bool)) {
+	s.pauseHandlers = append(s.pauseHandlers, fn)
+}
+
+func (s *session) watched(task string, ev watcher.Event) {
+	for _, fn := range s.watchHandlers {
+		fn(task, ev)
+	}
+}
+
+// commandStarted records an executing command for Status
+func (s *session) commandStarted(task string, t runner.Trigger, command []string) {
+	s.mu.Lock()
+	key := runningKey{task: task, runID: t.RunID, command: strings.Join(command, "\x00")}
+	s.running[key] = api.RunningCommand{Task: task, RunID: t.RunID, Command: command, Started: time.Now()}
+	s.mu.Unlock()
+
+	for _, fn := range s.startHandlers {
+		fn(task, t, command)
+	}
+}
+
+func (s *session) publishResult(task string, t runner.Trigger, r runner.RunResult) {
+	s.mu.Lock()
+	delete(s.running, runningKey{task: task, runID: t.RunID, command: strings.Join(r.Command, "\x00")})
+	s.mu.Unlock()
+
+	for _, fn := range s.resultHandlers {
+		fn(task, t, r)
+	}
+}
+
+func (s *session) publishOutput(task string, t runner.Trigger, line string, isError bool) {
+	for _, fn := range s.outputHandlers {
+		fn(task, t, line, isError)
+	}
+}
+
+// loop processes events until the context is cancelled
+func (s *session) loop(ctx context.Context, reloads <-chan struct{}) error {
+	defer func() {
+		if s.deadline != nil {
+			s.deadline.Stop()
+		}
+		for _, p := range s.pipelines {
+			if p.cooldown != nil {
+				p.cooldown.Stop()
+			}
+			p.runner.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.log.Info("")
+			s.log.Section("Shutdown")
+			s.exit()
+			s.summary.print(s.log, s.stats, time.Since(s.started))
+			s.log.Success("Shutdown complete")
+			return s.err
+
+		case fn := <-s.control:
+			fn()
+
+		case <-reloads:
+			s.log.Watch("Configuration changed: %s", s.file)
+			if err := s.reload(ctx); err != nil {
+				s.log.Error("Reload failed, keeping previous configuration: %v", err)
+				continue
+			}
+			s.log.Success("Configuration reloaded (%d pipeline(s))", len(s.pipelines))
+
+		case pe := <-s.events:
+			// Stopping, e.g. after --max-failures
+			if pe.pipeline.retired || ctx.Err() != nil {
+				continue
+			}
+			if s.paused && !pe.manual {
+				s.log.Debug("Paused, ignoring: %s %s", pe.event.Op, pe.event.Path)
+				continue
+			}
+			if pe.cooldown {
+				s.runPending(ctx, pe)
+				continue
+			}
+			if pe.pipeline.ownChanges(&pe) {
+				s.log.Debug("Ignoring changes made by the last run: %s %s", pe.event.Op, pe.event.Path)
+				continue
+			}
+
+			s.mu.Lock()
+			s.stats.Events++
+			s.mu.Unlock()
+			s.run(ctx, pe)
+		}
+	}
+}
+
+// run executes a pipeline's commands for one event and reports the outcome
+func (s *session) run(ctx context.Context, pe pipelineEvent) {
+	trigger := newTrigger(pe.event)
+	trigger.RunID = runner.NextRunID()
+	s.runTrigger(ctx, pe, trigger)
+}
+
+// newTrigger describes a watcher event to the runner
+func newTrigger(ev watcher.Event) runner.Trigger {
+	return runner.Trigger{
+		Path:  ev.Path,
+		Event: ev.Op,
+		Files: ev.Files,
+		Ops:   ev.Ops,
+		Tags:  ev.Tags,
+		Time:  ev.Timestamp,
+	}
+}
+
+// runPending runs the changes that command cooldowns held back, once the
+// cooldowns have ended
+func (s *session) runPending(ctx context.Context, pe pipelineEvent) {
+	trigger, ok := pe.pipeline.runner.TakePending()
+	if !ok {
+		s.scheduleCooldown(pe.pipeline)
+		return
+	}
+	trigger.RunID = runner.NextRunID()
+	pe.event = watcher.Event{
+		Path:      trigger.Path,
+		Op:        trigger.Event,
+		Files:     trigger.Files,
+		Ops:       trigger.Ops,
+		Tags:      trigger.Tags,
+		Timestamp: trigger.Time,
+	}
+	s.runTrigger(ctx, pe, trigger)
+}
+
+// workDir returns the directory the commands of a task run in, or "" if it
+// isn't running
+func (s *session) workDir(task string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.pipelines[task]
+	if p == nil {
+		return ""
+	}
+	dir, _ := p.cfg.WorkDir()
+	return dir
+}
+
+// commandNames returns the labels of a pipeline's on_change commands by
+// index. It must be called on the loop goroutine.
+func (s *session) commandNames(task string) []string {
+	p := s.pipelines[task]
+	if p == nil {
+		return nil
+	}
+	names := make([]string, len(p.cfg.OnChange.Commands))
+	for i, c := range p.cfg.OnChange.Commands {
+		names[i] = commandLabel(c)
+	}
+	return names
+}
+
+// restarts reports whether a pipeline has mode: restart commands. It must be
+// called on the loop goroutine.
+func (s *session) restarts(task string) bool {
+	p := s.pipelines[task]
+	return p != nil && slices.ContainsFunc(p.cfg.OnChange.Commands, config.Command.IsRestart)
+}
+
+// runTrigger runs a pipeline for a trigger and reports the outcome
+func (s *session) runTrigger(ctx context.Context, pe pipelineEvent, trigger runner.Trigger) {
+	if pe.pipeline.cfg.Clear && !useTUI {
+		clearTerminal()
+	}
+	if len(s.pipelines) > 1 {
+		s.log.Runner("Task: %s", pe.pipeline.name)
+	}
+	if pe.cooldown {
+		s.log.Runner("Cooldown over, running held back changes")
+	}
+
+	s.mu.Lock()
+	s.lastChange = &api.Event{
+		Task:  pe.pipeline.name,
+		Path:  pe.event.Path,
+		Op:    pe.event.Op,
+		Files: pe.event.Files,
+		Time:  pe.event.Timestamp,
+	}
+	s.mu.Unlock()
+	for _, fn := range s.eventHandlers {
+		fn(pe.pipeline.name, trigger.RunID, pe.event)
+	}
+
+	start := time.Now()
+	results := pe.pipeline.runner.RunTrigger(ctx, trigger)
+	report := runner.Report{
+		Task:     pe.pipeline.name,
+		Trigger:  trigger,
+		Start:    start,
+		Duration: time.Since(start),
+		Results:  results,
+	}
+
+	pe.pipeline.lastRunStart, pe.pipeline.lastRunEnd = start, time.Now()
+	s.lastEvent = &pe
+	s.mu.Lock()
+	s.stats.Runs++
+	if !report.Success() {
+		s.stats.Failures++
+	}
+	s.last = &report
+	s.mu.Unlock()
+	s.summary.add(report, s.commandNames(report.Task))
+	if !report.Success() && !dryRun {
+		s.log.Error("Execution completed with errors")
+	}
+	s.countFailure(ctx, pe.pipeline, report)
+
+	if pe.pipeline.cfg.Notify == config.NotifyDesktop && !dryRun {
+		go s.notify(report)
+	}
+	if !dryRun {
+		s.ring(ctx, pe.pipeline, report)
+	}
+	if len(pe.pipeline.cfg.Webhooks) > 0 && !dryRun {
+		go s.sendWebhooks(pe.pipeline.cfg.Webhooks, report)
+	}
+
+	for _, fn := range s.reporters {
+		fn(report)
+	}
+	s.scheduleCooldown(pe.pipeline)
+}
+
+// stopAfter stops the session once d has elapsed (--for). It must be called
+// before the loop starts.
+func (s *session) stopAfter(ctx context.Context, d time.Duration) {
+	s.deadline = time.AfterFunc(d, func() { s.do(ctx, s.timeUp) })
+}
+
+// timeUp stops the session once --for has elapsed. It runs on the loop, so
+// a run in progress is finished first. The session fails if any run failed.
+func (s *session) timeUp() {
+	s.log.Info("")
+	s.log.Warn("Stopping after %s (--for)", runFor)
+	s.finish()
+}
+
+// finish stops the session, failing it if any run failed
+func (s *session) finish() {
+	if s.deadline != nil {
+		s.deadline.Stop()
+	}
+	if s.err == nil && s.stats.Failures > 0 {
+		s.err = exitCodeError{code: 1, msg: fmt.Sprintf("%d of %d run(s) failed", s.stats.Failures, s.stats.Runs)}
+	}
+	s.quit()
+}
+
+// countFailure tracks the failures of a pipeline in a row and stops the
+// session once there are --max-failures of them. Runs interrupted by
+// shutdown don't count.
+func (s *session) countFailure(ctx context.Context, p *pipeline, report runner.Report) {
+	if maxFails <= 0 || ctx.Err() != nil {
+		return
+	}
+	if report.Success() {
+		p.failures = 0
+		return
+	}
+	p.failures++
+	if p.failures < maxFails {
+		return
+	}
+
+	what := "the pipeline"
+	if p.name != defaultPipeline {
+		what = fmt.Sprintf("task %q", p.name)
+	}
+	msg := fmt.Sprintf("%s failed %d times in a row", what, p.failures)
+	s.log.Error("Stopping: %s (--max-failures)", msg)
+	s.err = exitCodeError{code: 1, msg: msg}
+	s.quit()
+}
+
+// scheduleCooldown queues a run for when the earliest command cooldown of
+// the pipeline with held back changes ends
+func (s *session) scheduleCooldown(p *pipeline) {
+	at, ok := p.runner.NextPending()
+	if !ok {
+		return
+	}
+	if p.cooldown != nil {
+		p.cooldown.Stop()
+	}
+	p.cooldown = time.AfterFunc(time.Until(at), func() {
+		s.send(pipelineEvent{pipeline: p, event: watcher.Event{Op: "COOLDOWN"}, cooldown: true})
+	})
+}
+
+// notify sends a desktop notification for a finished run
+func (s *session) notify(report runner.Report) {
+	if err := notify.Send(notify.ForReport(report)); err != nil {
+		s.log.Warn("Notification failed: %v", err)
+	}
+}
+
+// ring sounds the bell for a run that failed, or that succeeded after a
+// failure with bell: change. Runs interrupted by shutdown don't ring.
+func (s *session) ring(ctx context.Context, p *pipeline, report runner.Report) {
+	recovered := report.Success() && p.failed
+	p.failed = !report.Success()
+	if p.cfg.Bell == "" || ctx.Err() != nil {
+		return
+	}
+	sound := p.cfg.BellSound
+	if recovered {
+		if p.cfg.Bell != config.BellChange {
+			return
+		}
+		sound = p.cfg.BellRecoverySound
+	} else if report.Success() {
+		return
+	}
+	if sound == "" {
+		fmt.Fprint(os.Stdout, "\a")
+		return
+	}
+	go func() {
+		if err := notify.PlaySound(sound); err != nil {
+			s.log.Warn("Bell failed: %v", err)
+		}
+	}()
+}
+
+// sendWebhooks posts a finished run to the webhooks that want it
+func (s *session) sendWebhooks(hooks []config.Webhook, report runner.Report) {
+	payload := webhook.NewPayload(report)
+	for _, hook := range hooks {
+		if !hook.Wants(report.Success()) {
+			continue
+		}
+		if err := webhook.Send(context.Background(), hook, payload); err != nil {
+			s.log.Warn("Webhook failed: %v", err)
+			continue
+		}
+		s.log.Debug("Webhook sent: %s", webhook.Redact(hook.URL))
+	}
+}
+
+// do runs fn on the loop goroutine and waits for it to finish
+func (s *session) do(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	select {
+	case s.control <- func() { fn(); close(done) }:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// trigger queues a manual run of one task, or of every pipeline when task
+// is empty
+func (s *session) trigger(task string) error {
+	var targets []*pipeline
+	if task == "" {
+		for _, name := range s.pipelineNames() {
+			targets = append(targets, s.pipelines[name])
+		}
+	} else {
+		p, ok := s.pipelines[task]
+		if !ok {
+			return fmt.Errorf("task %q is not running", task)
+		}
+		targets = append(targets, p)
+	}
+
+	// Queue without blocking the loop, which is the events consumer
+	go func() {
+		for _, p := range targets {
+			if !s.send(pipelineEvent{
+				pipeline: p,
+				event:    watcher.Event{Op: "MANUAL", Timestamp: time.Now()},
+				manual:   true,
+			}) {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// runOnStart queues a run of every pipeline configured with run_on_start
+func (s *session) runOnStart() {
+	var targets []*pipeline
+	for _, name := range s.pipelineNames() {
+		if p := s.pipelines[name]; p.cfg.RunOnStart {
+			targets = append(targets, p)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	go func() {
+		for _, p := range targets {
+			if !s.send(pipelineEvent{
+				pipeline: p,
+				event:    watcher.Event{Op: "STARTUP", Timestamp: time.Now()},
+				manual:   true,
+			}) {
+				return
+			}
+		}
+	}()
+}
+
+// rerun queues the most recent run again, or every pipeline if nothing has
+// run yet
+func (s *session) rerun() {
+	if s.lastEvent == nil || s.lastEvent.pipeline.retired {
+		s.trigger("")
+		return
+	}
+
+	pe := *s.lastEvent
+	pe.manual = true
+	go s.send(pe)
+}
+
+// send queues an event for the loop, giving up once the session stops. It
+// blocks until the loop takes the event, so it must not run on the loop.
+func (s *session) send(pe pipelineEvent) bool {
+	select {
+	case s.events <- pe:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// setPaused pauses or resumes reacting to file changes
+func (s *session) setPaused(paused bool) {
+	if s.paused == paused {
+		return
+	}
+	s.mu.Lock()
+	s.paused = paused
+	s.mu.Unlock()
+	if paused {
+		s.log.Warn("Watching paused")
+	} else {
+		s.log.Success("Watching resumed")
+	}
+	for _, fn := range s.pauseHandlers {
+		fn(paused)
+	}
+}
+
+// reload reloads the config file and swaps in new watchers. The swap only
+// happens once every new watcher has started; pipelines that survive the
+// reload keep their runners, so in-flight processes continue, and the state
+// of their past runs.
+func (s *session) reload(ctx context.Context) error {
+	newCfg, err := s.load()
+	if err == nil {
+		err = applyFlagOverrides(newCfg)
+	}
+	if err != nil {
+		return err
+	}
+
+	selected, err := selectPipelines(newCfg, s.tasks)
+	if err != nil {
+		return err
+	}
+
+	started := make(map[string]*pipeline)
+	for name, cfg := range selected {
+		p, err := startPipeline(ctx, name, cfg, s.log, s.events, s.hooks(), s.pipelines[name])
+		if err != nil {
+			for _, p := range started {
+				p.stop()
+			}
+			return err
+		}
+		started[name] = p
+	}
+
+	// Swap the pipelines, then wait for the old ones to stop without
+	// holding up Status
+	s.mu.Lock()
+	s.root = newCfg
+	retired := s.pipelines
+	s.pipelines = started
+	s.mu.Unlock()
+
+	for name, old := range retired {
+		old.stop()
+		old.retired = true
+		if old.cooldown != nil {
+			old.cooldown.Stop()
+		}
+
+		if p, ok := started[name]; ok {
+			old.runner.Reload(p.cfg)
+			s.scheduleCooldown(p)
+		} else {
+			old.runner.Close()
+		}
+	}
+
+	return nil
+}
+
+// exit stops the long-running commands of every pipeline and then runs the
+// on_exit hooks: those of each pipeline and, if the top-level pipeline isn't
+// running, the top-level ones. They get exitTimeout in all.
+func (s *session) exit() {
+	names := s.pipelineNames()
+	for _, name := range names {
+		s.pipelines[name].runner.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exitTimeout)
+	defer cancel()
+	for _, name := range names {
+		s.pipelines[name].runner.Exit(ctx)
+	}
+	if _, ok := s.pipelines[defaultPipeline]; !ok && s.root != nil && len(s.root.OnExit) > 0 {
+		runner.New(s.root, runner.Options{Logger: s.log, DryRun: dryRun}).Exit(ctx)
+	}
+	if ctx.Err() != nil {
+		s.log.Warn("on_exit hooks did not finish within %s", exitTimeout)
+	}
+}
+
+// pipelineNames returns the names of running pipelines in sorted order
+func (s *session) pipelineNames() []string {
+	cfgs := make(map[string]*config.Config, len(s.pipelines))
+	for name, p := range s.pipelines {
+		cfgs[name] = p.cfg
+	}
+	return sortedNames(cfgs)
+}
+
+// Status implements api.Controller. It doesn't go through the loop so that
+// it answers while commands are running.
+func (s *session) Status() api.Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := api.Status{
+		PID:       os.Getpid(),
+		Paused:    s.paused,
+		StartedAt: s.started,
+		Uptime:    time.Since(s.started).Round(time.Second).String(),
+		Tasks:     s.pipelineNames(),
+		Events:    s.stats.Events,
+		Runs:      s.stats.Runs,
+		Failures:  s.stats.Failures,
+		LastEvent: s.lastChange,
+		Running:   s.runningCommands(),
+	}
+	ws := s.watcherStats()
+	status.WatchedDirs = ws.Watched
+	status.Watcher = api.WatcherStats{
+		Received:   ws.Received,
+		Ignored:    ws.Ignored,
+		Filtered:   ws.Filtered,
+		Debounced:  ws.Debounced,
+		Emitted:    ws.Emitted,
+		Overflowed: ws.Overflowed,
+	}
+	status.Goroutines = runtime.NumGoroutine()
+	status.Roots = s.watchRoots()
+	if s.last != nil {
+		last := api.NewResult(*s.last)
+		status.LastRun = &last
+	}
+	return status
+}
+
+// runningCommands returns the executing commands, oldest first. s.mu must
+// be held.
+func (s *session) runningCommands() []api.RunningCommand {
+	running := []api.RunningCommand{}
+	for _, c := range s.running {
+		c.Elapsed = time.Since(c.Started).Round(time.Millisecond).String()
+		running = append(running, c)
+	}
+	slices.SortFunc(running, func(a, b api.RunningCommand) int {
+		return a.Started.Compare(b.Started)
+	})
+	return running
+}
+
+// watcherStats sums the stats of the running pipelines' watchers. s.mu
+// must be held.
+func (s *session) watcherStats() watcher.Stats {
+	var stats watcher.Stats
+	for _, p := range s.pipelines {
+		// Replayed pipelines have no watcher
+		if p.watcher != nil {
+			stats = stats.Add(p.watcher.Stats())
+		}
+	}
+	return stats
+}
+
+// watchRoots returns the stats of the local watch paths of the running
+// pipelines, by task. s.mu must be held.
+func (s *session) watchRoots() []api.WatchRoot {
+	var roots []api.WatchRoot
+	for _, name := range s.pipelineNames() {
+		p := s.pipelines[name]
+		if p.watcher == nil {
+			continue
+		}
+		for _, rs := range p.watcher.RootStats() {
+			roots = append(roots, api.WatchRoot{
+				Task:      name,
+				Path:      rs.Path,
+				Watched:   rs.Watched,
+				Files:     rs.Files,
+				Events:    rs.Events,
+				Ignored:   rs.Ignored,
+				IgnoredBy: rs.IgnoredBy,
+			})
+		}
+	}
+	return roots
+}
+
+// WatchedPaths implements api.Controller
+func (s *session) WatchedPaths() []api.WatchedPath {
+	var paths []api.WatchedPath
+	s.do(s.ctx, func() {
+		for _, name := range s.pipelineNames() {
+			cfg := s.pipelines[name].cfg
+			for _, w := range cfg.Watch {
+				paths = append(paths, api.WatchedPath{
+					Task:      name,
+					Path:      w.Location(),
+					Recursive: w.Recursive,
+					Backend:   cfg.BackendFor(w),
+				})
+			}
+		}
+	})
+	return paths
+}
+
+// Trigger implements api.Controller
+func (s *session) Trigger(task string) error {
+	var err error
+	if doErr := s.do(s.ctx, func() { err = s.trigger(task) }); doErr != nil {
+		return doErr
+	}
+	return err
+}
+
+// SetPaused implements api.Controller
+func (s *session) SetPaused(paused bool) {
+	s.do(s.ctx, func() { s.setPaused(paused) })
+}
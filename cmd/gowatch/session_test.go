@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+	"gowatch/pkg/watcher"
+)
+
+// testSession creates a session whose quit cancels the returned context
+func testSession(t *testing.T) (*session, context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s := newSession(logger.Discard(), nil, cancel)
+	s.ctx = ctx
+	return s, ctx
+}
+
+// fakePipeline creates a pipeline whose command exits with the status set
+// by the returned function
+func fakePipeline(t *testing.T, name string) (*pipeline, func(status string)) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	status := filepath.Join(t.TempDir(), "status")
+	set := func(code string) {
+		if err := os.WriteFile(status, []byte(code), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set("0")
+
+	cfg := &config.Config{MaxConcurrency: 1}
+	cfg.OnChange.Commands = []config.Command{{Cmd: []string{"sh", "-c", "exit $(cat '" + status + "')"}}}
+	p := newPipeline(name, cfg, logger.Discard(), pipelineHooks{})
+	t.Cleanup(func() { p.runner.Close() })
+	return p, set
+}
+
+// runOnce runs a pipeline as the loop does for a change
+func runOnce(ctx context.Context, s *session, p *pipeline) {
+	s.runTrigger(ctx, pipelineEvent{pipeline: p, event: watcher.Event{Op: "WRITE", Path: "main.go"}}, runner.Trigger{Event: "WRITE", Path: "main.go"})
+}
+
+func TestSession_MaxFailures(t *testing.T) {
+	t.Cleanup(func() { maxFails = 0 })
+	maxFails = 2
+
+	s, ctx := testSession(t)
+	p, set := fakePipeline(t, "api")
+
+	// A success in between starts the count again
+	for _, status := range []string{"1", "0", "1"} {
+		set(status)
+		runOnce(ctx, s, p)
+	}
+	if ctx.Err() != nil || s.err != nil {
+		t.Fatalf("session stopped after failures that weren't in a row: %v", s.err)
+	}
+	if p.failures != 1 {
+		t.Errorf("failures = %d, want 1", p.failures)
+	}
+
+	runOnce(ctx, s, p)
+	if ctx.Err() == nil {
+		t.Fatal("session still running after --max-failures failures in a row")
+	}
+	var exitErr exitCodeError
+	if !errors.As(s.err, &exitErr) || exitErr.code != 1 || exitErr.msg != `task "api" failed 2 times in a row` {
+		t.Errorf("err = %#v, want exit code 1 for task \"api\"", s.err)
+	}
+	if s.stats.Runs != 4 || s.stats.Failures != 3 {
+		t.Errorf("stats = %+v, want 4 runs and 3 failures", s.stats)
+	}
+}
+
+func TestSession_MaxFailuresShutdown(t *testing.T) {
+	t.Cleanup(func() { maxFails = 0 })
+	maxFails = 1
+
+	s, ctx := testSession(t)
+	p, set := fakePipeline(t, defaultPipeline)
+	set("1")
+
+	// Runs interrupted by shutdown don't count
+	stopped, cancel := context.WithCancel(ctx)
+	cancel()
+	runOnce(stopped, s, p)
+	if p.failures != 0 || s.err != nil || ctx.Err() != nil {
+		t.Errorf("interrupted run counted: failures = %d, err = %v", p.failures, s.err)
+	}
+}
+
+func TestSession_Finish(t *testing.T) {
+	tests := []struct {
+		name     string
+		runs     []string
+		maxFails int
+		timeUp   bool
+		wantErr  string
+	}{
+		{name: "no runs"},
+		{name: "all runs succeeded", runs: []string{"0", "0"}},
+		{name: "a run failed", runs: []string{"1", "0"}, wantErr: "1 of 2 run(s) failed"},
+		{name: "--for with a failed run", runs: []string{"0", "1", "1"}, timeUp: true, wantErr: "2 of 3 run(s) failed"},
+		{name: "--for after --max-failures", runs: []string{"1"}, maxFails: 1, timeUp: true, wantErr: "the pipeline failed 1 times in a row"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { maxFails = 0 })
+			maxFails = tt.maxFails
+
+			s, ctx := testSession(t)
+			p, set := fakePipeline(t, defaultPipeline)
+			for _, status := range tt.runs {
+				set(status)
+				runOnce(ctx, s, p)
+			}
+			if tt.timeUp {
+				s.timeUp()
+			} else {
+				s.finish()
+			}
+
+			if ctx.Err() == nil {
+				t.Error("session still running")
+			}
+			if tt.wantErr == "" {
+				if s.err != nil {
+					t.Errorf("err = %v, want nil", s.err)
+				}
+				return
+			}
+			var exitErr exitCodeError
+			if !errors.As(s.err, &exitErr) || exitErr.code != 1 || exitErr.msg != tt.wantErr {
+				t.Errorf("err = %#v, want exit code 1: %s", s.err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSession_Reload(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "gowatch.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	task := func(name, cmd string) string {
+		return "  " + name + ":\n    watch:\n      - path: " + filepath.ToSlash(dir) + "\n    on_change:\n      commands:\n        - cmd: [\"sh\", \"-c\", \"" + cmd + "\"]\n"
+	}
+	write("tasks:\n" + task("api", "true") + task("web", "true"))
+
+	s, ctx := testSession(t)
+	s.file = file
+	s.load = func() (*config.Config, error) { return config.Load(file) }
+	cfg, err := s.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	selected, err := selectPipelines(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.start(ctx, cfg, selected); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, p := range s.pipelines {
+			p.stop()
+			p.runner.Close()
+		}
+	})
+	oldAPI, oldWeb := s.pipelines["api"], s.pipelines["web"]
+
+	// web is removed, docs added and api changed
+	write("tasks:\n" + task("api", "exit 1") + task("docs", "true"))
+	if err := s.reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.pipelineNames(); !slices.Equal(got, []string{"api", "docs"}) {
+		t.Fatalf("pipelines = %v, want [api docs]", got)
+	}
+	if !oldAPI.retired || !oldWeb.retired {
+		t.Error("replaced pipelines not retired")
+	}
+	api := s.pipelines["api"]
+	if api == oldAPI || api.runner != oldAPI.runner {
+		t.Error("api didn't keep its runner across the reload")
+	}
+	if got := api.cfg.OnChange.Commands[0].Cmd[2]; got != "exit 1" {
+		t.Errorf("api command = %q, want the reloaded one", got)
+	}
+	if _, ok := s.root.Tasks["docs"]; !ok {
+		t.Error("root config not replaced")
+	}
+
+	// A broken config keeps the running pipelines
+	write("tasks: [")
+	if err := s.reload(ctx); err == nil {
+		t.Error("reload() of a broken config succeeded")
+	}
+	if s.pipelines["api"] != api {
+		t.Error("pipelines replaced by a failed reload")
+	}
+}
+
+func TestSession_StopAfter(t *testing.T) {
+	s, ctx := testSession(t)
+	s.stopAfter(ctx, 10*time.Millisecond)
+	done := make(chan error, 1)
+	go func() { done <- s.loop(ctx, nil) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("loop() = %v, want nil without failed runs", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("session still running after --for")
+	}
+
+	// Stopping otherwise stops the timer
+	s, ctx = testSession(t)
+	s.stopAfter(ctx, time.Hour)
+	s.finish()
+	if s.deadline.Stop() {
+		t.Error("finish() left the --for timer running")
+	}
+}
+
+func TestSession_ReloadStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "gowatch.yaml")
+	write := func(cmd string) {
+		content := "watch:\n  - path: " + filepath.ToSlash(dir) + "\non_change:\n  commands:\n" +
+			"    - cmd: [\"sh\", \"-c\", \"" + cmd + "\"]\n      mode: restart\n      kill_grace: 1s\n"
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A server slow to stop, which holds up the reload
+	write("trap '' INT TERM; while :; do sleep 0.1; done")
+
+	s, ctx := testSession(t)
+	s.load = func() (*config.Config, error) { return config.Load(file) }
+	cfg, err := s.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.start(ctx, cfg, map[string]*config.Config{defaultPipeline: cfg}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, p := range s.pipelines {
+			p.stop()
+			p.runner.Close()
+		}
+	})
+	runOnce(ctx, s, s.pipelines[defaultPipeline])
+
+	write("while :; do sleep 0.1; done")
+	reloaded := make(chan error, 1)
+	go func() { reloaded <- s.reload(ctx) }()
+
+	// Status answers while the reload waits for the server to stop
+	time.Sleep(200 * time.Millisecond)
+	status := make(chan struct{})
+	go func() {
+		s.Status()
+		close(status)
+	}()
+	select {
+	case <-status:
+	case err := <-reloaded:
+		t.Fatalf("reload() = %v before Status() answered", err)
+	}
+	select {
+	case <-reloaded:
+		t.Fatal("reload() didn't wait for the server to stop")
+	default:
+	}
+	if err := <-reloaded; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSession_Trigger(t *testing.T) {
+	s, _ := testSession(t)
+	p, _ := fakePipeline(t, "api")
+	s.pipelines["api"] = p
+
+	if err := s.trigger("web"); err == nil {
+		t.Error("trigger() of a task that isn't running succeeded")
+	}
+	if err := s.trigger("api"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case pe := <-s.events:
+		if pe.pipeline != p || !pe.manual || pe.event.Op != "MANUAL" {
+			t.Errorf("event = %+v, want a manual run of api", pe)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("trigger() queued nothing")
+	}
+
+	// Once the session stops nothing takes events, and senders give up
+	s.quit()
+	sent := make(chan bool)
+	go func() { sent <- s.send(pipelineEvent{pipeline: p, manual: true}) }()
+	select {
+	case ok := <-sent:
+		if ok {
+			t.Error("send() = true after the session stopped")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("send() blocked after the session stopped")
+	}
+}
+
+func TestSession_ReloadKeepsRuns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	t.Cleanup(func() { maxFails = 0 })
+	maxFails = 3
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "gowatch.yaml")
+	write := func(cmd string) {
+		content := "watch:\n  - path: " + filepath.ToSlash(dir) + "\non_change:\n  commands:\n    - cmd: [\"sh\", \"-c\", \"" + cmd + "\"]\n"
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("exit 1")
+
+	s, ctx := testSession(t)
+	s.load = func() (*config.Config, error) { return config.Load(file) }
+	cfg, err := s.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.start(ctx, cfg, map[string]*config.Config{defaultPipeline: cfg}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, p := range s.pipelines {
+			p.stop()
+			p.runner.Close()
+		}
+	})
+	old := s.pipelines[defaultPipeline]
+	runOnce(ctx, s, old)
+	runOnce(ctx, s, old)
+
+	// Editing the config doesn't forget the failures in a row, the bell's
+	// last outcome or when the last run wrote its outputs
+	write("exit 2")
+	if err := s.reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	p := s.pipelines[defaultPipeline]
+	if p.failures != 2 || !p.failed || !p.lastRunStart.Equal(old.lastRunStart) || !p.lastRunEnd.Equal(old.lastRunEnd) {
+		t.Errorf("after reload: failures = %d, failed = %v, last run %v-%v, want 2, true, %v-%v",
+			p.failures, p.failed, p.lastRunStart, p.lastRunEnd, old.lastRunStart, old.lastRunEnd)
+	}
+
+	runOnce(ctx, s, p)
+	if ctx.Err() == nil {
+		t.Error("session still running after --max-failures failures in a row across a reload")
+	}
+}
+
+func TestSession_RunOnStart(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name       string
+		runOnStart bool
+		flag       bool
+		want       []string
+	}{
+		{name: "tasks that set it", want: []string{"api"}},
+		{name: "inherited from the top level", runOnStart: true, want: []string{"api", "web"}},
+		// --run-on-start overrides the tasks that turn it off
+		{name: "--run-on-start", flag: true, want: []string{"api", "docs", "web"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { runOnStart = false })
+			runOnStart = tt.flag
+
+			cfg := &config.Config{
+				RunOnStart: tt.runOnStart,
+				Tasks: map[string]config.Task{
+					"api":  {RunOnStart: &yes},
+					"docs": {RunOnStart: &no},
+					"web":  {},
+				},
+			}
+			if err := applyFlagOverrides(cfg); err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			s := &session{ctx: ctx, events: make(chan pipelineEvent, 10), pipelines: make(map[string]*pipeline)}
+			for _, name := range cfg.TaskNames() {
+				tc, err := cfg.ForTask(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				s.pipelines[name] = &pipeline{name: name, cfg: tc}
+			}
+
+			s.runOnStart()
+			var got []string
+			for done := false; !done; {
+				select {
+				case pe := <-s.events:
+					if pe.event.Op != "STARTUP" || !pe.manual {
+						t.Errorf("event = %+v, manual = %v, want a manual STARTUP", pe.event, pe.manual)
+					}
+					got = append(got, pe.pipeline.name)
+				case <-time.After(100 * time.Millisecond):
+					done = true
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("started %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// pauseSignals returns the signals that pause and resume event processing
+func pauseSignals() (pause, resume os.Signal) {
+	return syscall.SIGUSR1, syscall.SIGUSR2
+}
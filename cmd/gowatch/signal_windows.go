@@ -0,0 +1,9 @@
+package main
+
+import "os"
+
+// pauseSignals returns nil on Windows, which has no user signals; the
+// control API's /pause and /resume endpoints serve the same purpose
+func pauseSignals() (pause, resume os.Signal) {
+	return nil, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+	"gowatch/pkg/watcher"
+)
+
+var (
+	simulatePaths []string
+	simulateEvent string
+)
+
+func init() {
+	testConfigCmd.Flags().StringArrayVar(&simulatePaths, "simulate", nil, "show what a change to this path would do, without watching (repeatable)")
+	testConfigCmd.Flags().StringVar(&simulateEvent, "event", config.EventWrite, "event type of the simulated changes: create, write, remove, rename or chmod")
+}
+
+// simulate reports what the pipelines of cfg would do if the --simulate
+// paths changed: which rules ignore them and which commands would run, with
+// their command lines as they would be started. The changes that aren't
+// ignored make up one run, as if they came within the debounce interval.
+func simulate(log *logger.Logger, cfg *config.Config) error {
+	selected, err := selectPipelines(cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	log.Section("Simulation")
+	for _, name := range sortedNames(selected) {
+		pcfg := selected[name]
+		if name != defaultPipeline {
+			log.Info("Task %s:", name)
+		}
+
+		var files, tags []string
+		for _, path := range simulatePaths {
+			sim, err := watcher.Simulate(pcfg, path, simulateEvent)
+			if err != nil {
+				return err
+			}
+			switch {
+			case sim.Ignored != "":
+				log.Info("  %s %s: ignored by %s", simulateEvent, path, sim.Ignored)
+			case sim.Dropped != "":
+				log.Info("  %s %s: no run, %s", simulateEvent, path, sim.Dropped)
+			default:
+				log.Success("  %s %s: triggers a run (watch path %s, %s debounce)",
+					simulateEvent, path, pcfg.Watch[sim.WatchPath].Path, sim.Strategy)
+				// Events carry absolute paths
+				files = append(files, sim.Path)
+				for _, tag := range pcfg.Watch[sim.WatchPath].Tags {
+					if !slices.Contains(tags, tag) {
+						tags = append(tags, tag)
+					}
+				}
+			}
+		}
+		if len(files) == 0 {
+			log.Info("  No run would start")
+			continue
+		}
+
+		// The watcher lists the most recent change first
+		slices.Reverse(files)
+		event := strings.ToUpper(simulateEvent)
+		t := runner.Trigger{Path: files[0], Event: event, Files: files, Ops: []string{event}, Tags: tags}
+		plan := runner.New(pcfg, runner.Options{}).Plan(t)
+
+		log.Info("  Commands:")
+		for _, p := range plan {
+			line := strings.Join(p.Command, " ")
+			if p.Skipped != "" {
+				log.Info("    %d. skipped, %s: %s", p.Index+1, p.Skipped, line)
+				continue
+			}
+			if p.Dir != "" {
+				line = fmt.Sprintf("%s (in %s)", line, p.Dir)
+			}
+			log.Info("    %d. %s", p.Index+1, line)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"gowatch/pkg/watcher"
+)
+
+// statsEvery is the interval of --stats, 0 when disabled
+var statsEvery time.Duration
+
+func init() {
+	runCmd.Flags().DurationVar(&statsEvery, "stats", 0, "log watcher and runtime statistics at this interval (e.g. 60s)")
+}
+
+// logStats logs what the watchers did over each interval until ctx is done:
+// how many changes came in, what dropped them and how many runs they made,
+// to tell why gowatch is slow on a big tree
+func (s *session) logStats(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	s.mu.Lock()
+	prev, prevRuns := s.watcherStats(), s.stats.Runs
+	s.mu.Unlock()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		cur, runs := s.watcherStats(), s.stats.Runs
+		s.mu.Unlock()
+		s.log.Info("Stats (last %s): %s", every, formatStats(statsSince(cur, prev), runs-prevRuns, every))
+		s.log.Info("  %d directories watched, %d goroutines", cur.Watched, runtime.NumGoroutine())
+		prev, prevRuns = cur, runs
+	}
+}
+
+// statsSince returns the counts added since prev. Watchers replaced on
+// reload start counting again from zero.
+func statsSince(cur, prev watcher.Stats) watcher.Stats {
+	since := func(cur, prev int64) int64 {
+		if cur < prev {
+			return cur
+		}
+		return cur - prev
+	}
+	return watcher.Stats{
+		Received:   since(cur.Received, prev.Received),
+		Ignored:    since(cur.Ignored, prev.Ignored),
+		Filtered:   since(cur.Filtered, prev.Filtered),
+		Debounced:  since(cur.Debounced, prev.Debounced),
+		Emitted:    since(cur.Emitted, prev.Emitted),
+		Overflowed: since(cur.Overflowed, prev.Overflowed),
+		Watched:    cur.Watched,
+	}
+}
+
+// formatStats describes the changes of an interval
+func formatStats(d watcher.Stats, runs int, every time.Duration) string {
+	ignored := 0.0
+	if d.Received > 0 {
+		ignored = float64(d.Ignored) / float64(d.Received) * 100
+	}
+	line := fmt.Sprintf("%.1f changes/s, %d received, %d ignored (%.0f%%), %d filtered, %d debounced, %d event(s), %d run(s)",
+		float64(d.Received)/every.Seconds(), d.Received, d.Ignored, ignored, d.Filtered, d.Debounced, d.Emitted, runs)
+	if d.Overflowed > 0 {
+		line += fmt.Sprintf(", %d overflowed the queue", d.Overflowed)
+	}
+	return line
+}
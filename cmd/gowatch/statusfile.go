@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gowatch/internal/api"
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+	"gowatch/pkg/watcher"
+)
+
+// States of a session in its status file
+const (
+	stateIdle    = "idle"
+	stateRunning = "running"
+	statePaused  = "paused"
+	stateStopped = "stopped"
+)
+
+// sessionState is the content of the status file
+type sessionState struct {
+	State   string    `json:"state"`
+	PID     int       `json:"pid"`
+	Updated time.Time `json:"updated"`
+	Tasks   []string  `json:"tasks"`
+	// Failing names the tasks whose last run failed
+	Failing   []string             `json:"failing"`
+	Running   []api.RunningCommand `json:"running"`
+	LastEvent *api.Event           `json:"last_event,omitempty"`
+	// Results holds the last run of each task, with the output excerpts of
+	// the commands that failed
+	Results []api.Result `json:"results"`
+}
+
+// statusFile keeps the state of a session in a JSON file, for the status
+// lines of editors to show without connecting to gowatch
+type statusFile struct {
+	path string
+	log  *logger.Logger
+	sess *session
+
+	// mu orders the writes, which come from the loop and from the runners
+	mu      sync.Mutex
+	running bool
+	stopped bool
+	results map[string]api.Result
+	// failed is set while writes fail, so that a failure is reported once
+	failed bool
+}
+
+// writeStatus keeps the status file of a config up to date with the state
+// of its session, unless it is disabled. The returned function records that
+// the session stopped.
+func writeStatus(log *logger.Logger, sess *session, cfg *config.Config) func() {
+	path := cfg.StatusPath()
+	if path == "" || dryRun {
+		return func() {}
+	}
+	f := &statusFile{path: path, log: log, sess: sess, results: make(map[string]api.Result)}
+	sess.onEvent(func(string, int64, watcher.Event) {
+		f.update(func() { f.running = true })
+	})
+	sess.onStart(func(string, runner.Trigger, []string) { f.update(nil) })
+	sess.onResult(func(string, runner.Trigger, runner.RunResult) { f.update(nil) })
+	sess.onReport(func(report runner.Report) {
+		f.update(func() {
+			f.running = false
+			f.results[report.Task] = api.NewResult(report)
+		})
+	})
+	sess.onPause(func(bool) { f.update(nil) })
+	f.update(nil)
+	return func() { f.update(func() { f.stopped = true }) }
+}
+
+// update applies a change, if any, and writes the file again
+func (f *statusFile) update(change func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if change != nil {
+		change()
+	}
+	if err := f.write(f.state()); err != nil {
+		if !f.failed {
+			f.log.Warn("Failed to write status file: %v", err)
+		}
+		f.failed = true
+		return
+	}
+	f.failed = false
+}
+
+// state reads the state of the session. f.mu must be held.
+func (f *statusFile) state() sessionState {
+	s := f.sess
+	s.mu.Lock()
+	state := sessionState{
+		State:     stateIdle,
+		PID:       os.Getpid(),
+		Updated:   time.Now(),
+		Tasks:     s.pipelineNames(),
+		Failing:   []string{},
+		Running:   s.runningCommands(),
+		LastEvent: s.lastChange,
+		Results:   []api.Result{},
+	}
+	paused := s.paused
+	s.mu.Unlock()
+
+	switch {
+	case f.stopped:
+		state.State = stateStopped
+		state.Running = []api.RunningCommand{}
+	case f.running || len(state.Running) > 0:
+		state.State = stateRunning
+	case paused:
+		state.State = statePaused
+	}
+	// Tasks a reload removed are left out
+	for _, task := range state.Tasks {
+		result, ok := f.results[task]
+		if !ok {
+			continue
+		}
+		state.Results = append(state.Results, result)
+		if !result.Success {
+			state.Failing = append(state.Failing, task)
+		}
+	}
+	return state
+}
+
+// write replaces the file atomically so that readers never see a partial
+// write
+func (f *statusFile) write(state sessionState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
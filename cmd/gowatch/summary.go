@@ -0,0 +1,150 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+)
+
+// slowestRuns is how many runs the shutdown summary lists as the slowest
+const slowestRuns = 3
+
+// sessionSummary collects what is reported when a session ends. It is
+// owned by the loop goroutine.
+type sessionSummary struct {
+	// busy is the time spent running commands
+	busy     time.Duration
+	commands []*commandSummary
+	// slowest holds the longest runs, slowest first
+	slowest []runner.Report
+}
+
+// commandSummary counts the results of one command
+type commandSummary struct {
+	task     string
+	name     string
+	runs     int
+	failures int
+	// timeouts counts the failures that were timeouts
+	timeouts int
+	total    time.Duration
+}
+
+// add records a finished run. names holds the labels of the pipeline's
+// commands by index.
+func (s *sessionSummary) add(report runner.Report, names []string) {
+	s.busy += report.Duration
+
+	for _, r := range report.Results {
+		name := strings.Join(r.Command, " ")
+		if r.Index >= 0 && r.Index < len(names) {
+			name = names[r.Index]
+		}
+		i := slices.IndexFunc(s.commands, func(c *commandSummary) bool {
+			return c.task == report.Task && c.name == name
+		})
+		if i < 0 {
+			i = len(s.commands)
+			s.commands = append(s.commands, &commandSummary{task: report.Task, name: name})
+		}
+		c := s.commands[i]
+		c.runs++
+		c.total += r.Duration
+		if r.ExitCode != 0 {
+			c.failures++
+		}
+		if r.TimedOut {
+			c.timeouts++
+		}
+	}
+
+	i, _ := slices.BinarySearchFunc(s.slowest, report.Duration, func(r runner.Report, d time.Duration) int {
+		// Sorted by descending duration
+		return cmp.Compare(d, r.Duration)
+	})
+	if i < slowestRuns {
+		s.slowest = slices.Insert(s.slowest, i, report)
+		s.slowest = s.slowest[:min(len(s.slowest), slowestRuns)]
+	}
+}
+
+// print shows the summary of a session that ran for uptime
+func (s *sessionSummary) print(log *logger.Logger, stats sessionStats, uptime time.Duration) {
+	runs := fmt.Sprintf("%d run(s)", stats.Runs)
+	if stats.Failures > 0 {
+		runs += fmt.Sprintf(", %d failed", stats.Failures)
+	}
+	log.Info("Session: %s, %d event(s), %s", uptime.Round(time.Second), stats.Events, runs)
+	// A static server alone runs no commands
+	if stats.Runs == 0 || len(s.commands) == 0 {
+		return
+	}
+	log.Info("Time spent running commands: %s", s.busy.Round(time.Millisecond))
+
+	multiTask := slices.ContainsFunc(s.commands, func(c *commandSummary) bool {
+		return c.task != s.commands[0].task
+	})
+	// Timeouts only get a column when there were some
+	timeouts := slices.ContainsFunc(s.commands, func(c *commandSummary) bool {
+		return c.timeouts > 0
+	})
+	header := []string{"COMMAND", "RUNS", "PASSED", "FAILED", "AVERAGE", "TOTAL"}
+	if timeouts {
+		header = slices.Insert(header, 4, "TIMED OUT")
+	}
+	if multiTask {
+		header = append([]string{"TASK"}, header...)
+	}
+	var rows [][]string
+	for _, c := range s.commands {
+		row := []string{
+			c.name,
+			strconv.Itoa(c.runs),
+			strconv.Itoa(c.runs - c.failures),
+			strconv.Itoa(c.failures),
+			(c.total / time.Duration(c.runs)).Round(time.Millisecond).String(),
+			c.total.Round(time.Millisecond).String(),
+		}
+		if timeouts {
+			row = slices.Insert(row, 4, strconv.Itoa(c.timeouts))
+		}
+		if multiTask {
+			row = append([]string{c.task}, row...)
+		}
+		rows = append(rows, row)
+	}
+	log.Info("Commands:")
+	log.Table(header, rows)
+
+	header = []string{"STARTED", "DURATION", "RESULT", "CHANGE"}
+	if multiTask {
+		header = append([]string{"TASK"}, header...)
+	}
+	rows = nil
+	for _, r := range s.slowest {
+		result := "ok"
+		switch {
+		case r.TimedOut():
+			result = "TIMEOUT"
+		case !r.Success():
+			result = "FAIL"
+		}
+		change := r.Trigger.Event
+		if r.Trigger.Path != "" {
+			change += " " + r.Trigger.Path
+		}
+		row := []string{r.Start.Format("15:04:05"), r.Duration.Round(time.Millisecond).String(), result, change}
+		if multiTask {
+			row = append([]string{r.Task}, row...)
+		}
+		rows = append(rows, row)
+	}
+	log.Info("Slowest runs:")
+	log.Table(header, rows)
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package main
+
+import "errors"
+
+// enableKeypresses is not supported on this platform
+func enableKeypresses(fd int) (func(), error) {
+	return nil, errors.New("keyboard controls are not supported on this platform")
+}
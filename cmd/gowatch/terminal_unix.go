@@ -0,0 +1,27 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// enableKeypresses switches the terminal to cbreak mode: keys are delivered
+// immediately without echo, while output processing and Ctrl+C keep working
+// (unlike full raw mode). The returned function restores the terminal.
+func enableKeypresses(fd int) (func(), error) {
+	termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+	saved := *termios
+
+	termios.Lflag &^= unix.ICANON | unix.ECHO
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, termios); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		unix.IoctlSetTermios(fd, ioctlSetTermios, &saved)
+	}, nil
+}
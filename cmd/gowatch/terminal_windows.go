@@ -0,0 +1,24 @@
+//go:build windows
+
+package main
+
+import "golang.org/x/sys/windows"
+
+// enableKeypresses turns off line input and echo on the console so keys are
+// delivered immediately; Ctrl+C processing is kept. The returned function
+// restores the console mode.
+func enableKeypresses(fd int) (func(), error) {
+	h := windows.Handle(fd)
+
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return nil, err
+	}
+	if err := windows.SetConsoleMode(h, mode&^(windows.ENABLE_LINE_INPUT|windows.ENABLE_ECHO_INPUT)); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		windows.SetConsoleMode(h, mode)
+	}, nil
+}
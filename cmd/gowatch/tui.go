@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+
+	"gowatch/internal/tui"
+	"gowatch/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui [task[,task...]]",
+	Short: "Watch with an interactive terminal dashboard",
+	Long: `Run the watcher like "gowatch run", behind a full-screen dashboard that
+shows the watched paths, a feed of changes, the status and duration of every
+command and a scrollable pane with the output of each.
+
+Keys:
+  ↑/↓, j/k     select a command (the first entry is gowatch's own log)
+  pgup/pgdn    scroll its output; home/end jump to the start or end
+  r            re-run the selected command's task
+  a            re-run every task
+  p            pause or resume watching
+  /            filter the output; esc clears the filter
+  c            clear the output pane
+  q            quit
+
+It takes the same flags as run.`,
+	RunE: runTUI,
+}
+
+// useTUI is set by gowatch tui, which runs the session of gowatch run
+// behind the dashboard
+var useTUI bool
+
+func init() {
+	rootCmd.AddCommand(tuiCmd)
+	// The flags of run, all registered by now, are shared
+	tuiCmd.Flags().AddFlagSet(runCmd.Flags())
+}
+
+func runTUI(cmd *cobra.Command, args []string) error {
+	useTUI = true
+	return runWatch(cmd, args)
+}
+
+// runDashboard runs the session loop in the background while the dashboard
+// is shown. Quitting the dashboard stops the session and vice versa.
+func runDashboard(ctx context.Context, cancel context.CancelFunc, dash *tui.Dashboard, sess *session, selected map[string]*config.Config, reloads <-chan struct{}) error {
+	sess.onEvent(dash.Event)
+	sess.onStart(dash.Started)
+	sess.onResult(dash.Result)
+	sess.onOutput(dash.Output)
+
+	loopErr := make(chan error, 1)
+	go func() {
+		err := sess.loop(ctx, reloads)
+		// The session may stop by itself, e.g. with --max-failures
+		cancel()
+		loopErr <- err
+	}()
+	go func() {
+		<-ctx.Done()
+		dash.Quit()
+	}()
+
+	err := dash.Run(sess, dashboardCommands(selected))
+	cancel()
+	if lerr := <-loopErr; err == nil {
+		err = lerr
+	}
+	return err
+}
+
+// dashboardCommands lists the on_change commands of the pipelines
+func dashboardCommands(selected map[string]*config.Config) []tui.Command {
+	var commands []tui.Command
+	for _, name := range sortedNames(selected) {
+		for i, c := range selected[name].OnChange.Commands {
+			commands = append(commands, tui.Command{Task: name, Index: i, Name: commandLabel(c), Restart: c.IsRestart()})
+		}
+	}
+	return commands
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+	"gowatch/pkg/watcher"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// cfgFiles are the --config flags of run; more than one runs them as a
+	// workspace
+	cfgFiles      []string
+	workspaceFile string
+)
+
+// workspaceFlags are the run flags that serve a single config, which a
+// workspace doesn't take
+var workspaceFlags = []string{"path", "cmd", "api", "ws", "livereload", "results-json", "state-file", "record", "problem-matcher", "group-output"}
+
+// memberColors tell the prefixes of the configs of a workspace apart
+var memberColors = []color.Attribute{
+	color.FgCyan,
+	color.FgMagenta,
+	color.FgYellow,
+	color.FgGreen,
+	color.FgBlue,
+	color.FgHiCyan,
+	color.FgHiMagenta,
+	color.FgHiYellow,
+}
+
+func init() {
+	runCmd.Flags().StringVar(&workspaceFile, "workspace", "", "run the configs listed in this workspace file side by side")
+	runCmd.MarkFlagsMutuallyExclusive("config", "workspace")
+}
+
+// member is one config of a workspace, with the session running it
+type member struct {
+	name     string
+	file     string
+	log      *logger.Logger
+	cfg      *config.Config
+	selected map[string]*config.Config
+	sess     *session
+	reloads  <-chan struct{}
+	// stopStatus records in the status file that the session stopped
+	stopStatus func()
+}
+
+// runWorkspace runs several configs side by side in one process, each with
+// its own watchers and runners as under a plain gowatch run. Their output
+// is prefixed with the name of the config, and stopping stops them all.
+func runWorkspace(cmd *cobra.Command, args []string) error {
+	level, _, err := resolveLogLevel()
+	if err != nil {
+		return err
+	}
+	if runFor < 0 {
+		return fmt.Errorf("--for must not be negative")
+	}
+	if statsEvery < 0 {
+		return fmt.Errorf("--stats must not be negative")
+	}
+	if useTUI {
+		return fmt.Errorf("the dashboard runs a single config")
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("tasks can't be selected in a workspace; each config runs its default pipelines")
+	}
+	for _, name := range workspaceFlags {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s can't be used with several configs", name)
+		}
+	}
+
+	files := cfgFiles
+	if workspaceFile != "" {
+		if files, err = config.LoadWorkspace(workspaceFile); err != nil {
+			return err
+		}
+	}
+	for i, file := range files {
+		if slices.Contains(files[:i], file) {
+			return fmt.Errorf("config %s is given twice", file)
+		}
+	}
+
+	log := logger.New(level, !noColor)
+	log.Banner("GoWatch - File Watcher & Auto-Runner", "1.0.0")
+	log.Section("Workspace")
+	if workspaceFile != "" {
+		log.Info("Workspace: %s", workspaceFile)
+	}
+
+	names := memberNames(files)
+	width := 0
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+	// The configs write whole lines under one lock, so that lines don't
+	// interleave
+	var outMu sync.Mutex
+	members := make([]*member, len(files))
+	for i, file := range files {
+		prefix := fmt.Sprintf("%-*s │ ", width, names[i])
+		if !noColor {
+			prefix = color.New(memberColors[i%len(memberColors)]).Sprint(prefix)
+		}
+		m := &member{
+			name: names[i],
+			file: file,
+			log:  logger.NewWriter(&prefixWriter{mu: &outMu, out: os.Stdout, prefix: []byte(prefix)}, level, !noColor),
+		}
+		if err := m.load(); err != nil {
+			return err
+		}
+		members[i] = m
+	}
+	for _, m := range members {
+		for _, name := range sortedNames(m.selected) {
+			printPipeline(m.log, name, m.selected[name])
+		}
+	}
+
+	log.Section("Settings")
+	log.Info("Sequential Mode: %v", sequential)
+	if dryRun {
+		log.Warn("DRY RUN MODE - Commands will not be executed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handleSignals(log, cancel)
+
+	// Errors from here on aren't usage errors, and main reports them
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	for _, m := range members {
+		if err := runSetup(ctx, m.log, m.cfg, m.selected); err != nil {
+			return fmt.Errorf("%s: %w (not watching)", m.name, err)
+		}
+	}
+
+	log.Section("Starting Watchers")
+	for _, m := range members {
+		shutdown, err := m.start(ctx, cancel)
+		if err != nil {
+			return fmt.Errorf("%s: %w", m.name, err)
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			shutdown(shutdownCtx)
+		}()
+	}
+
+	if pprofAddr != "" {
+		srv, err := startPprof(log, pprofAddr)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdownCtx)
+		}()
+	}
+
+	// SIGUSR1/SIGUSR2 pause and resume every config
+	if pause, resume := pauseSignals(); pause != nil {
+		pauseCh := make(chan os.Signal, 1)
+		signal.Notify(pauseCh, pause, resume)
+		defer signal.Stop(pauseCh)
+		go func() {
+			for {
+				select {
+				case sig := <-pauseCh:
+					for _, m := range members {
+						m.sess.do(ctx, func() { m.sess.setPaused(sig == pause) })
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	log.Success("Watching %d configs", len(members))
+	if statsEvery > 0 {
+		for _, m := range members {
+			go m.sess.logStats(ctx, statsEvery)
+		}
+	}
+	var timedOut atomic.Bool
+	if runFor > 0 {
+		log.Info("Watching for %s", runFor)
+		time.AfterFunc(runFor, func() {
+			log.Info("")
+			log.Warn("Stopping after %s (--for)", runFor)
+			timedOut.Store(true)
+			cancel()
+		})
+	}
+	log.Info("Watching for file changes... (Press Ctrl+C to stop)")
+	log.Separator()
+
+	// Each loop shuts its config down once the context is cancelled, by a
+	// signal, --for or a config stopping on --max-failures
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, m := range members {
+		wg.Go(func() {
+			m.sess.runOnStart()
+			errs[i] = m.sess.loop(ctx, m.reloads)
+			m.stopStatus()
+		})
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("%s: %w", members[i].name, err)
+		}
+	}
+	if timedOut.Load() {
+		var runs, failures int
+		for _, m := range members {
+			runs += m.sess.stats.Runs
+			failures += m.sess.stats.Failures
+		}
+		if failures > 0 {
+			return exitCodeError{code: 1, msg: fmt.Sprintf("%d of %d run(s) failed", failures, runs)}
+		}
+	}
+	return nil
+}
+
+// load loads the config of a member, resolving its paths against its
+// directory, and selects its pipelines
+func (m *member) load() error {
+	m.log.Info("Loading config from: %s", m.file)
+	cfg, err := config.LoadRelative(m.file, activeProfile())
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", m.file, err)
+	}
+	for _, key := range cfg.Unknown {
+		m.log.Warn("Ignoring unknown config key %s; --strict rejects unknown keys", config.DescribeUnknown(key))
+	}
+	if cfg.Profile != "" {
+		m.log.Info("Profile: %s", cfg.Profile)
+	}
+	if cfg.Color != nil && !*cfg.Color {
+		m.log.SetColors(false)
+	}
+	if err := applyFlagOverrides(cfg); err != nil {
+		return fmt.Errorf("%s: invalid configuration: %w", m.file, err)
+	}
+	selected, err := selectPipelines(cfg, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.file, err)
+	}
+	// Interactive commands would take the terminal from the other configs
+	for _, name := range sortedNames(selected) {
+		c := selected[name]
+		for _, cmd := range slices.Concat(c.Setup, c.OnChange.Commands, c.OnSuccess, c.OnFailure, c.OnExit) {
+			if cmd.Interactive {
+				return fmt.Errorf("%s: command %q is interactive, which a workspace doesn't support", m.file, commandLabel(cmd))
+			}
+		}
+	}
+	m.cfg, m.selected = cfg, selected
+	return nil
+}
+
+// start starts the session of a member, returning a function that stops the
+// LiveReload, static and proxy servers the config serves. quit stops the
+// whole workspace.
+func (m *member) start(ctx context.Context, quit context.CancelFunc) (func(context.Context), error) {
+	var err error
+	sess := newSession(m.log, nil, quit)
+	sess.file = m.file
+	sess.load = func() (*config.Config, error) { return config.LoadRelative(m.file, activeProfile()) }
+	if err = sess.start(ctx, m.cfg, m.selected); err != nil {
+		return nil, err
+	}
+	m.sess = sess
+
+	if !noReload {
+		reloads, err := watcher.WatchFile(ctx, m.file)
+		if err != nil {
+			m.log.Warn("Config hot-reload disabled: %v", err)
+		} else {
+			m.reloads = reloads
+		}
+	}
+	recordHistory(m.log, sess, m.cfg)
+	writeReports(m.log, sess)
+	m.stopStatus = writeStatus(m.log, sess, m.cfg)
+	var servers []interface{ Shutdown(context.Context) error }
+	if m.cfg.LiveReload != "" {
+		lr, err := startLiveReload(m.log, sess, m.cfg.LiveReload)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, lr)
+	}
+	if m.cfg.Serve.Enabled() {
+		srv, err := startServe(m.log, sess, m.cfg.Serve)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, srv)
+	}
+	if m.cfg.Proxy.Enabled() {
+		px, err := startProxy(m.log, sess, m.cfg.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, px)
+	}
+	m.log.Success("Watcher started successfully")
+	return func(ctx context.Context) {
+		for _, srv := range servers {
+			srv.Shutdown(ctx)
+		}
+	}, nil
+}
+
+// memberNames names the configs of a workspace after their directories, or
+// by their paths when two share a directory name
+func memberNames(files []string) []string {
+	names := make([]string, len(files))
+	for i, file := range files {
+		dir, err := filepath.Abs(filepath.Dir(file))
+		if err != nil {
+			dir = filepath.Dir(file)
+		}
+		names[i] = filepath.Base(dir)
+	}
+	for i, name := range names {
+		if slices.Contains(names[:i], name) || slices.Contains(names[i+1:], name) {
+			for i, file := range files {
+				names[i] = filepath.ToSlash(filepath.Clean(file))
+			}
+			break
+		}
+	}
+	return names
+}
+
+// prefixWriter prefixes every line written to it. Writers sharing mu write
+// whole lines in turn.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix []byte
+	// partial holds a line not yet ended
+	partial []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.partial = append(w.partial, p...)
+	var buf bytes.Buffer
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		// Blank lines, such as those before sections, stay blank
+		if i > 0 {
+			buf.Write(w.prefix)
+		}
+		buf.Write(w.partial[:i+1])
+		w.partial = w.partial[i+1:]
+	}
+	if _, err := w.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,364 @@
+// Package api serves a local HTTP control API for a running gowatch
+// session so that editors and scripts can query and drive it without
+// parsing terminal output.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+)
+
+// historySize is the number of recent results kept for /results
+const historySize = 50
+
+// Controller is implemented by the run loop that the API drives
+type Controller interface {
+	Status() Status
+	WatchedPaths() []WatchedPath
+	Trigger(task string) error
+	SetPaused(paused bool)
+}
+
+// Status describes the state of the session
+type Status struct {
+	PID         int              `json:"pid"`
+	Paused      bool             `json:"paused"`
+	StartedAt   time.Time        `json:"started_at"`
+	Uptime      string           `json:"uptime"`
+	Tasks       []string         `json:"tasks"`
+	WatchedDirs int              `json:"watched_dirs"`
+	Events      int              `json:"events"`
+	Runs        int              `json:"runs"`
+	Failures    int              `json:"failures"`
+	LastEvent   *Event           `json:"last_event,omitempty"`
+	LastRun     *Result          `json:"last_run,omitempty"`
+	Running     []RunningCommand `json:"running"`
+	// Watcher counts what the watchers of the running pipelines did with
+	// the changes reported to them
+	Watcher    WatcherStats `json:"watcher"`
+	Goroutines int          `json:"goroutines"`
+	// Roots counts the activity under each local watch path
+	Roots []WatchRoot `json:"roots,omitempty"`
+}
+
+// WatcherStats counts the changes reported to the watchers: those dropped
+// by ignore rules, by event type, include or size, those folded into other
+// events by debouncing, the events delivered and those that found the event
+// queue full
+type WatcherStats struct {
+	Received   int64 `json:"received"`
+	Ignored    int64 `json:"ignored"`
+	Filtered   int64 `json:"filtered"`
+	Debounced  int64 `json:"debounced"`
+	Emitted    int64 `json:"emitted"`
+	Overflowed int64 `json:"overflowed"`
+}
+
+// WatchRoot counts the activity under a local watch path of a task: the
+// directories watched and the files in them when they were scanned, the
+// changes reported under it and those ignored, by ignore rule
+type WatchRoot struct {
+	Task      string           `json:"task"`
+	Path      string           `json:"path"`
+	Watched   int              `json:"watched"`
+	Files     int              `json:"files"`
+	Events    int64            `json:"events"`
+	Ignored   int64            `json:"ignored"`
+	IgnoredBy map[string]int64 `json:"ignored_by,omitempty"`
+}
+
+// Event is the most recent change that started a run
+type Event struct {
+	Task  string    `json:"task"`
+	Path  string    `json:"path"`
+	Op    string    `json:"op"`
+	Files []string  `json:"files,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// RunningCommand is a command that is currently executing
+type RunningCommand struct {
+	Task    string    `json:"task"`
+	RunID   int64     `json:"run_id"`
+	Command []string  `json:"command"`
+	Started time.Time `json:"started"`
+	Elapsed string    `json:"elapsed"`
+}
+
+// WatchedPath is a configured watch path of a task
+type WatchedPath struct {
+	Task      string `json:"task"`
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive"`
+	Backend   string `json:"backend"`
+}
+
+// Result is the JSON form of a run report
+type Result struct {
+	RunID    int64           `json:"run_id"`
+	Task     string          `json:"task"`
+	Path     string          `json:"path"`
+	Event    string          `json:"event"`
+	Files    []string        `json:"files,omitempty"`
+	Start    time.Time       `json:"start"`
+	Duration string          `json:"duration"`
+	Success  bool            `json:"success"`
+	Commands []CommandResult `json:"commands"`
+}
+
+// CommandResult is the outcome of a single command within a run
+type CommandResult struct {
+	Command  []string `json:"command"`
+	ExitCode int      `json:"exit_code"`
+	Duration string   `json:"duration"`
+	Error    string   `json:"error,omitempty"`
+	TimedOut bool     `json:"timed_out,omitempty"`
+	Canceled bool     `json:"canceled,omitempty"`
+	Attempts int      `json:"attempts,omitempty"`
+	// Excerpt is the last lines of output of a failed command
+	Excerpt []string `json:"excerpt,omitempty"`
+	// Tests counts the tests of a command with a parser
+	Tests *TestSummary `json:"tests,omitempty"`
+}
+
+// TestSummary is the JSON form of the tests a command ran
+type TestSummary struct {
+	Passed      int      `json:"passed"`
+	Failed      int      `json:"failed"`
+	Skipped     int      `json:"skipped"`
+	FailedTests []string `json:"failed_tests,omitempty"`
+}
+
+// NewResult converts a run report into its JSON form
+func NewResult(report runner.Report) Result {
+	result := Result{
+		RunID:    report.Trigger.RunID,
+		Task:     report.Task,
+		Path:     report.Trigger.Path,
+		Event:    report.Trigger.Event,
+		Files:    report.Trigger.Files,
+		Start:    report.Start,
+		Duration: report.Duration.String(),
+		Success:  report.Success(),
+		Commands: make([]CommandResult, len(report.Results)),
+	}
+	for i, r := range report.Results {
+		result.Commands[i] = NewCommandResult(r)
+	}
+	return result
+}
+
+// NewCommandResult converts the result of one command into its JSON form
+func NewCommandResult(r runner.RunResult) CommandResult {
+	result := CommandResult{
+		Command:  r.Command,
+		ExitCode: r.ExitCode,
+		Duration: r.Duration.String(),
+		TimedOut: r.TimedOut,
+		Canceled: r.Canceled,
+		Attempts: r.Attempts,
+		Excerpt:  r.Excerpt,
+	}
+	if r.Error != nil {
+		result.Error = r.Error.Error()
+	}
+	if r.Tests != nil {
+		result.Tests = &TestSummary{
+			Passed:      r.Tests.Passed,
+			Failed:      r.Tests.Failed,
+			Skipped:     r.Tests.Skipped,
+			FailedTests: r.Tests.FailedTests,
+		}
+	}
+	return result
+}
+
+// Server is the HTTP control API
+type Server struct {
+	addr string
+	ctrl Controller
+	log  *logger.Logger
+	http *http.Server
+
+	mu          sync.Mutex
+	history     []Result
+	subscribers map[chan Result]struct{}
+}
+
+// New creates an API server for addr (e.g. ":7070" or "127.0.0.1:7070")
+func New(addr string, ctrl Controller, log *logger.Logger) *Server {
+	s := &Server{
+		addr:        addr,
+		ctrl:        ctrl,
+		log:         log,
+		subscribers: make(map[chan Result]struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("GET /paths", s.handlePaths)
+	mux.HandleFunc("POST /trigger", s.handleTrigger)
+	mux.HandleFunc("POST /pause", s.handlePause)
+	mux.HandleFunc("POST /resume", s.handleResume)
+	mux.HandleFunc("GET /results", s.handleResults)
+	mux.HandleFunc("GET /results/stream", s.handleStream)
+
+	s.http = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// Start binds the listener and serves in the background. Binding happens
+// synchronously so that address errors are reported to the caller.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+
+	s.Serve(ln)
+	s.log.Success("API listening on http://%s", ln.Addr())
+	return nil
+}
+
+// Serve serves the API on ln in the background, e.g. on a Unix socket
+func (s *Server) Serve(ln net.Listener) {
+	go func() {
+		if err := s.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("API server error: %v", err)
+		}
+	}()
+}
+
+// Shutdown stops the server, closing any open result streams
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	for ch := range s.subscribers {
+		close(ch)
+		delete(s.subscribers, ch)
+	}
+	s.mu.Unlock()
+
+	return s.http.Shutdown(ctx)
+}
+
+// Publish records a run report and forwards it to stream subscribers
+func (s *Server) Publish(report runner.Report) {
+	result := NewResult(report)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.history = append(s.history, result)
+	if len(s.history) > historySize {
+		s.history = s.history[len(s.history)-historySize:]
+	}
+
+	for ch := range s.subscribers {
+		// Slow subscribers miss results rather than stall the run loop
+		select {
+		case ch <- result:
+		default:
+		}
+	}
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.ctrl.Status())
+}
+
+func (s *Server) handlePaths(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.ctrl.WatchedPaths())
+}
+
+func (s *Server) handleTrigger(w http.ResponseWriter, r *http.Request) {
+	task := r.URL.Query().Get("task")
+	if err := s.ctrl.Trigger(task); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "queued"})
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.ctrl.SetPaused(true)
+	writeJSON(w, http.StatusOK, map[string]bool{"paused": true})
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.ctrl.SetPaused(false)
+	writeJSON(w, http.StatusOK, map[string]bool{"paused": false})
+}
+
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	results := make([]Result, len(s.history))
+	copy(results, s.history)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// handleStream sends each new result as a server-sent event
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan Result, 16)
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		if _, ok := s.subscribers[ch]; ok {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case result, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(result)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: result\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+)
+
+type fakeController struct {
+	paused    bool
+	triggered []string
+}
+
+func (f *fakeController) Status() Status {
+	return Status{Paused: f.paused, Tasks: []string{"build"}}
+}
+
+func (f *fakeController) WatchedPaths() []WatchedPath {
+	return []WatchedPath{{Task: "build", Path: ".", Recursive: true, Backend: "fsnotify"}}
+}
+
+func (f *fakeController) Trigger(task string) error {
+	if task != "" && task != "build" {
+		return fmt.Errorf("task %q is not running", task)
+	}
+	f.triggered = append(f.triggered, task)
+	return nil
+}
+
+func (f *fakeController) SetPaused(paused bool) {
+	f.paused = paused
+}
+
+func newTestServer(t *testing.T) (*Server, *fakeController, *httptest.Server) {
+	ctrl := &fakeController{}
+	s := New("127.0.0.1:0", ctrl, logger.New(logger.LevelError, false))
+	ts := httptest.NewServer(s.http.Handler)
+	t.Cleanup(ts.Close)
+	return s, ctrl, ts
+}
+
+func TestServer_Endpoints(t *testing.T) {
+	_, ctrl, ts := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{"status", http.MethodGet, "/status", http.StatusOK},
+		{"paths", http.MethodGet, "/paths", http.StatusOK},
+		{"trigger all", http.MethodPost, "/trigger", http.StatusAccepted},
+		{"trigger task", http.MethodPost, "/trigger?task=build", http.StatusAccepted},
+		{"trigger unknown task", http.MethodPost, "/trigger?task=nope", http.StatusNotFound},
+		{"trigger wrong method", http.MethodGet, "/trigger", http.StatusMethodNotAllowed},
+		{"pause", http.MethodPost, "/pause", http.StatusOK},
+		{"results", http.MethodGet, "/results", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	if !ctrl.paused {
+		t.Error("Expected controller to be paused")
+	}
+	if len(ctrl.triggered) != 2 {
+		t.Errorf("Expected 2 triggers, got %v", ctrl.triggered)
+	}
+}
+
+func TestServer_Results(t *testing.T) {
+	s, _, ts := newTestServer(t)
+
+	for i := 0; i < historySize+5; i++ {
+		s.Publish(runner.Report{
+			Task:    "build",
+			Trigger: runner.Trigger{Path: fmt.Sprintf("file%d.go", i), Event: "WRITE"},
+			Results: []runner.RunResult{{Command: []string{"go", "build"}, ExitCode: 0}},
+		})
+	}
+
+	resp, err := http.Get(ts.URL + "/results")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var results []Result
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatalf("failed to decode results: %v", err)
+	}
+	if len(results) != historySize {
+		t.Fatalf("Expected %d results, got %d", historySize, len(results))
+	}
+	if results[0].Path != "file5.go" {
+		t.Errorf("Expected oldest result file5.go, got %s", results[0].Path)
+	}
+	if !results[0].Success {
+		t.Error("Expected result to be successful")
+	}
+}
+
+func TestServer_Stream(t *testing.T) {
+	s, _, ts := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/results/stream")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The subscriber is registered before the headers are flushed
+	s.Publish(runner.Report{
+		Task:    "build",
+		Trigger: runner.Trigger{Path: "main.go", Event: "WRITE"},
+		Results: []runner.RunResult{{Command: []string{"false"}, ExitCode: 1}},
+	})
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("stream closed before a result was received")
+			}
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var result Result
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &result); err != nil {
+				t.Fatalf("failed to decode streamed result: %v", err)
+			}
+			if result.Path != "main.go" || result.Success {
+				t.Errorf("unexpected streamed result: %+v", result)
+			}
+			return
+		case <-timeout:
+			t.Fatal("timed out waiting for streamed result")
+		}
+	}
+}
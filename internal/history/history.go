@@ -0,0 +1,344 @@
+// Package history records finished runs in a local file and summarizes
+// them, for `gowatch history`.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gowatch/pkg/runner"
+)
+
+// Run is one recorded run of a pipeline
+type Run struct {
+	Task       string    `json:"task"`
+	RunID      int64     `json:"run_id"`
+	Event      string    `json:"event"`
+	Path       string    `json:"path,omitempty"`
+	Files      []string  `json:"files,omitempty"`
+	Start      time.Time `json:"start"`
+	DurationMS int64     `json:"duration_ms"`
+	Success    bool      `json:"success"`
+	Commands   []Command `json:"commands"`
+}
+
+// Command is the result of one command of a recorded run
+type Command struct {
+	// Name identifies the command across runs: its configured name, or its
+	// command line before placeholders are replaced
+	Name       string   `json:"name"`
+	Command    []string `json:"command"`
+	ExitCode   int      `json:"exit_code"`
+	DurationMS int64    `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+	TimedOut   bool     `json:"timed_out,omitempty"`
+	Attempts   int      `json:"attempts,omitempty"`
+}
+
+// NewRun records a report. names holds the names of the pipeline's
+// on_change commands by index; commands without one are named after the
+// command line they ran.
+func NewRun(report runner.Report, names []string) Run {
+	run := Run{
+		Task:       report.Task,
+		RunID:      report.Trigger.RunID,
+		Event:      report.Trigger.Event,
+		Path:       report.Trigger.Path,
+		Files:      report.Trigger.Files,
+		Start:      report.Start,
+		DurationMS: report.Duration.Milliseconds(),
+		Success:    report.Success(),
+		Commands:   make([]Command, 0, len(report.Results)),
+	}
+	for _, r := range report.Results {
+		c := Command{
+			Name:       strings.Join(r.Command, " "),
+			Command:    r.Command,
+			ExitCode:   r.ExitCode,
+			DurationMS: r.Duration.Milliseconds(),
+			TimedOut:   r.TimedOut,
+			Attempts:   r.Attempts,
+		}
+		if r.Index >= 0 && r.Index < len(names) && names[r.Index] != "" {
+			c.Name = names[r.Index]
+		}
+		if r.Error != nil {
+			c.Error = r.Error.Error()
+		}
+		run.Commands = append(run.Commands, c)
+	}
+	return run
+}
+
+// Duration returns how long the run took
+func (r Run) Duration() time.Duration {
+	return time.Duration(r.DurationMS) * time.Millisecond
+}
+
+// Duration returns how long the command took
+func (c Command) Duration() time.Duration {
+	return time.Duration(c.DurationMS) * time.Millisecond
+}
+
+// Store appends runs to a file of JSON lines, oldest first
+type Store struct {
+	path string
+	keep int
+
+	mu sync.Mutex
+	// count is the number of runs in the file
+	count int
+}
+
+// Open opens the history file at path, creating its directory. Only the
+// latest keep runs are kept.
+func Open(path string, keep int) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	runs, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{path: path, keep: keep, count: len(runs)}, nil
+}
+
+// Add appends a run. The file is trimmed to the latest runs once it holds a
+// tenth more than it should, so that it isn't rewritten on every run.
+func (s *Store) Add(run Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	s.count++
+	if s.count > s.keep+s.keep/10 {
+		return s.trim()
+	}
+	return nil
+}
+
+// trim rewrites the file with the latest runs only
+func (s *Store) trim() error {
+	runs, err := Load(s.path)
+	if err != nil {
+		return err
+	}
+	if len(runs) > s.keep {
+		runs = runs[len(runs)-s.keep:]
+	}
+
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to trim history: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, run := range runs {
+		if err = enc.Encode(run); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to trim history: %w", err)
+	}
+	s.count = len(runs)
+	return nil
+}
+
+// Load reads the runs recorded at path, oldest first. A missing file holds
+// no runs; lines that can't be parsed, such as one cut short by a crash,
+// are skipped.
+func Load(path string) ([]Run, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	var runs []Run
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var run Run
+		if json.Unmarshal(scanner.Bytes(), &run) == nil {
+			runs = append(runs, run)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return runs, nil
+}
+
+// Filter selects runs
+type Filter struct {
+	// Tasks keeps runs of these tasks; empty keeps all
+	Tasks []string
+	// Since keeps runs started at or after this time, if set
+	Since time.Time
+	// Failed keeps failed runs only
+	Failed bool
+	// Command keeps runs with a command whose name or command line
+	// contains this text
+	Command string
+}
+
+// Match reports whether a run passes the filter
+func (f Filter) Match(run Run) bool {
+	if len(f.Tasks) > 0 && !slices.Contains(f.Tasks, run.Task) {
+		return false
+	}
+	if !f.Since.IsZero() && run.Start.Before(f.Since) {
+		return false
+	}
+	if f.Failed && run.Success {
+		return false
+	}
+	if f.Command != "" {
+		return slices.ContainsFunc(run.Commands, f.matchCommand)
+	}
+	return true
+}
+
+// matchCommand reports whether a command contains the Command text
+func (f Filter) matchCommand(c Command) bool {
+	return strings.Contains(c.Name, f.Command) ||
+		strings.Contains(strings.Join(c.Command, " "), f.Command)
+}
+
+// Select returns the runs that pass the filter. With a command filter,
+// only the matching commands of each run are kept.
+func Select(runs []Run, f Filter) []Run {
+	var selected []Run
+	for _, run := range runs {
+		if !f.Match(run) {
+			continue
+		}
+		if f.Command != "" {
+			var commands []Command
+			for _, c := range run.Commands {
+				if f.matchCommand(c) {
+					commands = append(commands, c)
+				}
+			}
+			run.Commands = commands
+		}
+		selected = append(selected, run)
+	}
+	return selected
+}
+
+// Stats summarizes the results of one command over a number of runs
+type Stats struct {
+	Task     string `json:"task"`
+	Name     string `json:"name"`
+	Runs     int    `json:"runs"`
+	Failures int    `json:"failures"`
+	// AverageMS and MaxMS are taken over every run of the command
+	AverageMS int64 `json:"average_ms"`
+	MaxMS     int64 `json:"max_ms"`
+	// Streak is the number of failures in a row up to the latest run, and
+	// LongestStreak the longest such series
+	Streak        int       `json:"streak"`
+	LongestStreak int       `json:"longest_streak"`
+	Last          time.Time `json:"last"`
+}
+
+// Average returns the average duration of the command
+func (s Stats) Average() time.Duration {
+	return time.Duration(s.AverageMS) * time.Millisecond
+}
+
+// Max returns the longest duration of the command
+func (s Stats) Max() time.Duration {
+	return time.Duration(s.MaxMS) * time.Millisecond
+}
+
+// PassRate returns the share of runs that succeeded, in percent
+func (s Stats) PassRate() float64 {
+	if s.Runs == 0 {
+		return 0
+	}
+	return float64(s.Runs-s.Failures) * 100 / float64(s.Runs)
+}
+
+// Summarize computes the stats of every command in the runs, given oldest
+// first, ordered by task and then by first appearance
+func Summarize(runs []Run) []Stats {
+	type key struct{ task, name string }
+	var order []key
+	stats := make(map[key]*Stats)
+	total := make(map[key]int64)
+
+	for _, run := range runs {
+		for _, c := range run.Commands {
+			k := key{run.Task, c.Name}
+			s := stats[k]
+			if s == nil {
+				s = &Stats{Task: run.Task, Name: c.Name}
+				stats[k] = s
+				order = append(order, k)
+			}
+			s.Runs++
+			total[k] += c.DurationMS
+			s.MaxMS = max(s.MaxMS, c.DurationMS)
+			s.Last = run.Start
+			if c.ExitCode != 0 {
+				s.Failures++
+				s.Streak++
+				s.LongestStreak = max(s.LongestStreak, s.Streak)
+			} else {
+				s.Streak = 0
+			}
+		}
+	}
+
+	slices.SortStableFunc(order, func(a, b key) int {
+		return strings.Compare(a.task, b.task)
+	})
+	summary := make([]Stats, 0, len(order))
+	for _, k := range order {
+		s := stats[k]
+		s.AverageMS = total[k] / int64(s.Runs)
+		summary = append(summary, *s)
+	}
+	return summary
+}
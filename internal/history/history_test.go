@@ -0,0 +1,176 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gowatch/pkg/runner"
+)
+
+// run returns a run of the test task started at the given minute, with a
+// command named go test that exited with code
+func run(minute, code int, ms int64) Run {
+	return Run{
+		Task:    "test",
+		Start:   time.Date(2024, 1, 1, 12, minute, 0, 0, time.UTC),
+		Success: code == 0,
+		Commands: []Command{
+			{Name: "go vet", Command: []string{"go", "vet"}, DurationMS: 100},
+			{Name: "go test", Command: []string{"go", "test", "./..."}, ExitCode: code, DurationMS: ms},
+		},
+	}
+}
+
+func TestNewRun(t *testing.T) {
+	report := runner.Report{
+		Task:     "build",
+		Trigger:  runner.Trigger{RunID: 3, Event: "WRITE", Path: "main.go", Files: []string{"main.go"}},
+		Duration: 1500 * time.Millisecond,
+		Results: []runner.RunResult{
+			{Command: []string{"go", "build", "./cmd/app"}, Duration: time.Second, Attempts: 1, Index: 0},
+			{Command: []string{"go", "test", "main.go"}, ExitCode: 1, Error: errors.New("exit status 1"), Attempts: 2, Index: 1},
+		},
+	}
+
+	got := NewRun(report, []string{"", "test"})
+	if got.Task != "build" || got.RunID != 3 || got.DurationMS != 1500 || got.Success {
+		t.Errorf("run = %+v", got)
+	}
+	if len(got.Commands) != 2 {
+		t.Fatalf("got %d commands, want 2", len(got.Commands))
+	}
+	if c := got.Commands[0]; c.Name != "go build ./cmd/app" || c.DurationMS != 1000 {
+		t.Errorf("unnamed command = %+v", c)
+	}
+	if c := got.Commands[1]; c.Name != "test" || c.ExitCode != 1 || c.Error != "exit status 1" || c.Attempts != 2 {
+		t.Errorf("named command = %+v", c)
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".gowatch", "history.jsonl")
+	store, err := Open(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 11 {
+		if err := store.Add(run(i, 0, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 11 {
+		t.Fatalf("got %d runs, want 11 before trimming", len(runs))
+	}
+
+	// Reopening counts the runs already recorded
+	store, err = Open(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(run(11, 1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	runs, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 10 || runs[0].Start.Minute() != 2 || runs[9].Success {
+		t.Errorf("after trimming: %d runs from minute %d", len(runs), runs[0].Start.Minute())
+	}
+}
+
+func TestLoad(t *testing.T) {
+	runs, err := Load(filepath.Join(t.TempDir(), "missing.jsonl"))
+	if err != nil || runs != nil {
+		t.Errorf("missing file: %v, %v", runs, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	data := `{"task":"a","success":true}` + "\n" + `{"task":"b","succ` + "\n" + `{"task":"c"}` + "\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runs, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].Task != "a" || runs[1].Task != "c" {
+		t.Errorf("runs = %+v, want the broken line skipped", runs)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	other := run(3, 0, 1)
+	other.Task = "build"
+	other.Commands = []Command{{Name: "compile", Command: []string{"go", "build"}}}
+	runs := []Run{run(0, 0, 1), run(1, 1, 1), run(2, 0, 1), other}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"all", Filter{}, 4},
+		{"task", Filter{Tasks: []string{"build"}}, 1},
+		{"since", Filter{Since: runs[1].Start}, 3},
+		{"failed", Filter{Failed: true}, 1},
+		{"command name", Filter{Command: "compile"}, 1},
+		{"command line", Filter{Command: "./..."}, 3},
+		{"combined", Filter{Tasks: []string{"test"}, Since: runs[2].Start}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Select(runs, tt.filter); len(got) != tt.want {
+				t.Errorf("selected %d runs, want %d", len(got), tt.want)
+			}
+		})
+	}
+
+	// Only the matching commands are kept
+	got := Select(runs, Filter{Command: "test"})
+	if len(got[0].Commands) != 1 || got[0].Commands[0].Name != "go test" {
+		t.Errorf("commands = %+v", got[0].Commands)
+	}
+	if len(runs[0].Commands) != 2 {
+		t.Error("selecting modified the runs")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	runs := []Run{
+		run(0, 1, 100),
+		run(1, 1, 200),
+		run(2, 1, 300),
+		run(3, 0, 400),
+		run(4, 1, 500),
+		run(5, 2, 600),
+	}
+	summary := Summarize(runs)
+	if len(summary) != 2 {
+		t.Fatalf("got %d stats, want 2", len(summary))
+	}
+
+	vet, test := summary[0], summary[1]
+	if vet.Name != "go vet" || vet.Failures != 0 || vet.Streak != 0 || vet.PassRate() != 100 {
+		t.Errorf("go vet = %+v", vet)
+	}
+	if test.Runs != 6 || test.Failures != 5 {
+		t.Errorf("go test: %d runs, %d failures", test.Runs, test.Failures)
+	}
+	if test.Average() != 350*time.Millisecond || test.Max() != 600*time.Millisecond {
+		t.Errorf("go test: average %s, max %s", test.Average(), test.Max())
+	}
+	if test.Streak != 2 || test.LongestStreak != 3 {
+		t.Errorf("go test: streak %d, longest %d", test.Streak, test.LongestStreak)
+	}
+	if !test.Last.Equal(runs[5].Start) {
+		t.Errorf("go test: last run %s", test.Last)
+	}
+}
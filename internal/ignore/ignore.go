@@ -0,0 +1,329 @@
+// Package ignore implements gitignore-style path matching for watch paths
+// and .gowatchignore files.
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FileName is the name of per-directory ignore files
+const FileName = ".gowatchignore"
+
+// RegexPrefix marks a pattern as a regular expression
+const RegexPrefix = "regex:"
+
+// pattern is a single parsed ignore rule
+type pattern struct {
+	// base is the absolute, slash-separated directory the rule is relative to
+	base string
+	// source identifies where the rule came from so files can be reloaded
+	source string
+	// text is the rule as written, for Explain
+	text     string
+	segments []string
+	// re is set for regex: patterns, which match instead of segments
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Matcher decides whether paths are ignored using gitignore semantics:
+//
+//   - blank lines and lines starting with # are skipped
+//   - a leading ! re-includes a previously ignored path
+//   - a trailing / only matches directories
+//   - a pattern containing a / (other than trailing) is anchored to the
+//     directory it was defined in; otherwise it matches at any depth
+//   - ** matches any number of directories
+//   - the last matching rule wins, and nothing inside an ignored directory
+//     can be re-included
+//   - a pattern starting with regex: is a regular expression matched
+//     against the slash-separated path relative to the directory it was
+//     defined in, with a trailing / for directories; it can be negated
+//     with a leading !
+//
+// Rules from deeper directories take precedence over shallower ones.
+type Matcher struct {
+	mu       sync.RWMutex
+	patterns []pattern
+}
+
+// New returns an empty matcher
+func New() *Matcher {
+	return &Matcher{}
+}
+
+// Add registers patterns relative to the base directory. The source is an
+// arbitrary label; adding again with the same source replaces those rules.
+func (m *Matcher) Add(base, source string, lines []string) {
+	base = normalize(base)
+
+	var parsed []pattern
+	for _, line := range lines {
+		if p, ok, err := parse(base, source, line); ok && err == nil {
+			parsed = append(parsed, p)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.patterns[:0]
+	for _, p := range m.patterns {
+		if p.source != source {
+			kept = append(kept, p)
+		}
+	}
+	if len(parsed) == 0 && len(kept) == len(m.patterns) {
+		// Nothing to add or replace, as for the many directories without
+		// an ignore file
+		return
+	}
+	m.patterns = append(kept, parsed...)
+
+	// Deeper directories override shallower ones; keep insertion order otherwise
+	sort.SliceStable(m.patterns, func(i, j int) bool {
+		return depth(m.patterns[i].base) < depth(m.patterns[j].base)
+	})
+}
+
+// AddFile loads an ignore file whose rules are relative to its directory.
+// A missing file clears any rules previously loaded from it. Invalid lines
+// are reported after the valid ones have been added.
+func (m *Matcher) AddFile(file string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", file, err)
+	}
+
+	lines, err := readLines(abs)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	m.Add(filepath.Dir(abs), abs, lines)
+	if err := Check(lines); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+// Check reports the first pattern that can't be parsed, such as a regex:
+// pattern with an invalid expression. Add skips such patterns.
+func Check(lines []string) error {
+	for _, line := range lines {
+		if _, _, err := parse("/", "", line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match reports whether the path is ignored. isDir must be true when the
+// path is a directory so that directory-only rules apply.
+func (m *Matcher) Match(p string, isDir bool) bool {
+	p = normalize(p)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.patterns) == 0 {
+		return false
+	}
+
+	// Nothing inside an ignored directory can be re-included
+	for dir := path.Dir(p); dir != "/" && dir != "."; dir = path.Dir(dir) {
+		if ignored, _ := m.match(dir, true); ignored {
+			return true
+		}
+	}
+
+	ignored, _ := m.match(p, isDir)
+	return ignored
+}
+
+// Explain is Match that also returns the rule deciding the outcome, as
+// "source: pattern", or "" if no rule matches the path. A path inside an
+// ignored directory is explained by the rule ignoring the directory.
+func (m *Matcher) Explain(p string, isDir bool) (ignored bool, rule string) {
+	p = normalize(p)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for dir := path.Dir(p); dir != "/" && dir != "."; dir = path.Dir(dir) {
+		if ignored, pat := m.match(dir, true); ignored {
+			return true, pat.describe()
+		}
+	}
+
+	ignored, pat := m.match(p, isDir)
+	if pat == nil {
+		return false, ""
+	}
+	return ignored, pat.describe()
+}
+
+// MatchPath is Match with the directory flag taken from the filesystem.
+// Paths that no longer exist are treated as files.
+func (m *Matcher) MatchPath(p string) bool {
+	info, err := os.Stat(p)
+	return m.Match(p, err == nil && info.IsDir())
+}
+
+// match returns whether the path is ignored and the last rule matching it,
+// nil if none does
+func (m *Matcher) match(p string, isDir bool) (bool, *pattern) {
+	ignored := false
+	var last *pattern
+	for i := range m.patterns {
+		pat := &m.patterns[i]
+		if pat.dirOnly && !isDir {
+			continue
+		}
+		rel, ok := relative(pat.base, p)
+		if !ok {
+			continue
+		}
+		if pat.re != nil {
+			if isDir {
+				rel += "/"
+			}
+			if pat.re.MatchString(rel) {
+				ignored, last = !pat.negate, pat
+			}
+			continue
+		}
+		if matchSegments(pat.segments, strings.Split(rel, "/")) {
+			ignored, last = !pat.negate, pat
+		}
+	}
+	return ignored, last
+}
+
+// describe names the rule and where it came from
+func (p *pattern) describe() string {
+	return p.source + ": " + p.text
+}
+
+// parse converts one line of an ignore file into a rule. It returns false
+// for lines that hold no rule and an error for invalid ones.
+func parse(base, source, line string) (pattern, bool, error) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false, nil
+	}
+
+	p := pattern{base: base, source: source, text: line}
+
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+
+	if expr, ok := strings.CutPrefix(line, RegexPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return pattern{}, false, fmt.Errorf("invalid pattern %q: %w", line, err)
+		}
+		p.re = re
+		return p, true, nil
+	}
+
+	line = filepath.ToSlash(line)
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A slash anywhere but the end anchors the rule to its directory
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return pattern{}, false, nil
+	}
+
+	p.segments = strings.Split(line, "/")
+	if !anchored && p.segments[0] != "**" {
+		p.segments = append([]string{"**"}, p.segments...)
+	}
+
+	return p, true, nil
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" segment matches zero or more path segments
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			pat = pat[1:]
+			if len(pat) == 0 {
+				// A trailing ** matches everything inside, not the directory itself
+				return len(segs) > 0
+			}
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat, segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pat[0], segs[0]); err != nil || !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
+
+// normalize returns an absolute, cleaned, slash-separated path
+func normalize(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	return filepath.ToSlash(filepath.Clean(p))
+}
+
+// relative returns p relative to base if p is strictly inside base
+func relative(base, p string) (string, bool) {
+	if base == "/" {
+		return strings.TrimPrefix(p, "/"), p != "/"
+	}
+	if !strings.HasPrefix(p, base+"/") {
+		return "", false
+	}
+	return p[len(base)+1:], true
+}
+
+func depth(p string) int {
+	return strings.Count(p, "/")
+}
+
+func readLines(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
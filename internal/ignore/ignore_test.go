@@ -0,0 +1,189 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatcher_Match(t *testing.T) {
+	m := New()
+	m.Add("/project", "test", []string{
+		"# comment",
+		"",
+		"*.log",
+		"!keep.log",
+		"/build",
+		"vendor/**",
+		"**/node_modules/**",
+		"docs/*.md",
+		"tmp/",
+		"a/**/z",
+	})
+
+	tests := []struct {
+		path   string
+		isDir  bool
+		ignore bool
+	}{
+		{"/project/main.go", false, false},
+		{"/project/debug.log", false, true},
+		{"/project/sub/deep/debug.log", false, true},
+		{"/project/keep.log", false, false},
+		{"/project/build", true, true},
+		{"/project/build/out.bin", false, true},
+		{"/project/sub/build", true, false},
+		{"/project/vendor/pkg/file.go", false, true},
+		{"/project/vendor", true, false},
+		{"/project/web/node_modules/react/index.js", false, true},
+		{"/project/docs/readme.md", false, true},
+		{"/project/docs/api/readme.md", false, false},
+		{"/project/tmp", true, true},
+		{"/project/tmp", false, false},
+		{"/project/x/tmp/file", false, true},
+		{"/project/a/z", false, true},
+		{"/project/a/b/c/z", false, true},
+		{"/other/debug.log", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := m.Match(tt.path, tt.isDir); got != tt.ignore {
+				t.Errorf("Match(%s, %v) = %v, want %v", tt.path, tt.isDir, got, tt.ignore)
+			}
+		})
+	}
+}
+
+func TestMatcher_NegationInsideIgnoredDir(t *testing.T) {
+	m := New()
+	m.Add("/project", "test", []string{"dist/", "!dist/keep.js"})
+
+	if !m.Match("/project/dist/keep.js", false) {
+		t.Error("files inside an ignored directory must not be re-included")
+	}
+}
+
+func TestMatcher_Regex(t *testing.T) {
+	m := New()
+	m.Add("/project", "test", []string{
+		`regex:(^|/)generated/.+\.go$`,
+		"!*_manual.go",
+		`regex:^tmp-\d+/$`,
+		`!regex:^cache/keep\.`,
+		"cache/",
+		"regex:[", // invalid, skipped
+	})
+
+	tests := []struct {
+		path   string
+		isDir  bool
+		ignore bool
+	}{
+		{"/project/generated/api.go", false, true},
+		{"/project/pkg/generated/deep/types.go", false, true},
+		{"/project/pkg/generated/types_manual.go", false, false},
+		{"/project/pkg/generated", true, false},
+		{"/project/pkg/generated/README.md", false, false},
+		{"/project/regenerated/api.go", false, false},
+		{"/project/tmp-42", true, true},
+		{"/project/tmp-42/file.txt", false, true},
+		{"/project/tmp-42", false, false},
+		{"/project/sub/tmp-42", true, false},
+		{"/project/cache/keep.json", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := m.Match(tt.path, tt.isDir); got != tt.ignore {
+				t.Errorf("Match(%s, %v) = %v, want %v", tt.path, tt.isDir, got, tt.ignore)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if err := Check([]string{"*.log", "!keep.log", `regex:\.tmp$`, "# regex:[ in a comment"}); err != nil {
+		t.Errorf("valid patterns: %v", err)
+	}
+	if err := Check([]string{"*.log", "!regex:(unclosed"}); err == nil {
+		t.Error("expected an error for an invalid regex")
+	}
+}
+
+func TestMatcher_NestedFiles(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gowatch-ignore-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	sub := filepath.Join(tmpDir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatalf("failed to create subdir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, FileName), []byte("*.gen.go\n"), 0644); err != nil {
+		t.Fatalf("failed to write ignore file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, FileName), []byte("!keep.gen.go\n"), 0644); err != nil {
+		t.Fatalf("failed to write ignore file: %v", err)
+	}
+
+	m := New()
+	if err := m.AddFile(filepath.Join(tmpDir, FileName)); err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+	if err := m.AddFile(filepath.Join(sub, FileName)); err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+
+	if !m.Match(filepath.Join(tmpDir, "a.gen.go"), false) {
+		t.Error("expected root rule to apply")
+	}
+	if !m.Match(filepath.Join(sub, "b.gen.go"), false) {
+		t.Error("expected root rule to apply in subdirectory")
+	}
+	if m.Match(filepath.Join(sub, "keep.gen.go"), false) {
+		t.Error("expected nested rule to override root rule")
+	}
+
+	// Reloading replaces the previous rules from the same file
+	if err := os.WriteFile(filepath.Join(tmpDir, FileName), []byte("*.txt\n"), 0644); err != nil {
+		t.Fatalf("failed to rewrite ignore file: %v", err)
+	}
+	if err := m.AddFile(filepath.Join(tmpDir, FileName)); err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+	if m.Match(filepath.Join(tmpDir, "a.gen.go"), false) {
+		t.Error("expected old rules to be replaced on reload")
+	}
+	if !m.Match(filepath.Join(tmpDir, "notes.txt"), false) {
+		t.Error("expected new rules after reload")
+	}
+}
+
+func TestMatcher_Explain(t *testing.T) {
+	m := New()
+	m.Add("/project", "config", []string{"*.log", "!keep.log", "build/"})
+	m.Add("/project/web", "web", []string{"dist/**"})
+
+	tests := []struct {
+		path    string
+		ignored bool
+		rule    string
+	}{
+		{"/project/main.go", false, ""},
+		{"/project/debug.log", true, "config: *.log"},
+		{"/project/keep.log", false, "config: !keep.log"},
+		{"/project/build/out.bin", true, "config: build/"},
+		{"/project/web/dist/app.js", true, "web: dist/**"},
+	}
+
+	for _, tt := range tests {
+		ignored, rule := m.Explain(tt.path, false)
+		if ignored != tt.ignored || rule != tt.rule {
+			t.Errorf("Explain(%q) = %v, %q, want %v, %q", tt.path, ignored, rule, tt.ignored, tt.rule)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Package junit writes runs as JUnit XML reports, one test case per
+// command, so that CI servers and dashboards can track the checks gowatch
+// runs
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gowatch/pkg/runner"
+)
+
+// Suites is the root element of a report
+type Suites struct {
+	XMLName  xml.Name `xml:"testsuites"`
+	Name     string   `xml:"name,attr"`
+	Tests    int      `xml:"tests,attr"`
+	Failures int      `xml:"failures,attr"`
+	Skipped  int      `xml:"skipped,attr"`
+	Time     string   `xml:"time,attr"`
+	Suites   []Suite  `xml:"testsuite"`
+}
+
+// Suite is a run of a pipeline
+type Suite struct {
+	Name       string     `xml:"name,attr"`
+	Tests      int        `xml:"tests,attr"`
+	Failures   int        `xml:"failures,attr"`
+	Skipped    int        `xml:"skipped,attr"`
+	Time       string     `xml:"time,attr"`
+	Timestamp  string     `xml:"timestamp,attr"`
+	Properties []Property `xml:"properties>property,omitempty"`
+	Cases      []Case     `xml:"testcase"`
+}
+
+// Property describes the change that started a run
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// Case is a command of a run
+type Case struct {
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr"`
+	Time      string   `xml:"time,attr"`
+	Failure   *Failure `xml:"failure,omitempty"`
+	Skipped   *Skipped `xml:"skipped,omitempty"`
+}
+
+// Failure is why a command failed, with the last lines of its output
+type Failure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Output  string `xml:",chardata"`
+}
+
+// Skipped marks a command stopped by shutdown
+type Skipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// New builds the report of a run. names holds the names of the pipeline's
+// on_change commands by index; commands without one are named after the
+// command line they ran.
+func New(report runner.Report, names []string) Suites {
+	suite := Suite{
+		Name:      report.Task,
+		Time:      seconds(report.Duration),
+		Timestamp: report.Start.Format("2006-01-02T15:04:05"),
+		Properties: []Property{
+			{Name: "run_id", Value: strconv.FormatInt(report.Trigger.RunID, 10)},
+			{Name: "event", Value: report.Trigger.Event},
+		},
+		Cases: []Case{},
+	}
+	if report.Trigger.Path != "" {
+		suite.Properties = append(suite.Properties, Property{Name: "path", Value: report.Trigger.Path})
+	}
+
+	for _, r := range report.Results {
+		c := Case{
+			Name:      strings.Join(r.Command, " "),
+			ClassName: "gowatch." + report.Task,
+			Time:      seconds(r.Duration),
+		}
+		if r.Index >= 0 && r.Index < len(names) && names[r.Index] != "" {
+			c.Name = names[r.Index]
+		}
+		switch {
+		case r.Canceled:
+			c.Skipped = &Skipped{Message: "canceled"}
+			suite.Skipped++
+		case r.ExitCode != 0:
+			c.Failure = &Failure{Type: fmt.Sprintf("exit %d", r.ExitCode), Output: strings.Join(r.Excerpt, "\n")}
+			if r.TimedOut {
+				c.Failure.Type = "timeout"
+			}
+			if r.Error != nil {
+				c.Failure.Message = r.Error.Error()
+			}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	suite.Tests = len(suite.Cases)
+
+	return Suites{
+		Name:     "gowatch",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []Suite{suite},
+	}
+}
+
+// Write writes the report of a run to path, replacing the previous one
+// atomically so that readers never see a partial write
+func Write(path string, report runner.Report, names []string) error {
+	data, err := xml.MarshalIndent(New(report, names), "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// seconds formats a duration as JUnit times are, in seconds
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
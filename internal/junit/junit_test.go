@@ -0,0 +1,66 @@
+package junit
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gowatch/pkg/runner"
+)
+
+func TestWrite(t *testing.T) {
+	report := runner.Report{
+		Task:     "default",
+		Trigger:  runner.Trigger{Path: "main.go", Event: "WRITE", RunID: 4},
+		Start:    time.Date(2024, 5, 1, 14, 3, 7, 0, time.UTC),
+		Duration: 2500 * time.Millisecond,
+		Results: []runner.RunResult{
+			{Command: []string{"go", "vet", "./..."}, Duration: 300 * time.Millisecond, Index: 0},
+			{Command: []string{"go", "test", "./..."}, ExitCode: 1, Duration: 2 * time.Second, Index: 1,
+				Error: errors.New("exit status 1"), Excerpt: []string{"--- FAIL: TestParse", "FAIL"}},
+			{Command: []string{"sleep", "60"}, ExitCode: -1, Index: 2, Canceled: true},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "reports", "gowatch.xml")
+	if err := Write(path, report, []string{"vet", "", "sleep"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Errorf("report starts with %q, want the XML header", data[:20])
+	}
+	var got Suites
+	if err := xml.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Tests != 3 || got.Failures != 1 || got.Skipped != 1 || got.Time != "2.500" {
+		t.Errorf("testsuites = %d tests, %d failures, %d skipped in %s", got.Tests, got.Failures, got.Skipped, got.Time)
+	}
+	suite := got.Suites[0]
+	if suite.Name != "default" || suite.Timestamp != "2024-05-01T14:03:07" {
+		t.Errorf("testsuite = %q at %q", suite.Name, suite.Timestamp)
+	}
+
+	cases := suite.Cases
+	if cases[0].Name != "vet" || cases[0].Time != "0.300" || cases[0].Failure != nil {
+		t.Errorf("testcase 0 = %+v, want vet passing", cases[0])
+	}
+	failure := cases[1].Failure
+	if cases[1].Name != "go test ./..." || failure == nil {
+		t.Fatalf("testcase 1 = %+v, want go test ./... failing", cases[1])
+	}
+	if failure.Type != "exit 1" || failure.Message != "exit status 1" || failure.Output != "--- FAIL: TestParse\nFAIL" {
+		t.Errorf("failure = %+v", failure)
+	}
+	if cases[2].Skipped == nil || cases[2].Failure != nil {
+		t.Errorf("testcase 2 = %+v, want skipped", cases[2])
+	}
+}
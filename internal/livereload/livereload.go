@@ -0,0 +1,209 @@
+// Package livereload serves the LiveReload protocol so that browsers
+// refresh after a successful build. It works with the LiveReload browser
+// extensions and with the small client script it serves itself.
+package livereload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gowatch/internal/websocket"
+	"gowatch/pkg/logger"
+)
+
+// protocol is the LiveReload protocol version spoken by the server
+const protocol = "http://livereload.com/protocols/official-7"
+
+// message is a LiveReload protocol command
+type message struct {
+	Command    string   `json:"command"`
+	Protocols  []string `json:"protocols,omitempty"`
+	ServerName string   `json:"serverName,omitempty"`
+	Path       string   `json:"path,omitempty"`
+	LiveCSS    bool     `json:"liveCSS,omitempty"`
+}
+
+// Server accepts browser connections and tells them to reload
+type Server struct {
+	addr string
+	log  *logger.Logger
+	http *http.Server
+
+	mu      sync.Mutex
+	clients map[*websocket.Conn]struct{}
+}
+
+// New creates a LiveReload server for addr (e.g. ":35729")
+func New(addr string, log *logger.Logger) *Server {
+	s := &Server{
+		addr:    addr,
+		log:     log,
+		clients: make(map[*websocket.Conn]struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /livereload", s.handleConnect)
+	mux.HandleFunc("GET /livereload.js", s.handleScript)
+
+	s.http = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// Start binds the listener and serves in the background
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+
+	go func() {
+		if err := s.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("LiveReload server error: %v", err)
+		}
+	}()
+
+	s.log.Success("LiveReload listening on http://%s/livereload.js", ln.Addr())
+	return nil
+}
+
+// Handler serves the LiveReload endpoints, /livereload.js and /livereload,
+// for mounting them on another server
+func (s *Server) Handler() http.Handler {
+	return s.http.Handler
+}
+
+// Shutdown stops the server and disconnects all browsers
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	for conn := range s.clients {
+		conn.Close()
+		delete(s.clients, conn)
+	}
+	s.mu.Unlock()
+
+	return s.http.Shutdown(ctx)
+}
+
+// Reload tells every connected browser to reload after path changed and
+// returns how many were notified. Stylesheet changes are applied in place.
+func (s *Server) Reload(path string) int {
+	data, err := json.Marshal(message{
+		Command: "reload",
+		Path:    path,
+		LiveCSS: true,
+	})
+	if err != nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	clients := make([]*websocket.Conn, 0, len(s.clients))
+	for conn := range s.clients {
+		clients = append(clients, conn)
+	}
+	s.mu.Unlock()
+
+	sent := 0
+	for _, conn := range clients {
+		if err := conn.WriteText(data); err != nil {
+			s.remove(conn)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		s.log.Debug("LiveReload: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	s.clients[conn] = struct{}{}
+	s.mu.Unlock()
+	defer s.remove(conn)
+
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg message
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		if msg.Command == "hello" {
+			reply, _ := json.Marshal(message{
+				Command:    "hello",
+				Protocols:  []string{protocol},
+				ServerName: "gowatch",
+			})
+			if err := conn.WriteText(reply); err != nil {
+				return
+			}
+			s.log.Debug("LiveReload: browser connected")
+		}
+	}
+}
+
+func (s *Server) handleScript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/javascript")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(clientScript))
+}
+
+func (s *Server) remove(conn *websocket.Conn) {
+	s.mu.Lock()
+	delete(s.clients, conn)
+	s.mu.Unlock()
+	conn.Close()
+}
+
+// clientScript connects back to the server it was loaded from. Include it
+// with <script src="http://localhost:35729/livereload.js"></script>.
+var clientScript = strings.TrimSpace(`
+(function () {
+  var src = document.currentScript && document.currentScript.src;
+  var url = src
+    ? src.replace(/^http/, "ws").replace(/\.js(\?.*)?$/, "")
+    : "ws://" + location.hostname + ":35729/livereload";
+
+  function reloadStylesheets() {
+    var links = document.querySelectorAll('link[rel="stylesheet"]');
+    for (var i = 0; i < links.length; i++) {
+      var href = links[i].href.replace(/([?&])livereload=\d+&?/, "$1").replace(/[?&]$/, "");
+      links[i].href = href + (href.indexOf("?") < 0 ? "?" : "&") + "livereload=" + Date.now();
+    }
+  }
+
+  function connect() {
+    var ws = new WebSocket(url);
+    ws.onopen = function () {
+      ws.send(JSON.stringify({ command: "hello", protocols: ["`+protocol+`"] }));
+    };
+    ws.onmessage = function (e) {
+      var msg = JSON.parse(e.data);
+      if (msg.command !== "reload") return;
+      if (msg.liveCSS && /\.css$/i.test(msg.path || "")) reloadStylesheets();
+      else location.reload();
+    };
+    ws.onclose = function () { setTimeout(connect, 1000); };
+  }
+
+  connect();
+})();
+`) + "\n"
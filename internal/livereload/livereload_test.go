@@ -0,0 +1,103 @@
+package livereload
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gowatch/pkg/logger"
+)
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	s := New("127.0.0.1:0", logger.New(logger.LevelError, false))
+	ts := httptest.NewServer(s.http.Handler)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func TestServer_Script(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/livereload.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	if !strings.Contains(string(body), "new WebSocket(url)") {
+		t.Errorf("unexpected script: %s", body)
+	}
+}
+
+func TestServer_HelloAndReload(t *testing.T) {
+	s, ts := newTestServer(t)
+
+	if n := s.Reload("index.html"); n != 0 {
+		t.Errorf("Reload() without browsers = %d, want 0", n)
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req := "GET /livereload HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	conn.Write([]byte(req))
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake failed: %v", err)
+	}
+
+	// Masked client text frame
+	hello := []byte(`{"command":"hello","protocols":["` + protocol + `"]}`)
+	frame := []byte{0x81, 0x80 | byte(len(hello)), 0, 0, 0, 0}
+	conn.Write(append(frame, hello...))
+
+	var reply message
+	if err := json.Unmarshal(readFrame(t, br), &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Command != "hello" || len(reply.Protocols) != 1 || reply.Protocols[0] != protocol {
+		t.Errorf("hello reply = %+v", reply)
+	}
+
+	if n := s.Reload("css/site.css"); n != 1 {
+		t.Fatalf("Reload() = %d, want 1", n)
+	}
+	var reload message
+	if err := json.Unmarshal(readFrame(t, br), &reload); err != nil {
+		t.Fatal(err)
+	}
+	if reload.Command != "reload" || reload.Path != "css/site.css" || !reload.LiveCSS {
+		t.Errorf("reload message = %+v", reload)
+	}
+}
+
+// readFrame reads a short unmasked server frame
+func readFrame(t *testing.T, br *bufio.Reader) []byte {
+	t.Helper()
+
+	var header [2]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, header[1]&0x7F)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
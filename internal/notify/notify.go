@@ -0,0 +1,128 @@
+// Package notify announces finished runs with native desktop notifications
+// and sounds
+package notify
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"gowatch/pkg/runner"
+)
+
+// Message is a notification about a finished run
+type Message struct {
+	Title   string
+	Body    string
+	Success bool
+}
+
+// ForReport builds the notification for a run report
+func ForReport(report runner.Report) Message {
+	task := ""
+	if report.Task != "" && report.Task != "default" {
+		task = " " + report.Task
+	}
+
+	passed := 0
+	var failed *runner.RunResult
+	for i, r := range report.Results {
+		if r.ExitCode == 0 {
+			passed++
+		} else if failed == nil {
+			failed = &report.Results[i]
+		}
+	}
+
+	if failed == nil {
+		return Message{
+			Title:   fmt.Sprintf("gowatch%s: succeeded", task),
+			Body:    fmt.Sprintf("%d/%d commands passed in %s", passed, len(report.Results), roundDuration(report.Duration)),
+			Success: true,
+		}
+	}
+
+	outcome := fmt.Sprintf("exited with %d", failed.ExitCode)
+	if failed.TimedOut {
+		outcome = fmt.Sprintf("timed out after %s", roundDuration(failed.Duration))
+	}
+	body := fmt.Sprintf("%s %s", strings.Join(failed.Command, " "), outcome)
+	if failed.Tests != nil && failed.Tests.Failed > 0 {
+		// The tests that failed say more than the exit code
+		body = failed.Tests.Failures()
+	}
+	return Message{
+		Title: fmt.Sprintf("gowatch%s: failed", task),
+		Body:  fmt.Sprintf("%s (%d/%d passed)", body, passed, len(report.Results)),
+	}
+}
+
+// Send shows the notification using the platform's native mechanism
+func Send(m Message) error {
+	cmd, err := command(runtime.GOOS, m)
+	if err != nil {
+		return err
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to send notification: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// command builds the notifier invocation for goos
+func command(goos string, m Message) (*exec.Cmd, error) {
+	switch goos {
+	case "darwin":
+		sound := "Glass"
+		if !m.Success {
+			sound = "Basso"
+		}
+		script := fmt.Sprintf("display notification %s with title %s sound name %s",
+			appleScriptQuote(m.Body), appleScriptQuote(m.Title), appleScriptQuote(sound))
+		return exec.Command("osascript", "-e", script), nil
+
+	case "windows":
+		systemIcon, icon := "Information", "Info"
+		if !m.Success {
+			systemIcon, icon = "Error", "Error"
+		}
+		// A balloon tip works on every Windows version without extra modules
+		script := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms
+$n = New-Object System.Windows.Forms.NotifyIcon
+$n.Icon = [System.Drawing.SystemIcons]::%s
+$n.Visible = $true
+$n.ShowBalloonTip(5000, %s, %s, [System.Windows.Forms.ToolTipIcon]::%s)
+Start-Sleep -Seconds 5
+$n.Dispose()`, systemIcon, powerShellQuote(m.Title), powerShellQuote(m.Body), icon)
+		return exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script), nil
+
+	default:
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return nil, fmt.Errorf("notify-send not found (install libnotify)")
+		}
+		urgency, icon := "normal", "dialog-information"
+		if !m.Success {
+			urgency, icon = "critical", "dialog-error"
+		}
+		return exec.Command("notify-send", "-a", "gowatch", "-u", urgency, "-i", icon, m.Title, m.Body), nil
+	}
+}
+
+// roundDuration keeps durations readable
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(100 * time.Millisecond)
+}
+
+func appleScriptQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func powerShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
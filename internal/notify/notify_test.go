@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gowatch/pkg/runner"
+)
+
+func TestForReport(t *testing.T) {
+	tests := []struct {
+		name        string
+		report      runner.Report
+		wantTitle   string
+		wantBody    string
+		wantSuccess bool
+	}{
+		{
+			name: "success",
+			report: runner.Report{
+				Task:     "default",
+				Duration: 1234 * time.Millisecond,
+				Results: []runner.RunResult{
+					{Command: []string{"go", "build"}},
+					{Command: []string{"go", "vet"}},
+				},
+			},
+			wantTitle:   "gowatch: succeeded",
+			wantBody:    "2/2 commands passed in 1.2s",
+			wantSuccess: true,
+		},
+		{
+			name: "failure in task",
+			report: runner.Report{
+				Task: "test",
+				Results: []runner.RunResult{
+					{Command: []string{"go", "build"}},
+					{Command: []string{"go", "test", "./..."}, ExitCode: 1},
+				},
+			},
+			wantTitle: "gowatch test: failed",
+			wantBody:  "go test ./... exited with 1 (1/2 passed)",
+		},
+		{
+			name: "timeout",
+			report: runner.Report{
+				Task: "default",
+				Results: []runner.RunResult{
+					{Command: []string{"go", "test", "./..."}, ExitCode: -1, Duration: time.Minute, TimedOut: true},
+				},
+			},
+			wantTitle: "gowatch: failed",
+			wantBody:  "go test ./... timed out after 1m0s (0/1 passed)",
+		},
+		{
+			name: "failed tests",
+			report: runner.Report{
+				Task: "default",
+				Results: []runner.RunResult{
+					{Command: []string{"go", "test", "-json", "./..."}, ExitCode: 1, Tests: &runner.TestSummary{
+						Passed:      10,
+						Failed:      4,
+						FailedTests: []string{"TestA", "TestB", "TestC", "TestD"},
+					}},
+				},
+			},
+			wantTitle: "gowatch: failed",
+			wantBody:  "4 tests failed: TestA, TestB, TestC and 1 more (0/1 passed)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := ForReport(tt.report)
+			if m.Title != tt.wantTitle {
+				t.Errorf("Title = %q, want %q", m.Title, tt.wantTitle)
+			}
+			if m.Body != tt.wantBody {
+				t.Errorf("Body = %q, want %q", m.Body, tt.wantBody)
+			}
+			if m.Success != tt.wantSuccess {
+				t.Errorf("Success = %v, want %v", m.Success, tt.wantSuccess)
+			}
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	m := Message{Title: `say "hi"`, Body: "it's done", Success: false}
+
+	darwin, err := command("darwin", m)
+	if err != nil {
+		t.Fatalf("darwin: %v", err)
+	}
+	script := darwin.Args[len(darwin.Args)-1]
+	if !strings.Contains(script, `with title "say \"hi\""`) || !strings.Contains(script, `sound name "Basso"`) {
+		t.Errorf("unexpected osascript: %s", script)
+	}
+
+	windows, err := command("windows", m)
+	if err != nil {
+		t.Fatalf("windows: %v", err)
+	}
+	script = windows.Args[len(windows.Args)-1]
+	if !strings.Contains(script, `'it''s done'`) || !strings.Contains(script, "ToolTipIcon]::Error") {
+		t.Errorf("unexpected powershell script: %s", script)
+	}
+}
+
+func TestSoundCommand(t *testing.T) {
+	darwin, err := soundCommand("darwin", "/sounds/fail.aiff")
+	if err != nil {
+		t.Fatalf("darwin: %v", err)
+	}
+	if got := strings.Join(darwin.Args, " "); got != "afplay /sounds/fail.aiff" {
+		t.Errorf("darwin player = %s", got)
+	}
+
+	windows, err := soundCommand("windows", `C:\it's\fail.wav`)
+	if err != nil {
+		t.Fatalf("windows: %v", err)
+	}
+	script := windows.Args[len(windows.Args)-1]
+	if script != `(New-Object Media.SoundPlayer 'C:\it''s\fail.wav').PlaySync()` {
+		t.Errorf("unexpected powershell script: %s", script)
+	}
+}
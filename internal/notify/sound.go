@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// soundPlayers are the command-line players tried in turn on Linux and other
+// Unix systems, for PulseAudio, PipeWire and ALSA
+var soundPlayers = []string{"paplay", "pw-play", "aplay"}
+
+// PlaySound plays an audio file using the platform's player and waits for
+// it to finish
+func PlaySound(path string) error {
+	cmd, err := soundCommand(runtime.GOOS, path)
+	if err != nil {
+		return err
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to play %s: %w: %s", path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// soundCommand builds the player invocation for goos
+func soundCommand(goos, path string) (*exec.Cmd, error) {
+	switch goos {
+	case "darwin":
+		return exec.Command("afplay", path), nil
+
+	case "windows":
+		// SoundPlayer plays WAV files without extra modules
+		script := fmt.Sprintf("(New-Object Media.SoundPlayer %s).PlaySync()", powerShellQuote(path))
+		return exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script), nil
+
+	default:
+		for _, player := range soundPlayers {
+			if _, err := exec.LookPath(player); err == nil {
+				return exec.Command(player, path), nil
+			}
+		}
+		return nil, fmt.Errorf("no audio player found (%s)", strings.Join(soundPlayers, ", "))
+	}
+}
@@ -0,0 +1,86 @@
+// Package problems picks the errors that compilers, linters and tests
+// report in their output and writes them in one format, for editors such as
+// VS Code to read with a problem matcher
+package problems
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Markers bracket the problems of each run, for the background patterns of
+// a problem matcher
+const (
+	BeginMarker = "[gowatch] run started"
+	EndMarker   = "[gowatch] run finished"
+)
+
+// Locations are recognized as a file with an extension, which keeps times
+// and URLs out, followed by the line and optionally the column:
+//
+//	./main.go:12:2: undefined: cfg          go build, go vet, gcc, eslint
+//	    main_test.go:40: got 1, want 2      go test
+//	src/app.ts(12,5): error TS2304: ...     tsc
+var (
+	colonLocation = regexp.MustCompile(`^\s*((?:[A-Za-z]:[\\/])?[^\s:()]*\.[A-Za-z0-9]+):(\d+)(?::(\d+))?:\s+(\S.*)$`)
+	parenLocation = regexp.MustCompile(`^\s*((?:[A-Za-z]:[\\/])?[^\s:()]*\.[A-Za-z0-9]+)\((\d+),(\d+)\):\s+(\S.*)$`)
+)
+
+// Problem is an error reported at a location in a file
+type Problem struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// Parse returns the problem a line of output reports, if any. Lines that
+// give no column report column 1.
+func Parse(line string) (Problem, bool) {
+	line = ansi.Strip(line)
+	m := colonLocation.FindStringSubmatch(line)
+	if m == nil {
+		m = parenLocation.FindStringSubmatch(line)
+	}
+	if m == nil {
+		return Problem{}, false
+	}
+	p := Problem{File: m[1], Column: 1, Message: strings.TrimSpace(m[4])}
+	p.Line, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		p.Column, _ = strconv.Atoi(m[3])
+	}
+	if p.Line == 0 {
+		return Problem{}, false
+	}
+	return p, true
+}
+
+// Resolve makes the file of a problem absolute against dir, the directory
+// the command ran in, if it is there. Files that aren't, such as those go
+// test reports relative to their package, are left for the editor to find.
+func (p Problem) Resolve(dir string) Problem {
+	if dir == "" || filepath.IsAbs(p.File) {
+		return p
+	}
+	if path := filepath.Join(dir, p.File); fileExists(path) {
+		p.File = path
+	}
+	return p
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// String formats a problem as file:line:col: message
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+}
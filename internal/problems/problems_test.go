@@ -0,0 +1,57 @@
+package problems
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+		ok   bool
+	}{
+		{"./main.go:12:2: undefined: cfg", "./main.go:12:2: undefined: cfg", true},
+		{"    main_test.go:40: got 1, want 2", "main_test.go:40:1: got 1, want 2", true},
+		{"src/app.ts(12,5): error TS2304: Cannot find name 'x'.", "src/app.ts:12:5: error TS2304: Cannot find name 'x'.", true},
+		{"\x1b[1mpkg/a.go:3:1:\x1b[0m bad", "pkg/a.go:3:1: bad", true},
+		{"lib/util.c:3:10: warning: unused variable", "lib/util.c:3:10: warning: unused variable", true},
+		{`C:\src\app\main.go:7:1: syntax error`, `C:\src\app\main.go:7:1: syntax error`, true},
+		{"12:03:07 [ERROR] Execution completed with errors", "", false},
+		{"see https://example.com:443: refused", "", false},
+		{"main.go:0: no line", "", false},
+		{"--- FAIL: TestParse (0.00s)", "", false},
+		{"Makefile:12: *** missing separator.  Stop.", "", false},
+	}
+	for _, tt := range tests {
+		p, ok := Parse(tt.line)
+		if ok != tt.ok {
+			t.Errorf("Parse(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if ok && p.String() != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.line, p.String(), tt.want)
+		}
+	}
+}
+
+func TestProblem_Resolve(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "main.go"), nil, 0644)
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"main.go", filepath.Join(dir, "main.go")},
+		{"./main.go", filepath.Join(dir, "main.go")},
+		{"main_test.go", "main_test.go"},
+		{filepath.Join(dir, "other.go"), filepath.Join(dir, "other.go")},
+	}
+	for _, tt := range tests {
+		if got := (Problem{File: tt.file}).Resolve(dir).File; got != tt.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Package proxy serves a reverse proxy in front of the app gowatch restarts.
+// It holds requests while the app is rebuilt and restarted, and waits for
+// the app to accept connections, so that browsers get the new version
+// instead of "connection refused".
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"gowatch/pkg/logger"
+)
+
+// retryInterval is how often the app is dialed while it doesn't accept
+// connections
+const retryInterval = 100 * time.Millisecond
+
+// Server forwards requests to the app, holding them while it restarts
+type Server struct {
+	addr    string
+	target  *url.URL
+	timeout time.Duration
+	log     *logger.Logger
+	http    *http.Server
+	dialer  net.Dialer
+
+	mu sync.Mutex
+	// gate is open (nil) while requests pass; held requests wait for it to
+	// close
+	gate chan struct{}
+	// holds counts the calls to Hold, so that a release that was overtaken
+	// by another hold leaves the gate shut
+	holds int
+}
+
+// New creates a proxy serving addr (e.g. ":3000") that forwards to target.
+// Requests wait up to timeout for the app.
+func New(addr string, target *url.URL, timeout time.Duration, log *logger.Logger) *Server {
+	s := &Server{
+		addr:    addr,
+		target:  target,
+		timeout: timeout,
+		log:     log,
+		dialer:  net.Dialer{Timeout: 5 * time.Second},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = s.dial
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+		},
+		Transport:    transport,
+		ErrorHandler: s.handleError,
+	}
+	s.http = &http.Server{
+		Handler:           s.hold(proxy),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// Start binds the listener and serves in the background
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+
+	go func() {
+		if err := s.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("Proxy server error: %v", err)
+		}
+	}()
+
+	s.log.Success("Proxy listening on http://%s → %s", ln.Addr(), s.target)
+	return nil
+}
+
+// Shutdown stops the server, letting held requests go
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.open()
+	s.mu.Unlock()
+	return s.http.Shutdown(ctx)
+}
+
+// Hold makes new requests wait until Release, such as while the app is
+// rebuilt
+func (s *Server) Hold() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.holds++
+	if s.gate == nil {
+		s.gate = make(chan struct{})
+	}
+}
+
+// Release lets the held requests through once the app accepts
+// connections, or once the timeout has passed without it doing so
+func (s *Server) Release() {
+	s.mu.Lock()
+	holds := s.holds
+	s.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+		if conn, err := s.dial(ctx, "tcp", s.target.Host); err == nil {
+			conn.Close()
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.holds == holds {
+			s.open()
+		}
+	}()
+}
+
+// open lets requests pass. s.mu must be held.
+func (s *Server) open() {
+	if s.gate != nil {
+		close(s.gate)
+		s.gate = nil
+	}
+}
+
+// hold wraps a handler so that requests wait while the gate is shut
+func (s *Server) hold(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		gate := s.gate
+		s.mu.Unlock()
+		if gate != nil {
+			timer := time.NewTimer(s.timeout)
+			defer timer.Stop()
+			select {
+			case <-gate:
+			case <-timer.C:
+				http.Error(w, "gowatch: the app is still being rebuilt", http.StatusServiceUnavailable)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// dial connects to the app, retrying while it doesn't accept connections,
+// such as while it starts, until the timeout
+func (s *Server) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	deadline := time.Now().Add(s.timeout)
+	for {
+		conn, err := s.dialer.DialContext(ctx, network, addr)
+		if err == nil || ctx.Err() != nil || time.Now().After(deadline) {
+			return conn, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+func (s *Server) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Context().Err() != nil {
+		return
+	}
+	s.log.Debug("Proxy: %s %s: %v", r.Method, r.URL.Path, err)
+	http.Error(w, fmt.Sprintf("gowatch: the app at %s is not responding: %v", s.target.Host, err), http.StatusBadGateway)
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"gowatch/pkg/logger"
+)
+
+func newTestProxy(t *testing.T, target string, timeout time.Duration) (*Server, *httptest.Server) {
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New("127.0.0.1:0", u, timeout, logger.New(logger.LevelError, false))
+	ts := httptest.NewServer(s.http.Handler)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func newApp(t *testing.T, body string) *httptest.Server {
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(app.Close)
+	return app
+}
+
+type response struct {
+	status int
+	body   string
+	err    error
+}
+
+// get requests url in the background
+func get(url string) <-chan response {
+	ch := make(chan response, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			ch <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		ch <- response{resp.StatusCode, string(body), err}
+	}()
+	return ch
+}
+
+func TestServer_HoldAndRelease(t *testing.T) {
+	app := newApp(t, "v2")
+	s, ts := newTestProxy(t, app.URL, 5*time.Second)
+
+	s.Hold()
+	done := get(ts.URL)
+	select {
+	case r := <-done:
+		t.Fatalf("held request answered: %+v", r)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	s.Release()
+	select {
+	case r := <-done:
+		if r.err != nil || r.status != http.StatusOK || r.body != "v2" {
+			t.Errorf("response = %+v, want 200 v2", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request still held after Release")
+	}
+}
+
+func TestServer_ReleaseOvertaken(t *testing.T) {
+	app := newApp(t, "ok")
+	s, ts := newTestProxy(t, app.URL, 5*time.Second)
+
+	// A release followed by another hold leaves requests waiting
+	s.Hold()
+	s.Release()
+	s.Hold()
+	time.Sleep(200 * time.Millisecond)
+	done := get(ts.URL)
+	select {
+	case r := <-done:
+		t.Fatalf("held request answered: %+v", r)
+	case <-time.After(200 * time.Millisecond):
+	}
+	s.Release()
+	if r := <-done; r.err != nil || r.body != "ok" {
+		t.Errorf("response = %+v, want ok", r)
+	}
+}
+
+func TestServer_WaitsForApp(t *testing.T) {
+	// A port nothing listens on yet
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, ts := newTestProxy(t, "http://"+addr, 5*time.Second)
+	done := get(ts.URL)
+	time.Sleep(300 * time.Millisecond)
+
+	// The app comes up while the request waits
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("port taken meanwhile: %v", err)
+	}
+	app := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "up")
+	}))
+	app.Listener = ln
+	app.Start()
+	defer app.Close()
+
+	if r := <-done; r.err != nil || r.status != http.StatusOK || r.body != "up" {
+		t.Errorf("response = %+v, want 200 up", r)
+	}
+}
+
+func TestServer_Timeouts(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	// The app never comes up
+	_, ts := newTestProxy(t, "http://"+addr, 200*time.Millisecond)
+	if r := <-get(ts.URL); r.err != nil || r.status != http.StatusBadGateway {
+		t.Errorf("response = %+v, want 502", r)
+	}
+
+	// The run never ends
+	app := newApp(t, "ok")
+	s, ts := newTestProxy(t, app.URL, 200*time.Millisecond)
+	s.Hold()
+	if r := <-get(ts.URL); r.err != nil || r.status != http.StatusServiceUnavailable {
+		t.Errorf("response = %+v, want 503", r)
+	}
+}
@@ -0,0 +1,123 @@
+// Package record saves the events emitted by watchers to a file of JSON
+// lines and reads them back, for `gowatch replay`.
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"gowatch/pkg/watcher"
+)
+
+// Entry is one recorded event of a pipeline
+type Entry struct {
+	Task  string    `json:"task"`
+	Time  time.Time `json:"time"`
+	Op    string    `json:"op"`
+	Path  string    `json:"path,omitempty"`
+	Files []string  `json:"files,omitempty"`
+	Ops   []string  `json:"ops,omitempty"`
+	Tags  []string  `json:"tags,omitempty"`
+}
+
+// NewEntry records an event of the named pipeline
+func NewEntry(task string, ev watcher.Event) Entry {
+	return Entry{
+		Task:  task,
+		Time:  ev.Timestamp,
+		Op:    ev.Op,
+		Path:  ev.Path,
+		Files: ev.Files,
+		Ops:   ev.Ops,
+		Tags:  ev.Tags,
+	}
+}
+
+// Event returns the recorded event
+func (e Entry) Event() watcher.Event {
+	return watcher.Event{
+		Path:      e.Path,
+		Op:        e.Op,
+		Timestamp: e.Time,
+		Files:     e.Files,
+		Ops:       e.Ops,
+		Tags:      e.Tags,
+	}
+}
+
+// Writer appends events to a recording
+type Writer struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// Create starts a recording at path, replacing any file there
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	return &Writer{file: f, enc: enc}, nil
+}
+
+// Write records an event of the named pipeline. It is called from the
+// goroutines of several watchers concurrently.
+func (w *Writer) Write(task string, ev watcher.Event) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(NewEntry(task, ev))
+}
+
+func (w *Writer) Close() error {
+	return w.file.Close()
+}
+
+// Read returns the events recorded at path, in the order they were emitted
+func Read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return entries, nil
+}
+
+// Pace returns how long to wait before replaying each entry so that they
+// are spaced as they were recorded, speed times faster. A speed of 0 or
+// less replays them without waiting.
+func Pace(entries []Entry, speed float64) []time.Duration {
+	waits := make([]time.Duration, len(entries))
+	if speed <= 0 {
+		return waits
+	}
+	for i := 1; i < len(entries); i++ {
+		if gap := entries[i].Time.Sub(entries[i-1].Time); gap > 0 {
+			waits[i] = time.Duration(float64(gap) / speed)
+		}
+	}
+	return waits
+}
@@ -0,0 +1,91 @@
+package record
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gowatch/pkg/watcher"
+)
+
+func TestWriteRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []struct {
+		task string
+		ev   watcher.Event
+	}{
+		{"default", watcher.Event{Path: "main.go", Op: "WRITE", Timestamp: at, Files: []string{"main.go"}, Ops: []string{"WRITE"}, Tags: []string{"backend"}}},
+		{"api", watcher.Event{Path: "api/<x>.go", Op: "CREATE|WRITE", Timestamp: at.Add(time.Second), Files: []string{"api/a.go", "api/<x>.go"}}},
+	}
+
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events {
+		if err := w.Write(e.task, e.ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(events) {
+		t.Fatalf("read %d entries, want %d", len(entries), len(events))
+	}
+	for i, e := range events {
+		if entries[i].Task != e.task {
+			t.Errorf("entry %d task = %q, want %q", i, entries[i].Task, e.task)
+		}
+		if got := entries[i].Event(); !reflect.DeepEqual(got, e.ev) {
+			t.Errorf("entry %d event = %+v, want %+v", i, got, e.ev)
+		}
+	}
+}
+
+func TestRead_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	data := `{"task":"default","op":"WRITE","path":"a.go"}` + "\n\n{\"task\":\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Read(path)
+	if err == nil || !strings.Contains(err.Error(), "events.jsonl:3:") {
+		t.Errorf("Read() error = %v, want one locating line 3", err)
+	}
+}
+
+func TestPace(t *testing.T) {
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: at},
+		{Time: at.Add(time.Second)},
+		{Time: at.Add(3 * time.Second)},
+		// Out of order, e.g. from two watchers
+		{Time: at.Add(2 * time.Second)},
+	}
+
+	tests := []struct {
+		speed float64
+		want  []time.Duration
+	}{
+		{1, []time.Duration{0, time.Second, 2 * time.Second, 0}},
+		{4, []time.Duration{0, 250 * time.Millisecond, 500 * time.Millisecond, 0}},
+		{0.5, []time.Duration{0, 2 * time.Second, 4 * time.Second, 0}},
+		{0, []time.Duration{0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		if got := Pace(entries, tt.speed); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Pace(speed %v) = %v, want %v", tt.speed, got, tt.want)
+		}
+	}
+}
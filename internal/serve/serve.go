@@ -0,0 +1,143 @@
+// Package serve serves a directory of static files for plain HTML, CSS and
+// JavaScript development. The HTML pages it serves load a LiveReload client
+// from the same server, so that browsers refresh after a change without a
+// build tool or browser extension.
+package serve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"gowatch/internal/livereload"
+	"gowatch/pkg/logger"
+)
+
+// prefix is where the LiveReload endpoints are mounted, out of the way of
+// the files served
+const prefix = "/.gowatch"
+
+// script loads the LiveReload client, which connects back to prefix
+const script = `<script src="` + prefix + `/livereload.js"></script>`
+
+// Server serves the files of a directory and refreshes the browsers showing
+// them
+type Server struct {
+	addr   string
+	dir    string
+	log    *logger.Logger
+	http   *http.Server
+	reload *livereload.Server
+	root   http.FileSystem
+	files  http.Handler
+}
+
+// New creates a server for the files of dir on addr (e.g. ":8000")
+func New(addr, dir string, log *logger.Logger) *Server {
+	s := &Server{
+		addr:   addr,
+		dir:    dir,
+		log:    log,
+		reload: livereload.New(addr, log),
+		root:   http.Dir(dir),
+	}
+	s.files = http.FileServer(s.root)
+
+	mux := http.NewServeMux()
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, s.reload.Handler()))
+	mux.HandleFunc("/", s.handleFile)
+
+	s.http = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// Start binds the listener and serves in the background
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+
+	go func() {
+		if err := s.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("Static server error: %v", err)
+		}
+	}()
+
+	s.log.Success("Serving %s on http://%s", s.dir, ln.Addr())
+	return nil
+}
+
+// Shutdown stops the server and disconnects all browsers
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.reload.Shutdown(ctx)
+	return s.http.Shutdown(ctx)
+}
+
+// Reload tells every connected browser to reload after path changed and
+// returns how many were notified. Stylesheet changes are applied in place.
+func (s *Server) Reload(path string) int {
+	return s.reload.Reload(path)
+}
+
+// handleFile serves a file, loading the LiveReload client into HTML pages.
+// Everything else, such as directory listings, redirects and missing files,
+// is left to http.FileServer.
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	// Browsers check every file again, so that a refresh shows the changes
+	w.Header().Set("Cache-Control", "no-cache")
+
+	name := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+	if ext := strings.ToLower(path.Ext(name)); ext != ".html" && ext != ".htm" {
+		s.files.ServeHTTP(w, r)
+		return
+	}
+	f, err := s.root.Open(name)
+	if err != nil {
+		s.files.ServeHTTP(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		s.files.ServeHTTP(w, r)
+		return
+	}
+	page, err := io.ReadAll(f)
+	if err != nil {
+		http.Error(w, "failed to read "+name, http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, name, info.ModTime(), bytes.NewReader(inject(page)))
+}
+
+// inject adds the LiveReload client to an HTML page, before the closing
+// body tag if it has one
+func inject(page []byte) []byte {
+	lower := bytes.ToLower(page)
+	i := bytes.LastIndex(lower, []byte("</body>"))
+	if i < 0 {
+		i = bytes.LastIndex(lower, []byte("</html>"))
+	}
+	if i < 0 {
+		i = len(page)
+	}
+	out := make([]byte, 0, len(page)+len(script)+1)
+	out = append(out, page[:i]...)
+	out = append(out, script...)
+	out = append(out, '\n')
+	return append(out, page[i:]...)
+}
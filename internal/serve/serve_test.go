@@ -0,0 +1,125 @@
+package serve
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gowatch/pkg/logger"
+)
+
+func newTestServer(t *testing.T, files map[string]string) (*Server, *httptest.Server) {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := New("127.0.0.1:0", dir, logger.New(logger.LevelError, false))
+	ts := httptest.NewServer(s.http.Handler)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func TestInject(t *testing.T) {
+	tests := []struct {
+		page string
+		want string
+	}{
+		{"<html><body><p>hi</p></body></html>", "<html><body><p>hi</p>" + script + "\n</body></html>"},
+		{"<HTML><BODY>hi</BODY></HTML>", "<HTML><BODY>hi" + script + "\n</BODY></HTML>"},
+		{"<html>hi</html>", "<html>hi" + script + "\n</html>"},
+		{"<p>hi</p>", "<p>hi</p>" + script + "\n"},
+	}
+	for _, tt := range tests {
+		if got := string(inject([]byte(tt.page))); got != tt.want {
+			t.Errorf("inject(%q) = %q, want %q", tt.page, got, tt.want)
+		}
+	}
+}
+
+func TestServer_Files(t *testing.T) {
+	_, ts := newTestServer(t, map[string]string{
+		"index.html":      "<body>home</body>",
+		"about/index.htm": "<body>about</body>",
+		"style.css":       "body{}",
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+		script bool
+	}{
+		{"/", http.StatusOK, "home", true},
+		{"/index.html", http.StatusOK, "home", true},
+		{"/about/index.htm", http.StatusOK, "about", true},
+		{"/style.css", http.StatusOK, "body{}", false},
+		{"/missing.html", http.StatusNotFound, "", false},
+		{prefix + "/livereload.js", http.StatusOK, "new WebSocket(url)", false},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(ts.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s: status = %d, want %d", tt.path, resp.StatusCode, tt.status)
+			continue
+		}
+		if !strings.Contains(string(body), tt.body) {
+			t.Errorf("GET %s = %q, want it to contain %q", tt.path, body, tt.body)
+		}
+		if got := strings.Contains(string(body), script); got != tt.script {
+			t.Errorf("GET %s: script injected = %v, want %v", tt.path, got, tt.script)
+		}
+		if tt.status == http.StatusOK && resp.Header.Get("Cache-Control") != "no-cache" {
+			t.Errorf("GET %s: Cache-Control = %q, want no-cache", tt.path, resp.Header.Get("Cache-Control"))
+		}
+	}
+}
+
+func TestServer_Reload(t *testing.T) {
+	s, ts := newTestServer(t, map[string]string{"index.html": "<body>home</body>"})
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// The client script connects to the endpoint next to it
+	req := "GET " + prefix + "/livereload HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	conn.Write([]byte(req))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Reload("index.html") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Reload() never reached the browser")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,212 @@
+// Package stream serves a WebSocket feed of watcher events and command
+// results so that dashboards and editor plugins can follow a running
+// gowatch session in real time.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"gowatch/internal/api"
+	"gowatch/internal/websocket"
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+	"gowatch/pkg/watcher"
+)
+
+// Message types
+const (
+	// TypeEvent is sent when a change starts a run
+	TypeEvent = "event"
+	// TypeResult is sent as each command finishes
+	TypeResult = "result"
+	// TypeRun is sent when a whole run has finished
+	TypeRun = "run"
+)
+
+// clientBuffer is how many messages may queue for a slow client before
+// further ones are dropped
+const clientBuffer = 64
+
+// Message is one JSON message of the stream
+type Message struct {
+	Type   string             `json:"type"`
+	Task   string             `json:"task"`
+	RunID  int64              `json:"run_id,omitempty"`
+	Time   time.Time          `json:"time"`
+	Event  *Event             `json:"event,omitempty"`
+	Result *api.CommandResult `json:"result,omitempty"`
+	Run    *api.Result        `json:"run,omitempty"`
+}
+
+// Event is the JSON form of a watcher event
+type Event struct {
+	Path  string   `json:"path"`
+	Op    string   `json:"op"`
+	Files []string `json:"files,omitempty"`
+	Ops   []string `json:"ops,omitempty"`
+}
+
+// EventMessage describes the change that starts run runID of task
+func EventMessage(task string, runID int64, ev watcher.Event) Message {
+	return Message{
+		Type:  TypeEvent,
+		Task:  task,
+		RunID: runID,
+		Time:  ev.Timestamp,
+		Event: &Event{Path: ev.Path, Op: ev.Op, Files: ev.Files, Ops: ev.Ops},
+	}
+}
+
+// ResultMessage describes a finished command
+func ResultMessage(task string, t runner.Trigger, r runner.RunResult) Message {
+	result := api.NewCommandResult(r)
+	return Message{
+		Type:   TypeResult,
+		Task:   task,
+		RunID:  t.RunID,
+		Time:   time.Now(),
+		Result: &result,
+	}
+}
+
+// RunMessage describes a finished run
+func RunMessage(report runner.Report) Message {
+	run := api.NewResult(report)
+	return Message{
+		Type:  TypeRun,
+		Task:  report.Task,
+		RunID: report.Trigger.RunID,
+		Time:  report.Start.Add(report.Duration),
+		Run:   &run,
+	}
+}
+
+// Server streams messages to every connected WebSocket client
+type Server struct {
+	addr string
+	log  *logger.Logger
+	http *http.Server
+
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+}
+
+// New creates a stream server for addr (e.g. ":7071")
+func New(addr string, log *logger.Logger) *Server {
+	s := &Server{
+		addr:    addr,
+		log:     log,
+		clients: make(map[chan []byte]struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /", s.handleConnect)
+
+	s.http = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// Start binds the listener and serves in the background
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+
+	go func() {
+		if err := s.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("Stream server error: %v", err)
+		}
+	}()
+
+	s.log.Success("Event stream listening on ws://%s", ln.Addr())
+	return nil
+}
+
+// Shutdown stops the server, disconnecting all clients
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	for ch := range s.clients {
+		close(ch)
+		delete(s.clients, ch)
+	}
+	s.mu.Unlock()
+
+	return s.http.Shutdown(ctx)
+}
+
+// Publish sends a message to every connected client
+func (s *Server) Publish(m Message) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.clients {
+		// Slow clients miss messages rather than stall the run loop
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+}
+
+func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		s.log.Debug("Event stream: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	ch := make(chan []byte, clientBuffer)
+	s.mu.Lock()
+	s.clients[ch] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		if _, ok := s.clients[ch]; ok {
+			delete(s.clients, ch)
+			close(ch)
+		}
+		s.mu.Unlock()
+	}()
+
+	// Reading handles pings and notices when the client goes away
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-gone:
+			return
+		case data, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := conn.WriteText(data); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gowatch/pkg/logger"
+	"gowatch/pkg/runner"
+	"gowatch/pkg/watcher"
+)
+
+func TestMessages(t *testing.T) {
+	ev := watcher.Event{Path: "main.go", Op: "WRITE", Ops: []string{"WRITE"}, Timestamp: time.Now()}
+	if m := EventMessage("build", 7, ev); m.Type != TypeEvent || m.RunID != 7 || m.Event.Path != "main.go" {
+		t.Errorf("EventMessage() = %+v", m)
+	}
+
+	trigger := runner.Trigger{RunID: 7}
+	result := runner.RunResult{Command: []string{"go", "build"}, ExitCode: 1, Attempts: 1}
+	if m := ResultMessage("build", trigger, result); m.Type != TypeResult || m.RunID != 7 || m.Result.ExitCode != 1 {
+		t.Errorf("ResultMessage() = %+v", m)
+	}
+
+	report := runner.Report{Task: "build", Trigger: trigger, Results: []runner.RunResult{result}}
+	if m := RunMessage(report); m.Type != TypeRun || m.Run.Success || len(m.Run.Commands) != 1 {
+		t.Errorf("RunMessage() = %+v", m)
+	}
+}
+
+func TestServer_Publish(t *testing.T) {
+	s := New("127.0.0.1:0", logger.New(logger.LevelError, false))
+	ts := httptest.NewServer(s.http.Handler)
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req := "GET / HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	conn.Write([]byte(req))
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake failed: %v", err)
+	}
+
+	// The client is registered once the handshake completes
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.mu.Lock()
+		n := len(s.clients)
+		s.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("client was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.Publish(EventMessage("default", 3, watcher.Event{Path: "a.go", Op: "CREATE"}))
+
+	var header [2]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		io.ReadFull(br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	var m Message
+	if err := json.Unmarshal(payload, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != TypeEvent || m.RunID != 3 || m.Event == nil || m.Event.Op != "CREATE" {
+		t.Errorf("received %+v", m)
+	}
+}
@@ -0,0 +1,576 @@
+// Package tui is the full-screen terminal dashboard of `gowatch tui`. It
+// shows the watched paths, a feed of changes, the status of every command
+// and a scrollable pane with the output of each, so that commands running
+// in parallel don't interleave their output.
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gowatch/internal/api"
+	"gowatch/pkg/runner"
+	"gowatch/pkg/watcher"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	// tickInterval is how often queued updates are applied and the screen
+	// is redrawn
+	tickInterval = 100 * time.Millisecond
+	// statusEvery is how many ticks pass between status refreshes
+	statusEvery = 5
+	// pathsInterval is how often the watched paths are refreshed, as they
+	// change when the config is reloaded
+	pathsInterval = 10 * time.Second
+	// maxLines is how many output lines each pane keeps
+	maxLines = 5000
+	// maxEvents is how many changes the event feed keeps
+	maxEvents = 100
+)
+
+// Command is a configured on_change command of a task
+type Command struct {
+	Task  string
+	Index int
+	// Name labels the command in the list: its name, or its command line
+	Name string
+	// Restart is set for long-running commands, whose result reports that
+	// they started rather than that they finished
+	Restart bool
+}
+
+// Dashboard drives the terminal UI. Its methods may be called from any
+// goroutine: updates are queued and applied by the UI on its next tick,
+// which also keeps chatty commands from redrawing the screen per line.
+type Dashboard struct {
+	ctrl api.Controller
+
+	mu      sync.Mutex
+	pending []func(*model)
+	partial []byte
+	program *tea.Program
+	quit    bool
+	done    bool
+}
+
+// New creates a dashboard. Updates and log output are kept until Run shows
+// them.
+func New() *Dashboard {
+	return &Dashboard{}
+}
+
+// Run shows the dashboard for the given commands, controlling the session
+// through ctrl, until the user quits or Quit is called. Lines written to
+// the dashboard afterwards go to stdout.
+func (d *Dashboard) Run(ctrl api.Controller, commands []Command) error {
+	d.ctrl = ctrl
+	p := tea.NewProgram(newModel(d, commands), tea.WithAltScreen(), tea.WithMouseCellMotion())
+	d.mu.Lock()
+	d.program = p
+	if d.quit {
+		// Quit blocks until the program runs
+		go p.Quit()
+	}
+	d.mu.Unlock()
+
+	_, err := p.Run()
+
+	d.mu.Lock()
+	d.done = true
+	if len(d.partial) > 0 {
+		fmt.Fprintln(os.Stdout, string(d.partial))
+		d.partial = nil
+	}
+	d.mu.Unlock()
+	return err
+}
+
+// Quit closes the dashboard, or keeps it from opening if it isn't running
+// yet
+func (d *Dashboard) Quit() {
+	d.mu.Lock()
+	d.quit = true
+	p := d.program
+	d.mu.Unlock()
+	if p != nil {
+		p.Quit()
+	}
+}
+
+// queue schedules an update of the model
+func (d *Dashboard) queue(fn func(*model)) {
+	d.mu.Lock()
+	d.pending = append(d.pending, fn)
+	d.mu.Unlock()
+}
+
+// drain returns the queued updates
+func (d *Dashboard) drain() []func(*model) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pending := d.pending
+	d.pending = nil
+	return pending
+}
+
+// Write adds gowatch's own log output to the log pane, so that the
+// dashboard can be a logger's writer
+func (d *Dashboard) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.done {
+		return os.Stdout.Write(p)
+	}
+
+	d.partial = append(d.partial, p...)
+	for {
+		i := bytes.IndexByte(d.partial, '\n')
+		if i < 0 {
+			break
+		}
+		text := strings.TrimRight(string(d.partial[:i]), "\r")
+		d.partial = d.partial[i+1:]
+		d.pending = append(d.pending, func(m *model) { m.logPane().add(text, false) })
+	}
+	return len(p), nil
+}
+
+// Event adds a change that starts a run to the event feed
+func (d *Dashboard) Event(task string, runID int64, ev watcher.Event) {
+	d.queue(func(m *model) { m.addEvent(task, ev) })
+}
+
+// Started marks a command as running
+func (d *Dashboard) Started(task string, t runner.Trigger, command []string) {
+	d.started(task, t.CommandIndex(), t.RunID, command)
+}
+
+func (d *Dashboard) started(task string, index int, runID int64, command []string) {
+	now := time.Now()
+	d.queue(func(m *model) {
+		p := m.pane(task, index, command)
+		p.state = stateRunning
+		p.started = now
+		p.command = strings.Join(command, " ")
+		p.startRun(fmt.Sprintf("── run %d · %s · %s", runID, now.Format("15:04:05"), p.command))
+	})
+}
+
+// Output adds a line of command output to the command's pane. Output of
+// hooks goes to the log pane.
+func (d *Dashboard) Output(task string, t runner.Trigger, line string, isError bool) {
+	d.output(task, t.CommandIndex(), line, isError)
+}
+
+func (d *Dashboard) output(task string, index int, line string, isError bool) {
+	d.queue(func(m *model) { m.pane(task, index, nil).add(line, isError) })
+}
+
+// Result records how a command ended
+func (d *Dashboard) Result(task string, t runner.Trigger, r runner.RunResult) {
+	d.result(task, t.CommandIndex(), r)
+}
+
+func (d *Dashboard) result(task string, index int, r runner.RunResult) {
+	d.queue(func(m *model) {
+		p := m.pane(task, index, r.Command)
+		p.exitCode = r.ExitCode
+		p.timedOut = r.TimedOut
+		p.duration = r.Duration
+		switch {
+		case r.ExitCode != 0:
+			p.state = stateFailed
+		case p.Restart:
+			// Still running until it is restarted or exits
+		default:
+			p.state = statePassed
+		}
+	})
+}
+
+// state is what a command is doing
+type state int
+
+const (
+	stateIdle state = iota
+	stateRunning
+	statePassed
+	stateFailed
+)
+
+// line is a line of output
+type line struct {
+	text    string
+	isError bool
+	// separator marks the line that starts a run
+	separator bool
+}
+
+// pane holds the state and output of one command, or of gowatch's log
+type pane struct {
+	Command
+	state    state
+	command  string
+	started  time.Time
+	duration time.Duration
+	exitCode int
+	timedOut bool
+	lines    []line
+	// changed is set when lines changed since the pane was last shown
+	changed bool
+}
+
+// add appends a line of output
+func (p *pane) add(text string, isError bool) {
+	p.append(line{text: text, isError: isError})
+}
+
+// startRun appends the line that separates the output of a new run
+func (p *pane) startRun(text string) {
+	p.append(line{text: text, separator: true})
+}
+
+// append adds a line, dropping the oldest beyond maxLines
+func (p *pane) append(l line) {
+	p.lines = append(p.lines, l)
+	if len(p.lines) > maxLines {
+		p.lines = append(p.lines[:0], p.lines[len(p.lines)-maxLines:]...)
+	}
+	p.changed = true
+}
+
+// filtered returns the lines containing filter, case-insensitively and
+// ignoring colors; all of them if filter is empty
+func (p *pane) filtered(filter string) []line {
+	if filter == "" {
+		return p.lines
+	}
+	filter = strings.ToLower(filter)
+	var lines []line
+	for _, l := range p.lines {
+		if strings.Contains(strings.ToLower(ansi.Strip(l.text)), filter) {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// paneKey identifies a pane
+type paneKey struct {
+	task  string
+	index int
+}
+
+// event is an entry of the event feed
+type event struct {
+	time  time.Time
+	task  string
+	op    string
+	path  string
+	files int
+}
+
+// Messages of the UI
+type (
+	tickMsg   time.Time
+	pathsMsg  []api.WatchedPath
+	statusMsg api.Status
+	errMsg    struct{ err error }
+)
+
+// model is the bubbletea model of the dashboard
+type model struct {
+	dash *Dashboard
+
+	panes    []*pane
+	byKey    map[paneKey]*pane
+	selected int
+	events   []event
+	paths    []api.WatchedPath
+	status   api.Status
+	tasks    map[string]bool
+	ticks    int
+
+	output    viewport.Model
+	filter    textinput.Model
+	filtering bool
+
+	width, height int
+}
+
+func newModel(d *Dashboard, commands []Command) *model {
+	filter := textinput.New()
+	filter.Prompt = "/"
+	filter.Placeholder = "filter output"
+
+	m := &model{
+		dash:   d,
+		byKey:  make(map[paneKey]*pane),
+		tasks:  make(map[string]bool),
+		output: viewport.New(0, 0),
+		filter: filter,
+	}
+	m.output.MouseWheelEnabled = true
+
+	log := &pane{Command: Command{Index: -1, Name: "gowatch"}}
+	m.panes = append(m.panes, log)
+	m.byKey[paneKey{index: -1}] = log
+	for _, c := range commands {
+		p := &pane{Command: c}
+		m.panes = append(m.panes, p)
+		m.byKey[paneKey{task: c.Task, index: c.Index}] = p
+		m.tasks[c.Task] = true
+	}
+	return m
+}
+
+// logPane returns the pane of gowatch's own output
+func (m *model) logPane() *pane {
+	return m.panes[0]
+}
+
+// pane returns the pane of a command, adding one for commands that weren't
+// configured when the dashboard started, e.g. after a reload. Hooks, with
+// a negative index, write to the log pane.
+func (m *model) pane(task string, index int, command []string) *pane {
+	if index < 0 {
+		return m.logPane()
+	}
+	key := paneKey{task: task, index: index}
+	if p, ok := m.byKey[key]; ok {
+		return p
+	}
+	name := strings.Join(command, " ")
+	if name == "" {
+		name = fmt.Sprintf("command %d", index+1)
+	}
+	p := &pane{Command: Command{Task: task, Index: index, Name: name}}
+	m.panes = append(m.panes, p)
+	m.byKey[key] = p
+	m.tasks[task] = true
+	return p
+}
+
+// current returns the selected pane
+func (m *model) current() *pane {
+	return m.panes[m.selected]
+}
+
+// addEvent adds a change to the event feed
+func (m *model) addEvent(task string, ev watcher.Event) {
+	path := ev.Path
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	m.events = append(m.events, event{
+		time:  ev.Timestamp,
+		task:  task,
+		op:    ev.Op,
+		path:  path,
+		files: len(ev.Files),
+	})
+	if len(m.events) > maxEvents {
+		m.events = m.events[len(m.events)-maxEvents:]
+	}
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(tickInterval, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+// fetchPaths reads the watched paths, which waits for the session to be
+// idle, so it runs outside the UI goroutine
+func (m *model) fetchPaths(delay time.Duration) tea.Cmd {
+	ctrl := m.dash.ctrl
+	return tea.Tick(delay, func(time.Time) tea.Msg { return pathsMsg(ctrl.WatchedPaths()) })
+}
+
+func (m *model) Init() tea.Cmd {
+	return tea.Batch(tick(), m.fetchPaths(0), textinput.Blink)
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.layout()
+		m.refresh(true)
+		return m, nil
+
+	case tickMsg:
+		for _, fn := range m.dash.drain() {
+			fn(m)
+		}
+		if m.ticks%statusEvery == 0 {
+			m.status = m.dash.ctrl.Status()
+		}
+		m.ticks++
+		m.refresh(false)
+		return m, tick()
+
+	case pathsMsg:
+		m.paths = msg
+		m.layout()
+		return m, m.fetchPaths(pathsInterval)
+
+	case statusMsg:
+		m.status = api.Status(msg)
+		return m, nil
+
+	case errMsg:
+		m.logPane().add("Error: "+msg.err.Error(), true)
+		return m, nil
+
+	case tea.MouseMsg:
+		var cmd tea.Cmd
+		m.output, cmd = m.output.Update(msg)
+		return m, cmd
+
+	case tea.KeyMsg:
+		if m.filtering {
+			return m.updateFilter(msg)
+		}
+		return m.updateKey(msg)
+	}
+
+	if m.filtering {
+		var cmd tea.Cmd
+		m.filter, cmd = m.filter.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// updateKey handles a key outside the filter input
+func (m *model) updateKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "up", "k":
+		m.selectPane(m.selected - 1)
+	case "down", "j":
+		m.selectPane(m.selected + 1)
+	case "pgup", "b":
+		m.output.PageUp()
+	case "pgdown", " ":
+		m.output.PageDown()
+	case "ctrl+u":
+		m.output.HalfPageUp()
+	case "ctrl+d":
+		m.output.HalfPageDown()
+	case "home", "g":
+		m.output.GotoTop()
+	case "end", "G":
+		m.output.GotoBottom()
+	case "r":
+		// The log pane belongs to no task and re-runs everything
+		return m, m.trigger(m.current().Task)
+	case "a":
+		return m, m.trigger("")
+	case "p":
+		return m, m.setPaused(!m.status.Paused)
+	case "c":
+		m.current().lines = nil
+		m.refresh(true)
+	case "/":
+		m.filtering = true
+		m.layout()
+		return m, m.filter.Focus()
+	case "esc":
+		if m.filter.Value() != "" {
+			m.filter.SetValue("")
+			m.refresh(true)
+		}
+	}
+	return m, nil
+}
+
+// updateFilter handles a key while the filter is being typed
+func (m *model) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "enter":
+		m.filtering = false
+		m.filter.Blur()
+		m.layout()
+		return m, nil
+	case "esc":
+		m.filtering = false
+		m.filter.Blur()
+		m.filter.SetValue("")
+		m.layout()
+		m.refresh(true)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.filter, cmd = m.filter.Update(msg)
+	m.refresh(true)
+	return m, cmd
+}
+
+// selectPane shows the pane at index i, if there is one
+func (m *model) selectPane(i int) {
+	if i < 0 || i >= len(m.panes) || i == m.selected {
+		return
+	}
+	m.selected = i
+	m.refresh(true)
+	m.output.GotoBottom()
+}
+
+// trigger re-runs a task, or every task when task is empty. The session
+// queues the run once it is idle, so this doesn't block the UI.
+func (m *model) trigger(task string) tea.Cmd {
+	ctrl := m.dash.ctrl
+	return func() tea.Msg {
+		if err := ctrl.Trigger(task); err != nil {
+			return errMsg{err}
+		}
+		return nil
+	}
+}
+
+// setPaused pauses or resumes watching
+func (m *model) setPaused(paused bool) tea.Cmd {
+	ctrl := m.dash.ctrl
+	return func() tea.Msg {
+		ctrl.SetPaused(paused)
+		return statusMsg(ctrl.Status())
+	}
+}
+
+// refresh updates the output pane from the selected pane's lines if they
+// changed, or always with force. It follows new output while scrolled to
+// the bottom.
+func (m *model) refresh(force bool) {
+	p := m.current()
+	if !force && !p.changed {
+		return
+	}
+	p.changed = false
+
+	follow := m.output.AtBottom()
+	lines := p.filtered(m.filter.Value())
+	rendered := make([]string, len(lines))
+	for i, l := range lines {
+		rendered[i] = renderLine(l, m.output.Width)
+	}
+	m.output.SetContent(strings.Join(rendered, "\n"))
+	if follow {
+		m.output.GotoBottom()
+	}
+}
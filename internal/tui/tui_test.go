@@ -0,0 +1,198 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gowatch/internal/api"
+	"gowatch/pkg/runner"
+	"gowatch/pkg/watcher"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type fakeController struct {
+	paused    bool
+	triggered []string
+}
+
+func (f *fakeController) Status() api.Status {
+	return api.Status{Paused: f.paused, Tasks: []string{"build"}, WatchedDirs: 3}
+}
+
+func (f *fakeController) WatchedPaths() []api.WatchedPath {
+	return []api.WatchedPath{{Task: "build", Path: "src", Recursive: true, Backend: "fsnotify"}}
+}
+
+func (f *fakeController) Trigger(task string) error {
+	f.triggered = append(f.triggered, task)
+	return nil
+}
+
+func (f *fakeController) SetPaused(paused bool) {
+	f.paused = paused
+}
+
+// newTestModel returns a model for a build task with a compile command and
+// a long-running server, sized like a terminal
+func newTestModel() (*model, *Dashboard, *fakeController) {
+	ctrl := &fakeController{}
+	d := New()
+	d.ctrl = ctrl
+	m := newModel(d, []Command{
+		{Task: "build", Index: 0, Name: "compile"},
+		{Task: "build", Index: 1, Name: "server", Restart: true},
+	})
+	m.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+	return m, d, ctrl
+}
+
+func key(s string) tea.KeyMsg {
+	switch s {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestDashboard_Updates(t *testing.T) {
+	m, d, _ := newTestModel()
+
+	d.Write([]byte("watching src\npartial"))
+	d.Event("build", 1, watcher.Event{Path: "src/main.go", Op: "WRITE", Files: []string{"src/a.go", "src/main.go"}, Timestamp: time.Now()})
+	d.started("build", 0, 1, []string{"go", "build"})
+	d.output("build", 0, "compiled", false)
+	d.result("build", 0, runner.RunResult{Command: []string{"go", "build"}, ExitCode: 0, Duration: 20 * time.Millisecond})
+	d.started("build", 1, 1, []string{"./server"})
+	d.result("build", 1, runner.RunResult{Command: []string{"./server"}})
+	// Hooks print to the log pane
+	d.output("build", -1, "hook ran", false)
+	m.Update(tickMsg{})
+
+	compile, server, log := m.panes[1], m.panes[2], m.logPane()
+	if compile.state != statePassed || compile.duration != 20*time.Millisecond {
+		t.Errorf("compile: state %d, duration %s", compile.state, compile.duration)
+	}
+	if got := compile.lines[len(compile.lines)-1].text; got != "compiled" {
+		t.Errorf("compile output = %q", got)
+	}
+	if server.state != stateRunning {
+		t.Errorf("a started long-running command should stay running, got state %d", server.state)
+	}
+
+	var logged []string
+	for _, l := range log.lines {
+		logged = append(logged, l.text)
+	}
+	if want := []string{"watching src", "hook ran"}; !slices.Equal(logged, want) {
+		t.Errorf("log pane = %q, want %q (partial lines are held back)", logged, want)
+	}
+	if len(m.events) != 1 || m.events[0].files != 2 {
+		t.Errorf("events = %+v", m.events)
+	}
+	if m.status.WatchedDirs != 3 {
+		t.Errorf("status wasn't refreshed: %+v", m.status)
+	}
+}
+
+func TestDashboard_UnknownCommand(t *testing.T) {
+	m, d, _ := newTestModel()
+
+	// Commands added by a reload get a pane of their own
+	d.started("lint", 0, 2, []string{"golangci-lint", "run"})
+	m.Update(tickMsg{})
+
+	if len(m.panes) != 4 {
+		t.Fatalf("got %d panes, want 4", len(m.panes))
+	}
+	if p := m.panes[3]; p.Task != "lint" || p.Name != "golangci-lint run" || p.state != stateRunning {
+		t.Errorf("new pane = %+v", p.Command)
+	}
+}
+
+func TestPane_Lines(t *testing.T) {
+	p := &pane{}
+	for i := range maxLines + 10 {
+		p.add(fmt.Sprintf("line %d", i), i%2 == 1)
+	}
+	if len(p.lines) != maxLines || p.lines[0].text != "line 10" {
+		t.Fatalf("kept %d lines starting at %q", len(p.lines), p.lines[0].text)
+	}
+
+	p.lines = nil
+	p.add("\x1b[32mPASS\x1b[0m ok", false)
+	p.add("FAIL: TestX", true)
+	p.add("--- pass: TestY", false)
+	if got := p.filtered("pass"); len(got) != 2 || got[1].text != "--- pass: TestY" {
+		t.Errorf("filtered = %+v", got)
+	}
+	if got := p.filtered(""); len(got) != 3 {
+		t.Errorf("an empty filter should keep every line, got %d", len(got))
+	}
+}
+
+func TestModel_Keys(t *testing.T) {
+	m, _, ctrl := newTestModel()
+
+	m.Update(key("j"))
+	if m.current().Name != "compile" {
+		t.Fatalf("selected %q, want compile", m.current().Name)
+	}
+
+	// Re-running and pausing happen outside the UI goroutine
+	_, cmd := m.Update(key("r"))
+	cmd()
+	_, cmd = m.Update(key("a"))
+	cmd()
+	if want := []string{"build", ""}; !slices.Equal(ctrl.triggered, want) {
+		t.Errorf("triggered %q, want %q", ctrl.triggered, want)
+	}
+	_, cmd = m.Update(key("p"))
+	m.Update(cmd())
+	if !ctrl.paused || !m.status.Paused {
+		t.Error("p should pause watching")
+	}
+
+	m.current().add("ok main", false)
+	m.current().add("FAIL util", true)
+	m.Update(key("/"))
+	for _, r := range "fail" {
+		m.Update(key(string(r)))
+	}
+	m.Update(key("enter"))
+	if m.filtering || m.filter.Value() != "fail" {
+		t.Fatalf("filtering = %v, filter = %q", m.filtering, m.filter.Value())
+	}
+	if view := m.output.View(); strings.Contains(view, "ok main") || !strings.Contains(view, "FAIL util") {
+		t.Errorf("filtered output:\n%s", view)
+	}
+	m.Update(key("esc"))
+	if !strings.Contains(m.output.View(), "ok main") {
+		t.Error("esc should clear the filter")
+	}
+
+	if _, cmd := m.Update(key("q")); cmd == nil || cmd() != tea.Quit() {
+		t.Error("q should quit")
+	}
+}
+
+func TestModel_View(t *testing.T) {
+	m, _, _ := newTestModel()
+	m.Update(pathsMsg(m.dash.ctrl.WatchedPaths()))
+
+	view := m.View()
+	lines := strings.Split(view, "\n")
+	if len(lines) != 30 {
+		t.Errorf("view has %d lines, want 30", len(lines))
+	}
+	for _, want := range []string{"compile", "server", "src", "gowatch log", "q quit"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view is missing %q:\n%s", want, view)
+		}
+	}
+}
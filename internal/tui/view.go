@@ -0,0 +1,264 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	boxStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("8"))
+	activeBoxStyle = boxStyle.BorderForeground(lipgloss.Color("6"))
+	titleStyle     = lipgloss.NewStyle().Bold(true)
+	faintStyle     = lipgloss.NewStyle().Faint(true)
+	errorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	selectedStyle  = lipgloss.NewStyle().Reverse(true)
+	headerStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("6"))
+	pausedStyle    = lipgloss.NewStyle().Bold(true).Reverse(true).Foreground(lipgloss.Color("3"))
+
+	stateStyles = map[state]lipgloss.Style{
+		stateIdle:    faintStyle,
+		stateRunning: lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+		statePassed:  lipgloss.NewStyle().Foreground(lipgloss.Color("2")),
+		stateFailed:  errorStyle,
+	}
+	stateIcons = map[state]string{
+		stateIdle:    "·",
+		stateRunning: "●",
+		statePassed:  "✓",
+		stateFailed:  "✗",
+	}
+)
+
+const help = "↑↓ select · pgup/pgdn scroll · r re-run · a re-run all · p pause · / filter · c clear · q quit"
+
+// leftWidth returns the width of the column with the command list, paths
+// and events
+func (m *model) leftWidth() int {
+	return max(min(m.width/3, 50), min(24, m.width/2))
+}
+
+// bodyHeight returns the height of the area between header and footer
+func (m *model) bodyHeight() int {
+	return max(m.height-2, 6)
+}
+
+// layout sizes the output pane to the window
+func (m *model) layout() {
+	m.output.Width = max(m.width-m.leftWidth()-2, 1)
+	m.output.Height = max(m.bodyHeight()-3, 1)
+	m.filter.Width = max(m.width-4, 1)
+}
+
+func (m *model) View() string {
+	if m.width == 0 {
+		return ""
+	}
+
+	lw := m.leftWidth()
+	bh := m.bodyHeight()
+	listH := min(len(m.panes)+3, max(bh/2, 5))
+	pathsH := min(min(len(m.paths), 5)+3, (bh-listH)/2)
+	eventsH := bh - listH - pathsH
+
+	left := lipgloss.JoinVertical(lipgloss.Left,
+		box("Commands", m.listLines(listH-3, lw-2), lw, listH, false),
+		box("Watching", m.pathLines(lw-2), lw, pathsH, false),
+		box("Changes", m.eventLines(eventsH-3, lw-2), lw, eventsH, false),
+	)
+	right := box(m.outputTitle(), strings.Split(m.output.View(), "\n"), m.width-lw, bh, true)
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		m.header(),
+		lipgloss.JoinHorizontal(lipgloss.Top, left, right),
+		m.footer(),
+	)
+}
+
+// box draws a bordered box of the given outer size, with a title line
+// above the content
+func box(title string, lines []string, width, height int, active bool) string {
+	if height < 3 {
+		return ""
+	}
+	inner := width - 2
+	content := []string{ansi.Truncate(titleStyle.Render(title), inner, "…")}
+	for _, l := range lines {
+		if len(content) == height-2 {
+			break
+		}
+		content = append(content, ansi.Truncate(l, inner, "…"))
+	}
+
+	style := boxStyle
+	if active {
+		style = activeBoxStyle
+	}
+	return style.Width(inner).Height(height - 2).Render(strings.Join(content, "\n"))
+}
+
+// header shows the session's counters
+func (m *model) header() string {
+	parts := []string{
+		fmt.Sprintf("%d dirs", m.status.WatchedDirs),
+		fmt.Sprintf("%d runs", m.status.Runs),
+	}
+	if m.status.Failures > 0 {
+		parts = append(parts, errorStyle.Render(fmt.Sprintf("%d failed", m.status.Failures)))
+	}
+	line := headerStyle.Render(" GoWatch ") + faintStyle.Render(strings.Join(parts, " · "))
+	if m.status.Paused {
+		line += "  " + pausedStyle.Render(" PAUSED ")
+	}
+	return ansi.Truncate(line, m.width, "…")
+}
+
+// footer shows the filter input while typing, the key help otherwise
+func (m *model) footer() string {
+	if m.filtering {
+		return m.filter.View()
+	}
+	text := help
+	if f := m.filter.Value(); f != "" {
+		text = fmt.Sprintf("filter: %s (esc clears) · %s", f, help)
+	}
+	return ansi.Truncate(faintStyle.Render(" "+text), m.width, "…")
+}
+
+// listLines shows the commands with their state, scrolled so that the
+// selected one is visible
+func (m *model) listLines(height, width int) []string {
+	first := 0
+	if height > 0 && m.selected >= height {
+		first = m.selected - height + 1
+	}
+
+	var lines []string
+	for i := first; i < len(m.panes) && len(lines) < max(height, 0); i++ {
+		p := m.panes[i]
+		name := p.Name
+		if len(m.tasks) > 1 && p.Index >= 0 {
+			name = p.Task + ": " + name
+		}
+		info := p.info()
+		gap := max(width-lipgloss.Width(name)-lipgloss.Width(info)-3, 1)
+		row := ansi.Truncate(name, max(width-lipgloss.Width(info)-3, 1), "…") + strings.Repeat(" ", gap) + info
+
+		icon := stateStyles[p.state].Render(stateIcons[p.state])
+		if p.Index < 0 {
+			icon = " "
+		}
+		if i == m.selected {
+			row = selectedStyle.Render(row)
+		}
+		lines = append(lines, icon+" "+row)
+	}
+	return lines
+}
+
+// info describes the state of a command briefly
+func (p *pane) info() string {
+	switch p.state {
+	case stateRunning:
+		return stateStyles[stateRunning].Render(formatDuration(time.Since(p.started)))
+	case statePassed:
+		return faintStyle.Render(formatDuration(p.duration))
+	case stateFailed:
+		if p.timedOut {
+			return errorStyle.Render("timed out")
+		}
+		return errorStyle.Render(fmt.Sprintf("exit %d", p.exitCode))
+	}
+	return ""
+}
+
+// pathLines shows the watched paths
+func (m *model) pathLines(width int) []string {
+	if m.paths == nil {
+		return []string{faintStyle.Render("loading…")}
+	}
+	var lines []string
+	for _, w := range m.paths {
+		text := w.Path
+		if len(m.tasks) > 1 {
+			text = w.Task + ": " + text
+		}
+		if w.Backend != "" && w.Backend != "fsnotify" {
+			text += faintStyle.Render(" (" + w.Backend + ")")
+		}
+		lines = append(lines, ansi.Truncate(text, width, "…"))
+	}
+	return lines
+}
+
+// eventLines shows the most recent changes, newest last
+func (m *model) eventLines(height, width int) []string {
+	events := m.events
+	if height >= 0 && len(events) > height {
+		events = events[len(events)-height:]
+	}
+	if len(events) == 0 {
+		return []string{faintStyle.Render("waiting for changes…")}
+	}
+
+	var lines []string
+	for _, ev := range events {
+		text := ev.path
+		if ev.files > 1 {
+			text += fmt.Sprintf(" (+%d)", ev.files-1)
+		}
+		if len(m.tasks) > 1 {
+			text = ev.task + ": " + text
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s",
+			faintStyle.Render(ev.time.Format("15:04:05")),
+			stateStyles[stateRunning].Render(ev.op),
+			text))
+	}
+	return lines
+}
+
+// outputTitle names the selected pane and its state
+func (m *model) outputTitle() string {
+	p := m.current()
+	if p.Index < 0 {
+		return "gowatch log"
+	}
+	title := p.Name
+	if len(m.tasks) > 1 {
+		title = p.Task + ": " + title
+	}
+	if info := p.info(); info != "" {
+		title += " · " + info
+	}
+	if p.command != "" && p.command != p.Name {
+		title += " " + faintStyle.Render(p.command)
+	}
+	return title
+}
+
+// renderLine fits a line of output to the pane's width
+func renderLine(l line, width int) string {
+	text := ansi.Truncate(l.text, max(width, 1), "…")
+	switch {
+	case l.separator:
+		return faintStyle.Render(text)
+	case l.isError:
+		return errorStyle.Render(text)
+	}
+	return text
+}
+
+// formatDuration shows durations to the millisecond below a second and to
+// a tenth of a second above
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
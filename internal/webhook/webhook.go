@@ -0,0 +1,117 @@
+// Package webhook posts a JSON summary of finished runs to HTTP endpoints
+// so that gowatch can feed chat-ops and other automation
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gowatch/internal/api"
+	"gowatch/pkg/config"
+	"gowatch/pkg/runner"
+)
+
+// Payload is the body posted for a run
+type Payload struct {
+	api.Result
+	Host       string `json:"host"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// NewPayload builds the payload for a run report
+func NewPayload(report runner.Report) Payload {
+	host, _ := os.Hostname()
+	return Payload{
+		Result:     api.NewResult(report),
+		Host:       host,
+		DurationMS: report.Duration.Milliseconds(),
+	}
+}
+
+// client is shared by all requests; attempts are bounded by their context
+var client = &http.Client{}
+
+// Send posts the payload to the webhook, retrying network errors, 429 and
+// 5xx responses as configured. Other responses are not retried.
+func Send(ctx context.Context, hook config.Webhook, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	backoff := hook.GetRetryBackoff()
+	for attempt := 0; ; attempt++ {
+		retry, err := post(ctx, hook, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= hook.Retries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one attempt and reports whether a failure is worth retrying
+func post(ctx context.Context, hook config.Webhook, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, hook.GetTimeout())
+	defer cancel()
+
+	// Secrets can be kept out of the config in environment variables
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, os.ExpandEnv(hook.URL), bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gowatch")
+	for _, h := range hook.Headers {
+		name, value, _ := strings.Cut(h, ":")
+		req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(os.ExpandEnv(value)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = Redact(os.ExpandEnv(hook.URL))
+		}
+		return true, err
+	}
+	defer resp.Body.Close()
+	// Drain so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("%s responded %s", Redact(os.ExpandEnv(hook.URL)), resp.Status)
+}
+
+// Redact shortens a webhook URL to its scheme and host for logging, since
+// chat services embed the secret in the path
+func Redact(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "webhook"
+	}
+	if u.Path == "" && u.RawQuery == "" {
+		return u.Scheme + "://" + u.Host
+	}
+	return u.Scheme + "://" + u.Host + "/…"
+}
@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/runner"
+)
+
+func TestSend(t *testing.T) {
+	report := runner.Report{
+		Task:     "test",
+		Trigger:  runner.Trigger{Path: "main.go", Event: "WRITE", RunID: 7},
+		Duration: 1500 * time.Millisecond,
+		Results: []runner.RunResult{
+			{Command: []string{"go", "test"}, ExitCode: 1, Duration: time.Second},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		statuses     []int
+		retries      int
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{"success", []int{200}, 2, 1, false},
+		{"retried server error", []int{500, 503, 204}, 2, 3, false},
+		{"retries exhausted", []int{500, 500, 500}, 1, 2, true},
+		{"client error not retried", []int{400, 200}, 2, 1, true},
+		{"rate limited", []int{429, 200}, 1, 2, false},
+	}
+
+	t.Setenv("WEBHOOK_TOKEN", "secret")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+					t.Errorf("Authorization = %q", got)
+				}
+				var p Payload
+				if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+					t.Errorf("invalid payload: %v", err)
+				}
+				if p.Task != "test" || p.RunID != 7 || p.Success || p.DurationMS != 1500 || len(p.Commands) != 1 {
+					t.Errorf("unexpected payload: %+v", p)
+				}
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			hook := config.Webhook{
+				URL:          srv.URL,
+				Headers:      []string{"Authorization: Bearer ${WEBHOOK_TOKEN}"},
+				Retries:      tt.retries,
+				RetryBackoff: "1ms",
+			}
+			err := Send(context.Background(), hook, NewPayload(report))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestSend_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	hook := config.Webhook{URL: srv.URL, Timeout: "50ms"}
+	start := time.Now()
+	if err := Send(context.Background(), hook, Payload{}); err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send took %s despite 50ms timeout", elapsed)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://hooks.slack.com/services/T000/B000/XXXX", "https://hooks.slack.com/…"},
+		{"http://localhost:8080", "http://localhost:8080"},
+		{"https://example.com?token=secret", "https://example.com/…"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.url); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Package websocket implements the server side of the WebSocket protocol
+// (RFC 6455), enough for gowatch to push messages to browsers and tools
+// without an external dependency.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// acceptGUID is appended to the client key to compute Sec-WebSocket-Accept
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxMessageSize bounds messages read from clients
+const maxMessageSize = 1 << 20
+
+// writeTimeout bounds how long a write may block on a slow client
+const writeTimeout = 5 * time.Second
+
+// Frame opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// ErrMessageTooLarge is returned when a client sends an oversized message
+var ErrMessageTooLarge = errors.New("websocket: message too large")
+
+// Conn is an upgraded WebSocket connection. Writes are safe for concurrent
+// use; reads must happen from a single goroutine.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Upgrade performs the opening handshake and takes over the connection
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, errors.New("websocket: method must be GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: missing key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket unsupported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: connection cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+	// Clear deadlines the HTTP server may have set
+	conn.SetDeadline(time.Time{})
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + AcceptKey(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+
+	return &Conn{conn: conn, br: rw.Reader}, nil
+}
+
+// AcceptKey computes the Sec-WebSocket-Accept value for a client key
+func AcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// WriteText sends a text message
+func (c *Conn) WriteText(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// and a close frame from the client ends the connection with io.EOF.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+		case opPong:
+		case opClose:
+			c.writeFrame(opClose, nil)
+			c.Close()
+			return nil, io.EOF
+		case opText, opBinary, opContinuation:
+			if len(message)+len(payload) > maxMessageSize {
+				return nil, ErrMessageTooLarge
+			}
+			message = append(message, payload...)
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, fmt.Errorf("websocket: unknown opcode %d", op)
+		}
+	}
+}
+
+// Close closes the underlying connection
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.conn.Close()
+}
+
+// writeFrame sends a single unmasked frame, as servers must
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | op
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readFrame reads a single frame, unmasking its payload
+func (c *Conn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.br, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	op = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	if !masked {
+		err = errors.New("websocket: client frames must be masked")
+		return
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxMessageSize {
+		err = ErrMessageTooLarge
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// headerContains reports whether a comma-separated header has a token
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
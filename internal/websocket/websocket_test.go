@@ -0,0 +1,154 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455, section 1.3
+	if got := AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("AcceptKey() = %q", got)
+	}
+}
+
+func TestUpgrade_RejectsPlainRequests(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Upgrade(w, r)
+	}))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestConn_EchoAndClose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteText(append([]byte("echo: "), msg...))
+		}
+	}))
+	defer ts.Close()
+
+	conn, br := dial(t, ts.URL)
+	defer conn.Close()
+
+	long := strings.Repeat("x", 300)
+	for _, msg := range []string{"hello", long} {
+		writeClientFrame(t, conn, opText, []byte(msg))
+		op, payload := readServerFrame(t, br)
+		if op != opText || string(payload) != "echo: "+msg {
+			t.Errorf("got opcode %d %q, want echo of %d bytes", op, payload, len(msg))
+		}
+	}
+
+	// Pings are answered with the same payload
+	writeClientFrame(t, conn, opPing, []byte("p"))
+	if op, payload := readServerFrame(t, br); op != opPong || string(payload) != "p" {
+		t.Errorf("got opcode %d %q, want pong", op, payload)
+	}
+
+	writeClientFrame(t, conn, opClose, nil)
+	if op, _ := readServerFrame(t, br); op != opClose {
+		t.Errorf("got opcode %d, want close", op)
+	}
+}
+
+// dial opens a raw connection and completes the handshake
+func dial(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	req := "GET / HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: " + key + "\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != AcceptKey(key) {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	return conn, br
+}
+
+func writeClientFrame(t *testing.T, conn net.Conn, op byte, payload []byte) {
+	t.Helper()
+
+	frame := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	default:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readServerFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+	t.Helper()
+
+	var header [2]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	if header[1]&0x80 != 0 {
+		t.Fatal("server frame is masked")
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(br, ext[:]); err != nil {
+			t.Fatal(err)
+		}
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0F, payload
+}
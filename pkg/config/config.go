@@ -0,0 +1,2083 @@
+// Package config loads and validates gowatch configuration files
+package config
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gowatch/internal/ignore"
+	"gowatch/pkg/logger"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	Watch          []WatchPath `mapstructure:"watch"`
+	OnChange       OnChange    `mapstructure:"on_change"`
+	Debounce       string      `mapstructure:"debounce"`
+	MaxConcurrency int         `mapstructure:"max_concurrency"`
+	Backend        string      `mapstructure:"backend"`
+	// Shell is the shell commands run through unless they set their own:
+	// sh, bash, zsh, pwsh, powershell, cmd, or none to run them directly.
+	// ShellWindows overrides it on Windows. Unset, commands run directly
+	// unless they use shell operators, which run through cmd.exe on
+	// Windows.
+	Shell        string `mapstructure:"shell"`
+	ShellWindows string `mapstructure:"shell_windows"`
+	// DebounceStrategy decides when changes within the debounce interval
+	// trigger a run: trailing (default), leading or throttle
+	DebounceStrategy string `mapstructure:"debounce_strategy"`
+	PollInterval     string `mapstructure:"poll_interval"`
+	// PollFallback polls the directories that can't be watched natively
+	// because the OS watch limit was reached, instead of failing
+	PollFallback bool `mapstructure:"poll_fallback"`
+	// BufferSize is the change buffer of each watched directory on
+	// Windows, such as "256KB" (default 64KB); bigger buffers survive
+	// larger bursts of changes without overflowing
+	BufferSize string `mapstructure:"buffer_size"`
+	// QueueSize is how many events a watcher holds while the runner is
+	// busy (default 100). QueueOverflow decides what happens to an event
+	// that doesn't fit: coalesce (default), drop-oldest or block.
+	QueueSize     int    `mapstructure:"queue_size"`
+	QueueOverflow string `mapstructure:"queue_overflow"`
+	// WatchCache keeps the directories of recursive watch paths in
+	// .gowatch/watch-cache.json, so that a restart watches them right away
+	// and checks the trees for changes in the background
+	WatchCache bool `mapstructure:"watch_cache"`
+	// Ignore holds gitignore-style patterns relative to the working
+	// directory that apply to every watch path
+	Ignore []string        `mapstructure:"ignore"`
+	Tasks  map[string]Task `mapstructure:"tasks"`
+	// Notify selects how finished runs are announced ("desktop" or unset)
+	Notify string `mapstructure:"notify"`
+	// Webhooks receive a JSON summary of every finished run
+	Webhooks []Webhook `mapstructure:"webhooks"`
+	// Bell rings the terminal bell when a run fails ("failure"), or also
+	// when a run succeeds after a failure ("change"). BellSound and
+	// BellRecoverySound are audio files played instead.
+	Bell              string `mapstructure:"bell"`
+	BellSound         string `mapstructure:"bell_sound"`
+	BellRecoverySound string `mapstructure:"bell_recovery_sound"`
+	// Hyperlinks links the file:line references in command output to the
+	// file ("file") or to the line in an editor: "vscode", "idea" or a URL
+	// template, see HyperlinkURL. Unset or "off" leaves them plain.
+	Hyperlinks string `mapstructure:"hyperlinks"`
+	// Report writes a report of every run, "junit:<path>" for a JUnit XML
+	// file. Tasks write theirs next to it, see ForTask.
+	Report string `mapstructure:"report"`
+	// OnSuccess and OnFailure run after the on_change commands, depending on
+	// whether all of them succeeded
+	OnSuccess []Command `mapstructure:"on_success"`
+	OnFailure []Command `mapstructure:"on_failure"`
+	// Setup runs once before watching starts, such as starting services;
+	// gowatch aborts if one of its commands fails. OnExit runs once when
+	// gowatch shuts down gracefully, to clean up after the session. Tasks
+	// don't inherit either, so they run only once.
+	Setup  []Command `mapstructure:"setup"`
+	OnExit []Command `mapstructure:"on_exit"`
+	// LiveReload is the address of the LiveReload server (e.g. ":35729");
+	// unset disables it
+	LiveReload string `mapstructure:"livereload"`
+	// Proxy serves a reverse proxy in front of the app a mode: restart
+	// command runs, see Proxy
+	Proxy Proxy `mapstructure:"proxy"`
+	// Serve serves a directory of static files that refreshes in the
+	// browser after every successful run, see Serve
+	Serve Serve `mapstructure:"serve"`
+	// RunOnStart runs the commands once as soon as watching starts
+	RunOnStart bool `mapstructure:"run_on_start"`
+	// Clear wipes the terminal before each run
+	Clear bool `mapstructure:"clear"`
+	// MaxFileSize drops changes to files larger than this size, such as
+	// "100MB" (unset: no limit)
+	MaxFileSize string `mapstructure:"max_file_size"`
+	// IgnoreDuringRun drops changes made while the commands were running,
+	// such as files they wrote themselves
+	IgnoreDuringRun bool `mapstructure:"ignore_during_run"`
+	// OutputDir, if set, receives the combined output of every command of
+	// every run, one directory per run. Only the latest OutputKeep runs are
+	// kept. Tasks write to a subdirectory named after them, see RunOutputDir.
+	OutputDir  string `mapstructure:"output_dir"`
+	OutputKeep int    `mapstructure:"output_keep"`
+	// History is the file every run is recorded in for gowatch history
+	// (default .gowatch/history.jsonl); "off" disables it. Only the latest
+	// HistoryKeep runs are kept.
+	History     string `mapstructure:"history"`
+	HistoryKeep int    `mapstructure:"history_keep"`
+	// StatusFile is kept up to date with the state of the session, for
+	// editors to show (default .gowatch/status.json); "off" disables it
+	StatusFile string `mapstructure:"status_file"`
+	// Profiles are named sets of overrides, one of which can be activated
+	// when loading the config
+	Profiles map[string]Profile `mapstructure:"profiles"`
+	// Profile is the name of the active profile, if any
+	Profile string `mapstructure:"-"`
+	// Task is the name of the task the config was derived from, if any
+	Task string `mapstructure:"-"`
+	// Dir is the directory relative paths and patterns in the config are
+	// resolved against, the working directory if empty. LoadRelative sets
+	// it to the directory of the file.
+	Dir string `mapstructure:"-"`
+	// UserConfig is the user config merged beneath the file, if any
+	UserConfig string `mapstructure:"-"`
+	// Unknown lists the keys loading ignored, as paths such as
+	// "tasks.api.ignores"
+	Unknown []string `mapstructure:"-"`
+	// Color set to false disables colored output, like --no-color
+	Color *bool `mapstructure:"color"`
+}
+
+// Profile overrides top-level settings when active. Debounce,
+// max_concurrency, notify and run_on_start also replace the values of tasks.
+type Profile struct {
+	OnChange       OnChange  `mapstructure:"on_change"`
+	Debounce       string    `mapstructure:"debounce"`
+	MaxConcurrency int       `mapstructure:"max_concurrency"`
+	Notify         string    `mapstructure:"notify"`
+	RunOnStart     *bool     `mapstructure:"run_on_start"`
+	OnSuccess      []Command `mapstructure:"on_success"`
+	OnFailure      []Command `mapstructure:"on_failure"`
+}
+
+// Task is a named pipeline with its own watch paths and commands. Settings
+// left unset are inherited from the top level of the config.
+type Task struct {
+	Watch          []WatchPath `mapstructure:"watch"`
+	OnChange       OnChange    `mapstructure:"on_change"`
+	Debounce       string      `mapstructure:"debounce"`
+	MaxConcurrency int         `mapstructure:"max_concurrency"`
+	Ignore         []string    `mapstructure:"ignore"`
+	// DebounceStrategy overrides the top-level setting when set
+	DebounceStrategy string    `mapstructure:"debounce_strategy"`
+	Notify           string    `mapstructure:"notify"`
+	Webhooks         []Webhook `mapstructure:"webhooks"`
+	OnSuccess        []Command `mapstructure:"on_success"`
+	OnFailure        []Command `mapstructure:"on_failure"`
+	// Setup and OnExit run when gowatch starts and shuts down, in addition
+	// to the top-level ones
+	Setup  []Command `mapstructure:"setup"`
+	OnExit []Command `mapstructure:"on_exit"`
+	// RunOnStart overrides the top-level setting when set
+	RunOnStart *bool `mapstructure:"run_on_start"`
+}
+
+type WatchPath struct {
+	Path      string   `mapstructure:"path"`
+	Recursive bool     `mapstructure:"recursive"`
+	Ignore    []string `mapstructure:"ignore"`
+	Backend   string   `mapstructure:"backend"`
+	// DebounceStrategy overrides the global strategy for changes under
+	// this path
+	DebounceStrategy string `mapstructure:"debounce_strategy"`
+	// Include and Extensions restrict events to matching files; a file
+	// passes if it matches any include pattern or any extension
+	Include    []string `mapstructure:"include"`
+	Extensions []string `mapstructure:"extensions"`
+	// Events lists the event types that trigger runs (default: all but chmod)
+	Events []string `mapstructure:"events"`
+	// MaxDepth limits how many levels of subdirectories a recursive watch
+	// descends into; 0 means no limit
+	MaxDepth int `mapstructure:"max_depth"`
+	// FollowSymlinks makes recursive watches descend into symlinked
+	// directories
+	FollowSymlinks bool `mapstructure:"follow_symlinks"`
+	// Remote watches a directory on another machine over SSH instead of a
+	// local path, given as "[user@]host:/abs/path"
+	Remote string `mapstructure:"remote"`
+	// MaxFileSize overrides the top-level max_file_size when set
+	MaxFileSize string `mapstructure:"max_file_size"`
+	// BufferSize overrides the top-level buffer_size when set
+	BufferSize string `mapstructure:"buffer_size"`
+	// Tags label the changes under the path, for commands that set
+	// when_tags
+	Tags []string `mapstructure:"tags"`
+}
+
+// Location returns the path shown for the watch path: its remote if set
+func (w WatchPath) Location() string {
+	if w.Remote != "" {
+		return w.Remote
+	}
+	return w.Path
+}
+
+// RemoteTarget splits Remote into the SSH destination and the directory on
+// that host
+func (w WatchPath) RemoteTarget() (host, dir string) {
+	host, dir, _ = strings.Cut(w.Remote, ":")
+	return host, dir
+}
+
+// DepthLimit returns how many levels of subdirectories below the path are
+// watched: 0 for non-recursive paths and -1 for no limit
+func (w WatchPath) DepthLimit() int {
+	switch {
+	case !w.Recursive:
+		return 0
+	case w.MaxDepth > 0:
+		return w.MaxDepth
+	default:
+		return -1
+	}
+}
+
+// Event types that can be listed in events
+const (
+	EventCreate = "create"
+	EventWrite  = "write"
+	EventRemove = "remove"
+	EventRename = "rename"
+	EventChmod  = "chmod"
+)
+
+// eventTypes lists every valid event type
+var eventTypes = []string{EventCreate, EventWrite, EventRemove, EventRename, EventChmod}
+
+// MatchEvents reports whether any of the event names (e.g. "WRITE") is listed
+// in the filter. Matching is case-insensitive.
+func MatchEvents(filter []string, names []string) bool {
+	for _, want := range filter {
+		for _, name := range names {
+			if strings.EqualFold(want, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateEvents checks that every entry of an events list is known
+func validateEvents(events []string) error {
+	for _, e := range events {
+		if !MatchEvents(eventTypes, []string{e}) {
+			return fmt.Errorf("invalid event %q (expected one of %s)", e, strings.Join(eventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// Watcher backends
+const (
+	// BackendFSNotify uses native OS file notifications (default)
+	BackendFSNotify = "fsnotify"
+	// BackendPoll scans for mtime/size changes, for filesystems that don't
+	// deliver notifications (NFS, SMB, Docker bind mounts)
+	BackendPoll = "poll"
+	// BackendWatchman subscribes to changes through a running Watchman
+	// daemon, for very large trees
+	BackendWatchman = "watchman"
+	// BackendFanotify covers a whole tree with one fanotify filesystem mark
+	// on Linux, falling back to fsnotify where that isn't possible
+	BackendFanotify = "fanotify"
+	// BackendRemote scans a directory on another machine over SSH. It is
+	// selected by setting remote on a watch path, not by backend.
+	BackendRemote = "remote"
+)
+
+// Debounce strategies
+const (
+	// DebounceTrailing runs once changes have stopped for the debounce
+	// interval (default)
+	DebounceTrailing = "trailing"
+	// DebounceLeading runs on the first change and suppresses the changes
+	// that follow it until they stop for the debounce interval
+	DebounceLeading = "leading"
+	// DebounceThrottle runs on the first change and then at most once per
+	// debounce interval, with the changes collected in between
+	DebounceThrottle = "throttle"
+)
+
+// Queue overflow policies
+const (
+	// OverflowCoalesce merges the queued events and the new one into a
+	// single event carrying all their changes (default)
+	OverflowCoalesce = "coalesce"
+	// OverflowDropOldest drops the oldest queued event to make room
+	OverflowDropOldest = "drop-oldest"
+	// OverflowBlock waits for room, holding back the delivery of further
+	// changes
+	OverflowBlock = "block"
+)
+
+// DefaultQueueSize is used when queue_size is not set
+const DefaultQueueSize = 100
+
+// Notification targets
+const (
+	// NotifyDesktop sends a native desktop notification when a run finishes
+	NotifyDesktop = "desktop"
+)
+
+// When the bell rings
+const (
+	// BellFailure rings when a run fails
+	BellFailure = "failure"
+	// BellChange rings when a run fails and when one recovers
+	BellChange = "change"
+)
+
+// Targets of hyperlinks
+const (
+	// HyperlinksFile links to the file, for the terminal to open
+	HyperlinksFile = "file"
+	// HyperlinksVSCode opens the line in VS Code
+	HyperlinksVSCode = "vscode"
+	// HyperlinksIdea opens the line in a JetBrains IDE
+	HyperlinksIdea = "idea"
+)
+
+// hyperlinkTemplates are the URL templates of the hyperlinks targets
+var hyperlinkTemplates = map[string]string{
+	HyperlinksFile:   "file://{path}",
+	HyperlinksVSCode: "vscode://file{path}:{line}:{column}",
+	HyperlinksIdea:   "idea://open?file={path}&line={line}&column={column}",
+}
+
+// Test runners whose output commands can be parsed
+const (
+	// ParserGoTest reads the JSON events of go test -json
+	ParserGoTest = "gotest"
+	ParserPytest = "pytest"
+	ParserJest   = "jest"
+)
+
+// parsers are the accepted parser values
+var parsers = []string{ParserGoTest, ParserPytest, ParserJest}
+
+// DefaultPollInterval is used when poll_interval is not set
+const DefaultPollInterval = time.Second
+
+// MinBufferSize is the smallest buffer_size Windows accepts
+const MinBufferSize = 4 << 10
+
+type OnChange struct {
+	Commands []Command `mapstructure:"commands"`
+	// Stages groups commands instead of Commands: the commands of a stage
+	// run in parallel, and each stage starts once the previous one has
+	// succeeded. SetDefaults moves them into Commands, tagged with their
+	// stage.
+	Stages []Stage `mapstructure:"stages"`
+}
+
+// Stage is a group of commands that run side by side
+type Stage struct {
+	Name     string    `mapstructure:"name"`
+	Commands []Command `mapstructure:"commands"`
+}
+
+// expandStages lists the commands of the stages in Commands. Commands set
+// alongside stages are left for Validate to report.
+func (o *OnChange) expandStages() {
+	if len(o.Stages) == 0 || len(o.Commands) > 0 {
+		return
+	}
+	for i, stage := range o.Stages {
+		for _, cmd := range stage.Commands {
+			cmd.Stage = i + 1
+			o.Commands = append(o.Commands, cmd)
+		}
+	}
+}
+
+// StageName returns the name of a command's stage, or its number if it has
+// none
+func (o OnChange) StageName(cmd Command) string {
+	if cmd.Stage < 1 || cmd.Stage > len(o.Stages) {
+		return ""
+	}
+	if name := o.Stages[cmd.Stage-1].Name; name != "" {
+		return name
+	}
+	return strconv.Itoa(cmd.Stage)
+}
+
+type Command struct {
+	// Name labels the command's output lines, e.g. "[api] listening"
+	Name    string   `mapstructure:"name"`
+	Cmd     []string `mapstructure:"cmd"`
+	Run     string   `mapstructure:"run"`
+	Mode    string   `mapstructure:"mode"`
+	Timeout string   `mapstructure:"timeout"`
+	// Retries re-runs a failing command up to this many times, waiting
+	// RetryBackoff before the first retry and doubling it after each one
+	Retries      int    `mapstructure:"retries"`
+	RetryBackoff string `mapstructure:"retry_backoff"`
+	// Env holds extra KEY=value entries for the command's environment. A
+	// list rather than a map because viper lowercases map keys.
+	Env []string `mapstructure:"env"`
+	// Cwd is the working directory of the command (default: gowatch's)
+	Cwd string `mapstructure:"cwd"`
+	// Events limits the command to changes of these types (default: all)
+	Events []string `mapstructure:"events"`
+	// Match limits the command to changes of files matching these
+	// gitignore-style patterns, relative to the working directory
+	Match []string `mapstructure:"match"`
+	// WhenTags limits the command to changes under watch paths with any of
+	// these tags
+	WhenTags []string `mapstructure:"when_tags"`
+	// Cooldown is the minimum time between two starts of the command.
+	// Changes arriving sooner are coalesced into one run once it ends.
+	Cooldown string `mapstructure:"cooldown"`
+	// Reload refreshes LiveReload browsers after a run in which the command
+	// and all others succeeded
+	Reload bool `mapstructure:"reload"`
+	// KillSignal is sent to stop the command on timeout, cancellation or
+	// restart; it is killed if still running after KillGrace
+	KillSignal string `mapstructure:"kill_signal"`
+	KillGrace  string `mapstructure:"kill_grace"`
+	// DependsOn names commands that must succeed before this one starts.
+	// Commands of a pipeline that uses it run as a dependency graph.
+	DependsOn []string `mapstructure:"depends_on"`
+	// Outputs are gitignore-style patterns, relative to the working
+	// directory, of files the command writes. Changes to them made during
+	// a run do not trigger another one.
+	Outputs []string `mapstructure:"outputs"`
+	// Interactive connects the command's stdin to the terminal so it can
+	// prompt; its output goes to the terminal unprefixed, and interactive
+	// commands take turns at the terminal
+	Interactive bool `mapstructure:"interactive"`
+	// PTY runs the command in a pseudo-terminal, so that tools keep the
+	// colors and progress output they turn off for a pipe; in a container
+	// it runs with docker exec -t
+	PTY bool `mapstructure:"pty"`
+	// Priority orders commands waiting for max_concurrency slots: higher
+	// priorities start first. Weight is how many slots the command takes
+	// (default 1), so that heavy commands don't run alongside each other.
+	Priority int `mapstructure:"priority"`
+	Weight   int `mapstructure:"weight"`
+	// Stage is the 1-based stage the command is listed in, 0 outside
+	// stages
+	Stage int `mapstructure:"-"`
+	// Container runs the command inside this running container with
+	// docker exec. ContainerPaths maps host directories to directories in
+	// the container as "host:container" entries so that placeholders refer
+	// to paths the command can see; by default the container's bind mounts
+	// are used.
+	Container      string   `mapstructure:"container"`
+	ContainerPaths []string `mapstructure:"container_paths"`
+	// RestartService restarts a Docker Compose service instead of running
+	// cmd, and waits for it to become healthy. Recreate uses "up -d" so
+	// that changes to the image or service definition are picked up;
+	// ComposeFile selects the compose file (default: compose's lookup).
+	RestartService string `mapstructure:"restart_service"`
+	Recreate       bool   `mapstructure:"recreate"`
+	ComposeFile    string `mapstructure:"compose_file"`
+	// Nice runs the command and the processes it starts at this CPU
+	// priority, from -20 (highest) to 19 (lowest) as with nice, so that
+	// heavy builds don't slow down the editor; Windows maps it to a
+	// priority class. IONice sets their I/O class on Linux.
+	Nice   int    `mapstructure:"nice"`
+	IONice string `mapstructure:"ionice"`
+	// Limits caps the memory and CPU of the command and the processes it
+	// starts, through a cgroup on Linux
+	Limits Limits `mapstructure:"limits"`
+	// Output sends the output of the command to a tmux pane rather than
+	// the terminal: "tmux:<pane>" for an existing pane, or "new-window"
+	// for a window of its own
+	Output string `mapstructure:"output"`
+	// OutputFilter holds regular expressions of output lines not to show,
+	// such as progress bars; output_dir still saves them
+	OutputFilter []string `mapstructure:"output_filter"`
+	// Highlight styles the output lines matching its rules, in the order
+	// given, for tools that print plain text into a pipe
+	Highlight []Highlight `mapstructure:"highlight"`
+	// Parser reads the results of a test runner from the output of the
+	// command, "gotest" (go test -json), "pytest" or "jest", for a summary
+	// of the tests that passed and failed
+	Parser string `mapstructure:"parser"`
+	// Shell runs the command through this shell, or directly with "none",
+	// overriding the top-level shell; ShellWindows does on Windows
+	Shell        string `mapstructure:"shell"`
+	ShellWindows string `mapstructure:"shell_windows"`
+}
+
+// Highlight styles the output lines of a command matching Match, a regular
+// expression, in Style, such as "red bold"
+type Highlight struct {
+	Match string `mapstructure:"match"`
+	Style string `mapstructure:"style"`
+}
+
+// Limits are the resources a command may use
+type Limits struct {
+	// Memory is the most memory the command may use, such as "2GiB";
+	// beyond it the kernel kills it
+	Memory string `mapstructure:"memory"`
+	// CPU is how many CPUs' worth of time it may use, such as 2 or 0.5
+	CPU float64 `mapstructure:"cpu"`
+}
+
+// IsZero reports whether no limit is set
+func (l Limits) IsZero() bool {
+	return l.Memory == "" && l.CPU == 0
+}
+
+// validate checks the limits of a command
+func (l Limits) validate() error {
+	if l.Memory != "" {
+		n, err := ParseSize(l.Memory)
+		if err != nil {
+			return fmt.Errorf("limits: memory: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("limits: memory must be positive")
+		}
+	}
+	if l.CPU < 0 || math.IsNaN(l.CPU) {
+		return fmt.Errorf("limits: cpu must not be negative")
+	}
+	return nil
+}
+
+// ParseContainerPath splits a container_paths entry into the host directory
+// and the directory in the container. The container side must be absolute;
+// the host side may be relative to the working directory.
+func ParseContainerPath(entry string) (host, container string, ok bool) {
+	i := strings.LastIndex(entry, ":")
+	if i <= 0 {
+		return "", "", false
+	}
+	host, container = entry[:i], entry[i+1:]
+	return host, container, strings.HasPrefix(container, "/")
+}
+
+// DefaultRetryBackoff is used when retry_backoff is not set
+const DefaultRetryBackoff = time.Second
+
+// Command modes
+const (
+	// ModeOnce runs the command to completion on every change (default)
+	ModeOnce = "once"
+	// ModeRestart keeps the command running and restarts it on every change
+	ModeRestart = "restart"
+)
+
+// Shells commands run through
+const (
+	ShellSh         = "sh"
+	ShellBash       = "bash"
+	ShellZsh        = "zsh"
+	ShellPwsh       = "pwsh"
+	ShellPowerShell = "powershell"
+	ShellCmd        = "cmd"
+	// ShellNone runs the command directly, without guessing at a shell
+	ShellNone = "none"
+)
+
+// shells are the accepted shell values
+var shells = []string{ShellSh, ShellBash, ShellZsh, ShellPwsh, ShellPowerShell, ShellCmd, ShellNone}
+
+// Output targets of commands
+const (
+	// OutputTmuxPrefix prefixes the tmux target of an existing pane, e.g.
+	// "tmux:1.2" or "tmux:%3"
+	OutputTmuxPrefix = "tmux:"
+	// OutputNewWindow opens a tmux window for the command
+	OutputNewWindow = "new-window"
+)
+
+// ReportJUnitPrefix prefixes the file of a JUnit report, e.g.
+// "junit:reports/gowatch.xml"
+const ReportJUnitPrefix = "junit:"
+
+// I/O scheduling classes of commands on Linux
+const (
+	// IONiceBestEffort shares the disk by priority, following nice
+	IONiceBestEffort = "best-effort"
+	// IONiceIdle only gets the disk when nothing else uses it
+	IONiceIdle = "idle"
+)
+
+// Nice values
+const (
+	MinNice = -20
+	MaxNice = 19
+)
+
+// Kill defaults
+const (
+	DefaultKillSignal = "SIGINT"
+	DefaultKillGrace  = 5 * time.Second
+)
+
+// killSignals are the accepted kill_signal values
+var killSignals = []string{"SIGINT", "SIGTERM", "SIGHUP", "SIGQUIT", "SIGKILL"}
+
+// GetKillSignal returns the normalized kill signal name, e.g. "SIGTERM"
+func (c Command) GetKillSignal() string {
+	if c.KillSignal == "" {
+		return DefaultKillSignal
+	}
+	name := strings.ToUpper(c.KillSignal)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	return name
+}
+
+// GetKillGrace returns how long a stopped command may take to exit
+func (c Command) GetKillGrace() time.Duration {
+	if d, err := time.ParseDuration(c.KillGrace); err == nil && d >= 0 {
+		return d
+	}
+	return DefaultKillGrace
+}
+
+// GetRetryBackoff returns the delay before the first retry
+func (c Command) GetRetryBackoff() time.Duration {
+	if d, err := time.ParseDuration(c.RetryBackoff); err == nil && d > 0 {
+		return d
+	}
+	return DefaultRetryBackoff
+}
+
+// GetCooldown returns the minimum time between two starts of the command
+func (c Command) GetCooldown() time.Duration {
+	if d, err := time.ParseDuration(c.Cooldown); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
+// GetWeight returns how many concurrency slots the command takes
+func (c Command) GetWeight() int {
+	return max(c.Weight, 1)
+}
+
+// ComposeCommand returns the docker compose command line that restarts the
+// service of a restart_service command
+func (c Command) ComposeCommand() []string {
+	argv := []string{"docker", "compose"}
+	if c.ComposeFile != "" {
+		argv = append(argv, "-f", c.ComposeFile)
+	}
+	if c.Recreate {
+		return append(argv, "up", "-d", "--no-deps", c.RestartService)
+	}
+	return append(argv, "restart", c.RestartService)
+}
+
+// IsRestart reports whether the command is a long-running process that
+// should be restarted on change rather than waited on
+func (c Command) IsRestart() bool {
+	return c.Mode == ModeRestart
+}
+
+// Webhook is an HTTP endpoint that receives a JSON summary of finished runs
+type Webhook struct {
+	// URL and the header values may reference environment variables as
+	// $NAME or ${NAME}, keeping secrets out of the config
+	URL string `mapstructure:"url"`
+	// On selects the runs that are posted: "always" (default), "success"
+	// or "failure"
+	On string `mapstructure:"on"`
+	// Headers holds extra "Name: value" request headers, e.g. for
+	// authentication. A list rather than a map because viper lowercases
+	// map keys.
+	Headers []string `mapstructure:"headers"`
+	// Timeout bounds each attempt
+	Timeout string `mapstructure:"timeout"`
+	// Retries re-sends a failed request up to this many times, waiting
+	// RetryBackoff before the first retry and doubling it after each one
+	Retries      int    `mapstructure:"retries"`
+	RetryBackoff string `mapstructure:"retry_backoff"`
+}
+
+// Webhook run filters
+const (
+	WebhookAlways  = "always"
+	WebhookSuccess = "success"
+	WebhookFailure = "failure"
+)
+
+// DefaultWebhookTimeout is used when a webhook sets no timeout
+const DefaultWebhookTimeout = 10 * time.Second
+
+// GetTimeout returns how long each attempt may take
+func (w Webhook) GetTimeout() time.Duration {
+	if d, err := time.ParseDuration(w.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return DefaultWebhookTimeout
+}
+
+// GetRetryBackoff returns the delay before the first retry
+func (w Webhook) GetRetryBackoff() time.Duration {
+	if d, err := time.ParseDuration(w.RetryBackoff); err == nil && d > 0 {
+		return d
+	}
+	return DefaultRetryBackoff
+}
+
+// Wants reports whether a run with the given outcome is posted
+func (w Webhook) Wants(success bool) bool {
+	switch w.On {
+	case WebhookSuccess:
+		return success
+	case WebhookFailure:
+		return !success
+	default:
+		return true
+	}
+}
+
+// validate checks the fields of a single webhook
+func (w Webhook) validate() error {
+	u, err := url.Parse(os.ExpandEnv(w.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q (expected http:// or https://)", w.URL)
+	}
+	switch w.On {
+	case "", WebhookAlways, WebhookSuccess, WebhookFailure:
+	default:
+		return fmt.Errorf("invalid on %q (expected %q, %q or %q)", w.On, WebhookAlways, WebhookSuccess, WebhookFailure)
+	}
+	for _, h := range w.Headers {
+		if name, _, ok := strings.Cut(h, ":"); !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("invalid header %q (expected \"Name: value\")", h)
+		}
+	}
+	for _, d := range []struct{ name, value string }{{"timeout", w.Timeout}, {"retry_backoff", w.RetryBackoff}} {
+		if d.value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d.value); err != nil {
+			return fmt.Errorf("invalid %s: %w", d.name, err)
+		}
+	}
+	if w.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+	return nil
+}
+
+// Proxy is a reverse proxy in front of an app that gowatch restarts. During
+// the runs of pipelines with mode: restart commands it holds requests, and
+// lets them through once the app accepts connections again, so that the
+// browser waits for the new version instead of failing to connect.
+type Proxy struct {
+	// Listen is the address the proxy serves, such as ":3000"; unset
+	// disables it
+	Listen string `mapstructure:"listen"`
+	// Target is the address of the app, such as ":8080" or
+	// "http://localhost:8080"
+	Target string `mapstructure:"target"`
+	// Timeout is how long a request waits for the app before failing
+	Timeout string `mapstructure:"timeout"`
+}
+
+// DefaultProxyTimeout is used when the proxy sets no timeout
+const DefaultProxyTimeout = 30 * time.Second
+
+// Enabled reports whether the proxy is configured
+func (p Proxy) Enabled() bool {
+	return p.Listen != ""
+}
+
+// TargetURL returns the URL of the app, with a host of localhost when the
+// target only gives a port
+func (p Proxy) TargetURL() (*url.URL, error) {
+	target := p.Target
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Port() == "" && u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid target %q (expected [http://]host:port)", p.Target)
+	}
+	if u.Hostname() == "" {
+		u.Host = "localhost" + u.Host
+	}
+	return u, nil
+}
+
+// GetTimeout returns how long a request waits for the app
+func (p Proxy) GetTimeout() time.Duration {
+	if d, err := time.ParseDuration(p.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return DefaultProxyTimeout
+}
+
+// validate checks the fields of the proxy
+func (p Proxy) validate() error {
+	if !p.Enabled() {
+		if p.Target != "" || p.Timeout != "" {
+			return fmt.Errorf("listen is required")
+		}
+		return nil
+	}
+	if p.Target == "" {
+		return fmt.Errorf("target is required")
+	}
+	if _, err := p.TargetURL(); err != nil {
+		return err
+	}
+	if p.Timeout != "" {
+		if _, err := time.ParseDuration(p.Timeout); err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+	}
+	return nil
+}
+
+// Serve is a static file server for plain HTML, CSS and JavaScript. HTML
+// pages it serves load a LiveReload client, and browsers refresh after every
+// successful run, without a build tool or browser extension.
+type Serve struct {
+	// Dir is the directory served; unset disables the server
+	Dir string `mapstructure:"dir"`
+	// Listen is the address served (default: ":8000")
+	Listen string `mapstructure:"listen"`
+}
+
+// DefaultServeListen is used when the server sets no address
+const DefaultServeListen = ":8000"
+
+// Enabled reports whether the server is configured
+func (s Serve) Enabled() bool {
+	return s.Dir != ""
+}
+
+// GetListen returns the address served
+func (s Serve) GetListen() string {
+	if s.Listen != "" {
+		return s.Listen
+	}
+	return DefaultServeListen
+}
+
+// validate checks the fields of the server
+func (s Serve) validate() error {
+	if !s.Enabled() && s.Listen != "" {
+		return fmt.Errorf("dir is required")
+	}
+	return nil
+}
+
+// FileNames are the config file names searched for, in order of preference
+var FileNames = []string{"gowatch.yaml", "gowatch.yml", "gowatch.toml", "gowatch.json"}
+
+// Find returns the first config file found in the working directory, or in
+// ~/.config/gowatch if there is none
+func Find() (string, error) {
+	dirs := []string{"."}
+	if dir := userDir(); dir != "" {
+		dirs = append(dirs, dir)
+	}
+
+	for _, dir := range dirs {
+		if path, ok := findIn(dir); ok {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no config file found (looked for %s)", strings.Join(FileNames, ", "))
+}
+
+// FindIn returns the config file in a directory
+func FindIn(dir string) (string, error) {
+	if path, ok := findIn(dir); ok {
+		return path, nil
+	}
+	return "", fmt.Errorf("no config file found in %s (looked for %s)", dir, strings.Join(FileNames, ", "))
+}
+
+func findIn(dir string) (string, bool) {
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// Load loads a configuration file, YAML, TOML or JSON by extension. An
+// empty path searches for one with Find.
+func Load(configPath string) (*Config, error) {
+	return LoadProfile(configPath, "")
+}
+
+// LoadProfile loads a configuration file with the named profile applied.
+// An empty profile loads the file as is.
+func LoadProfile(configPath, profile string) (*Config, error) {
+	return load(configPath, profile, false)
+}
+
+// LoadRelative is LoadProfile for a config that isn't in the working
+// directory, such as one of a workspace: its relative paths and patterns
+// are resolved against the directory of the file instead, and commands run
+// there unless they set a cwd.
+func LoadRelative(configPath, profile string) (*Config, error) {
+	return load(configPath, profile, true)
+}
+
+func load(configPath, profile string, relative bool) (*Config, error) {
+	if configPath == "" {
+		found, err := Find()
+		if err != nil {
+			return nil, err
+		}
+		configPath = found
+	}
+
+	// The format follows the extension; files without one are YAML
+	settings, err := readSettings(configPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	// Personal defaults from the user config apply beneath the file
+	settings, userPath, err := withUserSettings(configPath, settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user config: %w", err)
+	}
+	v := viper.New()
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var cfg Config
+	var md mapstructure.Metadata
+	if err := v.Unmarshal(&cfg, func(dc *mapstructure.DecoderConfig) { dc.Metadata = &md }); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	cfg.UserConfig = userPath
+	cfg.Unknown = unknownKeys(md)
+	if Strict && len(cfg.Unknown) > 0 {
+		return nil, unknownKeysError(cfg.Unknown)
+	}
+
+	if profile != "" {
+		if err := cfg.ApplyProfile(profile); err != nil {
+			return nil, err
+		}
+	}
+	if relative {
+		dir, err := filepath.Abs(filepath.Dir(configPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		cfg.rebase(dir)
+	}
+
+	cfg.SetDefaults()
+
+	// Validate
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// rebase resolves the relative paths of the config against dir
+func (c *Config) rebase(dir string) {
+	c.Dir = dir
+	rebaseWatch := func(watch []WatchPath) {
+		for i, wp := range watch {
+			if wp.Remote == "" {
+				watch[i].Path = c.resolve(wp.Path)
+			}
+		}
+	}
+	rebaseWatch(c.Watch)
+	for _, task := range c.Tasks {
+		rebaseWatch(task.Watch)
+	}
+	if c.OutputDir != "" {
+		c.OutputDir = c.resolve(c.OutputDir)
+	}
+	if c.History != "" && c.History != "off" {
+		c.History = c.resolve(c.History)
+	}
+	if c.StatusFile != "" && c.StatusFile != "off" {
+		c.StatusFile = c.resolve(c.StatusFile)
+	}
+	if c.Serve.Dir != "" {
+		c.Serve.Dir = c.resolve(c.Serve.Dir)
+	}
+	if c.BellSound != "" {
+		c.BellSound = c.resolve(c.BellSound)
+	}
+	if c.BellRecoverySound != "" {
+		c.BellRecoverySound = c.resolve(c.BellRecoverySound)
+	}
+	if path := c.JUnitReport(); path != "" {
+		c.Report = ReportJUnitPrefix + c.resolve(path)
+	}
+}
+
+// resolve joins a relative path to the directory of the config, if it has
+// one
+func (c *Config) resolve(path string) string {
+	if c.Dir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.Dir, path)
+}
+
+// WorkDir returns the directory relative paths and patterns in the config
+// are resolved against: Dir if set, otherwise the working directory
+func (c *Config) WorkDir() (string, error) {
+	if c.Dir != "" {
+		return c.Dir, nil
+	}
+	return os.Getwd()
+}
+
+// Abs returns an absolute form of a path from the config, resolving it
+// against WorkDir
+func (c *Config) Abs(path string) (string, error) {
+	if c.Dir != "" {
+		return filepath.Clean(c.resolve(path)), nil
+	}
+	return filepath.Abs(path)
+}
+
+// SetDefaults fills in settings left unset. Load calls it; configs built in
+// code should call it before Validate.
+func (c *Config) SetDefaults() {
+	c.OnChange.expandStages()
+	for name, task := range c.Tasks {
+		task.OnChange.expandStages()
+		c.Tasks[name] = task
+	}
+	if c.Debounce == "" {
+		c.Debounce = "250ms"
+	}
+	if c.MaxConcurrency == 0 {
+		c.MaxConcurrency = 2
+	}
+}
+
+func (c *Config) Validate() error {
+	// A config made only of tasks has no top-level pipeline to validate,
+	// but its setup and on_exit still run
+	if len(c.Tasks) == 0 || c.HasPipeline() {
+		if err := c.validatePipeline(); err != nil {
+			return err
+		}
+	} else {
+		if err := validateHooks("setup", c.Setup); err != nil {
+			return err
+		}
+		if err := validateHooks("on_exit", c.OnExit); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range c.TaskNames() {
+		if name == "" || strings.ContainsAny(name, ", ") {
+			return fmt.Errorf("invalid task name %q: must be non-empty without commas or spaces", name)
+		}
+		tc, err := c.ForTask(name)
+		if err != nil {
+			return err
+		}
+		if err := tc.validatePipeline(); err != nil {
+			return fmt.Errorf("task %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// validatePipeline checks the watch paths, commands and settings of a single
+// runnable pipeline
+func (c *Config) validatePipeline() error {
+	if len(c.Watch) == 0 {
+		return fmt.Errorf("at least one watch path is required")
+	}
+
+	// Validate debounce duration
+	if _, err := time.ParseDuration(c.Debounce); err != nil {
+		return fmt.Errorf("invalid debounce duration: %w", err)
+	}
+
+	if err := validateDebounceStrategy(c.DebounceStrategy); err != nil {
+		return err
+	}
+
+	// Validate backend selection
+	if err := validateBackend(c.Backend); err != nil {
+		return err
+	}
+	if err := validateShell("shell", c.Shell); err != nil {
+		return err
+	}
+	if err := validateShell("shell_windows", c.ShellWindows); err != nil {
+		return err
+	}
+	if c.PollInterval != "" {
+		d, err := time.ParseDuration(c.PollInterval)
+		if err != nil {
+			return fmt.Errorf("invalid poll_interval: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("poll_interval must be positive")
+		}
+	}
+
+	if c.MaxFileSize != "" {
+		if _, err := ParseSize(c.MaxFileSize); err != nil {
+			return fmt.Errorf("invalid max_file_size: %w", err)
+		}
+	}
+	if err := validateBufferSize(c.BufferSize); err != nil {
+		return err
+	}
+	if c.QueueSize < 0 {
+		return fmt.Errorf("queue_size must not be negative")
+	}
+	switch c.QueueOverflow {
+	case "", OverflowCoalesce, OverflowDropOldest, OverflowBlock:
+	default:
+		return fmt.Errorf("invalid queue_overflow %q (expected %q, %q or %q)", c.QueueOverflow, OverflowCoalesce, OverflowDropOldest, OverflowBlock)
+	}
+	if err := ignore.Check(c.Ignore); err != nil {
+		return fmt.Errorf("ignore: %w", err)
+	}
+
+	switch c.Notify {
+	case "", NotifyDesktop:
+	default:
+		return fmt.Errorf("invalid notify %q (expected %q)", c.Notify, NotifyDesktop)
+	}
+	switch c.Bell {
+	case "", BellFailure, BellChange:
+	default:
+		return fmt.Errorf("invalid bell %q (expected %q or %q)", c.Bell, BellFailure, BellChange)
+	}
+	if c.Bell == "" && (c.BellSound != "" || c.BellRecoverySound != "") {
+		return fmt.Errorf("bell_sound and bell_recovery_sound need bell")
+	}
+	if c.BellRecoverySound != "" && c.Bell != BellChange {
+		return fmt.Errorf("bell_recovery_sound needs bell: %s", BellChange)
+	}
+	if c.Hyperlinks != "" && c.Hyperlinks != "off" && c.HyperlinkURL() == "" {
+		return fmt.Errorf("invalid hyperlinks %q (expected %s, %s, %s or a URL with {path})", c.Hyperlinks, HyperlinksFile, HyperlinksVSCode, HyperlinksIdea)
+	}
+	if err := c.Proxy.validate(); err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	if err := c.Serve.validate(); err != nil {
+		return fmt.Errorf("serve: %w", err)
+	}
+	if c.Report != "" && c.JUnitReport() == "" {
+		return fmt.Errorf("invalid report %q (expected %s<path>)", c.Report, ReportJUnitPrefix)
+	}
+
+	// Validate watch paths exist
+	for i, w := range c.Watch {
+		if w.Remote != "" {
+			if err := w.validateRemote(); err != nil {
+				return fmt.Errorf("watch path %d: %w", i, err)
+			}
+		} else if w.Path == "" {
+			return fmt.Errorf("watch path %d: path is empty", i)
+		}
+		if err := validateBackend(w.Backend); err != nil {
+			return fmt.Errorf("watch path %d: %w", i, err)
+		}
+		if err := validateDebounceStrategy(w.DebounceStrategy); err != nil {
+			return fmt.Errorf("watch path %d: %w", i, err)
+		}
+		if err := validateEvents(w.Events); err != nil {
+			return fmt.Errorf("watch path %d: %w", i, err)
+		}
+		if w.MaxDepth < 0 {
+			return fmt.Errorf("watch path %d: max_depth must not be negative", i)
+		}
+		if w.MaxFileSize != "" {
+			if _, err := ParseSize(w.MaxFileSize); err != nil {
+				return fmt.Errorf("watch path %d: invalid max_file_size: %w", i, err)
+			}
+		}
+		if err := validateBufferSize(w.BufferSize); err != nil {
+			return fmt.Errorf("watch path %d: %w", i, err)
+		}
+		if err := ignore.Check(w.Ignore); err != nil {
+			return fmt.Errorf("watch path %d: ignore: %w", i, err)
+		}
+		if err := ignore.Check(w.Include); err != nil {
+			return fmt.Errorf("watch path %d: include: %w", i, err)
+		}
+		if w.MaxDepth > 0 && !w.Recursive {
+			return fmt.Errorf("watch path %d: max_depth requires recursive: true", i)
+		}
+		if slices.Contains(w.Tags, "") {
+			return fmt.Errorf("watch path %d: empty tag", i)
+		}
+		if w.Remote != "" {
+			continue
+		}
+		absPath, err := filepath.Abs(w.Path)
+		if err != nil {
+			return fmt.Errorf("watch path %d: invalid path %s: %w", i, w.Path, err)
+		}
+		if _, err := os.Stat(absPath); err != nil {
+			return fmt.Errorf("watch path %d does not exist: %s", i, absPath)
+		}
+	}
+
+	// Validate commands; a static server refreshes browsers without any
+	if len(c.OnChange.Commands) == 0 && !c.Serve.Enabled() {
+		return fmt.Errorf("at least one command is required")
+	}
+	if err := c.OnChange.validateStages(); err != nil {
+		return err
+	}
+
+	for i, cmd := range c.OnChange.Commands {
+		if err := cmd.validate(); err != nil {
+			return fmt.Errorf("command %d: %w", i, err)
+		}
+		for _, tag := range cmd.WhenTags {
+			if !slices.ContainsFunc(c.Watch, func(w WatchPath) bool { return slices.Contains(w.Tags, tag) }) {
+				return fmt.Errorf("command %d: when_tags: no watch path is tagged %q", i, tag)
+			}
+		}
+	}
+	if err := validateDependencies(c.OnChange.Commands); err != nil {
+		return err
+	}
+
+	// Validate hooks
+	for _, hook := range []struct {
+		name     string
+		commands []Command
+	}{
+		{"setup", c.Setup},
+		{"on_success", c.OnSuccess},
+		{"on_failure", c.OnFailure},
+		{"on_exit", c.OnExit},
+	} {
+		if err := validateHooks(hook.name, hook.commands); err != nil {
+			return err
+		}
+	}
+
+	// Validate max concurrency
+	if c.MaxConcurrency < 1 {
+		return fmt.Errorf("max_concurrency must be at least 1")
+	}
+
+	if c.OutputKeep < 0 {
+		return fmt.Errorf("output_keep must not be negative")
+	}
+	if c.HistoryKeep < 0 {
+		return fmt.Errorf("history_keep must not be negative")
+	}
+
+	for i, w := range c.Webhooks {
+		if err := w.validate(); err != nil {
+			return fmt.Errorf("webhook %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// HasDependencies reports whether any command declares depends_on
+func (o OnChange) HasDependencies() bool {
+	for _, cmd := range o.Commands {
+		if len(cmd.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// HasStages reports whether the commands are grouped in stages
+func (o OnChange) HasStages() bool {
+	return len(o.Stages) > 0
+}
+
+// validateStages checks that stages aren't mixed with ungrouped commands or
+// dependencies, which they replace
+func (o OnChange) validateStages() error {
+	if !o.HasStages() {
+		return nil
+	}
+	for i, stage := range o.Stages {
+		if len(stage.Commands) == 0 {
+			return fmt.Errorf("stage %d: at least one command is required", i)
+		}
+	}
+	for _, cmd := range o.Commands {
+		switch {
+		case cmd.Stage == 0:
+			return fmt.Errorf("on_change: commands and stages are mutually exclusive")
+		case len(cmd.DependsOn) > 0:
+			return fmt.Errorf("stage %q: depends_on is not supported in stages", o.StageName(cmd))
+		}
+	}
+	return nil
+}
+
+// validateHooks checks hook commands, which run once in order and so can't
+// use the options that shape on_change runs
+func validateHooks(name string, commands []Command) error {
+	for i, cmd := range commands {
+		if err := cmd.validate(); err != nil {
+			return fmt.Errorf("%s command %d: %w", name, i, err)
+		}
+		if cmd.IsRestart() {
+			return fmt.Errorf("%s command %d: %q mode is not supported for hooks", name, i, ModeRestart)
+		}
+		if cmd.Reload {
+			return fmt.Errorf("%s command %d: reload is not supported for hooks", name, i)
+		}
+		if len(cmd.DependsOn) > 0 {
+			return fmt.Errorf("%s command %d: depends_on is not supported for hooks", name, i)
+		}
+		if cmd.Cooldown != "" {
+			return fmt.Errorf("%s command %d: cooldown is not supported for hooks", name, i)
+		}
+	}
+	return nil
+}
+
+// validateDependencies checks that depends_on refers to uniquely named
+// commands and that the commands form no cycle
+func validateDependencies(commands []Command) error {
+	index := make(map[string]int)
+	for i, cmd := range commands {
+		if cmd.Name == "" {
+			continue
+		}
+		if _, dup := index[cmd.Name]; dup {
+			index[cmd.Name] = -1
+			continue
+		}
+		index[cmd.Name] = i
+	}
+
+	for i, cmd := range commands {
+		for _, dep := range cmd.DependsOn {
+			j, ok := index[dep]
+			switch {
+			case !ok:
+				return fmt.Errorf("command %d: depends_on %q: no command has that name", i, dep)
+			case j < 0:
+				return fmt.Errorf("command %d: depends_on %q: several commands have that name", i, dep)
+			case j == i:
+				return fmt.Errorf("command %d: depends on itself", i)
+			}
+		}
+	}
+
+	// Depth-first search for a path back to a command being visited
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(commands))
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		name := commands[i].Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		path = append(path, name)
+		switch state[i] {
+		case visiting:
+			start := slices.Index(path, name)
+			return fmt.Errorf("dependency cycle: %s", strings.Join(path[start:], " → "))
+		case visited:
+			return nil
+		}
+		state[i] = visiting
+		for _, dep := range commands[i].DependsOn {
+			if err := visit(index[dep], path); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		return nil
+	}
+	for i := range commands {
+		if err := visit(i, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks the fields of a single command
+func (cmd Command) validate() error {
+	if cmd.RestartService != "" {
+		if err := cmd.validateService(); err != nil {
+			return err
+		}
+	} else if len(cmd.Cmd) == 0 {
+		return fmt.Errorf("cmd is empty")
+	} else if cmd.Recreate || cmd.ComposeFile != "" {
+		return fmt.Errorf("recreate and compose_file require restart_service")
+	}
+	if cmd.Timeout != "" {
+		if _, err := time.ParseDuration(cmd.Timeout); err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+	}
+	if err := ignore.Check(cmd.Match); err != nil {
+		return fmt.Errorf("match: %w", err)
+	}
+	switch cmd.Mode {
+	case "", ModeOnce, ModeRestart:
+	default:
+		return fmt.Errorf("invalid mode %q (expected %q or %q)", cmd.Mode, ModeOnce, ModeRestart)
+	}
+	if cmd.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+	if cmd.Retries > 0 && cmd.IsRestart() {
+		return fmt.Errorf("retries are not supported in %q mode", ModeRestart)
+	}
+	if cmd.Weight < 0 {
+		return fmt.Errorf("weight must not be negative")
+	}
+	if cmd.RetryBackoff != "" {
+		if _, err := time.ParseDuration(cmd.RetryBackoff); err != nil {
+			return fmt.Errorf("invalid retry_backoff: %w", err)
+		}
+	}
+	if cmd.Cooldown != "" {
+		d, err := time.ParseDuration(cmd.Cooldown)
+		if err != nil {
+			return fmt.Errorf("invalid cooldown: %w", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("cooldown must not be negative")
+		}
+	}
+	for _, kv := range cmd.Env {
+		if key, _, ok := strings.Cut(kv, "="); !ok || key == "" {
+			return fmt.Errorf("invalid env entry %q (expected KEY=value)", kv)
+		}
+	}
+	if len(cmd.ContainerPaths) > 0 && cmd.Container == "" {
+		return fmt.Errorf("container_paths requires container")
+	}
+	for _, entry := range cmd.ContainerPaths {
+		if _, _, ok := ParseContainerPath(entry); !ok {
+			return fmt.Errorf("invalid container_paths entry %q (expected host:/container/path)", entry)
+		}
+	}
+	if cmd.Nice < MinNice || cmd.Nice > MaxNice {
+		return fmt.Errorf("nice must be between %d and %d", MinNice, MaxNice)
+	}
+	switch cmd.IONice {
+	case "", IONiceBestEffort, IONiceIdle:
+	default:
+		return fmt.Errorf("invalid ionice %q (expected %q or %q)", cmd.IONice, IONiceBestEffort, IONiceIdle)
+	}
+	if (cmd.Nice != 0 || cmd.IONice != "" || !cmd.Limits.IsZero()) && cmd.Container != "" {
+		return fmt.Errorf("nice, ionice and limits don't apply to commands in a container")
+	}
+	if err := cmd.Limits.validate(); err != nil {
+		return err
+	}
+	switch {
+	case cmd.Output == "", cmd.Output == OutputNewWindow:
+	case strings.HasPrefix(cmd.Output, OutputTmuxPrefix) && len(cmd.Output) > len(OutputTmuxPrefix):
+	default:
+		return fmt.Errorf("invalid output %q (expected %q or %q)", cmd.Output, OutputTmuxPrefix+"<pane>", OutputNewWindow)
+	}
+	for _, expr := range cmd.OutputFilter {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("output_filter: %w", err)
+		}
+	}
+	if cmd.Output != "" && cmd.Interactive {
+		return fmt.Errorf("interactive commands write to the terminal, not to output")
+	}
+	for i, h := range cmd.Highlight {
+		if _, err := regexp.Compile(h.Match); err != nil {
+			return fmt.Errorf("highlight[%d]: match: %w", i, err)
+		}
+		if err := logger.ParseStyle(h.Style); err != nil {
+			return fmt.Errorf("highlight[%d]: style: %w", i, err)
+		}
+	}
+	if (len(cmd.OutputFilter) > 0 || len(cmd.Highlight) > 0) && cmd.Interactive {
+		return fmt.Errorf("interactive commands write to the terminal, which output_filter and highlight don't apply to")
+	}
+	if cmd.PTY && cmd.Interactive {
+		return fmt.Errorf("interactive commands use the terminal itself, not a pty")
+	}
+	if cmd.Parser != "" && !slices.Contains(parsers, cmd.Parser) {
+		return fmt.Errorf("invalid parser %q (expected one of: %s)", cmd.Parser, strings.Join(parsers, ", "))
+	}
+	if cmd.Parser != "" && cmd.Interactive {
+		return fmt.Errorf("interactive commands write to the terminal, where parser can't read them")
+	}
+	if cmd.Parser != "" && cmd.IsRestart() {
+		return fmt.Errorf("parser reads the results of commands that finish, which mode: %s commands don't", ModeRestart)
+	}
+	if err := validateShell("shell", cmd.Shell); err != nil {
+		return err
+	}
+	if err := validateShell("shell_windows", cmd.ShellWindows); err != nil {
+		return err
+	}
+	if !slices.Contains(killSignals, cmd.GetKillSignal()) {
+		return fmt.Errorf("invalid kill_signal %q (expected one of: %s)", cmd.KillSignal, strings.Join(killSignals, ", "))
+	}
+	if cmd.KillGrace != "" {
+		d, err := time.ParseDuration(cmd.KillGrace)
+		if err != nil {
+			return fmt.Errorf("invalid kill_grace: %w", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("kill_grace must not be negative")
+		}
+	}
+	return validateEvents(cmd.Events)
+}
+
+// validateService checks the settings of a restart_service command
+func (cmd Command) validateService() error {
+	switch {
+	case len(cmd.Cmd) > 0:
+		return fmt.Errorf("cmd and restart_service are mutually exclusive")
+	case cmd.IsRestart():
+		return fmt.Errorf("restart_service does not support %q mode", ModeRestart)
+	case cmd.Container != "":
+		return fmt.Errorf("restart_service cannot run in a container")
+	case cmd.Interactive:
+		return fmt.Errorf("restart_service cannot be interactive")
+	}
+	return nil
+}
+
+// ProfileNames returns the names of all configured profiles in sorted order
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile applies the overrides of the named profile
+func (c *Config) ApplyProfile(name string) error {
+	name = strings.ToLower(name)
+	p, ok := c.Profiles[name]
+	if !ok {
+		available := strings.Join(c.ProfileNames(), ", ")
+		if available == "" {
+			available = "none"
+		}
+		return fmt.Errorf("unknown profile %q (available: %s)", name, available)
+	}
+
+	if len(p.OnChange.Commands) > 0 || p.OnChange.HasStages() {
+		// A config of only tasks has no top-level commands to replace, and
+		// taking the profile's would start a pipeline next to the tasks
+		if !c.HasPipeline() && len(c.Tasks) > 0 {
+			return fmt.Errorf("profile %q sets on_change, but the config only defines tasks", name)
+		}
+		c.OnChange = p.OnChange
+	}
+	if len(p.OnSuccess) > 0 {
+		c.OnSuccess = p.OnSuccess
+	}
+	if len(p.OnFailure) > 0 {
+		c.OnFailure = p.OnFailure
+	}
+
+	for taskName, task := range c.Tasks {
+		if p.Debounce != "" {
+			task.Debounce = ""
+		}
+		if p.MaxConcurrency != 0 {
+			task.MaxConcurrency = 0
+		}
+		if p.Notify != "" {
+			task.Notify = ""
+		}
+		if p.RunOnStart != nil {
+			task.RunOnStart = nil
+		}
+		c.Tasks[taskName] = task
+	}
+	if p.Debounce != "" {
+		c.Debounce = p.Debounce
+	}
+	if p.MaxConcurrency != 0 {
+		c.MaxConcurrency = p.MaxConcurrency
+	}
+	if p.Notify != "" {
+		c.Notify = p.Notify
+	}
+	if p.RunOnStart != nil {
+		c.RunOnStart = *p.RunOnStart
+	}
+
+	c.Profile = name
+	return nil
+}
+
+// HasPipeline reports whether the top level of the config defines commands
+// of its own, as opposed to only tasks
+func (c *Config) HasPipeline() bool {
+	return len(c.OnChange.Commands) > 0 || c.OnChange.HasStages()
+}
+
+// TaskNames returns the names of all configured tasks in sorted order
+func (c *Config) TaskNames() []string {
+	names := make([]string, 0, len(c.Tasks))
+	for name := range c.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ForTask returns a standalone config for the named task, inheriting any
+// settings the task leaves unset from the top level
+func (c *Config) ForTask(name string) (*Config, error) {
+	task, ok := c.Tasks[name]
+	if !ok {
+		available := strings.Join(c.TaskNames(), ", ")
+		if available == "" {
+			available = "none"
+		}
+		return nil, fmt.Errorf("unknown task %q (available: %s)", name, available)
+	}
+
+	tc := *c
+	tc.Tasks = nil
+	tc.OnChange = task.OnChange
+	if len(task.Watch) > 0 {
+		tc.Watch = task.Watch
+	}
+	if task.Debounce != "" {
+		tc.Debounce = task.Debounce
+	}
+	if task.DebounceStrategy != "" {
+		tc.DebounceStrategy = task.DebounceStrategy
+	}
+	if task.MaxConcurrency != 0 {
+		tc.MaxConcurrency = task.MaxConcurrency
+	}
+	if task.Notify != "" {
+		tc.Notify = task.Notify
+	}
+	if len(task.Webhooks) > 0 {
+		tc.Webhooks = task.Webhooks
+	}
+	if len(task.OnSuccess) > 0 {
+		tc.OnSuccess = task.OnSuccess
+	}
+	if len(task.OnFailure) > 0 {
+		tc.OnFailure = task.OnFailure
+	}
+	tc.Setup = task.Setup
+	tc.OnExit = task.OnExit
+	if task.RunOnStart != nil {
+		tc.RunOnStart = *task.RunOnStart
+	}
+	tc.Ignore = append(append([]string{}, c.Ignore...), task.Ignore...)
+	tc.Task = name
+	// reports/gowatch.xml becomes reports/gowatch-<task>.xml
+	if path := c.JUnitReport(); path != "" {
+		ext := filepath.Ext(path)
+		tc.Report = ReportJUnitPrefix + strings.TrimSuffix(path, ext) + "-" + name + ext
+	}
+
+	return &tc, nil
+}
+
+func validateBackend(backend string) error {
+	switch backend {
+	case "", BackendFSNotify, BackendPoll, BackendWatchman, BackendFanotify:
+		return nil
+	default:
+		return fmt.Errorf("invalid backend %q (expected %q, %q, %q or %q)",
+			backend, BackendFSNotify, BackendPoll, BackendWatchman, BackendFanotify)
+	}
+}
+
+func validateShell(key, shell string) error {
+	if shell != "" && !slices.Contains(shells, shell) {
+		return fmt.Errorf("invalid %s %q (expected one of: %s)", key, shell, strings.Join(shells, ", "))
+	}
+	return nil
+}
+
+func validateBufferSize(size string) error {
+	if size == "" {
+		return nil
+	}
+	n, err := ParseSize(size)
+	if err != nil {
+		return fmt.Errorf("invalid buffer_size: %w", err)
+	}
+	if n < MinBufferSize || n > math.MaxInt32 {
+		return fmt.Errorf("buffer_size must be between 4KB and 2GB, got %s", size)
+	}
+	return nil
+}
+
+func validateDebounceStrategy(strategy string) error {
+	switch strategy {
+	case "", DebounceTrailing, DebounceLeading, DebounceThrottle:
+		return nil
+	default:
+		return fmt.Errorf("invalid debounce_strategy %q (expected %q, %q or %q)", strategy, DebounceTrailing, DebounceLeading, DebounceThrottle)
+	}
+}
+
+// DebounceStrategyFor returns the debounce strategy for changes under a
+// watch path, falling back to the global setting
+func (c *Config) DebounceStrategyFor(w WatchPath) string {
+	if w.DebounceStrategy != "" {
+		return w.DebounceStrategy
+	}
+	if c.DebounceStrategy != "" {
+		return c.DebounceStrategy
+	}
+	return DebounceTrailing
+}
+
+// MaxFileSizeFor returns the size in bytes above which changes under a
+// watch path are dropped, falling back to the global setting; 0 means no
+// limit
+func (c *Config) MaxFileSizeFor(w WatchPath) int64 {
+	size := w.MaxFileSize
+	if size == "" {
+		size = c.MaxFileSize
+	}
+	n, _ := ParseSize(size)
+	return n
+}
+
+// GetQueueSize returns how many events a watcher holds
+func (c *Config) GetQueueSize() int {
+	if c.QueueSize > 0 {
+		return c.QueueSize
+	}
+	return DefaultQueueSize
+}
+
+// GetQueueOverflow returns the policy for events that don't fit in the
+// queue
+func (c *Config) GetQueueOverflow() string {
+	if c.QueueOverflow != "" {
+		return c.QueueOverflow
+	}
+	return OverflowCoalesce
+}
+
+// BufferSizeFor returns the Windows change buffer size in bytes for a watch
+// path, falling back to the global setting; 0 means the default
+func (c *Config) BufferSizeFor(w WatchPath) int {
+	size := w.BufferSize
+	if size == "" {
+		size = c.BufferSize
+	}
+	n, _ := ParseSize(size)
+	return int(n)
+}
+
+// sizeUnits are the suffixes accepted by ParseSize, longest first so that
+// "KB" isn't read as "B"
+var sizeUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"TB", 1 << 40},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+// ParseSize parses a size such as "512", "100MB" or "1.5GiB" into bytes.
+// Units are case-insensitive powers of 1024; an empty string is 0.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	number, unit := s, 1.0
+	upper := strings.ToUpper(s)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(upper, u.suffix) {
+			number, unit = strings.TrimSpace(s[:len(s)-len(u.suffix)]), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 500KB, 100MB or 2GB)", s)
+	}
+	return int64(n * unit), nil
+}
+
+func (c *Config) GetDebounceDuration() time.Duration {
+	d, _ := time.ParseDuration(c.Debounce)
+	return d
+}
+
+// DefaultOutputKeep is the number of runs kept in output_dir when
+// output_keep is not set
+const DefaultOutputKeep = 20
+
+// RunOutputDir returns the directory receiving the run output of the
+// pipeline: output_dir itself, or a subdirectory of it for a task
+func (c *Config) RunOutputDir() string {
+	if c.OutputDir == "" || c.Task == "" {
+		return c.OutputDir
+	}
+	return filepath.Join(c.OutputDir, c.Task)
+}
+
+// GetOutputKeep returns how many runs to keep in output_dir
+func (c *Config) GetOutputKeep() int {
+	if c.OutputKeep > 0 {
+		return c.OutputKeep
+	}
+	return DefaultOutputKeep
+}
+
+// DefaultHistoryFile records the runs when history is not set
+const DefaultHistoryFile = ".gowatch/history.jsonl"
+
+// DefaultHistoryKeep is the number of runs kept in the history when
+// history_keep is not set
+const DefaultHistoryKeep = 1000
+
+// HistoryFile returns the file runs are recorded in, or "" if history is
+// disabled
+func (c *Config) HistoryFile() string {
+	switch c.History {
+	case "":
+		return c.resolve(DefaultHistoryFile)
+	case "off":
+		return ""
+	}
+	return c.History
+}
+
+// HyperlinkURL returns the URL template file references are linked with,
+// or "" if they aren't. Templates replace {path} with the absolute path of
+// the file, slash-separated and escaped, and {line} and {column} with the
+// location in it.
+func (c *Config) HyperlinkURL() string {
+	if template, ok := hyperlinkTemplates[c.Hyperlinks]; ok {
+		return template
+	}
+	if strings.Contains(c.Hyperlinks, "{path}") {
+		return c.Hyperlinks
+	}
+	return ""
+}
+
+// JUnitReport returns the file a JUnit report of every run is written to, or
+// "" if there is none
+func (c *Config) JUnitReport() string {
+	path, ok := strings.CutPrefix(c.Report, ReportJUnitPrefix)
+	if !ok {
+		return ""
+	}
+	return path
+}
+
+// DefaultStatusFile holds the state of the session when status_file is not
+// set
+const DefaultStatusFile = ".gowatch/status.json"
+
+// StatusPath returns the file the state of the session is written to, or ""
+// if it isn't
+func (c *Config) StatusPath() string {
+	switch c.StatusFile {
+	case "":
+		return c.resolve(DefaultStatusFile)
+	case "off":
+		return ""
+	}
+	return c.StatusFile
+}
+
+// DefaultWatchCacheFile holds the directories of the recursive watch paths
+// when watch_cache is set
+const DefaultWatchCacheFile = ".gowatch/watch-cache.json"
+
+// WatchCacheFile returns the file the watched directories are cached in, or
+// "" if they aren't
+func (c *Config) WatchCacheFile() string {
+	if !c.WatchCache {
+		return ""
+	}
+	return c.resolve(DefaultWatchCacheFile)
+}
+
+// GetHistoryKeep returns how many runs to keep in the history
+func (c *Config) GetHistoryKeep() int {
+	if c.HistoryKeep > 0 {
+		return c.HistoryKeep
+	}
+	return DefaultHistoryKeep
+}
+
+// GetPollInterval returns the scan interval for the polling backend
+func (c *Config) GetPollInterval() time.Duration {
+	if d, err := time.ParseDuration(c.PollInterval); err == nil && d > 0 {
+		return d
+	}
+	return DefaultPollInterval
+}
+
+// validateRemote checks the remote of a remote watch path
+func (w WatchPath) validateRemote() error {
+	if w.Path != "" {
+		return fmt.Errorf("path and remote are mutually exclusive")
+	}
+	if w.Backend != "" {
+		return fmt.Errorf("backend does not apply to remote watch paths")
+	}
+	host, dir := w.RemoteTarget()
+	if host == "" || !strings.HasPrefix(dir, "/") {
+		return fmt.Errorf("invalid remote %q (expected [user@]host:/absolute/path)", w.Remote)
+	}
+	return nil
+}
+
+// BackendFor returns the watcher backend to use for a watch path, falling
+// back to the global backend setting
+func (c *Config) BackendFor(w WatchPath) string {
+	if w.Remote != "" {
+		return BackendRemote
+	}
+	if w.Backend != "" {
+		return w.Backend
+	}
+	if c.Backend != "" {
+		return c.Backend
+	}
+	return BackendFSNotify
+}
+
+// ShellFor returns the shell a command runs through on the platform goos:
+// its own, else the top-level one, each preferring its Windows override on
+// Windows. Empty leaves it to the runner.
+func (c *Config) ShellFor(cmd Command, goos string) string {
+	for _, shell := range [][2]string{{cmd.ShellWindows, cmd.Shell}, {c.ShellWindows, c.Shell}} {
+		if goos == "windows" && shell[0] != "" {
+			return shell[0]
+		}
+		if shell[1] != "" {
+			return shell[1]
+		}
+	}
+	return ""
+}
+
+func WriteExample(path string) error {
+	exampleConfig := `# GoWatch Configuration Example
+# Watch paths and patterns
+watch:
+  - path: "./"
+    recursive: true
+    ignore:
+      - "vendor/**"
+      - ".git/**"
+      - "**/*.tmp"
+      - "**/*.log"
+      - "**/node_modules/**"
+
+# Commands to run when files change
+on_change:
+  commands:
+    - cmd: ["go", "test", "./..."]
+      run: sequential
+      timeout: "60s"
+    
+    - cmd: ["go", "build", "./..."]
+      run: sequential
+      timeout: "30s"
+
+# Debounce interval (prevent rapid retriggering)
+debounce: "250ms"
+
+# Maximum concurrent command executions
+max_concurrency: 2
+`
+
+	if err := os.WriteFile(path, []byte(exampleConfig), 0644); err != nil {
+		return fmt.Errorf("failed to write example config: %w", err)
+	}
+
+	return nil
+}
+
+func WriteExampleIgnore(path string) error {
+	exampleIgnore := `# GoWatch Ignore File
+# Similar to .gitignore - patterns to exclude from watching
+
+# Dependencies
+vendor/
+node_modules/
+
+# Version control
+.git/
+.svn/
+
+# Temp files
+*.tmp
+*.log
+*.swp
+*~
+
+# Build outputs
+*.exe
+*.dll
+*.so
+*.dylib
+dist/
+build/
+
+# IDE
+.idea/
+.vscode/
+*.iml
+
+# OS
+.DS_Store
+Thumbs.db
+Desktop.ini
+`
+
+	// Platform-specific additions
+	if runtime.GOOS == "windows" {
+		exampleIgnore += `
+# Windows-specific
+~$*
+*.lnk
+$RECYCLE.BIN/
+System Volume Information/
+`
+	}
+
+	if err := os.WriteFile(path, []byte(exampleIgnore), 0644); err != nil {
+		return fmt.Errorf("failed to write example ignore file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,981 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateDependencies(t *testing.T) {
+	cmd := func(name string, deps ...string) Command {
+		return Command{Name: name, Cmd: []string{"true"}, DependsOn: deps}
+	}
+
+	tests := []struct {
+		name     string
+		commands []Command
+		wantErr  string
+	}{
+		{"chain with independent command", []Command{cmd("test", "build"), cmd("build", "generate"), cmd("generate"), cmd("lint")}, ""},
+		{"unnamed commands", []Command{cmd(""), cmd("", "build"), cmd("build")}, ""},
+		{"unknown name", []Command{cmd("build", "generate")}, `no command has that name`},
+		{"ambiguous name", []Command{cmd("gen"), cmd("gen"), cmd("build", "gen")}, `several commands have that name`},
+		{"self", []Command{cmd("build", "build")}, "depends on itself"},
+		{"cycle", []Command{cmd("lint"), cmd("a", "b"), cmd("b", "c"), cmd("c", "a")}, "dependency cycle: a → b → c → a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDependencies(tt.commands)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDebounceStrategyFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		global string
+		path   string
+		want   string
+	}{
+		{"default", "", "", DebounceTrailing},
+		{"global", DebounceThrottle, "", DebounceThrottle},
+		{"watch path overrides global", DebounceThrottle, DebounceLeading, DebounceLeading},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{DebounceStrategy: tt.global}
+			if got := c.DebounceStrategyFor(WatchPath{DebounceStrategy: tt.path}); got != tt.want {
+				t.Errorf("DebounceStrategyFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"512", 512, false},
+		{"10B", 10, false},
+		{"500k", 500 << 10, false},
+		{"100MB", 100 << 20, false},
+		{"1.5GiB", 3 << 29, false},
+		{"2 gb", 2 << 30, false},
+		{"MB", 0, true},
+		{"-1MB", 0, true},
+		{"lots", 0, true},
+		{"Inf", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSize(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxFileSizeFor(t *testing.T) {
+	c := &Config{MaxFileSize: "1MB"}
+	if got := c.MaxFileSizeFor(WatchPath{}); got != 1<<20 {
+		t.Errorf("global: got %d", got)
+	}
+	if got := c.MaxFileSizeFor(WatchPath{MaxFileSize: "2KB"}); got != 2<<10 {
+		t.Errorf("watch path override: got %d", got)
+	}
+	if got := (&Config{}).MaxFileSizeFor(WatchPath{}); got != 0 {
+		t.Errorf("unset: got %d, want no limit", got)
+	}
+}
+
+func TestBufferSize(t *testing.T) {
+	c := &Config{BufferSize: "256KB"}
+	if got := c.BufferSizeFor(WatchPath{}); got != 256<<10 {
+		t.Errorf("global: got %d", got)
+	}
+	if got := c.BufferSizeFor(WatchPath{BufferSize: "1MB"}); got != 1<<20 {
+		t.Errorf("watch path override: got %d", got)
+	}
+	if got := (&Config{}).BufferSizeFor(WatchPath{}); got != 0 {
+		t.Errorf("unset: got %d, want the default", got)
+	}
+
+	for size, valid := range map[string]bool{"": true, "4KB": true, "64MB": true, "1KB": false, "3G": false, "big": false} {
+		if err := validateBufferSize(size); (err == nil) != valid {
+			t.Errorf("validateBufferSize(%q) = %v", size, err)
+		}
+	}
+}
+
+func TestQueue(t *testing.T) {
+	if c := (&Config{}); c.GetQueueSize() != DefaultQueueSize || c.GetQueueOverflow() != OverflowCoalesce {
+		t.Errorf("unset: got %d, %q", c.GetQueueSize(), c.GetQueueOverflow())
+	}
+	if c := (&Config{QueueSize: 500, QueueOverflow: OverflowBlock}); c.GetQueueSize() != 500 || c.GetQueueOverflow() != OverflowBlock {
+		t.Errorf("set: got %d, %q", c.GetQueueSize(), c.GetQueueOverflow())
+	}
+
+	tests := []struct {
+		size     int
+		overflow string
+		wantErr  string
+	}{
+		{0, "", ""},
+		{10, OverflowDropOldest, ""},
+		{-1, "", "queue_size must not be negative"},
+		{10, "drop", `invalid queue_overflow "drop"`},
+	}
+	for _, tt := range tests {
+		c := &Config{
+			Watch:         []WatchPath{{Path: t.TempDir()}},
+			OnChange:      OnChange{Commands: []Command{{Cmd: []string{"true"}}}},
+			QueueSize:     tt.size,
+			QueueOverflow: tt.overflow,
+		}
+		c.SetDefaults()
+		err := c.Validate()
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("Validate(queue_size %d, queue_overflow %q) = %v, want %q", tt.size, tt.overflow, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	c := &Config{
+		Setup:  []Command{{Cmd: []string{"docker", "compose", "up", "-d"}}},
+		OnExit: []Command{{Cmd: []string{"docker", "compose", "down"}}},
+		Tasks: map[string]Task{
+			"api": {
+				Setup:  []Command{{Cmd: []string{"go", "mod", "download"}}},
+				OnExit: []Command{{Cmd: []string{"rm", "-rf", "tmp"}}},
+			},
+			"web": {},
+		},
+	}
+
+	// Tasks don't inherit the top-level setup and on_exit, which run once
+	for name, want := range map[string]int{"api": 1, "web": 0} {
+		tc, err := c.ForTask(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tc.Setup) != want || len(tc.OnExit) != want {
+			t.Errorf("task %s: got %d setup and %d on_exit commands, want %d",
+				name, len(tc.Setup), len(tc.OnExit), want)
+		}
+	}
+
+	// The hooks of a config made only of tasks are validated too
+	restart := []Command{{Cmd: []string{"./server"}, Mode: ModeRestart}}
+	for _, hook := range []string{"setup", "on_exit"} {
+		bad := *c
+		if hook == "setup" {
+			bad.Setup = restart
+		} else {
+			bad.OnExit = restart
+		}
+		if err := bad.Validate(); err == nil || !strings.Contains(err.Error(), hook) {
+			t.Errorf("%s: Validate() = %v, want an error", hook, err)
+		}
+	}
+}
+
+func TestStages(t *testing.T) {
+	cmd := func(name string) Command { return Command{Cmd: []string{name}} }
+	stages := func() []Stage {
+		return []Stage{
+			{Name: "check", Commands: []Command{cmd("lint"), cmd("vet")}},
+			{Commands: []Command{cmd("test")}},
+		}
+	}
+
+	c := &Config{
+		Watch:    []WatchPath{{Path: "."}},
+		OnChange: OnChange{Stages: stages()},
+		Tasks:    map[string]Task{"ci": {OnChange: OnChange{Stages: stages()}}},
+	}
+	c.SetDefaults()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	tc, err := c.ForTask("ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []OnChange{c.OnChange, tc.OnChange} {
+		var got []string
+		for _, cmd := range o.Commands {
+			got = append(got, cmd.Cmd[0]+"@"+o.StageName(cmd))
+		}
+		if want := []string{"lint@check", "vet@check", "test@2"}; !slices.Equal(got, want) {
+			t.Errorf("commands = %v, want %v", got, want)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(o *OnChange)
+	}{
+		{"with commands", func(o *OnChange) { o.Commands = []Command{cmd("build")} }},
+		{"empty stage", func(o *OnChange) { o.Stages = append(o.Stages, Stage{Name: "build"}) }},
+		{"depends_on", func(o *OnChange) { o.Stages[1].Commands[0].DependsOn = []string{"lint"} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bad := &Config{Watch: []WatchPath{{Path: "."}}, OnChange: OnChange{Stages: stages()}}
+			tt.modify(&bad.OnChange)
+			bad.SetDefaults()
+			if err := bad.Validate(); err == nil {
+				t.Error("Validate() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestWatchPath_ValidateRemote(t *testing.T) {
+	tests := []struct {
+		name    string
+		wp      WatchPath
+		wantErr bool
+	}{
+		{"user and host", WatchPath{Remote: "dev@build01:/srv/app"}, false},
+		{"host only", WatchPath{Remote: "build01:/srv/app"}, false},
+		{"relative directory", WatchPath{Remote: "build01:srv/app"}, true},
+		{"no directory", WatchPath{Remote: "build01"}, true},
+		{"no host", WatchPath{Remote: ":/srv/app"}, true},
+		{"with path", WatchPath{Remote: "build01:/srv/app", Path: "."}, true},
+		{"with backend", WatchPath{Remote: "build01:/srv/app", Backend: BackendPoll}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.wp.validateRemote(); (err != nil) != tt.wantErr {
+				t.Errorf("validateRemote() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCommand_ValidateContainer(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     Command
+		wantErr bool
+	}{
+		{"container", Command{Cmd: []string{"make"}, Container: "dev"}, false},
+		{"relative host path", Command{Cmd: []string{"make"}, Container: "dev", ContainerPaths: []string{".:/workspace"}}, false},
+		{"windows host path", Command{Cmd: []string{"make"}, Container: "dev", ContainerPaths: []string{`C:\src:/src`}}, false},
+		{"relative container path", Command{Cmd: []string{"make"}, Container: "dev", ContainerPaths: []string{".:workspace"}}, true},
+		{"paths without container", Command{Cmd: []string{"make"}, ContainerPaths: []string{".:/workspace"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cmd.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCommand_ValidateRestartService(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     Command
+		wantErr bool
+	}{
+		{"restart", Command{RestartService: "api"}, false},
+		{"recreate", Command{RestartService: "api", Recreate: true, ComposeFile: "dev.yml"}, false},
+		{"with cmd", Command{RestartService: "api", Cmd: []string{"make"}}, true},
+		{"restart mode", Command{RestartService: "api", Mode: ModeRestart}, true},
+		{"in a container", Command{RestartService: "api", Container: "dev"}, true},
+		{"interactive", Command{RestartService: "api", Interactive: true}, true},
+		{"recreate without service", Command{Cmd: []string{"make"}, Recreate: true}, true},
+		{"compose file without service", Command{Cmd: []string{"make"}, ComposeFile: "dev.yml"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cmd.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCommand_ComposeCommand(t *testing.T) {
+	tests := []struct {
+		cmd  Command
+		want string
+	}{
+		{Command{RestartService: "api"}, "docker compose restart api"},
+		{Command{RestartService: "api", Recreate: true, ComposeFile: "dev.yml"}, "docker compose -f dev.yml up -d --no-deps api"},
+	}
+
+	for _, tt := range tests {
+		if got := strings.Join(tt.cmd.ComposeCommand(), " "); got != tt.want {
+			t.Errorf("ComposeCommand() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestValidate_Tags(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		tags     []string
+		whenTags []string
+		wantErr  string
+	}{
+		{"matching tag", []string{"backend", "go"}, []string{"backend"}, ""},
+		{"no when_tags", []string{"backend"}, nil, ""},
+		{"unknown tag", []string{"backend"}, []string{"frontend"}, `no watch path is tagged "frontend"`},
+		{"empty tag", []string{""}, nil, "empty tag"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Watch: []WatchPath{{Path: dir, Tags: tt.tags}},
+				OnChange: OnChange{
+					Commands: []Command{{Cmd: []string{"true"}, WhenTags: tt.whenTags}},
+				},
+			}
+			cfg.SetDefaults()
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_Shell(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		cfg     Config
+		cmd     Command
+		wantErr string
+	}{
+		{"command shell", Config{}, Command{Shell: ShellBash, ShellWindows: ShellPwsh}, ""},
+		{"default shell", Config{Shell: ShellNone, ShellWindows: ShellCmd}, Command{}, ""},
+		{"unknown command shell", Config{}, Command{Shell: "fish"}, `invalid shell "fish"`},
+		{"unknown default", Config{ShellWindows: "cmd.exe"}, Command{}, `invalid shell_windows "cmd.exe"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			tt.cmd.Cmd = []string{"true"}
+			cfg.Watch = []WatchPath{{Path: dir}}
+			cfg.OnChange.Commands = []Command{tt.cmd}
+			cfg.SetDefaults()
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_Bell(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"failure", Config{Bell: BellFailure, BellSound: "fail.wav"}, ""},
+		{"change", Config{Bell: BellChange, BellSound: "fail.wav", BellRecoverySound: "ok.wav"}, ""},
+		{"unknown", Config{Bell: "always"}, `invalid bell "always"`},
+		{"sound without bell", Config{BellSound: "fail.wav"}, "need bell"},
+		{"recovery sound on failure", Config{Bell: BellFailure, BellRecoverySound: "ok.wav"}, "bell_recovery_sound needs bell: change"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Watch = []WatchPath{{Path: dir}}
+			cfg.OnChange.Commands = []Command{{Cmd: []string{"true"}}}
+			cfg.SetDefaults()
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_HyperlinkURL(t *testing.T) {
+	tests := []struct {
+		hyperlinks string
+		want       string
+		wantErr    bool
+	}{
+		{"", "", false},
+		{"off", "", false},
+		{"vscode", "vscode://file{path}:{line}:{column}", false},
+		{"idea", "idea://open?file={path}&line={line}&column={column}", false},
+		{"zed://file{path}:{line}", "zed://file{path}:{line}", false},
+		{"emacs", "", true},
+	}
+	for _, tt := range tests {
+		c := Config{Hyperlinks: tt.hyperlinks, Watch: []WatchPath{{Path: t.TempDir()}}}
+		c.OnChange.Commands = []Command{{Cmd: []string{"true"}}}
+		c.SetDefaults()
+		if got := c.HyperlinkURL(); got != tt.want {
+			t.Errorf("HyperlinkURL(%q) = %q, want %q", tt.hyperlinks, got, tt.want)
+		}
+		if err := c.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) = %v, want error %v", tt.hyperlinks, err, tt.wantErr)
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	tests := []struct {
+		proxy   Proxy
+		want    string
+		wantErr string
+	}{
+		{Proxy{}, "", ""},
+		{Proxy{Listen: ":3000", Target: ":8080"}, "http://localhost:8080", ""},
+		{Proxy{Listen: ":3000", Target: "127.0.0.1:8080", Timeout: "1m"}, "http://127.0.0.1:8080", ""},
+		{Proxy{Listen: ":3000", Target: "https://app.test"}, "https://app.test", ""},
+		{Proxy{Listen: ":3000"}, "", "proxy: target is required"},
+		{Proxy{Target: ":8080"}, "", "proxy: listen is required"},
+		{Proxy{Listen: ":3000", Target: "ftp://app:21"}, "", "proxy: invalid target"},
+		{Proxy{Listen: ":3000", Target: ":8080", Timeout: "soon"}, "", "proxy: invalid timeout"},
+	}
+	for _, tt := range tests {
+		c := Config{Proxy: tt.proxy, Watch: []WatchPath{{Path: t.TempDir()}}}
+		c.OnChange.Commands = []Command{{Cmd: []string{"true"}}}
+		c.SetDefaults()
+		err := c.Validate()
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.proxy, err, tt.wantErr)
+		}
+		if tt.want == "" {
+			continue
+		}
+		if u, err := tt.proxy.TargetURL(); err != nil || u.String() != tt.want {
+			t.Errorf("TargetURL(%q) = %v, %v, want %s", tt.proxy.Target, u, err, tt.want)
+		}
+	}
+	if got := (Proxy{}).GetTimeout(); got != DefaultProxyTimeout {
+		t.Errorf("GetTimeout() = %v, want %v", got, DefaultProxyTimeout)
+	}
+}
+
+func TestServe(t *testing.T) {
+	tests := []struct {
+		serve    Serve
+		commands bool
+		wantErr  string
+	}{
+		{Serve{}, true, ""},
+		{Serve{Dir: "public"}, true, ""},
+		{Serve{Dir: "public", Listen: ":9000"}, true, ""},
+		// Browsers refresh after changes without anything to run
+		{Serve{Dir: "public"}, false, ""},
+		{Serve{}, false, "at least one command is required"},
+		{Serve{Listen: ":9000"}, true, "serve: dir is required"},
+	}
+	for _, tt := range tests {
+		c := Config{Serve: tt.serve, Watch: []WatchPath{{Path: t.TempDir()}}}
+		if tt.commands {
+			c.OnChange.Commands = []Command{{Cmd: []string{"true"}}}
+		}
+		c.SetDefaults()
+		err := c.Validate()
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.serve, err, tt.wantErr)
+		}
+	}
+	if got := (Serve{}).GetListen(); got != DefaultServeListen {
+		t.Errorf("GetListen() = %q, want %q", got, DefaultServeListen)
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	yes, no := true, false
+	cmds := func(args ...string) []Command { return []Command{{Cmd: args}} }
+	newConfig := func(tasksOnly bool) *Config {
+		c := &Config{
+			Debounce:       "250ms",
+			MaxConcurrency: 2,
+			OnSuccess:      cmds("echo", "ok"),
+			Tasks: map[string]Task{
+				"api": {Debounce: "1s", MaxConcurrency: 4, Notify: NotifyDesktop, RunOnStart: &yes, OnChange: OnChange{Commands: cmds("go", "build")}},
+			},
+			Profiles: map[string]Profile{
+				"quick": {Debounce: "100ms", RunOnStart: &no},
+				"ci":    {MaxConcurrency: 1, OnChange: OnChange{Commands: cmds("go", "test", "-race")}, OnFailure: cmds("echo", "failed")},
+			},
+		}
+		if !tasksOnly {
+			c.OnChange.Commands = cmds("make")
+		}
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		tasksOnly bool
+		profile   string
+		wantErr   string
+		check     func(t *testing.T, c *Config)
+	}{
+		{name: "scalar overrides", profile: "quick", check: func(t *testing.T, c *Config) {
+			if c.Debounce != "100ms" || c.RunOnStart || c.MaxConcurrency != 2 {
+				t.Errorf("debounce = %q, run_on_start = %v, max_concurrency = %d", c.Debounce, c.RunOnStart, c.MaxConcurrency)
+			}
+			// Tasks take the profile's settings instead of their own
+			api := c.Tasks["api"]
+			if api.Debounce != "" || api.RunOnStart != nil || api.MaxConcurrency != 4 {
+				t.Errorf("task = %+v, want the profile's debounce and run_on_start", api)
+			}
+			if tc, _ := c.ForTask("api"); tc.Debounce != "100ms" || tc.RunOnStart {
+				t.Errorf("ForTask() debounce = %q, run_on_start = %v", tc.Debounce, tc.RunOnStart)
+			}
+			if !slices.Equal(c.OnChange.Commands[0].Cmd, []string{"make"}) {
+				t.Errorf("on_change = %v, want it kept", c.OnChange.Commands)
+			}
+		}},
+		{name: "list overrides", profile: "ci", check: func(t *testing.T, c *Config) {
+			if len(c.OnChange.Commands) != 1 || !slices.Equal(c.OnChange.Commands[0].Cmd, []string{"go", "test", "-race"}) {
+				t.Errorf("on_change = %v, want the profile's", c.OnChange.Commands)
+			}
+			if len(c.OnFailure) != 1 || len(c.OnSuccess) != 1 {
+				t.Errorf("on_failure = %v, on_success = %v, want the profile's and the kept one", c.OnFailure, c.OnSuccess)
+			}
+			// The commands of tasks are their own
+			if tc, _ := c.ForTask("api"); tc.MaxConcurrency != 1 || !slices.Equal(tc.OnChange.Commands[0].Cmd, []string{"go", "build"}) {
+				t.Errorf("ForTask() max_concurrency = %d, on_change = %v", tc.MaxConcurrency, tc.OnChange.Commands)
+			}
+			if c.Profile != "ci" {
+				t.Errorf("Profile = %q", c.Profile)
+			}
+		}},
+		{name: "names are case-insensitive", profile: "CI", check: func(t *testing.T, c *Config) {
+			if c.MaxConcurrency != 1 {
+				t.Errorf("max_concurrency = %d, want 1", c.MaxConcurrency)
+			}
+		}},
+		{name: "unknown profile", profile: "prod", wantErr: `unknown profile "prod" (available: ci, quick)`},
+		{name: "tasks only", tasksOnly: true, profile: "quick", check: func(t *testing.T, c *Config) {
+			if c.HasPipeline() || c.Debounce != "100ms" {
+				t.Errorf("HasPipeline() = %v, debounce = %q", c.HasPipeline(), c.Debounce)
+			}
+		}},
+		// There are no top-level commands to replace
+		{name: "tasks only with on_change", tasksOnly: true, profile: "ci", wantErr: `profile "ci" sets on_change, but the config only defines tasks`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig(tt.tasksOnly)
+			err := c.ApplyProfile(tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ApplyProfile() = %v, want %q", err, tt.wantErr)
+				}
+				if c.HasPipeline() != !tt.tasksOnly {
+					t.Errorf("HasPipeline() = %v after a failed ApplyProfile()", c.HasPipeline())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyProfile() = %v", err)
+			}
+			tt.check(t, c)
+		})
+	}
+}
+
+func TestForTask(t *testing.T) {
+	yes := true
+	hook := func(name string) []Command { return []Command{{Cmd: []string{"echo", name}}} }
+	c := &Config{
+		Watch:            []WatchPath{{Path: "."}},
+		Ignore:           []string{"*.tmp"},
+		Debounce:         "250ms",
+		DebounceStrategy: DebounceTrailing,
+		MaxConcurrency:   2,
+		Backend:          BackendPoll,
+		Shell:            "bash",
+		Notify:           NotifyDesktop,
+		Webhooks:         []Webhook{{URL: "https://hooks.test/top"}},
+		OnSuccess:        hook("top ok"),
+		OnFailure:        hook("top failed"),
+		Setup:            hook("top setup"),
+		OnExit:           hook("top exit"),
+		RunOnStart:       true,
+		Report:           ReportJUnitPrefix + "reports/gowatch.xml",
+		OnChange:         OnChange{Commands: hook("top")},
+		Tasks: map[string]Task{
+			// Leaves everything it can unset
+			"lint": {OnChange: OnChange{Commands: hook("lint")}},
+			// Sets everything it can
+			"api": {
+				Watch:            []WatchPath{{Path: "api"}},
+				Ignore:           []string{"*.pb.go"},
+				Debounce:         "1s",
+				DebounceStrategy: DebounceThrottle,
+				MaxConcurrency:   4,
+				Webhooks:         []Webhook{{URL: "https://hooks.test/api"}},
+				OnSuccess:        hook("api ok"),
+				OnFailure:        hook("api failed"),
+				Setup:            hook("api setup"),
+				OnExit:           hook("api exit"),
+				RunOnStart:       new(bool),
+				OnChange:         OnChange{Commands: hook("api")},
+			},
+			"web": {RunOnStart: &yes, OnChange: OnChange{Commands: hook("web")}},
+		},
+	}
+
+	lint, err := c.ForTask("lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Inherited from the top level
+	if !reflect.DeepEqual(lint.Watch, c.Watch) || lint.Debounce != "250ms" || lint.DebounceStrategy != DebounceTrailing ||
+		lint.MaxConcurrency != 2 || lint.Backend != BackendPoll || lint.Shell != "bash" || lint.Notify != NotifyDesktop || !lint.RunOnStart {
+		t.Errorf("lint = %+v, want the top-level settings", lint)
+	}
+	if !reflect.DeepEqual(lint.Ignore, []string{"*.tmp"}) || !reflect.DeepEqual(lint.Webhooks, c.Webhooks) ||
+		!reflect.DeepEqual(lint.OnSuccess, c.OnSuccess) || !reflect.DeepEqual(lint.OnFailure, c.OnFailure) {
+		t.Errorf("lint ignore = %v, webhooks = %v, hooks = %v %v", lint.Ignore, lint.Webhooks, lint.OnSuccess, lint.OnFailure)
+	}
+	// Its own, never the top level's
+	if !reflect.DeepEqual(lint.OnChange.Commands, hook("lint")) || lint.Setup != nil || lint.OnExit != nil || lint.Tasks != nil {
+		t.Errorf("lint on_change = %v, setup = %v, on_exit = %v, tasks = %v", lint.OnChange.Commands, lint.Setup, lint.OnExit, lint.Tasks)
+	}
+	if lint.Task != "lint" || lint.JUnitReport() != "reports/gowatch-lint.xml" {
+		t.Errorf("Task = %q, JUnitReport() = %q", lint.Task, lint.JUnitReport())
+	}
+
+	api, err := c.ForTask("api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := c.Tasks["api"]
+	// Overridden by the task; ignore patterns add up
+	if !reflect.DeepEqual(api.Watch, task.Watch) || api.Debounce != "1s" || api.DebounceStrategy != DebounceThrottle ||
+		api.MaxConcurrency != 4 || api.RunOnStart {
+		t.Errorf("api = %+v, want the task's settings", api)
+	}
+	if !reflect.DeepEqual(api.Ignore, []string{"*.tmp", "*.pb.go"}) || !reflect.DeepEqual(api.Webhooks, task.Webhooks) ||
+		!reflect.DeepEqual(api.OnSuccess, task.OnSuccess) || !reflect.DeepEqual(api.OnFailure, task.OnFailure) ||
+		!reflect.DeepEqual(api.Setup, task.Setup) || !reflect.DeepEqual(api.OnExit, task.OnExit) {
+		t.Errorf("api ignore = %v, webhooks = %v, hooks = %v %v %v %v", api.Ignore, api.Webhooks, api.OnSuccess, api.OnFailure, api.Setup, api.OnExit)
+	}
+
+	// Several tasks at once don't share or change what they inherit
+	web, err := c.ForTask("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	web.Ignore[0] = "changed"
+	web.Debounce = "5s"
+	if c.Ignore[0] != "*.tmp" || lint.Ignore[0] != "*.tmp" || api.Ignore[0] != "*.tmp" || c.Debounce != "250ms" || lint.Debounce != "250ms" {
+		t.Errorf("changing one task's config changed others: top %v %q, lint %v %q, api %v", c.Ignore, c.Debounce, lint.Ignore, lint.Debounce, api.Ignore)
+	}
+	if !web.RunOnStart || c.Tasks["web"].RunOnStart != &yes {
+		t.Errorf("web run_on_start = %v", web.RunOnStart)
+	}
+	if len(c.Tasks) != 3 || !reflect.DeepEqual(c.OnChange.Commands, hook("top")) {
+		t.Errorf("ForTask() changed the config: tasks %v, on_change %v", c.TaskNames(), c.OnChange.Commands)
+	}
+
+	_, err = c.ForTask("docs")
+	if err == nil || err.Error() != `unknown task "docs" (available: api, lint, web)` {
+		t.Errorf("ForTask() of an unknown task = %v", err)
+	}
+	if _, err := (&Config{}).ForTask("lint"); err == nil || !strings.Contains(err.Error(), "(available: none)") {
+		t.Errorf("ForTask() without tasks = %v", err)
+	}
+}
+
+func TestConfig_JUnitReport(t *testing.T) {
+	tests := []struct {
+		report  string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"junit:reports/gowatch.xml", "reports/gowatch.xml", false},
+		{"junit:", "", true},
+		{"reports/gowatch.xml", "", true},
+	}
+	for _, tt := range tests {
+		c := Config{Report: tt.report, Watch: []WatchPath{{Path: t.TempDir()}}}
+		c.OnChange.Commands = []Command{{Cmd: []string{"true"}}}
+		c.SetDefaults()
+		if got := c.JUnitReport(); got != tt.want {
+			t.Errorf("JUnitReport(%q) = %q, want %q", tt.report, got, tt.want)
+		}
+		if err := c.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) = %v, want error %v", tt.report, err, tt.wantErr)
+		}
+	}
+
+	// Tasks write next to the top-level report
+	c := Config{Report: "junit:reports/gowatch.xml", Tasks: map[string]Task{"test": {}}}
+	tc, err := c.ForTask("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tc.JUnitReport(), "reports/gowatch-test.xml"; got != want {
+		t.Errorf("task report = %q, want %q", got, want)
+	}
+}
+
+func TestShellFor(t *testing.T) {
+	cfg := &Config{Shell: ShellBash, ShellWindows: ShellPwsh}
+	tests := []struct {
+		name string
+		cmd  Command
+		goos string
+		want string
+	}{
+		{"default", Command{}, "linux", ShellBash},
+		{"default on windows", Command{}, "windows", ShellPwsh},
+		{"command shell", Command{Shell: ShellNone}, "windows", ShellNone},
+		{"command windows shell", Command{Shell: ShellZsh, ShellWindows: ShellCmd}, "windows", ShellCmd},
+		{"command windows shell elsewhere", Command{ShellWindows: ShellCmd}, "darwin", ShellBash},
+	}
+	for _, tt := range tests {
+		if got := cfg.ShellFor(tt.cmd, tt.goos); got != tt.want {
+			t.Errorf("%s: ShellFor() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := (&Config{}).ShellFor(Command{}, "windows"); got != "" {
+		t.Errorf("ShellFor() without shells = %q, want none", got)
+	}
+}
+
+func TestValidate_Process(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		cmd     Command
+		wantErr string
+	}{
+		{"lowered", Command{Nice: 10, IONice: IONiceIdle}, ""},
+		{"raised", Command{Nice: MinNice, IONice: IONiceBestEffort}, ""},
+		{"out of range", Command{Nice: 20}, "nice must be between -20 and 19"},
+		{"unknown class", Command{IONice: "realtime"}, `invalid ionice "realtime"`},
+		{"container", Command{Nice: 5, Container: "dev"}, "don't apply to commands in a container"},
+		{"limits", Command{Limits: Limits{Memory: "2GiB", CPU: 1.5}}, ""},
+		{"memory limit", Command{Limits: Limits{Memory: "lots"}}, "limits: memory: invalid size"},
+		{"negative cpu limit", Command{Limits: Limits{CPU: -1}}, "limits: cpu must not be negative"},
+		{"limits in a container", Command{Limits: Limits{CPU: 1}, Container: "dev"}, "don't apply to commands in a container"},
+		{"tmux pane", Command{Output: "tmux:%3"}, ""},
+		{"new window", Command{Output: OutputNewWindow}, ""},
+		{"pane missing", Command{Output: "tmux:"}, `invalid output "tmux:"`},
+		{"unknown output", Command{Output: "screen"}, `invalid output "screen"`},
+		{"interactive output", Command{Output: OutputNewWindow, Interactive: true}, "interactive commands write to the terminal"},
+		{"output filter", Command{OutputFilter: []string{`^\s*\d+%`, "^Downloading "}}, ""},
+		{"invalid output filter", Command{OutputFilter: []string{"[0-9"}}, "output_filter: error parsing regexp"},
+		{"highlight", Command{Highlight: []Highlight{{Match: "^FAIL", Style: "Red bold"}, {Match: "warning:", Style: "on-yellow black"}}}, ""},
+		{"invalid highlight", Command{Highlight: []Highlight{{Match: "(", Style: "red"}}}, "highlight[0]: match: error parsing regexp"},
+		{"unknown highlight style", Command{Highlight: []Highlight{{Match: "x", Style: "red blinking"}}}, `highlight[0]: style: unknown style "blinking"`},
+		{"missing highlight style", Command{Highlight: []Highlight{{Match: "x"}}}, "highlight[0]: style: empty style"},
+		{"pty", Command{PTY: true, Output: OutputNewWindow}, ""},
+		{"pty in a container", Command{PTY: true, Container: "dev"}, ""},
+		{"interactive pty", Command{PTY: true, Interactive: true}, "use the terminal itself"},
+		{"interactive output filter", Command{OutputFilter: []string{"x"}, Interactive: true}, "output_filter and highlight don't apply"},
+		{"parser", Command{Parser: ParserGoTest}, ""},
+		{"unknown parser", Command{Parser: "rspec"}, `invalid parser "rspec"`},
+		{"interactive parser", Command{Parser: ParserJest, Interactive: true}, "where parser can't read them"},
+		{"restarted parser", Command{Parser: ParserPytest, Mode: ModeRestart}, "mode: restart commands don't"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cmd.Cmd = []string{"true"}
+			cfg := &Config{
+				Watch:    []WatchPath{{Path: dir}},
+				OnChange: OnChange{Commands: []Command{tt.cmd}},
+			}
+			cfg.SetDefaults()
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_Formats(t *testing.T) {
+	dir := t.TempDir()
+	// Without the user config beneath the files
+	t.Setenv("HOME", dir)
+	watch := filepath.ToSlash(dir)
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{"gowatch.yaml", `
+watch:
+  - path: ` + watch + `
+debounce: 1.5s
+on_change:
+  commands:
+    - cmd: ["go", "build"]
+      timeout: 2m
+    - cmd: ["go", "test", "./..."]
+      retries: 2
+`},
+		{"gowatch.toml", `
+debounce = "1.5s"
+
+[[watch]]
+path = "` + watch + `"
+
+[[on_change.commands]]
+cmd = ["go", "build"]
+timeout = "2m"
+
+[[on_change.commands]]
+cmd = ["go", "test", "./..."]
+retries = 2
+`},
+		{"gowatch.json", `{
+  "watch": [{"path": "` + watch + `"}],
+  "debounce": "1.5s",
+  "on_change": {
+    "commands": [
+      {"cmd": ["go", "build"], "timeout": "2m"},
+      {"cmd": ["go", "test", "./..."], "retries": 2}
+    ]
+  }
+}`},
+		// Files without an extension are YAML
+		{"gowatchrc", `
+watch: [{path: ` + watch + `}]
+debounce: 1.5s
+on_change:
+  commands:
+    - {cmd: ["go", "build"], timeout: 2m}
+    - {cmd: [go, test, ./...], retries: 2}
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if len(cfg.Watch) != 1 || cfg.Watch[0].Path != watch || cfg.GetDebounceDuration() != 1500*time.Millisecond {
+				t.Errorf("watch = %+v, debounce = %s", cfg.Watch, cfg.GetDebounceDuration())
+			}
+			cmds := cfg.OnChange.Commands
+			if len(cmds) != 2 || !slices.Equal(cmds[0].Cmd, []string{"go", "build"}) || cmds[0].Timeout != "2m" ||
+				!slices.Equal(cmds[1].Cmd, []string{"go", "test", "./..."}) || cmds[1].Retries != 2 {
+				t.Errorf("on_change commands = %+v", cmds)
+			}
+		})
+	}
+
+	path := filepath.Join(dir, "gowatch.conf")
+	if err := os.WriteFile(path, []byte("debounce: 1s\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), `"conf"`) {
+		t.Errorf("Load() of an unknown format = %v", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		want    string
+		wantErr bool
+	}{
+		{name: "all formats", files: []string{"gowatch.json", "gowatch.toml", "gowatch.yml", "gowatch.yaml"}, want: "gowatch.yaml"},
+		{name: "yml before toml", files: []string{"gowatch.json", "gowatch.toml", "gowatch.yml"}, want: "gowatch.yml"},
+		{name: "toml before json", files: []string{"gowatch.json", "gowatch.toml"}, want: "gowatch.toml"},
+		{name: "json", files: []string{"gowatch.json"}, want: "gowatch.json"},
+		{name: "other names", files: []string{"gowatch.conf", ".gowatch.yaml"}, wantErr: true},
+		{name: "none", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Chdir(dir)
+			// Without one in the user config directory either
+			t.Setenv("HOME", dir)
+			for _, name := range tt.files {
+				if err := os.WriteFile(name, nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := Find()
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "gowatch.yaml, gowatch.yml, gowatch.toml, gowatch.json") {
+					t.Errorf("Find() = %q, %v, want an error listing the names", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Find() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,372 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ProjectType represents the detected project type
+type ProjectType string
+
+const (
+	ProjectGo         ProjectType = "go"
+	ProjectPython     ProjectType = "python"
+	ProjectRust       ProjectType = "rust"
+	ProjectNode       ProjectType = "node"
+	ProjectTypeScript ProjectType = "typescript"
+	ProjectUnknown    ProjectType = "unknown"
+)
+
+// DetectProjectType attempts to detect the project type based on files
+func DetectProjectType(path string) ProjectType {
+	// Check for Go project
+	if fileExists(filepath.Join(path, "go.mod")) {
+		return ProjectGo
+	}
+
+	// Check for Rust project
+	if fileExists(filepath.Join(path, "Cargo.toml")) {
+		return ProjectRust
+	}
+
+	// Check for Python project
+	if fileExists(filepath.Join(path, "pyproject.toml")) ||
+		fileExists(filepath.Join(path, "setup.py")) ||
+		fileExists(filepath.Join(path, "requirements.txt")) ||
+		fileExists(filepath.Join(path, "Pipfile")) ||
+		fileExists(filepath.Join(path, "poetry.lock")) {
+		return ProjectPython
+	}
+
+	// Check for Node/TypeScript project
+	if fileExists(filepath.Join(path, "package.json")) {
+		// Check if TypeScript
+		if fileExists(filepath.Join(path, "tsconfig.json")) {
+			return ProjectTypeScript
+		}
+		return ProjectNode
+	}
+
+	return ProjectUnknown
+}
+
+// Subproject is a project found in a directory of a repository
+type Subproject struct {
+	// Dir is relative to the repository root, "." for the root itself
+	Dir  string
+	Type ProjectType
+}
+
+// detectDepth is how many levels of subdirectories DetectProjects scans
+const detectDepth = 2
+
+// skipDirs are directories that never hold a project of their own
+var skipDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"target":       true,
+	"dist":         true,
+	"build":        true,
+	"venv":         true,
+	"env":          true,
+	"__pycache__":  true,
+	"testdata":     true,
+}
+
+// DetectProjects finds the projects of a repository: the root itself and
+// its subdirectories up to two levels deep, in path order. Hidden and
+// dependency directories are skipped, and so are the subdirectories of a
+// project found below the root.
+func DetectProjects(path string) []Subproject {
+	var projects []Subproject
+	if pt := DetectProjectType(path); pt != ProjectUnknown {
+		projects = append(projects, Subproject{Dir: ".", Type: pt})
+	}
+	return append(projects, detectSubprojects(path, "", 1)...)
+}
+
+// detectSubprojects scans the subdirectories of root/rel
+func detectSubprojects(root, rel string, depth int) []Subproject {
+	entries, err := os.ReadDir(filepath.Join(root, rel))
+	if err != nil {
+		return nil
+	}
+
+	var projects []Subproject
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || strings.HasPrefix(name, ".") || skipDirs[name] {
+			continue
+		}
+		dir := filepath.ToSlash(filepath.Join(rel, name))
+		if pt := DetectProjectType(filepath.Join(root, dir)); pt != ProjectUnknown {
+			projects = append(projects, Subproject{Dir: dir, Type: pt})
+			continue
+		}
+		if depth < detectDepth {
+			projects = append(projects, detectSubprojects(root, dir, depth+1)...)
+		}
+	}
+	return projects
+}
+
+// fileExists checks if a file exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// GetTemplateForType returns the appropriate config template for a project type
+func GetTemplateForType(projectType ProjectType) string {
+	switch projectType {
+	case ProjectGo:
+		return goTemplate
+	case ProjectPython:
+		return pythonTemplate
+	case ProjectRust:
+		return rustTemplate
+	case ProjectNode, ProjectTypeScript:
+		return nodeTemplate
+	default:
+		return defaultTemplate
+	}
+}
+
+// Template configurations
+const goTemplate = `# GoWatch Configuration for Go Project
+watch:
+  - path: "./"
+    recursive: true
+    ignore:
+      - "**/vendor/**"
+      - "**/*.exe"
+      - "**/bin/**"
+      - ".git/**"
+
+on_change:
+  commands:
+    - cmd: ["go", "fmt", "./..."]
+      timeout: "30s"
+    - cmd: ["go", "test", "-v", "./..."]
+      timeout: "120s"
+    - cmd: ["go", "build", "./..."]
+      timeout: "90s"
+
+debounce: "500ms"
+max_concurrency: 1
+`
+
+const pythonTemplate = `# GoWatch Configuration for Python Project
+watch:
+  - path: "./"
+    recursive: true
+    ignore:
+      - "**/__pycache__/**"
+      - "**/venv/**"
+      - "**/env/**"
+      - "**/.pytest_cache/**"
+      - ".git/**"
+
+on_change:
+  commands:
+    - cmd: ["python", "-m", "pytest", "-v"]
+      timeout: "120s"
+
+debounce: "500ms"
+max_concurrency: 1
+`
+
+const rustTemplate = `# GoWatch Configuration for Rust Project
+watch:
+  - path: "./src"
+    recursive: true
+  - path: "Cargo.toml"
+
+ignore:
+  - "**/target/**"
+  - ".git/**"
+
+on_change:
+  commands:
+    - cmd: ["cargo", "check"]
+      timeout: "60s"
+    - cmd: ["cargo", "test"]
+      timeout: "180s"
+    - cmd: ["cargo", "build"]
+      timeout: "120s"
+
+debounce: "750ms"
+max_concurrency: 1
+`
+
+const nodeTemplate = `# GoWatch Configuration for Node.js/TypeScript Project
+watch:
+  - path: "./src"
+    recursive: true
+
+ignore:
+  - "**/node_modules/**"
+  - "**/dist/**"
+  - "**/build/**"
+  - ".git/**"
+
+on_change:
+  commands:
+    - cmd: ["npm", "run", "lint"]
+      timeout: "60s"
+    - cmd: ["npm", "test"]
+      timeout: "120s"
+    - cmd: ["npm", "run", "build"]
+      timeout: "180s"
+
+debounce: "500ms"
+max_concurrency: 1
+`
+
+const defaultTemplate = `# GoWatch Configuration
+watch:
+  - path: "./"
+    recursive: true
+    ignore:
+      - "vendor/**"
+      - ".git/**"
+      - "**/*.tmp"
+
+on_change:
+  commands:
+    - cmd: ["echo", "File changed: {path}"]
+
+debounce: "250ms"
+max_concurrency: 2
+`
+
+// projectCommands are the commands of a generated task for each project type
+var projectCommands = map[ProjectType][]string{
+	ProjectGo:         {`["go", "vet", "./..."]`, `["go", "test", "./..."]`, `["go", "build", "./..."]`},
+	ProjectPython:     {`["python", "-m", "pytest"]`},
+	ProjectRust:       {`["cargo", "check"]`, `["cargo", "test"]`},
+	ProjectNode:       {`["npm", "test"]`, `["npm", "run", "build"]`},
+	ProjectTypeScript: {`["npm", "test"]`, `["npm", "run", "build"]`},
+}
+
+// projectIgnores are the ignore patterns of a generated task for each
+// project type
+var projectIgnores = map[ProjectType][]string{
+	ProjectGo:         {"**/vendor/**", "**/bin/**"},
+	ProjectPython:     {"**/__pycache__/**", "**/venv/**", "**/.pytest_cache/**"},
+	ProjectRust:       {"**/target/**"},
+	ProjectNode:       {"**/node_modules/**", "**/dist/**", "**/build/**"},
+	ProjectTypeScript: {"**/node_modules/**", "**/dist/**", "**/build/**"},
+}
+
+// GetTemplateForProjects returns a config with a task per project, each
+// watching and running its commands in its own directory. A single project
+// gets the template of its type.
+func GetTemplateForProjects(projects []Subproject) string {
+	switch len(projects) {
+	case 0:
+		return defaultTemplate
+	case 1:
+		if projects[0].Dir == "." {
+			return GetTemplateForType(projects[0].Type)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# GoWatch Configuration for a repository with several projects\n")
+	b.WriteString("# Run every task with \"gowatch run\", or some with \"gowatch run <task>,<task>\"\n")
+	b.WriteString("ignore:\n  - \".git/**\"\n\n")
+	b.WriteString("debounce: \"500ms\"\n")
+	b.WriteString("max_concurrency: 1\n\n")
+	b.WriteString("tasks:\n")
+
+	names := make(map[string]bool)
+	for _, p := range projects {
+		name := taskName(p)
+		for i := 2; names[name]; i++ {
+			name = fmt.Sprintf("%s-%d", taskName(p), i)
+		}
+		names[name] = true
+
+		ignores := slices.Clone(projectIgnores[p.Type])
+		if p.Dir == "." {
+			// The other projects have tasks of their own
+			for _, o := range projects {
+				if o.Dir != "." {
+					ignores = append(ignores, o.Dir+"/**")
+				}
+			}
+		}
+
+		fmt.Fprintf(&b, "  %s:\n", name)
+		b.WriteString("    watch:\n")
+		fmt.Fprintf(&b, "      - path: %q\n", "./"+strings.TrimPrefix(p.Dir, "."))
+		b.WriteString("        recursive: true\n")
+		b.WriteString("        ignore:\n")
+		for _, pattern := range ignores {
+			fmt.Fprintf(&b, "          - %q\n", pattern)
+		}
+		b.WriteString("    on_change:\n")
+		b.WriteString("      commands:\n")
+		for _, cmd := range projectCommands[p.Type] {
+			fmt.Fprintf(&b, "        - cmd: %s\n", cmd)
+			if p.Dir != "." {
+				fmt.Fprintf(&b, "          cwd: %q\n", p.Dir)
+			}
+		}
+	}
+	return b.String()
+}
+
+// taskName names the generated task of a project after its directory
+func taskName(p Subproject) string {
+	if p.Dir == "." {
+		return string(p.Type)
+	}
+	return strings.Map(func(r rune) rune {
+		if r == ',' || r == ' ' || r == '/' {
+			return '-'
+		}
+		return r
+	}, p.Dir)
+}
+
+// WriteTemplateForProject writes a config template based on detected project
+// type, with a task per project when the path holds several
+func WriteTemplateForProject(path string) error {
+	template := GetTemplateForProjects(DetectProjects(path))
+
+	configPath := filepath.Join(path, "gowatch.yaml")
+
+	// Check if config already exists
+	if _, err := os.Stat(configPath); err == nil {
+		return fmt.Errorf("config file already exists: %s", configPath)
+	}
+
+	if err := os.WriteFile(configPath, []byte(template), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	return nil
+}
+
+// GetProjectTypeName returns a human-readable name for the project type
+func GetProjectTypeName(pt ProjectType) string {
+	switch pt {
+	case ProjectGo:
+		return "Go"
+	case ProjectPython:
+		return "Python"
+	case ProjectRust:
+		return "Rust"
+	case ProjectNode:
+		return "Node.js"
+	case ProjectTypeScript:
+		return "TypeScript"
+	default:
+		return "Unknown"
+	}
+}
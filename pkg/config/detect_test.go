@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetectProjects(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  []Subproject
+	}{
+		{"single project", []string{"go.mod", "cmd/main.go"}, []Subproject{{".", ProjectGo}}},
+		{"root and frontend", []string{"go.mod", "web/package.json", "web/tsconfig.json"},
+			[]Subproject{{".", ProjectGo}, {"web", ProjectTypeScript}}},
+		{"nested services", []string{"services/api/Cargo.toml", "services/ml/requirements.txt", "app/package.json"},
+			[]Subproject{{"app", ProjectNode}, {"services/api", ProjectRust}, {"services/ml", ProjectPython}}},
+		{"skipped directories", []string{"web/package.json", "web/node_modules/left-pad/package.json", ".cache/go.mod", "vendor/x/go.mod"},
+			[]Subproject{{"web", ProjectNode}}},
+		{"too deep", []string{"a/b/c/go.mod"}, nil},
+		{"nothing", []string{"README.md"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tt.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := DetectProjects(dir); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DetectProjects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTemplateForProjects(t *testing.T) {
+	projects := []Subproject{{".", ProjectGo}, {"web", ProjectTypeScript}, {"services/api", ProjectRust}, {"go", ProjectGo}}
+
+	dir := t.TempDir()
+	for _, p := range projects {
+		if err := os.MkdirAll(filepath.Join(dir, p.Dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Watch paths are relative to the working directory
+	t.Chdir(dir)
+
+	path := filepath.Join(dir, "gowatch.yaml")
+	if err := os.WriteFile(path, []byte(GetTemplateForProjects(projects)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if got, want := cfg.TaskNames(), []string{"go", "go-2", "services-api", "web"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("TaskNames() = %v, want %v", got, want)
+	}
+
+	root, err := cfg.ForTask("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := root.Watch[0].Ignore; !reflect.DeepEqual(got[len(got)-3:], []string{"web/**", "services/api/**", "go/**"}) {
+		t.Errorf("root task ignore = %v, want the other projects ignored", got)
+	}
+	if got := root.OnChange.Commands[0].Cwd; got != "" {
+		t.Errorf("root task cwd = %q, want none", got)
+	}
+
+	api, err := cfg.ForTask("services-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := api.Watch[0].Path; got != "./services/api" {
+		t.Errorf("services-api watch path = %q, want ./services/api", got)
+	}
+	for _, cmd := range api.OnChange.Commands {
+		if cmd.Cwd != "services/api" || cmd.Cmd[0] != "cargo" {
+			t.Errorf("services-api command = %v in %q, want cargo in services/api", cmd.Cmd, cmd.Cwd)
+		}
+	}
+
+	if got := GetTemplateForProjects([]Subproject{{".", ProjectRust}}); got != rustTemplate {
+		t.Errorf("single project template = %q, want the Rust template", got)
+	}
+}
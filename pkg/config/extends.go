@@ -0,0 +1,211 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// maxExtendsDepth bounds chains of configs extending each other
+const maxExtendsDepth = 10
+
+// fetchTimeout bounds downloading a base config from a URL
+const fetchTimeout = 10 * time.Second
+
+// readSettings reads the raw settings of a config file or URL with the
+// configs it extends merged in. chain holds the locations being read, to
+// detect cycles.
+func readSettings(location string, chain []string) (map[string]interface{}, error) {
+	for _, seen := range chain {
+		if seen == location {
+			return nil, fmt.Errorf("extends cycle: %s -> %s", strings.Join(chain, " -> "), location)
+		}
+	}
+	if len(chain) >= maxExtendsDepth {
+		return nil, fmt.Errorf("extends chain is deeper than %d files", maxExtendsDepth)
+	}
+	chain = append(chain, location)
+
+	data, err := fetch(location)
+	if err != nil {
+		return nil, err
+	}
+
+	v := viper.New()
+	v.SetConfigType(formatOf(location))
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("%s: %w", location, err)
+	}
+	settings := v.AllSettings()
+
+	parents, err := extendsList(settings["extends"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", location, err)
+	}
+	delete(settings, "extends")
+
+	merged := map[string]interface{}{}
+	for _, parent := range parents {
+		base, err := readSettings(resolveLocation(location, parent), chain)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeSettings(merged, base)
+	}
+	return mergeSettings(merged, settings), nil
+}
+
+// fetch reads a local file or downloads an http(s) URL
+func fetch(location string) ([]byte, error) {
+	if !isURL(location) {
+		return os.ReadFile(location)
+	}
+
+	client := http.Client{Timeout: fetchTimeout}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", location, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// formatOf returns the config format for a location's extension, YAML if it
+// has none
+func formatOf(location string) string {
+	p := location
+	if isURL(location) {
+		if u, err := url.Parse(location); err == nil {
+			p = u.Path
+		}
+	}
+	if ext := strings.TrimPrefix(path.Ext(p), "."); ext != "" {
+		return strings.ToLower(ext)
+	}
+	return "yaml"
+}
+
+// resolveLocation resolves an extends entry relative to the config that
+// names it
+func resolveLocation(from, ref string) string {
+	if isURL(ref) {
+		return ref
+	}
+	if isURL(from) {
+		base, err := url.Parse(from)
+		if err != nil {
+			return ref
+		}
+		rel, err := url.Parse(filepath.ToSlash(ref))
+		if err != nil {
+			return ref
+		}
+		return base.ResolveReference(rel).String()
+	}
+	if filepath.IsAbs(ref) {
+		return ref
+	}
+	return filepath.Join(filepath.Dir(from), ref)
+}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// extendsList accepts extends as a single location or a list of them
+func extendsList(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("extends entries must be strings")
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("extends must be a path or a list of paths")
+	}
+}
+
+// mergeSettings merges child settings over base ones. Maps merge
+// recursively and other values, lists included, are replaced, except for
+// the top-level watch and ignore lists: child entries are appended, and a
+// watch entry with the same path as a base one replaces it.
+func mergeSettings(base, child map[string]interface{}) map[string]interface{} {
+	merged := mergeMaps(base, child)
+	if list, ok := child["ignore"].([]interface{}); ok {
+		if baseList, ok := base["ignore"].([]interface{}); ok {
+			merged["ignore"] = append(append([]interface{}{}, baseList...), list...)
+		}
+	}
+	if list, ok := child["watch"].([]interface{}); ok {
+		if baseList, ok := base["watch"].([]interface{}); ok {
+			merged["watch"] = mergeWatch(baseList, list)
+		}
+	}
+	return merged
+}
+
+func mergeMaps(base, child map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(child))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range child {
+		baseMap, baseOK := merged[k].(map[string]interface{})
+		childMap, childOK := v.(map[string]interface{})
+		if baseOK && childOK {
+			merged[k] = mergeMaps(baseMap, childMap)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// mergeWatch appends child watch paths, replacing base entries that have
+// the same path
+func mergeWatch(base, child []interface{}) []interface{} {
+	pathOf := func(entry interface{}) string {
+		if m, ok := entry.(map[string]interface{}); ok {
+			if p, ok := m["path"].(string); ok {
+				return filepath.Clean(p)
+			}
+		}
+		return ""
+	}
+
+	replaced := make(map[string]bool)
+	for _, entry := range child {
+		if p := pathOf(entry); p != "" {
+			replaced[p] = true
+		}
+	}
+
+	var merged []interface{}
+	for _, entry := range base {
+		if !replaced[pathOf(entry)] {
+			merged = append(merged, entry)
+		}
+	}
+	return append(merged, child...)
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeSettings(t *testing.T) {
+	base := map[string]interface{}{
+		"debounce": "250ms",
+		"ignore":   []interface{}{"*.log"},
+		"watch": []interface{}{
+			map[string]interface{}{"path": "./src", "recursive": true},
+			map[string]interface{}{"path": "./lib"},
+		},
+		"on_change": map[string]interface{}{
+			"commands": []interface{}{"base"},
+		},
+		"tasks": map[string]interface{}{
+			"test": map[string]interface{}{"debounce": "1s"},
+		},
+	}
+	child := map[string]interface{}{
+		"debounce": "100ms",
+		"ignore":   []interface{}{"tmp/"},
+		"watch": []interface{}{
+			map[string]interface{}{"path": "src", "recursive": false},
+		},
+		"on_change": map[string]interface{}{
+			"commands": []interface{}{"child"},
+		},
+		"tasks": map[string]interface{}{
+			"lint": map[string]interface{}{"debounce": "2s"},
+		},
+	}
+
+	got := mergeSettings(base, child)
+
+	want := map[string]interface{}{
+		"debounce": "100ms",
+		"ignore":   []interface{}{"*.log", "tmp/"},
+		"watch": []interface{}{
+			map[string]interface{}{"path": "./lib"},
+			map[string]interface{}{"path": "src", "recursive": false},
+		},
+		"on_change": map[string]interface{}{
+			"commands": []interface{}{"child"},
+		},
+		"tasks": map[string]interface{}{
+			"test": map[string]interface{}{"debounce": "1s"},
+			"lint": map[string]interface{}{"debounce": "2s"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeSettings() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestLoad_Extends(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"max_concurrency": 3}`))
+	}))
+	defer srv.Close()
+
+	write("shared.json", `{"debounce": "1s", "ignore": ["*.tmp"]}`)
+	write("base.yaml", `
+extends: [shared.json, `+srv.URL+`/remote.json]
+watch:
+  - path: `+filepath.ToSlash(dir)+`
+on_change:
+  commands:
+    - cmd: ["echo", "base"]
+`)
+	child := write("gowatch.yaml", `
+extends: base.yaml
+debounce: 200ms
+ignore: ["*.log"]
+`)
+
+	cfg, err := Load(child)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Debounce != "200ms" || cfg.MaxConcurrency != 3 {
+		t.Errorf("debounce = %q, max_concurrency = %d", cfg.Debounce, cfg.MaxConcurrency)
+	}
+	if want := []string{"*.tmp", "*.log"}; !reflect.DeepEqual(cfg.Ignore, want) {
+		t.Errorf("ignore = %v, want %v", cfg.Ignore, want)
+	}
+	if len(cfg.OnChange.Commands) != 1 || cfg.OnChange.Commands[0].Cmd[1] != "base" {
+		t.Errorf("commands = %v", cfg.OnChange.Commands)
+	}
+}
+
+func TestLoadProfile_Extends(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	write("base.yaml", `
+watch:
+  - path: `+filepath.ToSlash(dir)+`
+on_change:
+  commands:
+    - cmd: ["make"]
+profiles:
+  ci:
+    debounce: 1s
+    max_concurrency: 1
+  quick:
+    debounce: 50ms
+`)
+	// The child overrides a setting of an inherited profile and adds one
+	child := write("gowatch.yaml", `
+extends: base.yaml
+profiles:
+  ci:
+    max_concurrency: 4
+  local:
+    notify: desktop
+`)
+
+	tests := []struct {
+		profile        string
+		debounce       string
+		maxConcurrency int
+		notify         string
+	}{
+		{"", "", 0, ""},
+		{"ci", "1s", 4, ""},
+		{"quick", "50ms", 0, ""},
+		{"local", "", 0, NotifyDesktop},
+	}
+	for _, tt := range tests {
+		cfg, err := LoadProfile(child, tt.profile)
+		if err != nil {
+			t.Fatalf("LoadProfile(%q) error = %v", tt.profile, err)
+		}
+		// Defaults fill in what the profile leaves unset
+		want := Config{Debounce: tt.debounce, MaxConcurrency: tt.maxConcurrency}
+		want.SetDefaults()
+		if cfg.Debounce != want.Debounce || cfg.MaxConcurrency != want.MaxConcurrency || cfg.Notify != tt.notify {
+			t.Errorf("LoadProfile(%q): debounce = %q, max_concurrency = %d, notify = %q, want %q, %d, %q",
+				tt.profile, cfg.Debounce, cfg.MaxConcurrency, cfg.Notify, want.Debounce, want.MaxConcurrency, tt.notify)
+		}
+	}
+	if _, err := LoadProfile(child, "prod"); err == nil || !strings.Contains(err.Error(), "available: ci, local, quick") {
+		t.Errorf("LoadProfile() of an unknown profile = %v", err)
+	}
+}
+
+func TestLoad_ExtendsCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yaml")
+	os.WriteFile(a, []byte("extends: b.yaml\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.yaml"), []byte("extends: a.yaml\n"), 0644)
+
+	_, err := Load(a)
+	if err == nil || !strings.Contains(err.Error(), "extends cycle") {
+		t.Errorf("Load() error = %v, want extends cycle", err)
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// GoApp describes the Go program gowatch go builds and runs
+type GoApp struct {
+	// Package is the main package to build, such as "./cmd/server"
+	Package string
+	// Binary is the file the program is built to
+	Binary string
+	// Dir is the directory watched for changes (default: the working
+	// directory)
+	Dir string
+	// BuildFlags are passed to go build, such as "-race"
+	BuildFlags []string
+	// Args are passed to the program
+	Args []string
+	// Extensions are watched besides .go files, go.mod and go.sum, such as
+	// the templates the program embeds
+	Extensions []string
+}
+
+// AppName names the program after its package, such as "server" for
+// "./cmd/server"
+func (a GoApp) AppName() string {
+	pkg := strings.TrimSuffix(a.Package, "/...")
+	name := filepath.Base(pkg)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		if abs, err := filepath.Abs(pkg); err == nil {
+			name = filepath.Base(abs)
+		}
+	}
+	return name
+}
+
+// BinaryName is the file name the program is built to
+func (a GoApp) BinaryName() string {
+	if runtime.GOOS == "windows" {
+		return a.AppName() + ".exe"
+	}
+	return a.AppName()
+}
+
+// Config returns the config of the program's reload loop: it is built when
+// gowatch starts and whenever a Go file, go.mod or go.sum changes, and
+// restarted once the build succeeds. A build that fails is shown and leaves
+// the previous binary running. go build replaces the binary while it runs,
+// moving it aside on Windows.
+func (a GoApp) Config() *Config {
+	dir := a.Dir
+	if dir == "" {
+		dir = "."
+	}
+	pkg := a.Package
+	if pkg == "" {
+		pkg = "."
+	}
+	build := append([]string{"go", "build", "-o", a.Binary}, a.BuildFlags...)
+	name := a.AppName()
+	if name == "build" {
+		// The build command has that name
+		name = "app"
+	}
+	cfg := &Config{
+		Watch: []WatchPath{{
+			Path:       dir,
+			Recursive:  true,
+			Extensions: append([]string{"go"}, a.Extensions...),
+			Include:    []string{"go.mod", "go.sum"},
+			// Tests aren't part of the program
+			Ignore: []string{".git/**", "**/*_test.go", "**/testdata/**"},
+		}},
+		OnChange: OnChange{Commands: []Command{
+			{
+				Name:    "build",
+				Cmd:     append(build, pkg),
+				Timeout: "5m",
+			},
+			{
+				Name:      name,
+				Cmd:       append([]string{a.Binary}, a.Args...),
+				Mode:      ModeRestart,
+				DependsOn: []string{"build"},
+			},
+		}},
+		RunOnStart: true,
+	}
+	cfg.SetDefaults()
+	return cfg
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGoApp_AppName(t *testing.T) {
+	wd, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		pkg  string
+		want string
+	}{
+		{"./cmd/server", "server"},
+		{"./cmd/server/", "server"},
+		{"example.com/app/cmd/api", "api"},
+		{"./cmd/worker/...", "worker"},
+		{".", filepath.Base(wd)},
+		{"", filepath.Base(wd)},
+	}
+	for _, tt := range tests {
+		if got := (GoApp{Package: tt.pkg}).AppName(); got != tt.want {
+			t.Errorf("AppName(%q) = %q, want %q", tt.pkg, got, tt.want)
+		}
+	}
+}
+
+func TestGoApp_Config(t *testing.T) {
+	dir := t.TempDir()
+	app := GoApp{
+		Package:    "./cmd/server",
+		Binary:     filepath.Join(dir, "server"),
+		Dir:        dir,
+		BuildFlags: []string{"-race"},
+		Args:       []string{"-addr", ":8080"},
+		Extensions: []string{"html"},
+	}
+	cfg := app.Config()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	if got, want := cfg.Watch[0].Extensions, []string{"go", "html"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Extensions = %q, want %q", got, want)
+	}
+	build, run := cfg.OnChange.Commands[0], cfg.OnChange.Commands[1]
+	if want := []string{"go", "build", "-o", app.Binary, "-race", "./cmd/server"}; !reflect.DeepEqual(build.Cmd, want) {
+		t.Errorf("build = %q, want %q", build.Cmd, want)
+	}
+	// The program restarts only once the build succeeded
+	if want := []string{app.Binary, "-addr", ":8080"}; !reflect.DeepEqual(run.Cmd, want) {
+		t.Errorf("run = %q, want %q", run.Cmd, want)
+	}
+	if !run.IsRestart() || !reflect.DeepEqual(run.DependsOn, []string{build.Name}) {
+		t.Errorf("run = %+v, want a restart command depending on %q", run, build.Name)
+	}
+	if !cfg.RunOnStart {
+		t.Error("RunOnStart = false, want the program built when watching starts")
+	}
+
+	// A program named build doesn't take the name of its build command
+	cfg = GoApp{Package: "./cmd/build", Binary: "build", Dir: dir}.Config()
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() of ./cmd/build = %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+// jsonSchema is the subset of JSON Schema describing the config format
+type jsonSchema struct {
+	Schema      string `json:"$schema,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type is a type name or a list of them
+	Type                 interface{}            `json:"type,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+}
+
+// schemaEnums are the values accepted by settings limited to a fixed set,
+// by key
+var schemaEnums = map[string][]string{
+	"backend":           {BackendFSNotify, BackendPoll, BackendWatchman, BackendFanotify},
+	"bell":              {BellFailure, BellChange},
+	"debounce_strategy": {DebounceTrailing, DebounceLeading, DebounceThrottle},
+	"ionice":            {IONiceBestEffort, IONiceIdle},
+	"notify":            {NotifyDesktop},
+	"mode":              {ModeOnce, ModeRestart},
+	"on":                {WebhookAlways, WebhookSuccess, WebhookFailure},
+	"parser":            parsers,
+	"queue_overflow":    {OverflowCoalesce, OverflowDropOldest, OverflowBlock},
+	"shell":             shells,
+	"shell_windows":     shells,
+}
+
+// Schema returns the JSON Schema of the config format, generated from the
+// Config struct, indented for editors to read
+func Schema() ([]byte, error) {
+	return json.MarshalIndent(configSchema(), "", "  ")
+}
+
+// configSchema describes the Config struct and the keys handled before it
+// is decoded
+func configSchema() *jsonSchema {
+	s := reflectSchema(reflect.TypeOf(Config{}))
+	s.Schema = "http://json-schema.org/draft-07/schema#"
+	s.Title = "gowatch configuration"
+	s.Properties["extends"] = &jsonSchema{
+		Description: "base configs to inherit from, as paths or http(s) URLs",
+		Type:        []string{"string", "array"},
+		Items:       &jsonSchema{Type: "string"},
+	}
+	return s
+}
+
+// reflectSchema describes a type by its mapstructure keys
+func reflectSchema(t reflect.Type) *jsonSchema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return reflectSchema(t.Elem())
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.Slice:
+		return &jsonSchema{Type: "array", Items: reflectSchema(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: reflectSchema(t.Elem())}
+	case reflect.Struct:
+		s := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}, AdditionalProperties: false}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key := f.Tag.Get("mapstructure")
+			if key == "" || key == "-" || !f.IsExported() {
+				continue
+			}
+			prop := reflectSchema(f.Type)
+			if enum, ok := schemaEnums[key]; ok && prop.Type == "string" {
+				prop.Enum = enum
+			}
+			s.Properties[key] = prop
+		}
+		return s
+	default:
+		panic(fmt.Sprintf("config: no schema for %s", t))
+	}
+}
+
+// SchemaIssue is a place where a config file departs from the schema
+type SchemaIssue struct {
+	// Line and Column are 1-based, 0 when the format doesn't report them
+	Line, Column int
+	// Path locates the value, e.g. "watch[0].recursive"
+	Path    string
+	Message string
+	// Unknown is set for keys the schema doesn't know, which are ignored
+	// when loading
+	Unknown bool
+}
+
+func (i SchemaIssue) String() string {
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s", i.Path, i.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", i.Line, i.Column, i.Path, i.Message)
+}
+
+// CheckSchema checks a local config file against the schema. YAML and JSON
+// issues carry their line and column; TOML ones only their path. Values
+// that loading would convert, such as "2" for a number, are reported too.
+func CheckSchema(path string) ([]SchemaIssue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if formatOf(path) == "toml" {
+		var settings map[string]interface{}
+		if err := toml.Unmarshal(data, &settings); err != nil {
+			return nil, err
+		}
+		if err := doc.Encode(settings); err != nil {
+			return nil, err
+		}
+		// Encoded nodes have no position in the file
+		clearPositions(&doc)
+	} else if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	root := &doc
+	if doc.Kind == yaml.DocumentNode {
+		if len(doc.Content) == 0 {
+			return nil, nil
+		}
+		root = doc.Content[0]
+	}
+	var issues []SchemaIssue
+	checkNode(root, configSchema(), "", &issues)
+	if root.Line == 0 {
+		// TOML tables come out of a map in no particular order
+		slices.SortStableFunc(issues, func(a, b SchemaIssue) int { return strings.Compare(a.Path, b.Path) })
+	}
+	return issues, nil
+}
+
+// checkNode checks a YAML node against a schema, appending what's wrong
+func checkNode(n *yaml.Node, s *jsonSchema, path string, issues *[]SchemaIssue) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	got := nodeType(n)
+	if got == "null" {
+		// Left unset
+		return
+	}
+	report := func(at *yaml.Node, path, format string, args ...interface{}) {
+		*issues = append(*issues, SchemaIssue{Line: at.Line, Column: at.Column, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if want := schemaTypes(s); !typeAllowed(want, got) {
+		report(n, displayPath(path), "expected %s, got %s", strings.Join(want, " or "), got)
+		return
+	}
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Value == "<<" {
+				// YAML merge keys bring in the entries of an anchor
+				checkNode(v, s, path, issues)
+				continue
+			}
+			key := strings.ToLower(k.Value)
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			prop, ok := s.Properties[key]
+			if !ok {
+				prop, ok = s.AdditionalProperties.(*jsonSchema)
+			}
+			if !ok {
+				msg := fmt.Sprintf("unknown key %q", k.Value)
+				if guess := closestKey(key, s.Properties); guess != "" {
+					msg += fmt.Sprintf(", did you mean %q?", guess)
+				}
+				*issues = append(*issues, SchemaIssue{Line: k.Line, Column: k.Column, Path: keyPath, Message: msg, Unknown: true})
+				continue
+			}
+			checkNode(v, prop, keyPath, issues)
+		}
+	case yaml.SequenceNode:
+		if s.Items == nil {
+			return
+		}
+		for i, item := range n.Content {
+			checkNode(item, s.Items, fmt.Sprintf("%s[%d]", path, i), issues)
+		}
+	case yaml.ScalarNode:
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, n.Value) {
+			report(n, displayPath(path), "%q is not one of %s", n.Value, strings.Join(s.Enum, ", "))
+		}
+	}
+}
+
+// nodeType returns the JSON Schema type of a YAML node
+func nodeType(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch n.ShortTag() {
+	case "!!null":
+		return "null"
+	case "!!bool":
+		return "boolean"
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// schemaTypes lists the types a schema allows
+func schemaTypes(s *jsonSchema) []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	}
+	return nil
+}
+
+func typeAllowed(allowed []string, got string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	return slices.Contains(allowed, got) || got == "integer" && slices.Contains(allowed, "number")
+}
+
+// displayPath names the root of the config in issues
+func displayPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+// clearPositions zeroes the positions of nodes built by encoding
+func clearPositions(n *yaml.Node) {
+	n.Line, n.Column = 0, 0
+	for _, c := range n.Content {
+		clearPositions(c)
+	}
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	data, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Schema() is not valid JSON: %v", err)
+	}
+
+	props := schema["properties"].(map[string]interface{})
+	for _, key := range []string{"watch", "on_change", "tasks", "profiles", "extends", "ignore"} {
+		if _, ok := props[key]; !ok {
+			t.Errorf("schema has no %q property", key)
+		}
+	}
+	command := props["on_change"].(map[string]interface{})["properties"].(map[string]interface{})["commands"].(map[string]interface{})["items"].(map[string]interface{})
+	if _, ok := command["properties"].(map[string]interface{})["stage"]; ok {
+		t.Error("schema lists the internal stage field of commands")
+	}
+	if got := command["properties"].(map[string]interface{})["mode"].(map[string]interface{})["enum"]; !reflect.DeepEqual(got, []interface{}{"once", "restart"}) {
+		t.Errorf("mode enum = %v", got)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string
+	}{
+		{"valid", "gowatch.yaml", `
+watch:
+  - path: ./
+    recursive: true
+tasks:
+  api:
+    on_change:
+      commands:
+        - cmd: [go, test]
+          retries: 2
+`, nil},
+		{"wrong types", "gowatch.yaml", `
+watch:
+  - path: ./
+    recursive: "yes"
+max_concurrency: [1]
+`, []string{`4:16: watch[0].recursive: expected boolean, got string`, `5:18: max_concurrency: expected integer, got array`}},
+		{"unknown keys", "gowatch.yaml", `
+debonce: 1s
+tasks:
+  api:
+    ignores: ["*.log"]
+`, []string{`2:1: debonce: unknown key "debonce", did you mean "debounce"?`,
+			`5:5: tasks.api.ignores: unknown key "ignores", did you mean "ignore"?`}},
+		{"enums", "gowatch.yaml", `
+backend: inotify
+on_change:
+  commands:
+    - cmd: ["true"]
+      mode: always
+`, []string{`2:10: backend: "inotify" is not one of fsnotify, poll, watchman, fanotify`,
+			`6:13: on_change.commands[0].mode: "always" is not one of once, restart`}},
+		{"merge keys and extends", "gowatch.yaml", `
+extends: [base.yaml]
+tasks:
+  base: &base
+    debounce: 1s
+  api:
+    <<: *base
+    notify: desktop
+`, nil},
+		{"json", "gowatch.json", `{
+  "watch": [{"path": "./", "recursive": 1}]
+}`, []string{`2:41: watch[0].recursive: expected boolean, got integer`}},
+		{"toml", "gowatch.toml", `
+debounce = 5
+[[watch]]
+path = "./"
+bogus = true
+`, []string{`debounce: expected string, got integer`, `watch[0].bogus: unknown key "bogus"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			issues, err := CheckSchema(path)
+			if err != nil {
+				t.Fatalf("CheckSchema() error = %v", err)
+			}
+			var got []string
+			for _, issue := range issues {
+				got = append(got, issue.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckSchema() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSchema_Examples(t *testing.T) {
+	paths, err := filepath.Glob("../../examples/*.yaml")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no examples found: %v", err)
+	}
+	for _, path := range paths {
+		issues, err := CheckSchema(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+		for _, issue := range issues {
+			t.Errorf("%s:%s", path, issue)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// Strict fails loading a config that has keys gowatch doesn't know, such as
+// a misspelled debounce, instead of ignoring them
+var Strict bool
+
+// unknownKeys returns the keys decoding left unused, as paths such as
+// "tasks.api.ignores"
+func unknownKeys(md mapstructure.Metadata) []string {
+	keys := make([]string, 0, len(md.Unused))
+	for _, key := range md.Unused {
+		keys = append(keys, unusedPath(key))
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// unusedPath turns the map keys in a mapstructure path, "tasks[api]", into
+// path segments, "tasks.api", leaving list indexes as they are
+func unusedPath(key string) string {
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(key, '[')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(key[open:], ']')
+		if end < 0 {
+			break
+		}
+		end += open
+		b.WriteString(key[:open])
+		if inner := key[open+1 : end]; isIndex(inner) {
+			b.WriteString(key[open : end+1])
+		} else {
+			b.WriteString("." + inner)
+		}
+		key = key[end+1:]
+	}
+	b.WriteString(key)
+	return b.String()
+}
+
+func isIndex(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// unknownKeysError reports the unknown keys of a config in strict mode
+func unknownKeysError(keys []string) error {
+	described := make([]string, len(keys))
+	for i, key := range keys {
+		described[i] = DescribeUnknown(key)
+	}
+	return fmt.Errorf("unknown config keys: %s", strings.Join(described, ", "))
+}
+
+// DescribeUnknown names an unknown key along with the known key it is
+// probably a misspelling of, if any
+func DescribeUnknown(path string) string {
+	parent, key := "", path
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		parent, key = path[:i], path[i+1:]
+	}
+	s := schemaAt(configSchema(), parent)
+	if s == nil {
+		return path
+	}
+	if guess := closestKey(key, s.Properties); guess != "" {
+		return fmt.Sprintf("%s (did you mean %s?)", path, guess)
+	}
+	return path
+}
+
+// schemaAt returns the schema of the value at a path, or nil if the path
+// leaves the schema
+func schemaAt(s *jsonSchema, path string) *jsonSchema {
+	if path == "" {
+		return s
+	}
+	for _, segment := range strings.Split(path, ".") {
+		name, rest, _ := strings.Cut(segment, "[")
+		if prop, ok := s.Properties[name]; ok {
+			s = prop
+		} else if prop, ok := s.AdditionalProperties.(*jsonSchema); ok {
+			s = prop
+		} else {
+			return nil
+		}
+		// One level of items per list index
+		for ; rest != ""; _, rest, _ = strings.Cut(rest, "[") {
+			if s.Items == nil {
+				return nil
+			}
+			s = s.Items
+		}
+	}
+	return s
+}
+
+// closestKey returns the known key nearest to a misspelled one, if one is
+// close enough to be a likely typo
+func closestKey(key string, known map[string]*jsonSchema) string {
+	key = strings.ToLower(key)
+	best, bestDist := "", max(len(key)/3, 1)+1
+	for candidate := range known {
+		d := editDistance(key, candidate)
+		// Also catch a plural or singular of the key, e.g. "ignores"
+		if strings.TrimSuffix(key, "s") == strings.TrimSuffix(candidate, "s") {
+			d = 1
+		}
+		if d < bestDist || d == bestDist && candidate < best {
+			best, bestDist = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
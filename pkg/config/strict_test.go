@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadStrict(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gowatch.yaml")
+	os.WriteFile(path, []byte(`
+watch:
+  - path: "`+filepath.ToSlash(dir)+`"
+    recursiv: true
+debonce: 1s
+tasks:
+  api:
+    ignores: ["*.log"]
+    on_change:
+      commands:
+        - cmd: ["true"]
+on_change:
+  commands:
+    - cmd: ["true"]
+      tiemout: 5s
+`), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := []string{"debonce", "on_change.commands[0].tiemout", "tasks.api.ignores", "watch[0].recursiv"}
+	if !reflect.DeepEqual(cfg.Unknown, want) {
+		t.Errorf("Unknown = %v, want %v", cfg.Unknown, want)
+	}
+
+	Strict = true
+	defer func() { Strict = false }()
+	_, err = Load(path)
+	if err == nil || !strings.Contains(err.Error(), "unknown config keys: debonce (did you mean debounce?)") {
+		t.Errorf("Load() error = %v, want the unknown keys rejected", err)
+	}
+}
+
+func TestDescribeUnknown(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"debonce", "debonce (did you mean debounce?)"},
+		{"ignores", "ignores (did you mean ignore?)"},
+		{"watch[0].recursiv", "watch[0].recursiv (did you mean recursive?)"},
+		{"tasks.api.on_change.commands[2].tiemout", "tasks.api.on_change.commands[2].tiemout (did you mean timeout?)"},
+		{"profiles.ci.watch", "profiles.ci.watch"},
+		{"something", "something"},
+		{"nowhere.at.all", "nowhere.at.all"},
+	}
+
+	for _, tt := range tests {
+		if got := DescribeUnknown(tt.path); got != tt.want {
+			t.Errorf("DescribeUnknown(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
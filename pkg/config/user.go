@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// UserConfigNames are the names of the user config, searched for in
+// ~/.config/gowatch in order of preference
+var UserConfigNames = []string{"config.yaml", "config.yml", "config.toml", "config.json"}
+
+// NoUserConfig skips the user config when loading a config file
+var NoUserConfig bool
+
+// projectKeys are the settings describing a project's pipelines, which
+// have no place among personal defaults
+var projectKeys = []string{"watch", "on_change", "tasks", "profiles", "setup", "on_exit", "on_success", "on_failure"}
+
+// userDir returns ~/.config/gowatch, or "" if there is no home directory
+func userDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gowatch")
+}
+
+// UserConfigPath returns the user config file, or "" if there is none or
+// NoUserConfig is set
+func UserConfigPath() string {
+	dir := userDir()
+	if NoUserConfig || dir == "" {
+		return ""
+	}
+	for _, name := range UserConfigNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// withUserSettings merges the settings of the config file at configPath
+// over those of the user config. It returns the user config merged in, if
+// any.
+func withUserSettings(configPath string, settings map[string]interface{}) (map[string]interface{}, string, error) {
+	userPath := UserConfigPath()
+	if userPath == "" || samePath(userPath, configPath) {
+		return settings, "", nil
+	}
+
+	user, err := readSettings(userPath, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	var misplaced []string
+	for key := range user {
+		if slices.Contains(projectKeys, key) {
+			misplaced = append(misplaced, key)
+		}
+	}
+	if len(misplaced) > 0 {
+		sort.Strings(misplaced)
+		return nil, "", fmt.Errorf("%s: %s belong in a project config, not the user config", userPath, strings.Join(misplaced, ", "))
+	}
+	return mergeSettings(user, settings), userPath, nil
+}
+
+// samePath reports whether two paths name the same file
+func samePath(a, b string) bool {
+	ia, errA := os.Stat(a)
+	ib, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(ia, ib)
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadUserConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	userPath := filepath.Join(home, ".config", "gowatch", "config.yaml")
+	writeUser := func(content string) {
+		if err := os.MkdirAll(filepath.Dir(userPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(userPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	project := filepath.Join(dir, "gowatch.yaml")
+	os.WriteFile(project, []byte(`
+watch:
+  - path: "`+filepath.ToSlash(dir)+`"
+ignore: ["*.log"]
+debounce: "100ms"
+on_change:
+  commands:
+    - cmd: ["true"]
+`), 0644)
+
+	writeUser(`
+debounce: "1s"
+notify: desktop
+color: false
+ignore: ["*.swp"]
+`)
+	cfg, err := Load(project)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.UserConfig != userPath {
+		t.Errorf("UserConfig = %q, want %q", cfg.UserConfig, userPath)
+	}
+	if cfg.Debounce != "100ms" {
+		t.Errorf("Debounce = %q, want the project's 100ms", cfg.Debounce)
+	}
+	if cfg.Notify != "desktop" {
+		t.Errorf("Notify = %q, want the user's desktop", cfg.Notify)
+	}
+	if cfg.Color == nil || *cfg.Color {
+		t.Errorf("Color = %v, want false", cfg.Color)
+	}
+	if want := []string{"*.swp", "*.log"}; !reflect.DeepEqual(cfg.Ignore, want) {
+		t.Errorf("Ignore = %v, want %v", cfg.Ignore, want)
+	}
+
+	NoUserConfig = true
+	cfg, err = Load(project)
+	NoUserConfig = false
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.UserConfig != "" || cfg.Notify != "" || !reflect.DeepEqual(cfg.Ignore, []string{"*.log"}) {
+		t.Errorf("Load() with NoUserConfig merged the user config: %+v", cfg)
+	}
+
+	writeUser(`
+debounce: "1s"
+watch:
+  - path: "./"
+tasks:
+  lint:
+    debounce: "2s"
+`)
+	_, err = Load(project)
+	if err == nil || !strings.Contains(err.Error(), "tasks, watch belong in a project config") {
+		t.Errorf("Load() error = %v, want the project settings rejected", err)
+	}
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pelletier/go-toml/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+// workspaceFile is the format of a workspace file, which lists the configs
+// `gowatch run --workspace` runs side by side:
+//
+//	configs:
+//	  - api/gowatch.yaml
+//	  - web
+type workspaceFile struct {
+	Configs []string `yaml:"configs" toml:"configs"`
+}
+
+// LoadWorkspace reads a workspace file, YAML, TOML or JSON by extension,
+// and returns the config files it lists. Entries are relative to the
+// workspace file; a directory stands for the config file found in it.
+func LoadWorkspace(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ws workspaceFile
+	if formatOf(path) == "toml" {
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&ws)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err = dec.Decode(&ws); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(ws.Configs) == 0 {
+		return nil, fmt.Errorf("%s: no configs listed", path)
+	}
+
+	dir := filepath.Dir(path)
+	files := make([]string, 0, len(ws.Configs))
+	seen := make(map[string]bool)
+	for _, entry := range ws.Configs {
+		if entry == "" {
+			return nil, fmt.Errorf("%s: empty config path", path)
+		}
+		file := entry
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if info, err := os.Stat(file); err == nil && info.IsDir() {
+			if file, err = FindIn(file); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		if seen[file] {
+			return nil, fmt.Errorf("%s: %s is listed twice", path, entry)
+		}
+		seen[file] = true
+		files = append(files, file)
+	}
+	return files, nil
+}
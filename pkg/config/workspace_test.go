@@ -0,0 +1,161 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	api := write("api/gowatch.yaml", "on_change:\n  commands: [echo]\n")
+	web := write("web/gowatch.toml", "")
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "files and directories",
+			file:    "ws.yaml",
+			content: "configs:\n  - api/gowatch.yaml\n  - web\n",
+			want:    []string{api, web},
+		},
+		{
+			name:    "toml",
+			file:    "ws.toml",
+			content: "configs = [\"web/gowatch.toml\"]\n",
+			want:    []string{web},
+		},
+		{
+			name:    "json",
+			file:    "ws.json",
+			content: `{"configs": ["api"]}`,
+			want:    []string{api},
+		},
+		{
+			name:    "empty",
+			file:    "empty.yaml",
+			content: "",
+			wantErr: "no configs listed",
+		},
+		{
+			name:    "unknown key",
+			file:    "typo.yaml",
+			content: "config: [api]\n",
+			wantErr: "config not found",
+		},
+		{
+			name:    "directory without a config",
+			file:    "nodir.yaml",
+			content: "configs: [.]\n",
+			wantErr: "no config file found in",
+		},
+		{
+			name:    "listed twice",
+			file:    "twice.yaml",
+			content: "configs: [api, api/gowatch.yaml]\n",
+			wantErr: "listed twice",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadWorkspace(write(tt.file, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadWorkspace() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadWorkspace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRelative(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "api")
+	if err := os.MkdirAll(filepath.Join(sub, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(sub, "gowatch.yaml")
+	content := `
+watch:
+  - path: src
+output_dir: logs
+serve:
+  dir: public
+on_change:
+  commands:
+    - cmd: [go, build]
+tasks:
+  test:
+    watch:
+      - path: .
+    on_change:
+      commands:
+        - cmd: [go, test]
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadRelative(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Dir != sub {
+		t.Errorf("Dir = %q, want %q", cfg.Dir, sub)
+	}
+	if got, want := cfg.Watch[0].Path, filepath.Join(sub, "src"); got != want {
+		t.Errorf("watch[0].path = %q, want %q", got, want)
+	}
+	if got, want := cfg.Tasks["test"].Watch[0].Path, sub; got != want {
+		t.Errorf("tasks.test.watch[0].path = %q, want %q", got, want)
+	}
+	if got, want := cfg.OutputDir, filepath.Join(sub, "logs"); got != want {
+		t.Errorf("output_dir = %q, want %q", got, want)
+	}
+	if got, want := cfg.Serve.Dir, filepath.Join(sub, "public"); got != want {
+		t.Errorf("serve.dir = %q, want %q", got, want)
+	}
+	if got, want := cfg.HistoryFile(), filepath.Join(sub, DefaultHistoryFile); got != want {
+		t.Errorf("HistoryFile() = %q, want %q", got, want)
+	}
+	if got, want := cfg.StatusPath(), filepath.Join(sub, DefaultStatusFile); got != want {
+		t.Errorf("StatusPath() = %q, want %q", got, want)
+	}
+	if wd, _ := cfg.WorkDir(); wd != sub {
+		t.Errorf("WorkDir() = %q, want %q", wd, sub)
+	}
+
+	// LoadProfile leaves paths to the working directory
+	t.Chdir(sub)
+	cfg, err = LoadProfile("gowatch.yaml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Dir != "" || cfg.Watch[0].Path != "src" || cfg.HistoryFile() != DefaultHistoryFile {
+		t.Errorf("LoadProfile() resolved paths: dir %q, watch %q, history %q", cfg.Dir, cfg.Watch[0].Path, cfg.HistoryFile())
+	}
+}
@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Styles of SetGroups, named after the CI services whose log viewers fold
+// them
+const (
+	GroupGitHub = "github"
+	GroupGitLab = "gitlab"
+)
+
+// GroupStyles lists the styles SetGroups accepts
+var GroupStyles = []string{GroupGitHub, GroupGitLab}
+
+// groups folds the output of each command in a CI log. One group is open at
+// a time, so the output of commands running alongside it is held back until
+// they finish and then printed as a group of its own.
+type groups struct {
+	style string
+	// open is held by the group being printed
+	open sync.Mutex
+	// sections numbers GitLab's sections, whose names must be unique
+	sections atomic.Int64
+}
+
+// SetGroups folds the output of each command into a group of a CI log
+// viewer, "github" or "gitlab", or stops grouping for "". Call it before the
+// logger is used.
+func (l *Logger) SetGroups(style string) error {
+	switch style {
+	case "":
+		l.groups = nil
+	case GroupGitHub, GroupGitLab:
+		l.groups = &groups{style: style}
+	default:
+		return fmt.Errorf("invalid output group style %q (expected github or gitlab)", style)
+	}
+	return nil
+}
+
+// Group is the output of one command, folded under a title
+type Group struct {
+	l     *Logger
+	title string
+	start time.Time
+	// section names the group for GitLab
+	section string
+	// live is set while the group is open and its lines are printed as
+	// they come; held lines wait for the group to close
+	live bool
+	mu   sync.Mutex
+	held []groupLine
+	done bool
+}
+
+type groupLine struct {
+	label, line string
+	isError     bool
+}
+
+// CommandGroup starts the group of a command's output, which its lines go
+// through until End is called. It returns nil when output isn't grouped.
+func (l *Logger) CommandGroup(title string) *Group {
+	if l.groups == nil || l.hideCommandOutput {
+		return nil
+	}
+	g := &Group{l: l, title: title, start: time.Now()}
+	if l.groups.open.TryLock() {
+		g.live = true
+		g.begin()
+	}
+	return g
+}
+
+// Output prints a line of the command's output, see CommandOutput
+func (g *Group) Output(label, line string, isError bool) {
+	if g.live {
+		g.l.CommandOutput(label, line, isError)
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.held = append(g.held, groupLine{label, line, isError})
+}
+
+// End closes the group, printing the lines held back first
+func (g *Group) End() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done {
+		return
+	}
+	g.done = true
+	if !g.live {
+		g.l.groups.open.Lock()
+		g.begin()
+		for _, h := range g.held {
+			g.l.CommandOutput(h.label, h.line, h.isError)
+		}
+		g.held = nil
+	}
+	g.end()
+	g.l.groups.open.Unlock()
+}
+
+func (g *Group) begin() {
+	switch g.l.groups.style {
+	case GroupGitHub:
+		fmt.Fprintf(g.l.output, "::group::%s\n", g.title)
+	case GroupGitLab:
+		g.section = fmt.Sprintf("gowatch_%d", g.l.groups.sections.Add(1))
+		fmt.Fprintf(g.l.output, "\x1b[0Ksection_start:%d:%s\r\x1b[0K%s\n", g.start.Unix(), g.section, g.title)
+	}
+}
+
+func (g *Group) end() {
+	switch g.l.groups.style {
+	case GroupGitHub:
+		fmt.Fprintln(g.l.output, "::endgroup::")
+	case GroupGitLab:
+		fmt.Fprintf(g.l.output, "\x1b[0Ksection_end:%d:%s\r\x1b[0K\n", time.Now().Unix(), g.section)
+	}
+}
@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCommandGroup(t *testing.T) {
+	var out strings.Builder
+	l := NewWriter(&out, LevelInfo, false)
+	if err := l.SetGroups(GroupGitHub); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second group is held back until the first closes
+	build := l.CommandGroup("build")
+	vet := l.CommandGroup("go vet ./...")
+	build.Output("", "compiling", false)
+	vet.Output("", "ok", false)
+	build.Output("", "done", true)
+	ended := make(chan struct{})
+	go func() {
+		vet.End()
+		close(ended)
+	}()
+	build.End()
+	<-ended
+	build.End()
+
+	want := "::group::build\n  │ compiling\n  │ done\n::endgroup::\n" +
+		"::group::go vet ./...\n  │ ok\n::endgroup::\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestCommandGroup_GitLab(t *testing.T) {
+	var out strings.Builder
+	l := NewWriter(&out, LevelInfo, false)
+	l.SetGroups(GroupGitLab)
+	g := l.CommandGroup("build")
+	g.Output("", "compiling", false)
+	g.End()
+
+	want := regexp.MustCompile(`^\x1b\[0Ksection_start:\d+:gowatch_1\r\x1b\[0Kbuild\n  │ compiling\n\x1b\[0Ksection_end:\d+:gowatch_1\r\x1b\[0K\n$`)
+	if !want.MatchString(out.String()) {
+		t.Errorf("output = %q, want a gowatch_1 section", out.String())
+	}
+}
+
+func TestSetGroups(t *testing.T) {
+	l := NewWriter(&strings.Builder{}, LevelInfo, false)
+	if err := l.SetGroups("jenkins"); err == nil {
+		t.Error("SetGroups(jenkins) = nil, want an error")
+	}
+	if err := l.SetGroups(""); err != nil || l.CommandGroup("build") != nil {
+		t.Errorf("SetGroups(\"\") = %v, want output ungrouped", err)
+	}
+}
@@ -0,0 +1,450 @@
+// Package logger prints gowatch's colored, leveled terminal output
+package logger
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// levelNames are the names accepted by ParseLevel, indexed by level
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l >= 0 && int(l) < len(levelNames) {
+		return levelNames[l]
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// ParseLevel returns the level with the given name, case-insensitively;
+// "warning" is accepted for warn
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "warning" {
+		name = "warn"
+	}
+	for i, n := range levelNames {
+		if n == name {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("invalid log level %q (expected %s)", name, strings.Join(levelNames, ", "))
+}
+
+type Logger struct {
+	level  Level
+	output io.Writer
+	colors bool
+	// hideCommandOutput drops command output, see SetCommandOutput
+	hideCommandOutput bool
+	// status is the status line of running commands, when stdout is a
+	// terminal; output goes through it
+	status *statusLine
+	// groups folds command output in CI logs, see SetGroups
+	groups *groups
+}
+
+func New(level Level, colors bool) *Logger {
+	l := &Logger{
+		level:  level,
+		output: os.Stdout,
+		colors: colors,
+	}
+	if l.status = terminalStatus(); l.status != nil {
+		l.status.colors = colors
+		l.output = l.status
+	}
+	return l
+}
+
+// NewWriter creates a logger that writes to w instead of stdout
+func NewWriter(w io.Writer, level Level, colors bool) *Logger {
+	l := New(level, colors)
+	l.output = w
+	l.status = nil
+	return l
+}
+
+// SetCommandOutput sets whether CommandOutput prints, for front ends that
+// show command output themselves. Call it before the logger is used.
+func (l *Logger) SetCommandOutput(show bool) {
+	l.hideCommandOutput = !show
+}
+
+// SetColors turns colored output on or off, such as once a config that
+// disables it has loaded
+func (l *Logger) SetColors(colors bool) {
+	l.colors = colors
+	if l.status != nil {
+		l.status.mu.Lock()
+		l.status.colors = colors
+		l.status.mu.Unlock()
+	}
+}
+
+// Colors reports whether the logger colors its output
+func (l *Logger) Colors() bool {
+	return l.colors && !color.NoColor
+}
+
+// Discard returns a logger that drops all output
+func Discard() *Logger {
+	return NewWriter(io.Discard, LevelError, false)
+}
+
+func (l *Logger) timestamp() string {
+	return time.Now().Format("15:04:05")
+}
+
+func (l *Logger) Debug(format string, args ...interface{}) {
+	if l.level <= LevelDebug {
+		l.log(color.New(color.FgCyan), "DEBUG", format, args...)
+	}
+}
+
+func (l *Logger) Info(format string, args ...interface{}) {
+	if l.level <= LevelInfo {
+		l.log(color.New(color.FgBlue), "INFO ", format, args...)
+	}
+}
+
+func (l *Logger) Watch(format string, args ...interface{}) {
+	if l.level <= LevelInfo {
+		l.log(color.New(color.FgMagenta, color.Bold), "WATCH", format, args...)
+	}
+}
+
+func (l *Logger) Runner(format string, args ...interface{}) {
+	if l.level <= LevelInfo {
+		l.log(color.New(color.FgYellow, color.Bold), "EXEC ", format, args...)
+	}
+}
+
+func (l *Logger) Warn(format string, args ...interface{}) {
+	if l.level <= LevelWarn {
+		l.log(color.New(color.FgYellow), "WARN ", format, args...)
+	}
+}
+
+func (l *Logger) Error(format string, args ...interface{}) {
+	if l.level <= LevelError {
+		l.log(color.New(color.FgRed, color.Bold), "ERROR", format, args...)
+	}
+}
+
+func (l *Logger) Success(format string, args ...interface{}) {
+	if l.level <= LevelInfo {
+		l.log(color.New(color.FgGreen, color.Bold), "✓ OK ", format, args...)
+	}
+}
+
+func (l *Logger) Banner(title, version string) {
+	if l.level > LevelInfo {
+		return
+	}
+
+	if l.colors {
+		cyan := color.New(color.FgCyan, color.Bold)
+		magenta := color.New(color.FgMagenta)
+
+		fmt.Fprintf(l.output, "\n")
+		fmt.Fprintf(l.output, "%s\n", cyan.Sprint("╔════════════════════════════════════════════════════════════╗"))
+		fmt.Fprintf(l.output, "%s  %s %s\n",
+			cyan.Sprint("║"),
+			magenta.Sprint("🕵️  GoWatch - File Watcher & Auto-Runner"),
+			cyan.Sprint("║"))
+		fmt.Fprintf(l.output, "%s  %s%s%s\n",
+			cyan.Sprint("║"),
+			strings.Repeat(" ", 28),
+			color.New(color.Faint).Sprintf("v%s", version),
+			strings.Repeat(" ", 28-len(version)))
+		fmt.Fprintf(l.output, "%s\n", cyan.Sprint("╚════════════════════════════════════════════════════════════╝"))
+		fmt.Fprintf(l.output, "\n")
+	} else {
+		fmt.Fprintf(l.output, "\n%s v%s\n\n", title, version)
+	}
+}
+
+func (l *Logger) Section(title string) {
+	if l.level > LevelInfo {
+		return
+	}
+
+	if l.colors {
+		blue := color.New(color.FgBlue, color.Bold)
+		fmt.Fprintf(l.output, "\n%s\n", blue.Sprintf("── %s ──", title))
+	} else {
+		fmt.Fprintf(l.output, "\n-- %s --\n", title)
+	}
+}
+
+// labelColors are assigned to command labels by name, so a command keeps
+// its color across runs
+var labelColors = []color.Attribute{
+	color.FgCyan,
+	color.FgYellow,
+	color.FgGreen,
+	color.FgMagenta,
+	color.FgBlue,
+	color.FgHiCyan,
+	color.FgHiYellow,
+	color.FgHiGreen,
+	color.FgHiMagenta,
+	color.FgHiBlue,
+}
+
+// styleAttributes are the words of a style, see ParseStyle
+var styleAttributes = map[string]color.Attribute{
+	"bold":      color.Bold,
+	"faint":     color.Faint,
+	"italic":    color.Italic,
+	"underline": color.Underline,
+	"reverse":   color.ReverseVideo,
+}
+
+func init() {
+	colors := []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+	for i, name := range colors {
+		styleAttributes[name] = color.FgBlack + color.Attribute(i)
+		styleAttributes["bright-"+name] = color.FgHiBlack + color.Attribute(i)
+		styleAttributes["on-"+name] = color.BgBlack + color.Attribute(i)
+	}
+}
+
+// ParseStyle checks a style of space-separated words, such as "red bold":
+// the eight terminal colors, their "bright-" and "on-" (background) forms,
+// and bold, faint, italic, underline and reverse
+func ParseStyle(style string) error {
+	words := strings.Fields(style)
+	if len(words) == 0 {
+		return fmt.Errorf("empty style")
+	}
+	for _, word := range words {
+		if _, ok := styleAttributes[strings.ToLower(word)]; !ok {
+			return fmt.Errorf("unknown style %q", word)
+		}
+	}
+	return nil
+}
+
+// Style renders s in a style ParseStyle accepts, when colors are on
+func (l *Logger) Style(style, s string) string {
+	if !l.colors {
+		return s
+	}
+	var attrs []color.Attribute
+	for _, word := range strings.Fields(style) {
+		if attr, ok := styleAttributes[strings.ToLower(word)]; ok {
+			attrs = append(attrs, attr)
+		}
+	}
+	return color.New(attrs...).Sprint(s)
+}
+
+// Hyperlink makes text a link to url, an OSC 8 hyperlink that terminals
+// supporting them open on click, when colors are on; others show the text
+func (l *Logger) Hyperlink(url, text string) string {
+	if !l.Colors() {
+		return text
+	}
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+func labelColor(name string) *color.Color {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return color.New(labelColors[h.Sum32()%uint32(len(labelColors))])
+}
+
+// CommandOutput prints a line of command output. A non-empty label, such as
+// the command's name, tags the line as "[label]" in a color derived from it.
+// Command output is not gowatch's own logging and is shown at every level.
+func (l *Logger) CommandOutput(label, line string, isError bool) {
+	if l.hideCommandOutput {
+		return
+	}
+	prefix := "  │ "
+	tag := ""
+	if label != "" {
+		// Labels come padded to a common width; keep the padding outside
+		// the brackets so that the lines start in the same column
+		name := strings.TrimRight(label, " ")
+		tag = "[" + name + "]" + label[len(name):] + " "
+		if l.colors {
+			tag = labelColor(name).Sprint(tag)
+		}
+	}
+
+	if l.colors {
+		if isError {
+			fmt.Fprintf(l.output, "%s%s%s\n",
+				color.New(color.Faint).Sprint(prefix),
+				tag,
+				color.New(color.FgRed).Sprint(line))
+		} else {
+			fmt.Fprintf(l.output, "%s%s%s\n",
+				color.New(color.FgCyan, color.Faint).Sprint(prefix),
+				tag,
+				line)
+		}
+	} else {
+		fmt.Fprintf(l.output, "%s%s%s\n", prefix, tag, line)
+	}
+}
+
+// Plain prints a line as is, without timestamp or prefix, for output that
+// tools read, such as editors. It is shown at every level.
+func (l *Logger) Plain(line string) {
+	fmt.Fprintln(l.output, line)
+}
+
+func (l *Logger) Separator() {
+	if l.level > LevelInfo {
+		return
+	}
+
+	if l.colors {
+		fmt.Fprintf(l.output, "%s\n", color.New(color.Faint).Sprint(strings.Repeat("─", 60)))
+	} else {
+		fmt.Fprintf(l.output, "%s\n", strings.Repeat("-", 60))
+	}
+}
+
+// Table prints rows aligned in columns below a header, without timestamps
+func (l *Logger) Table(header []string, rows [][]string) {
+	if l.level > LevelInfo {
+		return
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	format := func(row []string) string {
+		var b strings.Builder
+		b.WriteString("  ")
+		for i, cell := range row {
+			b.WriteString(cell)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2))
+			}
+		}
+		return b.String()
+	}
+
+	head := format(header)
+	if l.colors {
+		head = color.New(color.Bold).Sprint(head)
+	}
+	fmt.Fprintln(l.output, head)
+	for _, row := range rows {
+		fmt.Fprintln(l.output, format(row))
+	}
+}
+
+func (l *Logger) CommandStart(cmd string) {
+	if l.level > LevelInfo {
+		return
+	}
+
+	if l.colors {
+		fmt.Fprintf(l.output, "%s %s %s\n",
+			color.New(color.FgYellow, color.Bold).Sprint("▶"),
+			color.New(color.FgWhite).Sprint("Running:"),
+			color.New(color.FgCyan).Sprint(cmd))
+	} else {
+		fmt.Fprintf(l.output, "▶ Running: %s\n", cmd)
+	}
+}
+
+// CommandRunning shows a command as running, with a spinner and its
+// elapsed time on the status line until the returned function is called,
+// which is before its CommandEnd. Without a status line, when stdout isn't
+// a terminal, it prints CommandStart's line instead.
+func (l *Logger) CommandRunning(name, cmd string) (done func()) {
+	if l.status == nil || l.level > LevelInfo {
+		l.CommandStart(cmd)
+		return func() {}
+	}
+	return l.status.add(name)
+}
+
+// PauseStatus clears the status line until the returned function is called,
+// while a command has the terminal
+func (l *Logger) PauseStatus() (resume func()) {
+	if l.status == nil {
+		return func() {}
+	}
+	return l.status.pause()
+}
+
+// CommandEnd reports a finished command; failures are logged as errors
+func (l *Logger) CommandEnd(cmd string, exitCode int, duration time.Duration) {
+	if exitCode == 0 && l.level > LevelInfo {
+		return
+	}
+
+	durationStr := l.formatDuration(duration)
+
+	if l.colors {
+		if exitCode == 0 {
+			fmt.Fprintf(l.output, "%s %s %s %s\n",
+				color.New(color.FgGreen, color.Bold).Sprint("✓"),
+				color.New(color.FgGreen).Sprint("Completed:"),
+				color.New(color.Faint).Sprint(cmd),
+				color.New(color.FgGreen, color.Faint).Sprintf("(%s)", durationStr))
+		} else {
+			fmt.Fprintf(l.output, "%s %s %s %s %s\n",
+				color.New(color.FgRed, color.Bold).Sprint("✗"),
+				color.New(color.FgRed).Sprint("Failed:"),
+				color.New(color.Faint).Sprint(cmd),
+				color.New(color.FgRed).Sprintf("(exit: %d)", exitCode),
+				color.New(color.Faint).Sprintf("(%s)", durationStr))
+		}
+	} else {
+		if exitCode == 0 {
+			fmt.Fprintf(l.output, "✓ Completed: %s (%s)\n", cmd, durationStr)
+		} else {
+			fmt.Fprintf(l.output, "✗ Failed: %s (exit: %d) (%s)\n", cmd, exitCode, durationStr)
+		}
+	}
+}
+
+func (l *Logger) formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.2fs", d.Seconds())
+}
+
+func (l *Logger) log(c *color.Color, prefix, format string, args ...interface{}) {
+	timestamp := l.timestamp()
+	msg := fmt.Sprintf(format, args...)
+
+	if l.colors {
+		fmt.Fprintf(l.output, "%s %s %s\n",
+			color.New(color.Faint).Sprint(timestamp),
+			c.Sprintf("[%s]", prefix),
+			msg)
+	} else {
+		fmt.Fprintf(l.output, "%s [%s] %s\n", timestamp, prefix, msg)
+	}
+}
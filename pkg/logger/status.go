@@ -0,0 +1,173 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// spinnerFrames are drawn in turn on the status line
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+const (
+	// spinnerInterval is how often the status line is redrawn
+	spinnerInterval = 100 * time.Millisecond
+	// statusWidth caps the status line so that it never wraps, which
+	// would leave a copy of it behind at every redraw
+	statusWidth = 79
+)
+
+// statusLine is the last line of a terminal, showing the commands running
+// with a spinner and their elapsed time while output scrolls above it.
+// Output written through it clears the line first and redraws it after.
+type statusLine struct {
+	mu      sync.Mutex
+	out     io.Writer
+	colors  bool
+	running []*runningCommand
+	frame   int
+	drawn   bool
+	// paused is how many callers have the terminal to themselves
+	paused int
+	// ticking is set while the spinner is redrawn
+	ticking bool
+}
+
+// runningCommand is a command shown on the status line
+type runningCommand struct {
+	name  string
+	start time.Time
+}
+
+// terminalStatus returns the status line of stdout, or nil when stdout
+// isn't a terminal
+func terminalStatus() *statusLine {
+	fd := os.Stdout.Fd()
+	if !isatty.IsTerminal(fd) && !isatty.IsCygwinTerminal(fd) {
+		return nil
+	}
+	return &statusLine{out: os.Stdout}
+}
+
+func (s *statusLine) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clear()
+	n, err := s.out.Write(p)
+	s.draw()
+	return n, err
+}
+
+// add shows a command on the status line until the returned function is
+// called
+func (s *statusLine) add(name string) func() {
+	c := &runningCommand{name: name, start: time.Now()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = append(s.running, c)
+	s.redraw()
+	if !s.ticking {
+		s.ticking = true
+		go s.tick()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for i, r := range s.running {
+				if r == c {
+					s.running = append(s.running[:i], s.running[i+1:]...)
+					break
+				}
+			}
+			s.redraw()
+		})
+	}
+}
+
+// pause clears the status line until the returned function is called, for
+// a command that has the terminal
+func (s *statusLine) pause() func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused++
+	s.clear()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.paused--
+			s.draw()
+		})
+	}
+}
+
+// tick advances the spinner until no command is running
+func (s *statusLine) tick() {
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.mu.Lock()
+		if len(s.running) == 0 {
+			s.ticking = false
+			s.mu.Unlock()
+			return
+		}
+		s.frame++
+		s.redraw()
+		s.mu.Unlock()
+	}
+}
+
+func (s *statusLine) redraw() {
+	s.clear()
+	s.draw()
+}
+
+// clear erases the status line, leaving the cursor at its start
+func (s *statusLine) clear() {
+	if s.drawn {
+		io.WriteString(s.out, "\r\x1b[K")
+		s.drawn = false
+	}
+}
+
+// draw writes the status line, without a newline so that it stays the
+// last line
+func (s *statusLine) draw() {
+	if s.drawn || s.paused > 0 || len(s.running) == 0 {
+		return
+	}
+	names := make([]string, len(s.running))
+	for i, c := range s.running {
+		names[i] = fmt.Sprintf("%s %s", c.name, time.Since(c.start).Truncate(spinnerInterval))
+	}
+	spinner := string(spinnerFrames[s.frame%len(spinnerFrames)])
+	text := truncate("Running: "+strings.Join(names, ", "), statusWidth-2)
+	if s.colors {
+		spinner = color.New(color.FgYellow, color.Bold).Sprint(spinner)
+		text = color.New(color.Faint).Sprint(text)
+	}
+	io.WriteString(s.out, spinner+" "+text)
+	s.drawn = true
+}
+
+// truncate shortens s to at most width characters, ending it with an
+// ellipsis if it was longer
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
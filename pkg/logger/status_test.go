@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStatusLine(t *testing.T) {
+	var out bytes.Buffer
+	s := &statusLine{out: &out}
+	// output reads what was written since the last call
+	output := func() string {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		defer out.Reset()
+		return out.String()
+	}
+
+	done := s.add("build")
+	if got := output(); !strings.HasPrefix(got, "⠋ Running: build 0s") || strings.Contains(got, "\n") {
+		t.Errorf("status line = %q, want the running command without a newline", got)
+	}
+
+	s.Write([]byte("  │ compiling\n"))
+	got := output()
+	if !strings.HasPrefix(got, "\r\x1b[K  │ compiling\n") || !strings.Contains(got, "Running: build") {
+		t.Errorf("output = %q, want the line cleared, the output and the line again", got)
+	}
+
+	resume := s.pause()
+	s.Write([]byte("prompt\n"))
+	if got := output(); got != "\r\x1b[Kprompt\n" {
+		t.Errorf("paused output = %q, want no status line", got)
+	}
+	resume()
+	if got := output(); !strings.Contains(got, "Running: build") {
+		t.Errorf("resumed output = %q, want the status line", got)
+	}
+
+	done()
+	done()
+	s.Write([]byte("✓ Completed\n"))
+	if got := output(); got != "\r\x1b[K✓ Completed\n" {
+		t.Errorf("output = %q, want the status line replaced", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"build 1s", 10, "build 1s"},
+		{"build 1s, test 2s", 10, "build 1s,…"},
+		{"⠋ éèà", 4, "⠋ é…"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.in, tt.width); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gowatch/pkg/config"
+)
+
+// healthPollInterval is how often a restarted service's state is checked
+const healthPollInterval = 500 * time.Millisecond
+
+// resolve fills in the command line of commands that restart a service
+func resolve(cmd config.Command) config.Command {
+	if cmd.RestartService != "" {
+		cmd.Cmd = cmd.ComposeCommand()
+		cmd.Cmd[0] = dockerCommand
+	}
+	return cmd
+}
+
+// serviceContainer is the state of one container of a compose service as
+// reported by "docker compose ps"
+type serviceContainer struct {
+	Name   string
+	State  string
+	Health string
+}
+
+// waitHealthy waits until every container of a restarted service is running
+// and, if it has a health check, healthy. It fails if one stops or turns
+// unhealthy, or when ctx ends.
+func (r *Runner) waitHealthy(ctx context.Context, cmd config.Command, out *commandOutput) error {
+	start := time.Now()
+	waiting := false
+	for {
+		containers, err := serviceState(ctx, cmd)
+		if err != nil {
+			return err
+		}
+
+		ready := len(containers) > 0
+		for _, c := range containers {
+			switch {
+			case c.Health == "unhealthy":
+				return fmt.Errorf("service %s is unhealthy (%s)", cmd.RestartService, c.Name)
+			case c.State == "exited" || c.State == "dead":
+				return fmt.Errorf("service %s is %s (%s)", cmd.RestartService, c.State, c.Name)
+			case c.State != "running" || (c.Health != "" && c.Health != "healthy"):
+				ready = false
+			}
+		}
+		if ready {
+			if waiting {
+				r.log.Success("Service %s is healthy (%s)", cmd.RestartService, time.Since(start).Round(time.Millisecond))
+				out.writeLine(fmt.Sprintf("service %s is healthy", cmd.RestartService))
+			}
+			return nil
+		}
+		if !waiting {
+			r.log.Runner("Waiting for service %s to become healthy", cmd.RestartService)
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("service %s did not become healthy: %w", cmd.RestartService, ctx.Err())
+		case <-time.After(healthPollInterval):
+		}
+	}
+}
+
+// serviceState lists the containers of a command's service
+func serviceState(ctx context.Context, cmd config.Command) ([]serviceContainer, error) {
+	args := []string{"compose"}
+	if cmd.ComposeFile != "" {
+		args = append(args, "-f", cmd.ComposeFile)
+	}
+	args = append(args, "ps", "--all", "--format", "json", cmd.RestartService)
+
+	output, err := exec.CommandContext(ctx, dockerCommand, args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("docker compose ps: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("docker compose ps: %w", err)
+	}
+	return parseComposePS(output)
+}
+
+// parseComposePS parses the JSON output of "docker compose ps": an array
+// in older releases of Compose, one object per line in newer ones
+func parseComposePS(output []byte) ([]serviceContainer, error) {
+	output = bytes.TrimSpace(output)
+	var containers []serviceContainer
+	if bytes.HasPrefix(output, []byte("[")) {
+		if err := json.Unmarshal(output, &containers); err != nil {
+			return nil, fmt.Errorf("unexpected docker compose ps output: %w", err)
+		}
+		return containers, nil
+	}
+
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var c serviceContainer
+		if err := json.Unmarshal(line, &c); err != nil {
+			return nil, fmt.Errorf("unexpected docker compose ps output: %w", err)
+		}
+		containers = append(containers, c)
+	}
+	return containers, nil
+}
@@ -0,0 +1,258 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gowatch/pkg/config"
+)
+
+// dockerCommand is the docker CLI used for commands with a container
+var dockerCommand = "docker"
+
+// containerSignalTimeout bounds the docker exec that signals a command
+const containerSignalTimeout = 5 * time.Second
+
+// pidScript starts a command in a container through sh, printing the PID
+// it will have first; the arguments follow it
+const pidScript = `echo $$; exec "$@"`
+
+// pathMapping maps a host directory to a directory in a container
+type pathMapping struct {
+	host      string
+	container string
+}
+
+// pathMap translates host paths to the paths a container sees them at
+type pathMap []pathMapping
+
+// toContainer returns where the host path p is in the container, using the
+// most specific mapping that contains it
+func (m pathMap) toContainer(p string) (string, bool) {
+	if p == "" {
+		return "", false
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p, false
+	}
+
+	best, bestLen := "", -1
+	for _, pm := range m {
+		rel, err := filepath.Rel(pm.host, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if len(pm.host) > bestLen {
+			best, bestLen = path.Join(pm.container, filepath.ToSlash(rel)), len(pm.host)
+		}
+	}
+	if bestLen < 0 {
+		return p, false
+	}
+	return best, true
+}
+
+// mapPath returns p as seen by the command: translated into its container
+// if it runs in one, unchanged otherwise
+func (t Trigger) mapPath(p string) string {
+	if t.paths == nil {
+		return p
+	}
+	mapped, _ := t.paths.toContainer(p)
+	return mapped
+}
+
+// mappedFiles returns the changed files as seen by the command
+func (t Trigger) mappedFiles() []string {
+	files := t.files()
+	if t.paths == nil {
+		return files
+	}
+	mapped := make([]string, len(files))
+	for i, f := range files {
+		mapped[i] = t.mapPath(f)
+	}
+	return mapped
+}
+
+// containerPaths returns the path mappings of a command's container: its
+// container_paths if set, the container's bind mounts otherwise. Mounts
+// are looked up once per container.
+func (r *Runner) containerPaths(ctx context.Context, cmd config.Command) pathMap {
+	m := pathMap{}
+	if len(cmd.ContainerPaths) > 0 {
+		for _, entry := range cmd.ContainerPaths {
+			host, ctr, _ := config.ParseContainerPath(entry)
+			if abs, err := r.config().Abs(host); err == nil {
+				m = append(m, pathMapping{host: abs, container: ctr})
+			}
+		}
+		return m
+	}
+
+	r.mu.Lock()
+	cached, ok := r.mounts[cmd.Container]
+	r.mu.Unlock()
+	if ok {
+		return cached
+	}
+
+	m, err := inspectMounts(ctx, cmd.Container)
+	if err != nil {
+		r.log.Warn("Failed to read the mounts of container %s, paths are passed unchanged (set container_paths): %v", cmd.Container, err)
+		return pathMap{}
+	}
+	r.mu.Lock()
+	r.mounts[cmd.Container] = m
+	r.mu.Unlock()
+	return m
+}
+
+// inspectMounts reads the bind mounts of a container
+func inspectMounts(ctx context.Context, container string) (pathMap, error) {
+	out, err := exec.CommandContext(ctx, dockerCommand, "inspect", "--format", "{{json .Mounts}}", container).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+
+	var mounts []struct {
+		Type        string
+		Source      string
+		Destination string
+	}
+	if err := json.Unmarshal(out, &mounts); err != nil {
+		return nil, fmt.Errorf("unexpected docker inspect output: %w", err)
+	}
+	m := pathMap{}
+	for _, mount := range mounts {
+		if mount.Type == "bind" {
+			m = append(m, pathMapping{host: filepath.Clean(mount.Source), container: mount.Destination})
+		}
+	}
+	return m, nil
+}
+
+// containerCommand prepares a docker exec of argv in the command's
+// container. The environment and working directory are passed to the
+// container rather than set on the docker client.
+func (r *Runner) containerCommand(ctx context.Context, cmd config.Command, t Trigger, argv []string) (*exec.Cmd, *containerExec) {
+	values := r.placeholderValues(t)
+	env := triggerEnv(t, ":")
+	for _, kv := range cmd.Env {
+		env = append(env, expandPlaceholders(kv, values))
+	}
+
+	// Without a cwd the command starts in the directory of the config, where
+	// gowatch runs unless it is part of a workspace, if the container can
+	// see that directory
+	var workdir string
+	if cmd.Cwd != "" {
+		workdir = t.mapPath(r.workDir(cmd, values))
+	} else if wd, err := r.config().WorkDir(); err == nil {
+		workdir, _ = t.paths.toContainer(wd)
+	}
+
+	if shell := r.shellFor(cmd); shell != "" && shell != config.ShellNone {
+		argv = shellArgv(shell, argv)
+	}
+	command := exec.CommandContext(ctx, dockerCommand, containerArgs(cmd.Container, workdir, env, argv, cmd.Interactive, cmd.PTY)...)
+	return command, &containerExec{name: cmd.Container}
+}
+
+// containerArgs returns the docker arguments that run argv in a container,
+// keeping stdin open for interactive commands and allocating a
+// pseudo-terminal for those with pty
+func containerArgs(container, workdir string, env, argv []string, interactive, tty bool) []string {
+	args := []string{"exec"}
+	if interactive {
+		args = append(args, "-i")
+	}
+	if tty {
+		args = append(args, "-t")
+	}
+	if workdir != "" {
+		args = append(args, "-w", workdir)
+	}
+	for _, kv := range env {
+		args = append(args, "-e", kv)
+	}
+	args = append(args, container, "sh", "-c", pidScript, "sh")
+	return append(args, argv...)
+}
+
+// containerExec is a command running in a container. docker exec doesn't
+// forward signals, so the command reports its PID in the container on the
+// first line of its output, which is held back, and is signalled with kill
+// in the container.
+type containerExec struct {
+	name string
+
+	mu     sync.Mutex
+	stdout io.Writer
+	buf    []byte
+	pid    string
+	gotPID bool
+}
+
+// Write passes output on to stdout once the PID line has been read
+func (c *containerExec) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	if c.gotPID {
+		c.mu.Unlock()
+		return c.stdout.Write(p)
+	}
+
+	c.buf = append(c.buf, p...)
+	i := bytes.IndexByte(c.buf, '\n')
+	if i < 0 {
+		c.mu.Unlock()
+		return len(p), nil
+	}
+	c.pid = strings.TrimSpace(string(c.buf[:i]))
+	c.gotPID = true
+	rest := c.buf[i+1:]
+	c.buf = nil
+	c.mu.Unlock()
+
+	if len(rest) > 0 {
+		c.stdout.Write(rest)
+	}
+	return len(p), nil
+}
+
+// signal sends the named signal to the command inside the container
+func (c *containerExec) signal(name string) error {
+	c.mu.Lock()
+	pid := c.pid
+	c.mu.Unlock()
+	if pid == "" {
+		return fmt.Errorf("PID in container %s unknown", c.name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), containerSignalTimeout)
+	defer cancel()
+	return exec.CommandContext(ctx, dockerCommand, "exec", c.name, "kill", "-s", strings.TrimPrefix(name, "SIG"), pid).Run()
+}
+
+// signalCommand sends the named signal to a command, inside its container if
+// it runs in one. If that fails the docker client is signalled instead.
+func signalCommand(proc *os.Process, ctr *containerExec, name string) error {
+	if ctr != nil && ctr.signal(name) == nil {
+		return nil
+	}
+	return signalProcess(proc, name)
+}
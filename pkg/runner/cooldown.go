@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"gowatch/pkg/config"
+)
+
+// cooldown tracks when a command configured with a cooldown may run again
+// and the changes that arrived while it couldn't
+type cooldown struct {
+	until   time.Time
+	pending *Trigger
+}
+
+// coolingDown reports whether the command at idx must wait before running
+// for t. If so, t is merged into the changes pending for it. Otherwise the
+// command is assumed to run now and its next cooldown starts.
+func (r *Runner) coolingDown(idx int, cmd config.Command, t Trigger) bool {
+	d := cmd.GetCooldown()
+	if d <= 0 {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	c := r.cooldowns[idx]
+	if c == nil {
+		c = &cooldown{}
+		r.cooldowns[idx] = c
+	}
+	// Manual runs and coalesced runs are never held back
+	if now.Before(c.until) && t.Path != "" && t.only == nil {
+		c.pending = mergeTriggers(c.pending, t)
+		r.log.Info("Cooling down: %s (runs in %s)", strings.Join(cmd.Cmd, " "), c.until.Sub(now).Round(time.Second))
+		return true
+	}
+	c.until = now.Add(d)
+	c.pending = nil
+	return false
+}
+
+// NextPending returns when the earliest cooldown with pending changes ends
+func (r *Runner) NextPending() (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var next time.Time
+	for _, c := range r.cooldowns {
+		if c.pending != nil && (next.IsZero() || c.until.Before(next)) {
+			next = c.until
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// TakePending returns a trigger that coalesces the changes skipped by
+// commands whose cooldown has ended; running it runs only those commands.
+// It returns false if there is nothing to run yet.
+func (r *Runner) TakePending() (Trigger, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var merged *Trigger
+	only := make(map[int]bool)
+	for idx, c := range r.cooldowns {
+		if c.pending == nil || now.Before(c.until) {
+			continue
+		}
+		merged = mergeTriggers(merged, *c.pending)
+		only[idx] = true
+		c.pending = nil
+	}
+	if merged == nil {
+		return Trigger{}, false
+	}
+
+	t := *merged
+	t.RunID = 0
+	t.only = only
+	return t, true
+}
+
+// mergeTriggers combines the changes of two triggers; the later one
+// provides the path and event
+func mergeTriggers(prev *Trigger, t Trigger) *Trigger {
+	merged := t
+	merged.outcome = nil
+	merged.output = nil
+	merged.only = nil
+	if prev == nil {
+		merged.Files = slices.Clone(t.files())
+		merged.Ops = slices.Clone(t.ops())
+		merged.Tags = slices.Clone(t.Tags)
+		return &merged
+	}
+
+	merged.Files = slices.Clone(prev.Files)
+	for _, f := range t.files() {
+		if !slices.Contains(merged.Files, f) {
+			merged.Files = append(merged.Files, f)
+		}
+	}
+	merged.Ops = slices.Clone(prev.Ops)
+	for _, op := range t.ops() {
+		if !slices.Contains(merged.Ops, op) {
+			merged.Ops = append(merged.Ops, op)
+		}
+	}
+	merged.Tags = slices.Clone(prev.Tags)
+	for _, tag := range t.Tags {
+		if !slices.Contains(merged.Tags, tag) {
+			merged.Tags = append(merged.Tags, tag)
+		}
+	}
+	if t.Time.Before(prev.Time) {
+		merged.Path, merged.Event, merged.Time = prev.Path, prev.Event, prev.Time
+	}
+	return &merged
+}
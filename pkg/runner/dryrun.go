@@ -0,0 +1,140 @@
+package runner
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gowatch/pkg/config"
+)
+
+// skippedCommand is a command left out of a run by its events or match
+// rules
+type skippedCommand struct {
+	indexedCommand
+	reason string
+}
+
+// logPlan describes a dry run before its commands are reported: how they
+// are scheduled and, in the order they would start, the exact argv of each
+// with its working directory and the environment it gets on top of
+// gowatch's
+func (r *Runner) logPlan(cfg *config.Config, commands []indexedCommand, skipped []skippedCommand, t Trigger) {
+	r.log.Info("[DRY-RUN] %s", r.schedule(cfg, commands))
+	for n, c := range r.startOrder(cfg, commands) {
+		p := r.plan(c.idx, c.cmd, t)
+		r.log.Info("  %d. %s", n+1, commandTitle(c))
+		if details := r.scheduling(cfg, c.cmd); details != "" {
+			r.log.Info("     %s", details)
+		}
+		argv := make([]string, len(p.Command))
+		for i, arg := range p.Command {
+			argv[i] = strconv.Quote(arg)
+		}
+		r.log.Info("     argv: %s", strings.Join(argv, " "))
+		if p.Dir != "" {
+			r.log.Info("     cwd:  %s", p.Dir)
+		}
+		if c.cmd.Container != "" {
+			r.log.Info("     in:   %s (paths are mapped when it runs)", c.cmd.Container)
+		}
+		for i, kv := range r.injectedEnv(c.idx, c.cmd, t) {
+			if i == 0 {
+				r.log.Info("     env:  %s", kv)
+			} else {
+				r.log.Info("           %s", kv)
+			}
+		}
+	}
+	r.logSkipped(skipped)
+	r.log.Separator()
+}
+
+// logSkipped reports the commands a dry run leaves out and why
+func (r *Runner) logSkipped(skipped []skippedCommand) {
+	for _, s := range skipped {
+		r.log.Info("[DRY-RUN] Would skip %s: %s", commandTitle(s.indexedCommand), s.reason)
+	}
+}
+
+// schedule describes how a run executes its commands, following the
+// branches of RunTrigger
+func (r *Runner) schedule(cfg *config.Config, commands []indexedCommand) string {
+	n := len(commands)
+	switch {
+	case cfg.OnChange.HasDependencies():
+		limit := cfg.MaxConcurrency
+		if r.sequential {
+			limit = 1
+		}
+		return fmt.Sprintf("%s as a dependency graph, up to %s at once, higher priority first", plural(n, "command"), plural(limit, "slot"))
+	case r.sequential:
+		return fmt.Sprintf("%s one after the other, stopping at the first failure", plural(n, "command"))
+	case cfg.OnChange.HasStages():
+		stages := 0
+		for i, c := range commands {
+			if i == 0 || c.cmd.Stage != commands[i-1].cmd.Stage {
+				stages++
+			}
+		}
+		return fmt.Sprintf("%s in %s, up to %s at once within a stage, higher priority first", plural(n, "command"), plural(stages, "stage"), plural(cfg.MaxConcurrency, "slot"))
+	default:
+		return fmt.Sprintf("%s in parallel, up to %s at once, higher priority first", plural(n, "command"), plural(cfg.MaxConcurrency, "slot"))
+	}
+}
+
+// startOrder returns the commands in the order a run asks for slots: by
+// priority within each stage, or as configured when sequential
+func (r *Runner) startOrder(cfg *config.Config, commands []indexedCommand) []indexedCommand {
+	ordered := slices.Clone(commands)
+	if r.sequential && !cfg.OnChange.HasDependencies() {
+		return ordered
+	}
+	// Stages are contiguous, so sorting by stage keeps them in order
+	slices.SortStableFunc(ordered, func(a, b indexedCommand) int {
+		if a.cmd.Stage != b.cmd.Stage {
+			return a.cmd.Stage - b.cmd.Stage
+		}
+		return b.cmd.Priority - a.cmd.Priority
+	})
+	return ordered
+}
+
+// scheduling lists what places a command in the schedule: its stage,
+// dependencies, priority and weight
+func (r *Runner) scheduling(cfg *config.Config, cmd config.Command) string {
+	var parts []string
+	if cmd.Stage > 0 && !r.sequential {
+		parts = append(parts, "stage "+cfg.OnChange.StageName(cmd))
+	}
+	if len(cmd.DependsOn) > 0 {
+		parts = append(parts, "after "+strings.Join(cmd.DependsOn, ", "))
+	}
+	if cmd.Priority != 0 {
+		parts = append(parts, "priority "+strconv.Itoa(cmd.Priority))
+	}
+	if cmd.GetWeight() > 1 {
+		parts = append(parts, "weight "+strconv.Itoa(cmd.GetWeight()))
+	}
+	if cmd.IsRestart() {
+		parts = append(parts, "restarts")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// commandTitle names a command in dry-run output
+func commandTitle(c indexedCommand) string {
+	title := fmt.Sprintf("on_change.commands[%d]", c.idx)
+	if c.cmd.Name != "" {
+		title += " (" + c.cmd.Name + ")"
+	}
+	return title
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"syscall"
+
+	"gowatch/pkg/config"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioprio_set arguments, from linux/ioprio.h
+const (
+	ioprioWhoProcess = 1
+	ioprioWhoPgrp    = 2
+	ioprioClassShift = 13
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+)
+
+// setIONice sets the I/O scheduling class of a process, or of its group.
+// Best-effort takes the level the kernel derives from the nice value.
+func setIONice(pid int, group bool, cmd config.Command) error {
+	who := ioprioWhoProcess
+	if group {
+		who = ioprioWhoPgrp
+	}
+	prio := ioprioClassIdle << ioprioClassShift
+	if cmd.IONice == config.IONiceBestEffort {
+		prio = ioprioClassBE<<ioprioClassShift | (cmd.Nice+20)/5
+	}
+	if _, _, errno := syscall.Syscall(unix.SYS_IOPRIO_SET, uintptr(who), uintptr(pid), uintptr(prio)); errno != 0 {
+		return errno
+	}
+	return nil
+}
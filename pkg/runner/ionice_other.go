@@ -0,0 +1,10 @@
+//go:build !linux
+
+package runner
+
+import "gowatch/pkg/config"
+
+// setIONice is a no-op: I/O classes are Linux only
+func setIONice(pid int, group bool, cmd config.Command) error {
+	return nil
+}
@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gowatch/pkg/config"
+)
+
+// cgroupMount is where the cgroup v2 hierarchy is mounted on Linux
+const cgroupMount = "/sys/fs/cgroup"
+
+// cpuPeriod is the period, in microseconds, that the CPU limit of a command
+// is a quota of
+const cpuPeriod = 100000
+
+// ownCgroup returns the cgroup v2 path of a process from its
+// /proc/<pid>/cgroup, e.g. "/user.slice/user-1000.slice/session-2.scope"
+func ownCgroup(procCgroup string) (string, error) {
+	for line := range strings.SplitSeq(procCgroup, "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", errors.New("cgroup v2 is not in use")
+}
+
+// limitControllers returns the cgroup controllers that enforce limits
+func limitControllers(l config.Limits) []string {
+	var controllers []string
+	if l.Memory != "" {
+		controllers = append(controllers, "memory")
+	}
+	if l.CPU > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	return controllers
+}
+
+// createCgroup creates a cgroup for a command in the hierarchy mounted at
+// mount. A cgroup with processes, such as that of gowatch, self, can't
+// have children with controllers, so it goes under the nearest ancestor
+// that delegates the controllers to its children and lets gowatch move
+// processes into them, as systemd does for user sessions.
+func createCgroup(mount, self string, controllers []string) (string, error) {
+	mount = filepath.Clean(mount)
+	dir := filepath.Join(mount, self)
+	for {
+		if delegates(dir, controllers) {
+			if cgroup, err := os.MkdirTemp(dir, "gowatch-"); err == nil {
+				return cgroup, nil
+			}
+		}
+		if dir == mount {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	return "", fmt.Errorf("no cgroup above gowatch's delegates the %s controllers", strings.Join(controllers, " and "))
+}
+
+// delegates reports whether a cgroup enables the controllers for its
+// children and gowatch may move processes into them
+func delegates(dir string, controllers []string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.subtree_control"))
+	if err != nil {
+		return false
+	}
+	enabled := strings.Fields(string(data))
+	for _, c := range controllers {
+		if !slices.Contains(enabled, c) {
+			return false
+		}
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "cgroup.procs"), os.O_WRONLY, 0)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+// writeLimits sets the limits of a cgroup. Going over the memory limit
+// kills the whole cgroup rather than one of its processes, and swap doesn't
+// stretch it.
+func writeLimits(dir string, l config.Limits) error {
+	write := func(file, value string) error {
+		return os.WriteFile(filepath.Join(dir, file), []byte(value), 0644)
+	}
+	if l.Memory != "" {
+		n, err := config.ParseSize(l.Memory)
+		if err != nil {
+			return err
+		}
+		if err := write("memory.max", strconv.FormatInt(n, 10)); err != nil {
+			return err
+		}
+		// Without swap accounting the file is missing
+		write("memory.swap.max", "0")
+		write("memory.oom.group", "1")
+	}
+	if l.CPU > 0 {
+		quota := max(int(l.CPU*cpuPeriod), 1000)
+		if err := write("cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"gowatch/pkg/config"
+)
+
+// limitTree puts a command in a cgroup of its own that enforces its limits
+// on it and the processes it starts. The returned function removes the
+// cgroup once the command has been waited on; processes it left running
+// keep it until they exit.
+func limitTree(command *exec.Cmd, cmd config.Command) (func(), error) {
+	if cmd.Limits.IsZero() {
+		return func() {}, nil
+	}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return nil, err
+	}
+	self, err := ownCgroup(string(data))
+	if err != nil {
+		return nil, err
+	}
+	dir, err := createCgroup(cgroupMount, self, limitControllers(cmd.Limits))
+	if err != nil {
+		return nil, err
+	}
+	if err := writeLimits(dir, cmd.Limits); err != nil {
+		os.Remove(dir)
+		return nil, err
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		os.Remove(dir)
+		return nil, err
+	}
+
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.UseCgroupFD = true
+	command.SysProcAttr.CgroupFD = int(f.Fd())
+	return func() {
+		f.Close()
+		os.Remove(dir)
+	}, nil
+}
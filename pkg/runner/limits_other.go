@@ -0,0 +1,18 @@
+//go:build !linux
+
+package runner
+
+import (
+	"errors"
+	"os/exec"
+
+	"gowatch/pkg/config"
+)
+
+// limitTree can't enforce limits: they take cgroups, which only Linux has
+func limitTree(command *exec.Cmd, cmd config.Command) (func(), error) {
+	if cmd.Limits.IsZero() {
+		return func() {}, nil
+	}
+	return nil, errors.New("resource limits are only enforced on Linux")
+}
@@ -0,0 +1,280 @@
+package runner
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gowatch/pkg/config"
+)
+
+// runDirLayout formats the timestamp that starts the name of a run's output
+// directory; it sorts chronologically
+const runDirLayout = "2006-01-02T15-04-05.000"
+
+// runDirPattern matches the output directories of runs, so that pruning
+// never touches anything else in output_dir
+var runDirPattern = regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d-\d\d-\d\d\.\d{3}_run\d+$`)
+
+// runOutput saves the output of one run's commands to files in its own
+// directory, one file per command
+type runOutput struct {
+	dir string
+
+	mu    sync.Mutex
+	names map[string]int
+}
+
+// newRunOutput creates the output directory for a run and removes those of
+// runs beyond the configured retention
+func newRunOutput(cfg *config.Config, t Trigger) (*runOutput, error) {
+	name := fmt.Sprintf("%s_run%d", t.Time.Format(runDirLayout), t.RunID)
+	dir := filepath.Join(cfg.RunOutputDir(), name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := pruneRuns(cfg.RunOutputDir(), cfg.GetOutputKeep()); err != nil {
+		return nil, err
+	}
+	return &runOutput{dir: dir, names: make(map[string]int)}, nil
+}
+
+// pruneRuns removes the oldest run directories in dir so that keep remain
+func pruneRuns(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var runs []string
+	for _, e := range entries {
+		if e.IsDir() && runDirPattern.MatchString(e.Name()) {
+			runs = append(runs, e.Name())
+		}
+	}
+	if len(runs) <= keep {
+		return nil
+	}
+
+	// Runs started within the same millisecond are ordered by run ID
+	slices.SortFunc(runs, func(a, b string) int {
+		ta, ida, _ := strings.Cut(a, "_run")
+		tb, idb, _ := strings.Cut(b, "_run")
+		if c := strings.Compare(ta, tb); c != 0 {
+			return c
+		}
+		na, _ := strconv.Atoi(ida)
+		nb, _ := strconv.Atoi(idb)
+		return na - nb
+	})
+	for _, name := range runs[:len(runs)-keep] {
+		if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// create opens the file for a command, named after the command's name,
+// service or program. Commands that share a name, or are retried, get numbered files.
+func (o *runOutput) create(cmd config.Command, cmdString string) (*commandOutput, error) {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>| `, r) {
+			return '_'
+		}
+		return r
+	}, commandName(cmd))
+
+	o.mu.Lock()
+	o.names[name]++
+	if n := o.names[name]; n > 1 {
+		name = fmt.Sprintf("%s-%d", name, n)
+	}
+	o.mu.Unlock()
+
+	f, err := os.Create(filepath.Join(o.dir, name+".log"))
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(f, "$ %s\n", cmdString)
+	return &commandOutput{file: f}, nil
+}
+
+// commandName names a command after its name, or its service or program
+func commandName(cmd config.Command) string {
+	if cmd.Name != "" {
+		return cmd.Name
+	}
+	if cmd.RestartService != "" {
+		return cmd.RestartService
+	}
+	if len(cmd.Cmd) > 0 {
+		return filepath.Base(cmd.Cmd[0])
+	}
+	return ""
+}
+
+// outputFilter returns whether a line of a command's output is to be
+// dropped, matching one of its output_filter expressions. Validation has
+// compiled them already.
+func outputFilter(cmd config.Command) func(string) bool {
+	if len(cmd.OutputFilter) == 0 {
+		return func(string) bool { return false }
+	}
+	filters := make([]*regexp.Regexp, len(cmd.OutputFilter))
+	for i, expr := range cmd.OutputFilter {
+		filters[i] = regexp.MustCompile(expr)
+	}
+	return func(line string) bool {
+		for _, re := range filters {
+			if re.MatchString(line) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// highlighter returns a function styling a line of a command's output in
+// the style of the first of its highlight rules that matches it, for
+// showing it; saved and streamed output stays plain
+func (r *Runner) highlighter(cmd config.Command) func(string) string {
+	if len(cmd.Highlight) == 0 {
+		return func(line string) string { return line }
+	}
+	rules := make([]*regexp.Regexp, len(cmd.Highlight))
+	for i, h := range cmd.Highlight {
+		rules[i] = regexp.MustCompile(h.Match)
+	}
+	return func(line string) string {
+		for i, re := range rules {
+			if re.MatchString(line) {
+				return r.log.Style(cmd.Highlight[i].Style, line)
+			}
+		}
+		return line
+	}
+}
+
+// fileReference matches a file:line or file:line:column reference in a line
+// of output, at its start or after a space, quote or bracket. The file needs
+// an extension, which keeps times out.
+var fileReference = regexp.MustCompile(`(?:^|[\s("'\[])((?:[A-Za-z]:[\\/])?[^\s:()"'\[\]<>\x1b]*\.[A-Za-z0-9]+):(\d+)(?::(\d+))?`)
+
+// linker returns a function linking the file references in a line of a
+// command's output to the files, with dir the directory the command runs
+// in. References to files that aren't there, such as URLs or files go test
+// names relative to their package, stay plain.
+func (r *Runner) linker(dir string) func(string) string {
+	template := r.config().HyperlinkURL()
+	if template == "" || !r.log.Colors() {
+		return func(line string) string { return line }
+	}
+	return func(line string) string {
+		var b strings.Builder
+		last := 0
+		for _, m := range fileReference.FindAllStringSubmatchIndex(line, -1) {
+			path := line[m[2]:m[3]]
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			path, err := filepath.Abs(path)
+			if err != nil {
+				continue
+			}
+			if info, err := os.Stat(path); err != nil || info.IsDir() {
+				continue
+			}
+			column := "1"
+			if m[6] >= 0 {
+				column = line[m[6]:m[7]]
+			}
+			b.WriteString(line[last:m[2]])
+			b.WriteString(r.log.Hyperlink(hyperlinkURL(template, path, line[m[4]:m[5]], column), line[m[2]:m[1]]))
+			last = m[1]
+		}
+		if last == 0 {
+			return line
+		}
+		b.WriteString(line[last:])
+		return b.String()
+	}
+}
+
+// hyperlinkURL fills in the URL template of a file reference
+func hyperlinkURL(template, path, line, column string) string {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		// Windows drives, as in file:///C:/src/main.go
+		p = "/" + p
+	}
+	return strings.NewReplacer(
+		"{path}", (&url.URL{Path: p}).EscapedPath(),
+		"{line}", line,
+		"{column}", column,
+	).Replace(template)
+}
+
+// excerptLines is how many of its last lines of output the result of a
+// failed command keeps
+const excerptLines = 20
+
+// excerpt keeps the last lines a command showed, for the result of a failed
+// command to point at the error
+type excerpt struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (e *excerpt) add(line string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.lines) == excerptLines {
+		e.lines = slices.Delete(e.lines, 0, 1)
+	}
+	e.lines = append(e.lines, line)
+}
+
+// get returns the lines kept, oldest first
+func (e *excerpt) get() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.lines)
+}
+
+// commandOutput is the output file of a single command. Lines of stdout and
+// stderr are written in the order they arrive.
+type commandOutput struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func (c *commandOutput) writeLine(line string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintln(c.file, line)
+}
+
+// close records how the command ended and closes the file
+func (c *commandOutput) close(exitCode int, duration time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.file, "\n[exit %d after %s]\n", exitCode, duration.Round(time.Millisecond))
+	c.file.Close()
+}
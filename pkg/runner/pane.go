@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"gowatch/pkg/config"
+)
+
+// tmuxCommand is the tmux client; tests replace it
+var tmuxCommand = "tmux"
+
+// pane is a tmux pane that command output is written to, through its
+// terminal
+type pane struct {
+	// id names the pane for tmux, e.g. "%3"
+	id  string
+	mu  sync.Mutex
+	tty *os.File
+	// created is set for the windows gowatch opened, which it closes
+	created bool
+	closed  bool
+}
+
+// writeLine writes a line to the pane. A pane that has been closed is
+// reported so that it can be opened again.
+func (p *pane) writeLine(line string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return os.ErrClosed
+	}
+	_, err := p.tty.WriteString(line + "\n")
+	return err
+}
+
+// close lets go of the pane, closing the window if gowatch opened it
+func (p *pane) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	p.tty.Close()
+	if p.created {
+		exec.Command(tmuxCommand, "kill-pane", "-t", p.id).Run()
+	}
+}
+
+// openPane opens the pane an output target names: an existing pane for
+// "tmux:<pane>", or a new window in the background named after the command
+// for "new-window". The window runs nothing but keeps showing what is
+// written to it.
+func openPane(target, name string) (*pane, error) {
+	var out []byte
+	var err error
+	if pane, ok := strings.CutPrefix(target, config.OutputTmuxPrefix); ok {
+		out, err = exec.Command(tmuxCommand, "display-message", "-p", "-t", pane, "#{pane_id} #{pane_tty}").Output()
+	} else {
+		out, err = exec.Command(tmuxCommand, "new-window", "-d", "-P", "-F", "#{pane_id} #{pane_tty}", "-n", name, "tail -f /dev/null").Output()
+	}
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			err = fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("tmux: %w", err)
+	}
+	id, path, ok := strings.Cut(strings.TrimSpace(string(out)), " ")
+	if !ok {
+		return nil, fmt.Errorf("tmux: unexpected pane %q", out)
+	}
+	tty, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &pane{id: id, tty: tty, created: target == config.OutputNewWindow}, nil
+}
+
+// paneFor returns the pane the output of a command goes to, opening it on
+// first use, or nil for the terminal. A pane that can't be opened is
+// reported, and the output stays in the terminal.
+func (r *Runner) paneFor(cmd config.Command) *pane {
+	if cmd.Output == "" {
+		return nil
+	}
+	name := commandName(cmd)
+	key := cmd.Output
+	if key == config.OutputNewWindow {
+		key += ":" + name
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.panes[key]; ok {
+		return p
+	}
+	p, err := openPane(cmd.Output, name)
+	if err != nil {
+		r.log.Warn("Showing the output of %s here: %v", name, err)
+	} else {
+		r.log.Runner("Output of %s goes to tmux pane %s", name, p.id)
+	}
+	// A failure isn't retried on every run
+	r.panes[key] = p
+	return p
+}
+
+// dropPane forgets a pane that can no longer be written to, such as one
+// the user closed, so that the next run opens it again
+func (r *Runner) dropPane(p *pane) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, q := range r.panes {
+		if q == p {
+			delete(r.panes, key)
+		}
+	}
+	p.close()
+}
@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"os"
+
+	"gowatch/pkg/config"
+)
+
+// PlannedCommand is an on_change command as a run would start it
+type PlannedCommand struct {
+	// Index is the position of the command in on_change.commands
+	Index int
+	// Command is the command line with placeholders expanded
+	Command []string
+	// Dir is the working directory, "" for gowatch's
+	Dir string
+	// Skipped tells why the command wouldn't run, "" if it would
+	Skipped string
+}
+
+// Plan returns what a run for the trigger would execute, without running
+// anything. Paths aren't translated for commands run in a container, and
+// cooldowns are not applied.
+func (r *Runner) Plan(t Trigger) []PlannedCommand {
+	cfg := r.config()
+	plan := make([]PlannedCommand, 0, len(cfg.OnChange.Commands))
+	for i, cmd := range cfg.OnChange.Commands {
+		p := r.plan(i, cmd, t)
+		p.Skipped = t.skipReason(cfg, resolve(cmd))
+		plan = append(plan, p)
+	}
+	return plan
+}
+
+// plan expands the command line and working directory of a command for a
+// trigger
+func (r *Runner) plan(idx int, cmd config.Command, t Trigger) PlannedCommand {
+	cmd = resolve(cmd)
+	t.index = idx
+	p := PlannedCommand{
+		Index:   idx,
+		Command: r.replacePlaceholders(cmd, t),
+	}
+	p.Dir = r.workDir(cmd, r.placeholderValues(t))
+	return p
+}
+
+// injectedEnv returns what a command gets on top of gowatch's environment:
+// the GOWATCH_ variables and its env entries, expanded
+func (r *Runner) injectedEnv(idx int, cmd config.Command, t Trigger) []string {
+	t.index = idx
+	return append(triggerEnv(t, string(os.PathListSeparator)), expandedEnv(resolve(cmd), r.placeholderValues(t))...)
+}
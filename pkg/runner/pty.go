@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+// ptySize is the size of pseudo-terminals when gowatch isn't writing to a
+// terminal itself
+var ptySize = pty.Winsize{Rows: 24, Cols: 120}
+
+// ptyOutput is the pseudo-terminal a command with pty: true writes to in
+// place of pipes
+type ptyOutput struct {
+	ptmx *os.File
+	tty  *os.File
+	done chan struct{}
+}
+
+// openPTY opens a pseudo-terminal as a command's stdin, stdout and stderr,
+// as wide as gowatch's terminal less the indent of output lines. It is
+// unsupported on Windows.
+func openPTY(command *exec.Cmd, indent int) (*ptyOutput, error) {
+	ptmx, tty, err := pty.Open()
+	if errors.Is(err, pty.ErrUnsupported) {
+		return nil, fmt.Errorf("pseudo-terminals are not supported on %s yet", runtime.GOOS)
+	}
+	if err != nil {
+		return nil, err
+	}
+	size := ptySize
+	if ws, err := pty.GetsizeFull(os.Stdout); err == nil && int(ws.Cols) > indent {
+		size = *ws
+		size.Cols -= uint16(indent)
+	}
+	if err := pty.Setsize(ptmx, &size); err != nil {
+		ptmx.Close()
+		tty.Close()
+		return nil, err
+	}
+	command.Stdin = tty
+	command.Stdout = tty
+	command.Stderr = tty
+	return &ptyOutput{ptmx: ptmx, tty: tty, done: make(chan struct{})}, nil
+}
+
+// start copies what the started command writes to its terminal to w
+func (p *ptyOutput) start(w io.Writer) {
+	// The command has its own copy; reads end once it and the processes it
+	// started have closed theirs
+	p.tty.Close()
+	go func() {
+		defer close(p.done)
+		io.Copy(w, p.ptmx)
+	}()
+}
+
+// close closes the pseudo-terminal once the command has been waited on and
+// its output read, or after outputWaitDelay if processes it left running
+// still hold the terminal
+func (p *ptyOutput) close() {
+	select {
+	case <-p.done:
+	case <-time.After(outputWaitDelay):
+	}
+	p.ptmx.Close()
+	<-p.done
+}
+
+// abort closes the pseudo-terminal of a command that failed to start
+func (p *ptyOutput) abort() {
+	p.tty.Close()
+	p.ptmx.Close()
+}
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Shells that {name:q} placeholders are quoted for
+const (
+	shellPOSIX      = "sh"
+	shellCmd        = "cmd"
+	shellPowerShell = "powershell"
+)
+
+// shellNames maps the programs recognized as shells to how they quote
+var shellNames = map[string]string{
+	"sh":         shellPOSIX,
+	"bash":       shellPOSIX,
+	"zsh":        shellPOSIX,
+	"dash":       shellPOSIX,
+	"ksh":        shellPOSIX,
+	"ash":        shellPOSIX,
+	"cmd":        shellCmd,
+	"powershell": shellPowerShell,
+	"pwsh":       shellPowerShell,
+}
+
+// shellOf returns the shell a command's quoted placeholders are for: the
+// first shell the command line invokes, e.g. through docker exec or env,
+// otherwise cmd.exe on Windows, which gowatch runs commands using shell
+// syntax through, and sh elsewhere
+func shellOf(argv []string, goos string) string {
+	for _, arg := range argv {
+		name := strings.ToLower(filepath.Base(strings.ReplaceAll(arg, `\`, "/")))
+		if shell, ok := shellNames[strings.TrimSuffix(name, ".exe")]; ok {
+			return shell
+		}
+	}
+	if goos == "windows" {
+		return shellCmd
+	}
+	return shellPOSIX
+}
+
+// quoteFor quotes a value so that the shell reads it as a single word,
+// leaving it as is when it has nothing the shell would interpret
+func quoteFor(shell, s string) string {
+	switch shell {
+	case shellCmd:
+		return quoteCmd(s)
+	case shellPowerShell:
+		return quotePowerShell(s)
+	default:
+		return quotePOSIX(s)
+	}
+}
+
+// quotePOSIX single-quotes a value for sh and compatible shells, in which
+// nothing within single quotes is special. A single quote in the value
+// closes the quotes, is escaped and reopens them.
+func quotePOSIX(s string) string {
+	if s != "" && !strings.ContainsFunc(s, func(r rune) bool { return !isSafeRune(r) }) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// quotePowerShell single-quotes a value for PowerShell, which doubles
+// single quotes within them. The typographic single quotes PowerShell also
+// accepts are doubled likewise. Backslashes aren't special to it, but
+// commas and splatting are.
+func quotePowerShell(s string) string {
+	if s != "" && !strings.ContainsFunc(s, func(r rune) bool { return !isSafeRune(r) && r != '\\' || strings.ContainsRune(",=+@", r) }) {
+		return s
+	}
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '‘', '’', '‚', '‛':
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// quoteCmd double-quotes a value for cmd.exe, within which its operators
+// are literal, escaping quotes and the backslashes before them the way
+// programs parse their command line
+func quoteCmd(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"&|<>^()%!,;=") {
+		return s
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	slashes := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			slashes++
+		case '"':
+			// Backslashes before a quote are doubled, and the quote escaped
+			b.WriteString(strings.Repeat(`\`, slashes+1))
+			slashes = 0
+		default:
+			slashes = 0
+		}
+		b.WriteByte(s[i])
+	}
+	// Backslashes before the closing quote are doubled too
+	b.WriteString(strings.Repeat(`\`, slashes))
+	b.WriteByte('"')
+	return b.String()
+}
+
+// isSafeRune reports whether a character needs no quoting in any shell
+func isSafeRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-+=.,/:@", r)
+}
+
+// shellOperators are the arguments kept as they are when a command is
+// joined into a command line, for commands that pipe or redirect
+var shellOperators = map[string]bool{
+	"|": true, "||": true, "&": true, "&&": true, ";": true,
+	"<": true, ">": true, ">>": true, "2>": true, "2>>": true, "2>&1": true, "1>&2": true,
+}
+
+// joinFor joins a command into a command line for the shell, quoting each
+// argument that needs it apart from the operators, so that arguments with
+// spaces or metacharacters, such as changed paths, stay one argument
+func joinFor(shell string, argv []string) string {
+	parts := make([]string, len(argv))
+	for i, arg := range argv {
+		if shellOperators[arg] {
+			parts[i] = arg
+		} else {
+			parts[i] = quoteFor(shell, arg)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// cmdLine joins a command into a cmd.exe command line
+func cmdLine(argv []string) string {
+	return joinFor(shellCmd, argv)
+}
+
+// shellScript returns the script a configured shell runs for a command: a
+// single argument is the script as written, and several are joined into
+// one
+func shellScript(shell string, argv []string) string {
+	if len(argv) == 1 {
+		return argv[0]
+	}
+	return joinFor(shellNames[shell], argv)
+}
+
+// shellArgv returns the command line that runs a command through a
+// configured shell
+func shellArgv(shell string, argv []string) []string {
+	script := shellScript(shell, argv)
+	switch shellNames[shell] {
+	case shellPowerShell:
+		return []string{shell, "-NoProfile", "-Command", script}
+	case shellCmd:
+		return []string{"cmd.exe", "/S", "/C", script}
+	default:
+		return []string{shell, "-c", script}
+	}
+}
@@ -0,0 +1,1608 @@
+// Package runner executes the commands of a config in response to changes
+package runner
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"gowatch/internal/ignore"
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+
+	"github.com/charmbracelet/x/ansi"
+	"golang.org/x/sync/errgroup"
+)
+
+// outputWaitDelay bounds how long Wait blocks on output pipes after the
+// process itself has exited
+const outputWaitDelay = 2 * time.Second
+
+type Runner struct {
+	cfg        *config.Config
+	log        *logger.Logger
+	sequential bool
+	dryRun     bool
+	onStart    func(Trigger, []string)
+	onResult   func(Trigger, RunResult)
+	onOutput   func(Trigger, string, bool)
+	terminal   func() (*os.File, func())
+	mu         sync.Mutex
+	running    int
+	procs      map[int]*process
+	cooldowns  map[int]*cooldown
+	// mounts caches the bind mounts of containers commands run in
+	mounts map[string]pathMap
+	// unlimited is set once the runner has warned that the limits of
+	// commands can't be enforced
+	unlimited bool
+	// noPTY is set once the runner has warned that commands can't be given
+	// a pseudo-terminal
+	noPTY bool
+	// panes holds the tmux panes commands write their output to, by output
+	// target; nil for targets that couldn't be opened
+	panes map[string]*pane
+}
+
+// process is a long-running command started in restart mode
+type process struct {
+	spec     config.Command
+	cmd      *exec.Cmd
+	done     chan struct{}
+	stopping bool
+	// container is set for commands running in a container
+	container *containerExec
+}
+
+type RunResult struct {
+	Command  []string
+	ExitCode int
+	Duration time.Duration
+	Error    error
+	// Attempts is how many times the command ran, including retries
+	Attempts int
+	// Reload is set for commands configured with reload: true
+	Reload bool
+	// Index is the position of the command in on_change.commands
+	Index int
+	// TimedOut is set when the command was stopped for exceeding its
+	// timeout, and Canceled when it was stopped because the run was
+	// cancelled, e.g. on shutdown
+	TimedOut bool
+	Canceled bool
+	// Excerpt is the last lines a failed command showed
+	Excerpt []string
+	// Tests counts the tests of a command with a parser, if it ran any
+	Tests *TestSummary
+}
+
+// Report summarizes one run of a pipeline's commands
+type Report struct {
+	Task     string
+	Trigger  Trigger
+	Start    time.Time
+	Duration time.Duration
+	Results  []RunResult
+}
+
+// WantsReload reports whether the run succeeded and included a command
+// configured to reload browsers
+func (r Report) WantsReload() bool {
+	if !r.Success() {
+		return false
+	}
+	for _, result := range r.Results {
+		if result.Reload {
+			return true
+		}
+	}
+	return false
+}
+
+// TimedOut reports whether a command of the run exceeded its timeout
+func (r Report) TimedOut() bool {
+	return slices.ContainsFunc(r.Results, func(result RunResult) bool { return result.TimedOut })
+}
+
+// Success reports whether every command in the run exited cleanly
+func (r Report) Success() bool {
+	for _, result := range r.Results {
+		if result.ExitCode != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Options configures a Runner
+type Options struct {
+	// Logger receives command output and progress; nil discards it
+	Logger *logger.Logger
+	// Sequential runs commands one at a time, stopping at the first failure
+	Sequential bool
+	// DryRun logs commands instead of executing them
+	DryRun bool
+	// OnStart and OnResult, if set, are called as each on_change command
+	// starts, with its expanded command line, and finishes. They may be
+	// called from several goroutines at once.
+	OnStart  func(t Trigger, command []string)
+	OnResult func(Trigger, RunResult)
+	// OnOutput, if set, receives each line the commands and hooks print.
+	// The trigger's CommandIndex tells which command printed it.
+	OnOutput func(t Trigger, line string, isError bool)
+	// Terminal, if set, is called as an interactive command starts to take
+	// the terminal from whatever else reads it. The command reads stdin
+	// (nothing if nil) instead of os.Stdin, and release is called once it
+	// exits.
+	Terminal func() (stdin *os.File, release func())
+}
+
+// New creates a runner for the commands of cfg. Call Close to stop any
+// long-running processes it started.
+func New(cfg *config.Config, opts Options) *Runner {
+	log := opts.Logger
+	if log == nil {
+		log = logger.Discard()
+	}
+
+	return &Runner{
+		cfg:        cfg,
+		log:        log,
+		sequential: opts.Sequential,
+		dryRun:     opts.DryRun,
+		onStart:    opts.OnStart,
+		onResult:   opts.OnResult,
+		onOutput:   opts.OnOutput,
+		terminal:   opts.Terminal,
+		procs:      make(map[int]*process),
+		cooldowns:  make(map[int]*cooldown),
+		mounts:     make(map[string]pathMap),
+		panes:      make(map[string]*pane),
+	}
+}
+
+// Trigger describes the change that caused a run
+type Trigger struct {
+	Path  string
+	Event string
+	// Files holds every path changed in the batch; defaults to Path
+	Files []string
+	// Time is when the change happened; defaults to the start of the run
+	Time time.Time
+	// RunID identifies the run; RunTrigger assigns one when zero
+	RunID int64
+	// Ops lists the event types in the batch; defaults to those in Event
+	Ops []string
+	// Tags are the tags of the watch paths the batch changed under
+	Tags []string
+
+	// outcome is set for on_success/on_failure hooks
+	outcome *outcome
+	// output saves command output when output_dir is set
+	output *runOutput
+	// only restricts the run to these command indexes, see TakePending
+	only map[int]bool
+	// paths translates changed paths for commands run in a container
+	paths pathMap
+	// index is the position of the command being run, see CommandIndex
+	index int
+}
+
+// outcome describes how the on_change commands of a run ended
+type outcome struct {
+	failedCommand string
+	exitCode      int
+}
+
+// lastRunID is shared by all runners so IDs are unique within the process
+var lastRunID atomic.Int64
+
+// NextRunID returns a new process-wide unique run ID
+func NextRunID() int64 {
+	return lastRunID.Add(1)
+}
+
+// CommandIndex returns the position in on_change.commands of the command
+// that OnStart, OnResult and OnOutput are called for, or -1 for hooks
+func (t Trigger) CommandIndex() int {
+	return t.index
+}
+
+// files returns the changed paths, falling back to the single trigger path
+func (t Trigger) files() []string {
+	if len(t.Files) > 0 {
+		return t.Files
+	}
+	if t.Path != "" {
+		return []string{t.Path}
+	}
+	return nil
+}
+
+// ops returns the event types of the trigger, falling back to Event
+func (t Trigger) ops() []string {
+	if len(t.Ops) > 0 {
+		return t.Ops
+	}
+	return strings.Split(t.Event, "|")
+}
+
+// skipReason tells why a command of cfg doesn't run for the trigger, or
+// returns "" if it does. Manual runs (without a path) run every command.
+func (t Trigger) skipReason(cfg *config.Config, cmd config.Command) string {
+	if t.Path == "" {
+		return ""
+	}
+	if len(cmd.Events) > 0 && !config.MatchEvents(cmd.Events, t.ops()) {
+		return "only runs on " + strings.Join(cmd.Events, ", ") + " events"
+	}
+	if len(cmd.Match) > 0 && !matchFiles(cfg, cmd.Match, t.files()) {
+		return "no changed file matches " + strings.Join(cmd.Match, ", ")
+	}
+	if len(cmd.WhenTags) > 0 && !slices.ContainsFunc(cmd.WhenTags, func(tag string) bool { return slices.Contains(t.Tags, tag) }) {
+		return "only runs on changes tagged " + strings.Join(cmd.WhenTags, ", ")
+	}
+	return ""
+}
+
+// matchFiles reports whether any of the files matches the patterns, which
+// are relative to the directory of cfg
+func matchFiles(cfg *config.Config, patterns, files []string) bool {
+	wd, err := cfg.WorkDir()
+	if err != nil {
+		return true
+	}
+	m := ignore.New()
+	m.Add(wd, "match", patterns)
+
+	for _, f := range files {
+		if abs, err := filepath.Abs(f); err == nil && m.Match(abs, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run executes the configured commands for a single file change
+func (r *Runner) Run(ctx context.Context, eventPath, eventType string) []RunResult {
+	return r.RunTrigger(ctx, Trigger{Path: eventPath, Event: eventType})
+}
+
+// RunTrigger executes the configured commands for a batch of changes
+func (r *Runner) RunTrigger(ctx context.Context, t Trigger) []RunResult {
+	if t.RunID == 0 {
+		t.RunID = NextRunID()
+	}
+	if t.Time.IsZero() {
+		t.Time = time.Now()
+	}
+
+	cfg := r.config()
+	if len(cfg.OnChange.Commands) == 0 {
+		// A static server only refreshes the browsers
+		if !cfg.Serve.Enabled() {
+			r.log.Warn("No commands configured to run")
+		}
+		return nil
+	}
+
+	// Commands keep their configured index, which identifies restart-mode
+	// processes across runs
+	var commands []indexedCommand
+	var skipped []skippedCommand
+	for i, cmd := range cfg.OnChange.Commands {
+		if t.only != nil && !t.only[i] {
+			continue
+		}
+		if reason := t.skipReason(cfg, cmd); reason != "" {
+			skipped = append(skipped, skippedCommand{indexedCommand{idx: i, cmd: cmd}, reason})
+			continue
+		}
+		if r.coolingDown(i, cmd, t) {
+			continue
+		}
+		commands = append(commands, indexedCommand{idx: i, cmd: cmd})
+	}
+	if len(commands) == 0 {
+		if r.dryRun {
+			r.logSkipped(skipped)
+		}
+		r.log.Debug("No commands for %s events: %s", t.Event, t.Path)
+		return nil
+	}
+
+	r.log.Separator()
+	if t.Path == "" {
+		r.log.Runner("Run requested")
+	} else {
+		r.log.Runner("File change detected")
+		r.log.Info("  Path:  %s", t.Path)
+	}
+	r.log.Info("  Event: %s", t.Event)
+	if files := t.files(); len(files) > 1 {
+		r.log.Info("  Files: %d changed", len(files))
+	}
+	r.log.Separator()
+
+	if r.dryRun {
+		r.logPlan(cfg, commands, skipped, t)
+	}
+
+	if cfg.OutputDir != "" && !r.dryRun {
+		out, err := newRunOutput(cfg, t)
+		if err != nil {
+			r.log.Warn("Failed to save command output: %v", err)
+		}
+		t.output = out
+	}
+
+	results := make([]RunResult, 0, len(commands))
+
+	if cfg.OnChange.HasDependencies() {
+		limit := cfg.MaxConcurrency
+		if r.sequential {
+			limit = 1
+		}
+		results = r.executeGraph(ctx, commands, limit, t)
+	} else if r.sequential {
+		for i, c := range commands {
+			r.log.Info("Command %d/%d", i+1, len(commands))
+			result := r.runCommand(ctx, c.idx, c.cmd, t)
+			results = append(results, result)
+			if result.Error != nil && result.ExitCode != 0 {
+				r.log.Error("Command failed, stopping execution chain")
+				break
+			}
+		}
+	} else if cfg.OnChange.HasStages() {
+		results = r.executeStages(ctx, cfg.OnChange, commands, cfg.MaxConcurrency, t)
+	} else {
+		results = r.executeParallel(ctx, commands, cfg.MaxConcurrency, t)
+	}
+
+	// Summary
+	r.log.Separator()
+	successCount := 0
+	for _, result := range results {
+		if result.ExitCode == 0 {
+			successCount++
+		}
+	}
+
+	if successCount == len(results) {
+		r.log.Success("All commands completed successfully (%d/%d)", successCount, len(results))
+	} else {
+		r.log.Error("Some commands failed (%d/%d succeeded)", successCount, len(results))
+	}
+	if t.output != nil {
+		r.log.Info("Output saved to %s", t.output.dir)
+	}
+	r.log.Separator()
+
+	r.runHooks(ctx, cfg, t, results)
+
+	return results
+}
+
+// runHooks runs the on_success or on_failure commands for a finished run.
+// Hooks run sequentially and their results are not part of the run's results.
+func (r *Runner) runHooks(ctx context.Context, cfg *config.Config, t Trigger, results []RunResult) {
+	t.outcome = &outcome{}
+	for _, result := range results {
+		if result.ExitCode != 0 {
+			t.outcome.failedCommand = strings.Join(result.Command, " ")
+			t.outcome.exitCode = result.ExitCode
+			break
+		}
+	}
+
+	hooks, name := cfg.OnSuccess, "on_success"
+	if t.outcome.exitCode != 0 {
+		hooks, name = cfg.OnFailure, "on_failure"
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	r.log.Runner("Running %s hooks", name)
+	t.index = -1
+	for _, cmd := range hooks {
+		if result := r.executeCommand(ctx, resolve(cmd), t); result.ExitCode != 0 {
+			r.log.Error("%s hook failed: %s", name, strings.Join(result.Command, " "))
+		}
+	}
+	r.log.Separator()
+}
+
+// Setup runs the setup commands once, in order, before watching starts. It
+// stops at the first command that fails and returns its error.
+func (r *Runner) Setup(ctx context.Context) error {
+	cfg := r.config()
+	if len(cfg.Setup) == 0 {
+		return nil
+	}
+
+	r.log.Runner("Running setup commands")
+	t := Trigger{Event: "SETUP", Time: time.Now(), RunID: NextRunID(), index: -1}
+	for _, cmd := range cfg.Setup {
+		result := r.executeCommand(ctx, resolve(cmd), t)
+		if result.ExitCode != 0 {
+			err := result.Error
+			if err == nil {
+				err = fmt.Errorf("exit code %d", result.ExitCode)
+			}
+			return fmt.Errorf("setup command %q failed: %w", strings.Join(result.Command, " "), err)
+		}
+	}
+	r.log.Separator()
+	return nil
+}
+
+// Exit runs the on_exit commands once, sequentially, as the session ends.
+// A failing command is logged and doesn't stop the others.
+func (r *Runner) Exit(ctx context.Context) {
+	cfg := r.config()
+	if len(cfg.OnExit) == 0 {
+		return
+	}
+
+	r.log.Runner("Running on_exit hooks")
+	t := Trigger{Event: "EXIT", Time: time.Now(), RunID: NextRunID(), index: -1}
+	for _, cmd := range cfg.OnExit {
+		if result := r.executeCommand(ctx, resolve(cmd), t); result.ExitCode != 0 {
+			r.log.Error("on_exit hook failed: %s", strings.Join(result.Command, " "))
+		}
+	}
+	r.log.Separator()
+}
+
+// indexedCommand is a command with its index in the config
+type indexedCommand struct {
+	idx int
+	cmd config.Command
+}
+
+// executeParallel runs commands up to maxConcurrency slots at a time,
+// starting them in order of priority
+func (r *Runner) executeParallel(ctx context.Context, commands []indexedCommand, maxConcurrency int, t Trigger) []RunResult {
+	results := make([]RunResult, len(commands))
+	g, gctx := errgroup.WithContext(ctx)
+	sem := newSlots(maxConcurrency)
+
+	order := make([]int, len(commands))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, byPriority(commands))
+
+	for _, i := range order {
+		c := commands[i]
+		release, err := sem.acquire(gctx, c.cmd.GetWeight(), c.cmd.Priority)
+		if err != nil {
+			break
+		}
+		g.Go(func() error {
+			defer release()
+			r.log.Info("Command %d/%d (parallel)", i+1, len(commands))
+			results[i] = r.runCommand(gctx, c.idx, c.cmd, t)
+			return nil
+		})
+	}
+
+	g.Wait()
+	return results
+}
+
+// executeStages runs each stage's commands in parallel, one stage after the
+// other. The stages after a failed one are skipped and left out of the
+// results.
+func (r *Runner) executeStages(ctx context.Context, o config.OnChange, commands []indexedCommand, maxConcurrency int, t Trigger) []RunResult {
+	results := make([]RunResult, 0, len(commands))
+	for start := 0; start < len(commands); {
+		stage := commands[start].cmd.Stage
+		end := start + 1
+		for end < len(commands) && commands[end].cmd.Stage == stage {
+			end++
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		r.log.Info("Stage %s", o.StageName(commands[start].cmd))
+		stageResults := r.executeParallel(ctx, commands[start:end], maxConcurrency, t)
+		results = append(results, stageResults...)
+		if slices.ContainsFunc(stageResults, func(result RunResult) bool { return result.ExitCode != 0 }) {
+			if end < len(commands) {
+				r.log.Error("Stage %s failed, skipping the remaining stages", o.StageName(commands[start].cmd))
+			}
+			break
+		}
+		start = end
+	}
+	return results
+}
+
+// executeGraph runs commands as soon as the commands they depend on have
+// succeeded, taking up to maxConcurrency slots at a time; commands waiting
+// for slots start in order of priority. Dependents of a failed command are
+// skipped and left out of the results. Dependencies that don't run for this
+// trigger count as satisfied.
+func (r *Runner) executeGraph(ctx context.Context, commands []indexedCommand, maxConcurrency int, t Trigger) []RunResult {
+	type node struct {
+		done chan struct{}
+		ok   bool
+	}
+	nodes := make(map[string]*node)
+	for _, c := range commands {
+		if c.cmd.Name != "" {
+			nodes[c.cmd.Name] = &node{done: make(chan struct{})}
+		}
+	}
+
+	results := make([]*RunResult, len(commands))
+	sem := newSlots(maxConcurrency)
+	var wg sync.WaitGroup
+
+	// start runs command i once its dependencies have succeeded, in the
+	// slots it holds if release is set
+	start := func(i int, release func()) {
+		c := commands[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			self := nodes[c.cmd.Name]
+			if self != nil {
+				defer close(self.done)
+			}
+			label := strings.Join(r.replacePlaceholders(c.cmd, t), " ")
+			if c.cmd.Name != "" {
+				label = c.cmd.Name
+			}
+
+			for _, dep := range c.cmd.DependsOn {
+				n, ok := nodes[dep]
+				if !ok {
+					continue
+				}
+				select {
+				case <-n.done:
+				case <-ctx.Done():
+					return
+				}
+				if !n.ok {
+					r.log.Warn("Skipping %s: %s failed", label, dep)
+					return
+				}
+			}
+
+			if release == nil {
+				var err error
+				if release, err = sem.acquire(ctx, c.cmd.GetWeight(), c.cmd.Priority); err != nil {
+					return
+				}
+			}
+			defer release()
+			if ctx.Err() != nil {
+				return
+			}
+
+			r.log.Info("Command %d/%d: %s", i+1, len(commands), label)
+			result := r.runCommand(ctx, c.idx, c.cmd, t)
+			results[i] = &result
+			if self != nil {
+				self.ok = result.ExitCode == 0
+			}
+		}()
+	}
+
+	// Commands that wait for others queue for slots once those are done.
+	// The rest are given their slots here, in order of priority, rather
+	// than in whatever order their goroutines happen to run.
+	var ready []int
+	for i, c := range commands {
+		if slices.ContainsFunc(c.cmd.DependsOn, func(dep string) bool { return nodes[dep] != nil }) {
+			start(i, nil)
+		} else {
+			ready = append(ready, i)
+		}
+	}
+	slices.SortStableFunc(ready, byPriority(commands))
+	for _, i := range ready {
+		// Without slots, once cancelled, the command gives up by itself
+		release, _ := sem.acquire(ctx, commands[i].cmd.GetWeight(), commands[i].cmd.Priority)
+		start(i, release)
+	}
+	wg.Wait()
+
+	ran := make([]RunResult, 0, len(commands))
+	for _, result := range results {
+		if result != nil {
+			ran = append(ran, *result)
+		}
+	}
+	return ran
+}
+
+// byPriority orders indexes of commands by descending priority
+func byPriority(commands []indexedCommand) func(a, b int) int {
+	return func(a, b int) int {
+		return cmp.Compare(commands[b].cmd.Priority, commands[a].cmd.Priority)
+	}
+}
+
+// runCommand dispatches a command to the executor matching its mode
+func (r *Runner) runCommand(ctx context.Context, idx int, cmd config.Command, t Trigger) RunResult {
+	cmd = resolve(cmd)
+	t.index = idx
+	if r.onStart != nil {
+		r.onStart(t, r.replacePlaceholders(cmd, t))
+	}
+
+	var result RunResult
+	if cmd.IsRestart() {
+		result = r.restartCommand(idx, cmd, t)
+	} else {
+		result = r.executeCommand(ctx, cmd, t)
+	}
+	result.Reload = cmd.Reload
+	result.Index = idx
+	if r.onResult != nil {
+		r.onResult(t, result)
+	}
+	return result
+}
+
+// executeCommand runs a command to completion, retrying failures with
+// exponential backoff as configured
+func (r *Runner) executeCommand(ctx context.Context, cmd config.Command, t Trigger) RunResult {
+	result := r.executeOnce(ctx, cmd, t)
+	result.Attempts = 1
+
+	backoff := cmd.GetRetryBackoff()
+	for result.ExitCode != 0 && result.Attempts <= cmd.Retries {
+		r.log.Warn("Retrying in %s (%d/%d): %s", backoff, result.Attempts, cmd.Retries, strings.Join(result.Command, " "))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return result
+		}
+
+		attempts := result.Attempts + 1
+		result = r.executeOnce(ctx, cmd, t)
+		result.Attempts = attempts
+		backoff *= 2
+	}
+
+	return result
+}
+
+// executeOnce runs a single attempt of a command
+func (r *Runner) executeOnce(ctx context.Context, cmd config.Command, t Trigger) RunResult {
+	if cmd.Container != "" {
+		t.paths = r.containerPaths(ctx, cmd)
+	}
+	cmdWithPlaceholders := r.replacePlaceholders(cmd, t)
+	cmdString := strings.Join(cmdWithPlaceholders, " ")
+
+	if r.dryRun {
+		if cmd.Container != "" {
+			r.log.Info("[DRY-RUN] Would execute in %s: %s", cmd.Container, cmdString)
+			return RunResult{
+				Command:  cmdWithPlaceholders,
+				ExitCode: 0,
+			}
+		}
+		r.log.Info("[DRY-RUN] Would execute: %s", cmdString)
+		return RunResult{
+			Command:  cmdWithPlaceholders,
+			ExitCode: 0,
+		}
+	}
+
+	// Parse timeout
+	timeout := 60 * time.Second
+	if cmd.Timeout != "" {
+		if d, err := time.ParseDuration(cmd.Timeout); err == nil {
+			timeout = d
+		}
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	// Interactive commands have the terminal, and the status line waits
+	done := func() {}
+	if cmd.Interactive {
+		r.log.CommandStart(cmdString)
+		defer r.log.PauseStatus()()
+	} else {
+		done = r.log.CommandRunning(commandName(cmd), cmdString)
+		defer done()
+	}
+
+	// Validate command
+	if len(cmdWithPlaceholders) == 0 {
+		return RunResult{
+			Command:  cmdWithPlaceholders,
+			ExitCode: -1,
+			Duration: time.Since(start),
+			Error:    fmt.Errorf("empty command"),
+		}
+	}
+
+	command, ctr := r.prepareCommand(cmdCtx, cmd, t, cmdWithPlaceholders)
+	gracefulCancel(command, cmd, ctr)
+
+	if cmd.Interactive {
+		release, err := r.attachTerminal(cmdCtx, command, cmdString)
+		if err != nil {
+			r.log.Error("%v", err)
+			return RunResult{
+				Command:  cmdWithPlaceholders,
+				ExitCode: -1,
+				Duration: time.Since(start),
+				Error:    err,
+			}
+		}
+		defer release()
+	}
+
+	out := r.openOutput(t, cmd, cmdString)
+	tail := &excerpt{}
+	tests := newTestOutput(cmd)
+	flush, err := r.startCommand(command, cmd, t, out, tail, tests, ctr)
+	if err != nil {
+		r.log.Error("%v", err)
+		out.close(-1, time.Since(start))
+		return RunResult{
+			Command:  cmdWithPlaceholders,
+			ExitCode: -1,
+			Duration: time.Since(start),
+			Error:    err,
+		}
+	}
+
+	err = command.Wait()
+	if cmdCtx.Err() != nil {
+		// Processes the stopped command started may have outlived it
+		killTree(command.Process)
+	}
+	flush()
+	if err == nil && cmd.RestartService != "" {
+		if err = r.waitHealthy(cmdCtx, cmd, out); err != nil {
+			r.log.Error("%v", err)
+		}
+	}
+	duration := time.Since(start)
+	done()
+
+	result := RunResult{
+		Command:  cmdWithPlaceholders,
+		Duration: duration,
+	}
+
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			result.ExitCode = exitErr.ExitCode()
+		} else {
+			result.ExitCode = -1
+		}
+		result.Error = err
+		result.Excerpt = tail.get()
+		switch {
+		case ctx.Err() != nil:
+			result.Canceled = true
+			r.log.Warn("Canceled after %s: %s", duration.Round(time.Millisecond), cmdString)
+		case errors.Is(cmdCtx.Err(), context.DeadlineExceeded):
+			result.TimedOut = true
+			result.Error = fmt.Errorf("timed out after %s: %w", timeout, err)
+			r.log.Error("Timed out after %s: %s", timeout, cmdString)
+		default:
+			r.log.CommandEnd(cmdString, result.ExitCode, duration)
+		}
+	} else {
+		result.ExitCode = 0
+		r.log.CommandEnd(cmdString, 0, duration)
+	}
+	if result.Tests = tests.summary(); result.Tests != nil {
+		if result.Tests.Failed > 0 {
+			r.log.Error("%s", result.Tests)
+		} else {
+			r.log.Success("%s", result.Tests)
+		}
+	}
+	out.close(result.ExitCode, duration)
+
+	return result
+}
+
+// restartCommand stops the previous instance of a long-running command, if
+// any, and starts a fresh one without waiting for it to exit
+func (r *Runner) restartCommand(idx int, cmd config.Command, t Trigger) RunResult {
+	if cmd.Container != "" {
+		t.paths = r.containerPaths(context.Background(), cmd)
+	}
+	cmdWithPlaceholders := r.replacePlaceholders(cmd, t)
+	cmdString := strings.Join(cmdWithPlaceholders, " ")
+
+	if r.dryRun {
+		if cmd.Container != "" {
+			r.log.Info("[DRY-RUN] Would restart in %s: %s", cmd.Container, cmdString)
+			return RunResult{
+				Command:  cmdWithPlaceholders,
+				ExitCode: 0,
+			}
+		}
+		r.log.Info("[DRY-RUN] Would restart: %s", cmdString)
+		return RunResult{
+			Command:  cmdWithPlaceholders,
+			ExitCode: 0,
+		}
+	}
+
+	r.mu.Lock()
+	prev := r.procs[idx]
+	delete(r.procs, idx)
+	r.mu.Unlock()
+
+	if prev != nil {
+		r.log.Runner("Restarting: %s", cmdString)
+		r.stopProcess(prev)
+	}
+
+	start := time.Now()
+	r.log.CommandStart(cmdString)
+
+	// Not bound to the event context: the process outlives this run and is
+	// stopped explicitly on the next restart or on shutdown
+	command, ctr := r.prepareCommand(context.Background(), cmd, t, cmdWithPlaceholders)
+
+	release := func() {}
+	if cmd.Interactive {
+		var err error
+		if release, err = r.attachTerminal(context.Background(), command, cmdString); err != nil {
+			r.log.Error("%v", err)
+			return RunResult{
+				Command:  cmdWithPlaceholders,
+				ExitCode: -1,
+				Duration: time.Since(start),
+				Error:    err,
+			}
+		}
+	}
+
+	out := r.openOutput(t, cmd, cmdString)
+	flush, err := r.startCommand(command, cmd, t, out, nil, nil, ctr)
+	if err != nil {
+		release()
+		r.log.Error("%v", err)
+		out.close(-1, time.Since(start))
+		return RunResult{
+			Command:  cmdWithPlaceholders,
+			ExitCode: -1,
+			Duration: time.Since(start),
+			Error:    err,
+		}
+	}
+
+	p := &process{spec: cmd, cmd: command, done: make(chan struct{}), container: ctr}
+
+	go func() {
+		defer close(p.done)
+		err := command.Wait()
+		r.mu.Lock()
+		stopping := p.stopping
+		r.mu.Unlock()
+		if stopping {
+			// Processes the stopped command started may have outlived it
+			killTree(command.Process)
+		}
+		flush()
+		release()
+
+		exitCode := 0
+		if err != nil {
+			exitCode = -1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				exitCode = exitErr.ExitCode()
+			}
+		}
+		out.close(exitCode, time.Since(start))
+
+		r.mu.Lock()
+		stopping = p.stopping
+		if r.procs[idx] == p {
+			delete(r.procs, idx)
+		}
+		r.mu.Unlock()
+
+		if stopping {
+			r.log.Debug("Stopped: %s", cmdString)
+			return
+		}
+		r.log.CommandEnd(cmdString, exitCode, time.Since(start))
+	}()
+
+	r.mu.Lock()
+	r.procs[idx] = p
+	r.mu.Unlock()
+
+	r.log.Success("Started: %s (pid %d)", cmdString, command.Process.Pid)
+
+	return RunResult{
+		Command:  cmdWithPlaceholders,
+		ExitCode: 0,
+		Duration: time.Since(start),
+	}
+}
+
+// stopProcess signals a long-running process and kills it if it has not
+// exited within its grace period
+func (r *Runner) stopProcess(p *process) {
+	r.mu.Lock()
+	p.stopping = true
+	r.mu.Unlock()
+
+	signalCommand(p.cmd.Process, p.container, p.spec.GetKillSignal())
+
+	grace := p.spec.GetKillGrace()
+	select {
+	case <-p.done:
+	case <-time.After(grace):
+		r.log.Warn("Process %d did not exit after %s, killing", p.cmd.Process.Pid, grace)
+		if p.container != nil {
+			p.container.signal("SIGKILL")
+		}
+		killTree(p.cmd.Process)
+		<-p.done
+	}
+}
+
+// gracefulCancel makes a cancelled or timed-out command receive its kill
+// signal first, and only be killed once its grace period has passed. A zero
+// grace period kills it immediately.
+func gracefulCancel(command *exec.Cmd, cmd config.Command, ctr *containerExec) {
+	grace := cmd.GetKillGrace()
+	if grace == 0 {
+		command.Cancel = func() error {
+			if ctr != nil {
+				ctr.signal("SIGKILL")
+			}
+			return killTree(command.Process)
+		}
+		return
+	}
+	command.Cancel = func() error {
+		return signalCommand(command.Process, ctr, cmd.GetKillSignal())
+	}
+	// WaitDelay also bounds the wait for the process after cancellation
+	command.WaitDelay = grace
+}
+
+// killSignals maps kill_signal names to signals
+var killSignals = map[string]os.Signal{
+	"SIGINT":  os.Interrupt,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": os.Kill,
+}
+
+// signalProcess sends the named signal. Windows cannot deliver signals to
+// child processes, so there the process is killed outright, along with the
+// processes it started.
+func signalProcess(proc *os.Process, name string) error {
+	sig, ok := killSignals[name]
+	if !ok || sig == os.Kill || runtime.GOOS == "windows" {
+		return killTree(proc)
+	}
+	return signalTree(proc, sig)
+}
+
+// config returns the current configuration
+func (r *Runner) config() *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg
+}
+
+// Reload swaps in a new configuration for subsequent runs. Long-running
+// processes whose command is unchanged keep running; the rest are stopped.
+func (r *Runner) Reload(cfg *config.Config) {
+	r.mu.Lock()
+	r.cfg = cfg
+	var stale []*process
+	for idx, p := range r.procs {
+		commands := cfg.OnChange.Commands
+		if idx >= len(commands) || !reflect.DeepEqual(p.spec, commands[idx]) {
+			stale = append(stale, p)
+			delete(r.procs, idx)
+		}
+	}
+	for idx := range r.cooldowns {
+		if idx >= len(cfg.OnChange.Commands) || cfg.OnChange.Commands[idx].GetCooldown() == 0 {
+			delete(r.cooldowns, idx)
+		}
+	}
+	// Containers may have been recreated with other mounts
+	clear(r.mounts)
+	r.mu.Unlock()
+
+	for _, p := range stale {
+		r.stopProcess(p)
+	}
+}
+
+// Close terminates all long-running processes started in restart mode
+func (r *Runner) Close() error {
+	r.mu.Lock()
+	procs := make([]*process, 0, len(r.procs))
+	for idx, p := range r.procs {
+		procs = append(procs, p)
+		delete(r.procs, idx)
+	}
+	panes := r.panes
+	r.panes = make(map[string]*pane)
+	r.mu.Unlock()
+
+	for _, p := range procs {
+		r.stopProcess(p)
+	}
+	for _, p := range panes {
+		if p != nil {
+			p.close()
+		}
+	}
+	return nil
+}
+
+// buildCommand prepares an exec.Cmd, run through the shell if one is
+// configured. Otherwise commands run directly, apart from those that need a
+// shell on Windows, which run through cmd.exe.
+func buildCommand(ctx context.Context, shell string, argv []string) *exec.Cmd {
+	switch shell {
+	case "":
+		if runtime.GOOS == "windows" && needsShell(argv) {
+			// Quote the arguments so that those with spaces or
+			// metacharacters stay whole
+			return shellCommand(ctx, cmdLine(argv))
+		}
+	case config.ShellNone:
+	case config.ShellCmd:
+		return shellCommand(ctx, shellScript(shell, argv))
+	default:
+		argv = shellArgv(shell, argv)
+	}
+	return exec.CommandContext(ctx, argv[0], argv[1:]...)
+}
+
+// prepareCommand builds the process for a command, run through docker exec
+// if it has a container. The container is nil otherwise.
+func (r *Runner) prepareCommand(ctx context.Context, cmd config.Command, t Trigger, argv []string) (*exec.Cmd, *containerExec) {
+	if cmd.Container != "" {
+		return r.containerCommand(ctx, cmd, t, argv)
+	}
+	command := buildCommand(ctx, r.shellFor(cmd), argv)
+	r.configureCommand(command, cmd, t)
+	return command, nil
+}
+
+// startCommand starts the command with its output streamed through the
+// logger and returns a function to call once the command has been waited
+// on, which flushes any trailing partial lines and lets go of the processes
+// it started. The lines shown are kept in tail and the output is read by the
+// parser in tests, if set. Interactive commands write to the terminal
+// directly, so that prompts without a newline show up.
+func (r *Runner) startCommand(command *exec.Cmd, cmd config.Command, t Trigger, out *commandOutput, tail *excerpt, tests *testOutput, ctr *containerExec) (func(), error) {
+	if cmd.Interactive {
+		command.Stdout = os.Stdout
+		command.Stderr = os.Stderr
+		if ctr != nil {
+			ctr.stdout = os.Stdout
+			command.Stdout = ctr
+		}
+		release, err := r.startTree(command, cmd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start command: %w", err)
+		}
+		return release, nil
+	}
+
+	label := r.label(cmd)
+	show := r.log.CommandOutput
+	// The output of a command that runs on, restarted on change, isn't
+	// grouped: its group would never close
+	var group *logger.Group
+	if p := r.paneFor(cmd); p != nil {
+		p.writeLine(fmt.Sprintf("── %s %s ──", commandName(cmd), time.Now().Format("15:04:05")))
+		show = func(label, line string, isError bool) {
+			if err := p.writeLine(line); err != nil {
+				r.dropPane(p)
+				r.log.CommandOutput(label, line, isError)
+			}
+		}
+	} else if !cmd.IsRestart() {
+		title := cmd.Name
+		if title == "" {
+			title = strings.Join(command.Args, " ")
+		}
+		if group = r.log.CommandGroup(title); group != nil {
+			show = group.Output
+		}
+	}
+	// Output from a terminal, that of the command or docker exec -t of its
+	// container, comes with the escape sequences a terminal reads
+	var term *ptyOutput
+	if cmd.PTY && ctr == nil {
+		term = r.openPTY(command, label)
+	}
+	raw := term != nil || cmd.PTY && ctr != nil
+	// Only a command given a pseudo-terminal of its own starts in it
+	cmd.PTY = term != nil
+
+	// Filtered lines are still saved to output_dir
+	dropped := outputFilter(cmd)
+	highlight := r.highlighter(cmd)
+	link := r.linker(command.Dir)
+	emit := func(line string, isError bool) {
+		shown := line
+		if raw {
+			// A terminal shows what follows the last carriage return, such as
+			// the final state of a progress bar; the escape sequences that
+			// color it are kept for the terminal alone
+			if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+				shown = line[i+1:]
+			}
+			line = ansi.Strip(shown)
+		}
+		out.writeLine(line)
+		// A parser may show the output its events carry instead of them
+		parsed, ok := tests.parse(line)
+		if !ok {
+			return
+		}
+		if parsed != line {
+			line, shown = parsed, parsed
+		}
+		if dropped(line) {
+			return
+		}
+		tail.add(line)
+		// Highlighting restyles a line, and a terminal's colors only show
+		// along with gowatch's own
+		if styled := highlight(line); styled != line || !raw || !r.log.Colors() {
+			shown = styled
+		}
+		show(label, link(shown), isError)
+		if r.onOutput != nil {
+			r.onOutput(t, line, isError)
+		}
+	}
+	stdout := newLineWriter(func(line string) { emit(line, false) })
+	stderr := newLineWriter(func(line string) { emit(line, true) })
+	if term == nil {
+		command.Stdout = stdout
+		command.Stderr = stderr
+	}
+	if ctr != nil {
+		ctr.stdout = stdout
+		command.Stdout = ctr
+	}
+
+	// Don't hang forever on pipes held open by orphaned grandchildren
+	if command.WaitDelay < outputWaitDelay {
+		command.WaitDelay = outputWaitDelay
+	}
+
+	release, err := r.startTree(command, cmd)
+	if err != nil {
+		if term != nil {
+			term.abort()
+		}
+		if group != nil {
+			group.End()
+		}
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+	if term != nil {
+		term.start(stdout)
+	}
+
+	return func() {
+		if term != nil {
+			term.close()
+		}
+		stdout.Flush()
+		stderr.Flush()
+		if group != nil {
+			group.End()
+		}
+		release()
+	}, nil
+}
+
+// openPTY gives a command with pty: true a pseudo-terminal for its output,
+// returning nil if it can't, in which case the command writes to pipes. The
+// terminal is as wide as gowatch's output lines leave after the label.
+func (r *Runner) openPTY(command *exec.Cmd, label string) *ptyOutput {
+	indent := len("  │ ")
+	if label != "" {
+		indent += len(label) + len("[] ")
+	}
+	term, err := openPTY(command, indent)
+	if err != nil {
+		r.mu.Lock()
+		warn := !r.noPTY
+		r.noPTY = true
+		r.mu.Unlock()
+		if warn {
+			r.log.Warn("Running commands without a pseudo-terminal: %v", err)
+		}
+		return nil
+	}
+	return term
+}
+
+// startTree starts a command so that signalling or killing it reaches the
+// processes it starts too: in a process group of its own on Unix, unless
+// it is interactive, and in a job object on Windows. A priority or limits
+// that can't be set, such as a negative nice value without the privileges
+// for it, leave the command running without them. The returned function
+// lets go of the processes once the command has been waited on.
+func (r *Runner) startTree(command *exec.Cmd, cmd config.Command) (func(), error) {
+	prepareTree(command, cmd)
+	unlimit, err := limitTree(command, cmd)
+	if err != nil {
+		r.mu.Lock()
+		warn := !r.unlimited
+		r.unlimited = true
+		r.mu.Unlock()
+		if warn {
+			r.log.Warn("Running commands without their limits: %v", err)
+		}
+		unlimit = func() {}
+	}
+	if err := command.Start(); err != nil {
+		unlimit()
+		return nil, err
+	}
+	if err := trackTree(command); err != nil {
+		command.Process.Kill()
+		command.Wait()
+		unlimit()
+		return nil, err
+	}
+	if err := setPriority(command.Process, cmd); err != nil {
+		r.log.Warn("Failed to set the priority of process %d: %v", command.Process.Pid, err)
+	}
+	return func() {
+		untrackTree(command.Process)
+		unlimit()
+	}, nil
+}
+
+// openOutput creates the output file of a command if the run saves output.
+// Failing to do so doesn't stop the command.
+func (r *Runner) openOutput(t Trigger, cmd config.Command, cmdString string) *commandOutput {
+	if t.output == nil {
+		return nil
+	}
+	out, err := t.output.create(cmd, cmdString)
+	if err != nil {
+		r.log.Warn("Failed to save command output: %v", err)
+		return nil
+	}
+	return out
+}
+
+// label returns the name of the command padded to the longest name in the
+// config, so that the output of commands running side by side lines up
+func (r *Runner) label(cmd config.Command) string {
+	if cmd.Name == "" {
+		return ""
+	}
+
+	cfg := r.config()
+	width := 0
+	for _, c := range slices.Concat(cfg.Setup, cfg.OnChange.Commands, cfg.OnSuccess, cfg.OnFailure, cfg.OnExit) {
+		width = max(width, len(c.Name))
+	}
+	return fmt.Sprintf("%-*s", width, cmd.Name)
+}
+
+// lineWriter is an io.Writer that hands complete lines to a callback
+type lineWriter struct {
+	mu   sync.Mutex
+	buf  []byte
+	emit func(string)
+}
+
+func newLineWriter(emit func(string)) *lineWriter {
+	return &lineWriter{emit: emit}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush emits any buffered partial line
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.emit(strings.TrimSuffix(string(w.buf), "\r"))
+		w.buf = nil
+	}
+}
+
+// needsShell determines if a command needs shell interpretation on Windows
+func needsShell(cmd []string) bool {
+	if len(cmd) == 0 {
+		return false
+	}
+
+	// Commands that need shell
+	shellCommands := map[string]bool{
+		"sh":         true,
+		"bash":       true,
+		"powershell": true,
+		"pwsh":       true,
+	}
+
+	firstCmd := strings.ToLower(cmd[0])
+
+	// If explicitly using a shell
+	if shellCommands[firstCmd] {
+		return true
+	}
+
+	// If command contains shell operators
+	fullCmd := strings.Join(cmd, " ")
+	shellOperators := []string{"|", ">", "<", ">>", "&&", "||", "&"}
+	for _, op := range shellOperators {
+		if strings.Contains(fullCmd, op) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replacePlaceholders substitutes {path}, {event} and {files}. An argument
+// that is exactly "{files}" expands to one argument per changed file;
+// elsewhere {files} is replaced with the space-separated list. The {name:q}
+// forms are quoted for the shell of the command.
+func (r *Runner) replacePlaceholders(cmd config.Command, t Trigger) []string {
+	files := t.mappedFiles()
+	values := r.placeholderValues(t)
+	addQuoted(values, files, r.quotingFor(cmd))
+	result := make([]string, 0, len(cmd.Cmd))
+	for _, part := range cmd.Cmd {
+		if part == "{files}" {
+			result = append(result, files...)
+			continue
+		}
+		result = append(result, expandPlaceholders(part, values))
+	}
+	return result
+}
+
+// shellFor returns the shell configured for a command on this platform
+func (r *Runner) shellFor(cmd config.Command) string {
+	return r.config().ShellFor(cmd, runtime.GOOS)
+}
+
+// quotingFor returns the shell the quoted placeholders of a command are
+// for: the one it runs through if configured, otherwise the one its
+// command line invokes
+func (r *Runner) quotingFor(cmd config.Command) string {
+	if shell, ok := shellNames[r.shellFor(cmd)]; ok {
+		return shell
+	}
+	return shellOf(cmd.Cmd, runtime.GOOS)
+}
+
+// placeholderValues derives the value of every placeholder from a trigger
+func (r *Runner) placeholderValues(t Trigger) map[string]string {
+	values := map[string]string{
+		"path":       t.mapPath(t.Path),
+		"event":      t.Event,
+		"files":      strings.Join(t.mappedFiles(), " "),
+		"dir":        "",
+		"base":       "",
+		"ext":        "",
+		"name_noext": "",
+		"relpath":    t.Path,
+	}
+	if t.outcome != nil {
+		values["failed_cmd"] = t.outcome.failedCommand
+		values["exit_code"] = strconv.Itoa(t.outcome.exitCode)
+	}
+	if t.Path == "" {
+		return values
+	}
+
+	base := filepath.Base(t.Path)
+	ext := filepath.Ext(base)
+	values["dir"] = t.mapPath(filepath.Dir(t.Path))
+	values["base"] = base
+	values["ext"] = ext
+	values["name_noext"] = strings.TrimSuffix(base, ext)
+	if rel, ok := r.relativeToWatchRoot(t.Path); ok {
+		values["relpath"] = rel
+	}
+	return values
+}
+
+// addQuoted adds the {name:q} form of every placeholder, quoted for shell;
+// {files:q} quotes each file
+func addQuoted(values map[string]string, files []string, shell string) {
+	for _, name := range slices.Collect(maps.Keys(values)) {
+		values[name+":q"] = quoteFor(shell, values[name])
+	}
+	quoted := make([]string, len(files))
+	for i, f := range files {
+		quoted[i] = quoteFor(shell, f)
+	}
+	values["files:q"] = strings.Join(quoted, " ")
+}
+
+// relativeToWatchRoot returns path relative to the most specific watch path
+// containing it
+func (r *Runner) relativeToWatchRoot(path string) (string, bool) {
+	// Remote changes are named "host:/path" after their watch path's remote
+	for _, w := range r.config().Watch {
+		if w.Remote == "" {
+			continue
+		}
+		if rel, ok := strings.CutPrefix(path, strings.TrimSuffix(w.Remote, "/")+"/"); ok {
+			return rel, true
+		}
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+
+	best, bestLen := "", -1
+	for _, w := range r.config().Watch {
+		if w.Remote != "" {
+			continue
+		}
+		root, err := filepath.Abs(w.Path)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, absPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if len(root) > bestLen {
+			best, bestLen = rel, len(root)
+		}
+	}
+	return best, bestLen >= 0
+}
+
+// expandPlaceholders replaces {name} with its value. Doubled braces escape a
+// literal brace ("{{path}}" becomes "{path}"); unknown names are left as is.
+func expandPlaceholders(s string, values map[string]string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '{' && strings.HasPrefix(s[i:], "{{"):
+			b.WriteByte('{')
+			i++
+		case c == '}' && strings.HasPrefix(s[i:], "}}"):
+			b.WriteByte('}')
+			i++
+		case c == '{':
+			end := strings.IndexAny(s[i+1:], "{}")
+			if end >= 0 && s[i+1+end] == '}' {
+				if v, ok := values[s[i+1:i+1+end]]; ok {
+					b.WriteString(v)
+					i += end + 1
+					continue
+				}
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// configureCommand sets the environment and working directory of a command.
+// Placeholders are expanded in env values and cwd.
+func (r *Runner) configureCommand(command *exec.Cmd, cmd config.Command, t Trigger) {
+	values := r.placeholderValues(t)
+	command.Env = append(commandEnv(t), expandedEnv(cmd, values)...)
+	command.Dir = r.workDir(cmd, values)
+}
+
+// workDir returns the directory a command runs in: its cwd, expanded and
+// resolved against the directory of the config, or that directory. It is
+// "" for gowatch's working directory.
+func (r *Runner) workDir(cmd config.Command, values map[string]string) string {
+	cfg := r.config()
+	if cmd.Cwd == "" {
+		return cfg.Dir
+	}
+	dir := expandPlaceholders(cmd.Cwd, values)
+	if cfg.Dir == "" {
+		return dir
+	}
+	abs, _ := cfg.Abs(dir)
+	return abs
+}
+
+// expandedEnv returns the env entries of a command with placeholders
+// expanded
+func expandedEnv(cmd config.Command, values map[string]string) []string {
+	env := make([]string, 0, len(cmd.Env))
+	for _, kv := range cmd.Env {
+		env = append(env, expandPlaceholders(kv, values))
+	}
+	return env
+}
+
+// commandEnv returns the child environment with details of the trigger
+func commandEnv(t Trigger) []string {
+	return append(os.Environ(), triggerEnv(t, string(os.PathListSeparator))...)
+}
+
+// triggerEnv returns the GOWATCH_ variables describing the trigger, with
+// GOWATCH_FILES joined by sep
+func triggerEnv(t Trigger, sep string) []string {
+	dir := ""
+	if t.Path != "" {
+		dir = t.mapPath(filepath.Dir(t.Path))
+	}
+	env := []string{
+		"GOWATCH_PATH=" + t.mapPath(t.Path),
+		"GOWATCH_EVENT=" + t.Event,
+		"GOWATCH_DIR=" + dir,
+		"GOWATCH_TIMESTAMP=" + t.Time.Format(time.RFC3339Nano),
+		"GOWATCH_RUN_ID=" + strconv.FormatInt(t.RunID, 10),
+		"GOWATCH_FILES=" + strings.Join(t.mappedFiles(), sep),
+	}
+	if t.outcome != nil {
+		env = append(env,
+			"GOWATCH_FAILED_CMD="+t.outcome.failedCommand,
+			"GOWATCH_EXIT_CODE="+strconv.Itoa(t.outcome.exitCode),
+		)
+	}
+	return env
+}
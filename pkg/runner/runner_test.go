@@ -0,0 +1,2020 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+
+	"github.com/fatih/color"
+)
+
+func TestRunner_ReplacePlaceholders(t *testing.T) {
+	cfg := &config.Config{
+		Watch: []config.WatchPath{{Path: "/tmp"}, {Path: "/tmp/project"}},
+	}
+	log := logger.New(logger.LevelInfo, false)
+	r := New(cfg, Options{Logger: log})
+
+	tests := []struct {
+		name     string
+		cmd      []string
+		path     string
+		event    string
+		files    []string
+		expected []string
+	}{
+		{
+			name:     "no placeholders",
+			cmd:      []string{"echo", "hello"},
+			path:     "/tmp/test.go",
+			event:    "WRITE",
+			expected: []string{"echo", "hello"},
+		},
+		{
+			name:     "path placeholder",
+			cmd:      []string{"echo", "{path}"},
+			path:     "/tmp/test.go",
+			event:    "WRITE",
+			expected: []string{"echo", "/tmp/test.go"},
+		},
+		{
+			name:     "event placeholder",
+			cmd:      []string{"echo", "Event: {event}"},
+			path:     "/tmp/test.go",
+			event:    "WRITE",
+			expected: []string{"echo", "Event: WRITE"},
+		},
+		{
+			name:     "multiple placeholders",
+			cmd:      []string{"process", "{path}", "--event={event}"},
+			path:     "/tmp/test.go",
+			event:    "CREATE",
+			expected: []string{"process", "/tmp/test.go", "--event=CREATE"},
+		},
+		{
+			name:     "files placeholder as argument",
+			cmd:      []string{"gofmt", "-l", "{files}"},
+			path:     "/tmp/b.go",
+			event:    "WRITE",
+			files:    []string{"/tmp/a.go", "/tmp/b.go"},
+			expected: []string{"gofmt", "-l", "/tmp/a.go", "/tmp/b.go"},
+		},
+		{
+			name:     "files placeholder inline",
+			cmd:      []string{"echo", "changed: {files}"},
+			path:     "/tmp/b.go",
+			event:    "WRITE",
+			files:    []string{"/tmp/a.go", "/tmp/b.go"},
+			expected: []string{"echo", "changed: /tmp/a.go /tmp/b.go"},
+		},
+		{
+			name:     "files defaults to path",
+			cmd:      []string{"echo", "{files}"},
+			path:     "/tmp/test.go",
+			event:    "WRITE",
+			expected: []string{"echo", "/tmp/test.go"},
+		},
+		{
+			name:     "derived placeholders",
+			cmd:      []string{"echo", "{dir}", "{base}", "{ext}", "{name_noext}"},
+			path:     "/tmp/src/main.test.go",
+			event:    "WRITE",
+			expected: []string{"echo", "/tmp/src", "main.test.go", ".go", "main.test"},
+		},
+		{
+			name:     "relpath uses most specific watch root",
+			cmd:      []string{"echo", "{relpath}"},
+			path:     "/tmp/project/pkg/a.go",
+			event:    "WRITE",
+			expected: []string{"echo", "pkg/a.go"},
+		},
+		{
+			name:     "relpath outside watch roots",
+			cmd:      []string{"echo", "{relpath}"},
+			path:     "/var/a.go",
+			event:    "WRITE",
+			expected: []string{"echo", "/var/a.go"},
+		},
+		{
+			name:     "escaped braces",
+			cmd:      []string{"printf", "{{path}} is {path}, {{}} {unknown}"},
+			path:     "/tmp/a.go",
+			event:    "WRITE",
+			expected: []string{"printf", "{path} is /tmp/a.go, {} {unknown}"},
+		},
+		{
+			name:     "no path",
+			cmd:      []string{"echo", "[{dir}{base}{ext}]"},
+			event:    "MANUAL",
+			expected: []string{"echo", "[]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Paths are written with slashes and converted for the host OS
+			var files []string
+			for _, f := range tt.files {
+				files = append(files, filepath.FromSlash(f))
+			}
+			trigger := Trigger{Path: filepath.FromSlash(tt.path), Event: tt.event, Files: files}
+
+			result := r.replacePlaceholders(config.Command{Cmd: tt.cmd}, trigger)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("expected %d parts, got %d", len(tt.expected), len(result))
+			}
+			for i := range result {
+				if expected := filepath.FromSlash(tt.expected[i]); result[i] != expected {
+					t.Errorf("part %d: expected %q, got %q", i, expected, result[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunner_DryRun(t *testing.T) {
+	cfg := &config.Config{
+		OnChange: config.OnChange{
+			Commands: []config.Command{
+				{Cmd: []string{"echo", "test"}},
+			},
+		},
+		MaxConcurrency: 1,
+	}
+	log := logger.New(logger.LevelInfo, false)
+	r := New(cfg, Options{Logger: log, DryRun: true})
+
+	ctx := context.Background()
+	results := r.Run(ctx, "/tmp/test.go", "WRITE")
+
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+
+	if results[0].ExitCode != 0 {
+		t.Errorf("expected exit code 0 in dry run, got %d", results[0].ExitCode)
+	}
+
+	if results[0].Error != nil {
+		t.Errorf("expected no error in dry run, got %v", results[0].Error)
+	}
+}
+
+func TestRunner_DryRunPlan(t *testing.T) {
+	cfg := &config.Config{
+		OnChange: config.OnChange{
+			Commands: []config.Command{
+				{Name: "test", Cmd: []string{"go", "test", "{dir}"}, Cwd: "{dir}", Env: []string{"FILE={path}"}},
+				{Name: "lint", Cmd: []string{"golangci-lint", "run"}, Priority: 5, Weight: 2},
+				{Name: "gen", Cmd: []string{"go", "generate"}, Events: []string{"create"}},
+			},
+		},
+		MaxConcurrency: 2,
+	}
+	var out strings.Builder
+	r := New(cfg, Options{Logger: logger.NewWriter(&out, logger.LevelInfo, false), DryRun: true})
+	r.Run(context.Background(), filepath.Join("pkg", "a.go"), "WRITE")
+
+	got := out.String()
+	for _, want := range []string{
+		"2 commands in parallel, up to 2 slots at once",
+		"1. on_change.commands[1] (lint)\n",
+		"priority 5, weight 2\n",
+		"2. on_change.commands[0] (test)\n",
+		`argv: "go" "test" "pkg"`,
+		"cwd:  pkg\n",
+		"GOWATCH_EVENT=WRITE\n",
+		"FILE=" + filepath.Join("pkg", "a.go") + "\n",
+		"Would skip on_change.commands[2] (gen): only runs on create events",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("dry run output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRunner_ExecuteCommand(t *testing.T) {
+	cfg := &config.Config{
+		MaxConcurrency: 1,
+	}
+	log := logger.New(logger.LevelInfo, false)
+	r := New(cfg, Options{Logger: log})
+
+	ctx := context.Background()
+
+	// Test successful command
+	cmd := config.Command{
+		Cmd:     []string{"echo", "hello"},
+		Timeout: "5s",
+	}
+
+	result := r.executeCommand(ctx, cmd, Trigger{Path: "/tmp/test.go", Event: "WRITE"})
+
+	if result.ExitCode != 0 {
+		t.Errorf("expected exit code 0, got %d", result.ExitCode)
+	}
+
+	if result.Error != nil {
+		t.Errorf("expected no error, got %v", result.Error)
+	}
+
+	if result.Duration == 0 {
+		t.Error("expected non-zero duration")
+	}
+}
+
+func TestRunner_ExecuteCommand_Timeout(t *testing.T) {
+	cfg := &config.Config{
+		MaxConcurrency: 1,
+	}
+	log := logger.New(logger.LevelInfo, false)
+	r := New(cfg, Options{Logger: log})
+
+	ctx := context.Background()
+
+	// Test command that times out
+	cmd := config.Command{
+		Cmd:     []string{"sleep", "10"},
+		Timeout: "100ms",
+	}
+
+	result := r.executeCommand(ctx, cmd, Trigger{Path: "/tmp/test.go", Event: "WRITE"})
+
+	if result.ExitCode == 0 {
+		t.Error("expected non-zero exit code for timeout")
+	}
+
+	if result.Error == nil {
+		t.Error("expected error for timeout")
+	}
+	if !result.TimedOut || result.Canceled {
+		t.Errorf("TimedOut = %v, Canceled = %v, want a timeout", result.TimedOut, result.Canceled)
+	}
+
+	// Cancelling the run isn't a timeout
+	ctx, cancel := context.WithCancel(ctx)
+	time.AfterFunc(100*time.Millisecond, cancel)
+	cmd.Timeout = "10s"
+	result = r.executeCommand(ctx, cmd, Trigger{Path: "/tmp/test.go", Event: "WRITE"})
+	if result.TimedOut || !result.Canceled {
+		t.Errorf("TimedOut = %v, Canceled = %v, want cancellation", result.TimedOut, result.Canceled)
+	}
+}
+
+func TestRunner_Sequential(t *testing.T) {
+	cfg := &config.Config{
+		OnChange: config.OnChange{
+			Commands: []config.Command{
+				{Cmd: []string{"echo", "first"}},
+				{Cmd: []string{"echo", "second"}},
+			},
+		},
+		MaxConcurrency: 1,
+	}
+	log := logger.New(logger.LevelInfo, false)
+	r := New(cfg, Options{Logger: log, Sequential: true})
+
+	ctx := context.Background()
+	results := r.Run(ctx, "/tmp/test.go", "WRITE")
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+
+	for i, result := range results {
+		if result.ExitCode != 0 {
+			t.Errorf("command %d: expected exit code 0, got %d", i, result.ExitCode)
+		}
+	}
+}
+
+func TestRunner_Parallel(t *testing.T) {
+	cfg := &config.Config{
+		OnChange: config.OnChange{
+			Commands: []config.Command{
+				{Cmd: []string{"echo", "first"}},
+				{Cmd: []string{"echo", "second"}},
+				{Cmd: []string{"echo", "third"}},
+			},
+		},
+		MaxConcurrency: 2,
+	}
+	log := logger.New(logger.LevelInfo, false)
+	r := New(cfg, Options{Logger: log})
+
+	ctx := context.Background()
+	start := time.Now()
+	results := r.Run(ctx, "/tmp/test.go", "WRITE")
+	duration := time.Since(start)
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+
+	for i, result := range results {
+		if result.ExitCode != 0 {
+			t.Errorf("command %d: expected exit code 0, got %d", i, result.ExitCode)
+		}
+	}
+
+	// Parallel execution should be faster than sequential
+	// This is a rough check - in reality, echo commands are very fast
+	if duration > 5*time.Second {
+		t.Errorf("parallel execution took too long: %v", duration)
+	}
+}
+
+func TestRunner_PriorityAndWeight(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	sleep := func(name string) []string { return []string{"sh", "-c", "sleep 0.2", name} }
+	cfg := &config.Config{
+		MaxConcurrency: 2,
+		OnChange: config.OnChange{Commands: []config.Command{
+			{Cmd: sleep("low")},
+			{Cmd: sleep("heavy"), Priority: 1, Weight: 2},
+			{Cmd: sleep("high"), Priority: 2},
+		}},
+	}
+
+	var mu sync.Mutex
+	var started []string
+	running, overlapped := map[string]bool{}, false
+	r := New(cfg, Options{
+		OnStart: func(_ Trigger, command []string) {
+			mu.Lock()
+			defer mu.Unlock()
+			name := command[3]
+			started = append(started, name)
+			if (name == "heavy" && len(running) > 0) || running["heavy"] {
+				overlapped = true
+			}
+			running[name] = true
+		},
+		OnResult: func(_ Trigger, result RunResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			delete(running, result.Command[3])
+		},
+	})
+
+	for _, deps := range []bool{false, true} {
+		t.Run(fmt.Sprintf("depends_on=%v", deps), func(t *testing.T) {
+			started, overlapped = nil, false
+			if deps {
+				// Run as a graph, where low also waits for high
+				for i := range cfg.OnChange.Commands {
+					cfg.OnChange.Commands[i].Name = cfg.OnChange.Commands[i].Cmd[3]
+				}
+				cfg.OnChange.Commands[0].DependsOn = []string{"high"}
+			}
+			r.Run(context.Background(), "main.go", "WRITE")
+
+			// The heavy command takes both slots, and holds back the
+			// command of lower priority until it is done
+			if want := []string{"high", "heavy", "low"}; !slices.Equal(started, want) {
+				t.Errorf("start order = %v, want %v", started, want)
+			}
+			if overlapped {
+				t.Error("the heavy command ran alongside another one")
+			}
+		})
+	}
+}
+
+func TestRunner_Stages(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "stages.txt")
+	step := func(name string, code int) config.Command {
+		return config.Command{Cmd: []string{"sh", "-c", fmt.Sprintf("echo %s >> '%s'; exit %d", name, out, code)}}
+	}
+	cfg := &config.Config{
+		MaxConcurrency: 2,
+		OnChange: config.OnChange{Stages: []config.Stage{
+			{Name: "check", Commands: []config.Command{step("lint", 0), step("vet", 0)}},
+			{Name: "test", Commands: []config.Command{step("test", 1)}},
+			{Name: "build", Commands: []config.Command{step("build", 0)}},
+		}},
+	}
+	cfg.SetDefaults()
+	r := New(cfg, Options{Logger: logger.New(logger.LevelInfo, false)})
+
+	results := r.Run(context.Background(), "main.go", "WRITE")
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want the check and test stages", len(results))
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Fields(string(data))
+	slices.Sort(lines[:2])
+	if want := []string{"lint", "vet", "test"}; !slices.Equal(lines, want) {
+		t.Errorf("ran %v, want %v", lines, want)
+	}
+}
+
+func TestRunner_RestartMode(t *testing.T) {
+	cfg := &config.Config{
+		OnChange: config.OnChange{
+			Commands: []config.Command{
+				{Cmd: []string{"sleep", "10"}, Mode: config.ModeRestart},
+			},
+		},
+		MaxConcurrency: 1,
+	}
+	log := logger.New(logger.LevelInfo, false)
+	r := New(cfg, Options{Logger: log})
+	defer r.Close()
+
+	ctx := context.Background()
+
+	start := time.Now()
+	results := r.Run(ctx, "/tmp/test.go", "WRITE")
+	if len(results) != 1 || results[0].ExitCode != 0 {
+		t.Fatalf("expected process to start, got %+v", results)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("restart mode blocked waiting for the process")
+	}
+
+	r.mu.Lock()
+	first := r.procs[0]
+	r.mu.Unlock()
+	if first == nil {
+		t.Fatal("expected a running process")
+	}
+
+	r.Run(ctx, "/tmp/test.go", "WRITE")
+
+	select {
+	case <-first.done:
+		// Expected
+	case <-time.After(2 * time.Second):
+		t.Fatal("previous process was not stopped on restart")
+	}
+
+	r.mu.Lock()
+	second := r.procs[0]
+	r.mu.Unlock()
+	if second == nil || second == first {
+		t.Fatal("expected a new process after restart")
+	}
+}
+
+func TestCommandEnv(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	path := filepath.Join("src", "main.go")
+	env := commandEnv(Trigger{Path: path, Event: "WRITE", Time: ts, RunID: 42})
+
+	want := map[string]string{
+		"GOWATCH_PATH":      path,
+		"GOWATCH_EVENT":     "WRITE",
+		"GOWATCH_DIR":       "src",
+		"GOWATCH_TIMESTAMP": "2024-01-02T03:04:05Z",
+		"GOWATCH_RUN_ID":    "42",
+		"GOWATCH_FILES":     path,
+	}
+
+	got := make(map[string]string)
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(k, "GOWATCH_") {
+			got[k] = v
+		}
+	}
+
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestRunner_Retries(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gowatch-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cfg := &config.Config{MaxConcurrency: 1}
+	r := New(cfg, Options{Logger: logger.New(logger.LevelInfo, false)})
+
+	// Fails until the marker file exists, which the first attempt creates
+	marker := filepath.Join(tmpDir, "marker")
+	cmd := config.Command{
+		Cmd:          []string{"sh", "-c", "test -f '" + filepath.ToSlash(marker) + "' || { touch '" + filepath.ToSlash(marker) + "'; exit 1; }"},
+		Retries:      2,
+		RetryBackoff: "10ms",
+	}
+
+	result := r.executeCommand(context.Background(), cmd, Trigger{Path: "/tmp/test.go", Event: "WRITE"})
+	if result.ExitCode != 0 {
+		t.Fatalf("expected success after retry, got exit code %d", result.ExitCode)
+	}
+	if result.Attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", result.Attempts)
+	}
+
+	// A command that always fails is reported after exhausting its retries
+	cmd = config.Command{Cmd: []string{"sh", "-c", "exit 3"}, Retries: 2, RetryBackoff: "10ms"}
+	start := time.Now()
+	result = r.executeCommand(context.Background(), cmd, Trigger{Path: "/tmp/test.go", Event: "WRITE"})
+	if result.ExitCode != 3 || result.Attempts != 3 {
+		t.Errorf("expected exit code 3 after 3 attempts, got %d after %d", result.ExitCode, result.Attempts)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected exponential backoff (10ms + 20ms), finished in %s", elapsed)
+	}
+}
+
+func TestRunner_Setup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "setup.txt")
+	step := func(name string, code int) config.Command {
+		return config.Command{Cmd: []string{"sh", "-c", fmt.Sprintf("echo %s >> '%s'; exit %d", name, out, code)}}
+	}
+	cfg := &config.Config{
+		MaxConcurrency: 1,
+		OnChange:       config.OnChange{Commands: []config.Command{{Cmd: []string{"true"}}}},
+		Setup:          []config.Command{step("{event}", 0), step("migrate", 2), step("seed", 0)},
+	}
+	r := New(cfg, Options{Logger: logger.New(logger.LevelInfo, false)})
+
+	err := r.Setup(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "migrate") {
+		t.Errorf("Setup() = %v, want the migrate step to fail", err)
+	}
+	// The first failure stops the setup
+	data, _ := os.ReadFile(out)
+	if got := string(data); got != "SETUP\nmigrate\n" {
+		t.Errorf("setup output = %q", got)
+	}
+}
+
+func TestRunner_Exit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "exit.txt")
+	cfg := &config.Config{
+		MaxConcurrency: 1,
+		OnChange:       config.OnChange{Commands: []config.Command{{Cmd: []string{"true"}}}},
+		OnExit: []config.Command{
+			{Cmd: []string{"sh", "-c", "echo {event} >> '" + out + "'; exit 1"}},
+			// A failing hook doesn't stop the next one
+			{Cmd: []string{"sh", "-c", "echo done >> '" + out + "'"}},
+		},
+	}
+	r := New(cfg, Options{Logger: logger.New(logger.LevelInfo, false)})
+	r.Exit(context.Background())
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("on_exit did not run: %v", err)
+	}
+	if got := string(data); got != "EXIT\ndone\n" {
+		t.Errorf("on_exit output = %q", got)
+	}
+}
+
+func TestRunner_Interactive(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "answer.txt")
+	stdin, typed, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	typed.WriteString("yes\n")
+	typed.Close()
+
+	released := false
+	cfg := &config.Config{
+		MaxConcurrency: 1,
+		OnChange: config.OnChange{Commands: []config.Command{{
+			Cmd:         []string{"sh", "-c", "read answer; echo \"$answer\" > '" + out + "'"},
+			Interactive: true,
+		}}},
+	}
+	r := New(cfg, Options{
+		Logger: logger.New(logger.LevelInfo, false),
+		Terminal: func() (*os.File, func()) {
+			return stdin, func() { released = true }
+		},
+	})
+
+	results := r.Run(context.Background(), "main.go", "WRITE")
+	if len(results) != 1 || results[0].ExitCode != 0 {
+		t.Fatalf("results = %+v", results)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "yes\n" {
+		t.Errorf("command read %q", data)
+	}
+	if !released {
+		t.Error("terminal was not released")
+	}
+
+	// Long-running commands don't wait for a busy terminal
+	terminal <- struct{}{}
+	defer func() { <-terminal }()
+	cfg.OnChange.Commands[0].Mode = config.ModeRestart
+	results = r.Run(context.Background(), "main.go", "WRITE")
+	if len(results) != 1 || !errors.Is(results[0].Error, errTerminalBusy) {
+		t.Errorf("restart with a busy terminal = %+v", results)
+	}
+}
+
+func TestRunner_Hooks(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gowatch-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	out := filepath.ToSlash(filepath.Join(tmpDir, "hook.txt"))
+	hook := func(label string) []config.Command {
+		return []config.Command{{
+			Cmd: []string{"sh", "-c", "echo " + label + " {exit_code} \"$GOWATCH_FAILED_CMD\" > '" + out + "'"},
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		cmd      []string
+		expected string
+	}{
+		{"success", []string{"sh", "-c", "exit 0"}, "success 0"},
+		{"failure", []string{"sh", "-c", "exit 2"}, "failure 2 sh -c exit 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(out)
+			cfg := &config.Config{
+				MaxConcurrency: 1,
+				OnChange:       config.OnChange{Commands: []config.Command{{Cmd: tt.cmd}}},
+				OnSuccess:      hook("success"),
+				OnFailure:      hook("failure"),
+			}
+			r := New(cfg, Options{Logger: logger.New(logger.LevelInfo, false)})
+			r.Run(context.Background(), "/tmp/test.go", "WRITE")
+
+			data, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatalf("hook did not run: %v", err)
+			}
+			if got := strings.TrimSpace(string(data)); got != tt.expected {
+				t.Errorf("hook output = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRunner_CommandEnvAndCwd(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gowatch-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cfg := &config.Config{MaxConcurrency: 1}
+	r := New(cfg, Options{Logger: logger.New(logger.LevelInfo, false)})
+
+	// Writes to a relative path, which only lands in tmpDir if cwd applies
+	cmd := config.Command{
+		Cmd: []string{"sh", "-c", "echo \"$GREETING\" > out.txt"},
+		Env: []string{"GREETING=hello {base}"},
+		Cwd: tmpDir,
+	}
+
+	result := r.executeCommand(context.Background(), cmd, Trigger{Path: "/tmp/test.go", Event: "WRITE"})
+	if result.ExitCode != 0 {
+		t.Fatalf("command failed: %v", result.Error)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "out.txt"))
+	if err != nil {
+		t.Fatalf("command did not run in cwd: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "hello test.go" {
+		t.Errorf("GREETING = %q, want %q", got, "hello test.go")
+	}
+}
+
+func TestRunner_OutputLabels(t *testing.T) {
+	api := config.Command{Name: "api", Cmd: []string{"echo", "listening"}}
+	cfg := &config.Config{
+		MaxConcurrency: 1,
+		OnChange: config.OnChange{Commands: []config.Command{
+			api,
+			{Name: "frontend", Cmd: []string{"echo", "compiled"}},
+			{Cmd: []string{"echo", "plain"}},
+		}},
+	}
+
+	var out strings.Builder
+	r := New(cfg, Options{Logger: logger.NewWriter(&out, logger.LevelInfo, false)})
+
+	tests := []struct {
+		cmd  config.Command
+		want string
+	}{
+		{api, "  │ [api]      listening\n"},
+		{cfg.OnChange.Commands[1], "  │ [frontend] compiled\n"},
+		{cfg.OnChange.Commands[2], "  │ plain\n"},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		if result := r.executeCommand(context.Background(), tt.cmd, Trigger{}); result.ExitCode != 0 {
+			t.Fatalf("command failed: %v", result.Error)
+		}
+		if !strings.Contains(out.String(), tt.want) {
+			t.Errorf("output = %q, want line %q", out.String(), tt.want)
+		}
+	}
+}
+
+func TestQuoteFor(t *testing.T) {
+	tests := []struct {
+		shell string
+		in    string
+		want  string
+	}{
+		{shellPOSIX, "main.go", "main.go"},
+		{shellPOSIX, "/src/a b.go", "'/src/a b.go'"},
+		{shellPOSIX, "$(rm -rf ~).go", "'$(rm -rf ~).go'"},
+		{shellPOSIX, "it's.go", `'it'\''s.go'`},
+		{shellPOSIX, "", "''"},
+		{shellCmd, `C:\src\main.go`, `C:\src\main.go`},
+		{shellCmd, `C:\my src\a&b.go`, `"C:\my src\a&b.go"`},
+		{shellCmd, `say "hi"`, `"say \"hi\""`},
+		{shellCmd, `C:\my dir\`, `"C:\my dir\\"`},
+		{shellCmd, "", `""`},
+		{shellPowerShell, `C:\src\main.go`, `C:\src\main.go`},
+		{shellPowerShell, `C:\my src\it's.go`, `'C:\my src\it''s.go'`},
+		{shellPowerShell, "a,b", "'a,b'"},
+		{shellPowerShell, "$env:HOME", "'$env:HOME'"},
+	}
+	for _, tt := range tests {
+		if got := quoteFor(tt.shell, tt.in); got != tt.want {
+			t.Errorf("quoteFor(%s, %q) = %s, want %s", tt.shell, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestShellOf(t *testing.T) {
+	tests := []struct {
+		argv []string
+		goos string
+		want string
+	}{
+		{[]string{"sh", "-c", "go vet {path:q}"}, "linux", shellPOSIX},
+		{[]string{"/bin/bash", "-c", "x"}, "darwin", shellPOSIX},
+		{[]string{"docker", "exec", "dev", "sh", "-c", "x"}, "windows", shellPOSIX},
+		{[]string{"pwsh", "-Command", "x"}, "linux", shellPowerShell},
+		{[]string{`C:\Windows\System32\cmd.exe`, "/C", "x"}, "windows", shellCmd},
+		{[]string{"go", "test", "{path:q}"}, "windows", shellCmd},
+		{[]string{"go", "test", "{path:q}"}, "linux", shellPOSIX},
+	}
+	for _, tt := range tests {
+		if got := shellOf(tt.argv, tt.goos); got != tt.want {
+			t.Errorf("shellOf(%q, %s) = %s, want %s", tt.argv, tt.goos, got, tt.want)
+		}
+	}
+}
+
+func TestCmdLine(t *testing.T) {
+	argv := []string{"sh", "-c", "go build && go test", "|", "findstr", `C:\my src\a.go`, "2>&1"}
+	want := `sh -c "go build && go test" | findstr "C:\my src\a.go" 2>&1`
+	if got := cmdLine(argv); got != want {
+		t.Errorf("cmdLine() = %s, want %s", got, want)
+	}
+}
+
+func TestRunner_QuotedPlaceholders(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	tmpDir := t.TempDir()
+	// A file name a shell would otherwise split, expand and run
+	name := filepath.Join(tmpDir, "it's a $(touch pwned) file.go")
+	other := filepath.Join(tmpDir, "b;c.go")
+
+	var out strings.Builder
+	r := New(&config.Config{}, Options{Logger: logger.NewWriter(&out, logger.LevelInfo, false)})
+	cmd := config.Command{
+		Cmd: []string{"sh", "-c", "printf '<%s>\\n' {path:q}; printf '[%s]\\n' {files:q}"},
+		Cwd: tmpDir,
+	}
+	result := r.executeCommand(context.Background(), cmd, Trigger{Path: name, Event: "WRITE", Files: []string{other, name}})
+	if result.ExitCode != 0 {
+		t.Fatalf("command failed: %v\n%s", result.Error, out.String())
+	}
+	for _, want := range []string{"<" + name + ">", "[" + other + "]", "[" + name + "]"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output = %q, want %q", out.String(), want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "pwned")); err == nil {
+		t.Error("the file name ran as a command")
+	}
+}
+
+func TestShellArgv(t *testing.T) {
+	tests := []struct {
+		shell string
+		argv  []string
+		want  []string
+	}{
+		{config.ShellBash, []string{"go test ./... | tee log"}, []string{"bash", "-c", "go test ./... | tee log"}},
+		{config.ShellZsh, []string{"echo", "a b", "&&", "echo", "it's"}, []string{"zsh", "-c", `echo 'a b' && echo 'it'\''s'`}},
+		{config.ShellPwsh, []string{"Write-Host", "a b"}, []string{"pwsh", "-NoProfile", "-Command", "Write-Host 'a b'"}},
+		{config.ShellPowerShell, []string{"Get-Date"}, []string{"powershell", "-NoProfile", "-Command", "Get-Date"}},
+		{config.ShellCmd, []string{"echo", "a&b"}, []string{"cmd.exe", "/S", "/C", `echo "a&b"`}},
+	}
+	for _, tt := range tests {
+		if got := shellArgv(tt.shell, tt.argv); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shellArgv(%s, %q) = %q, want %q", tt.shell, tt.argv, got, tt.want)
+		}
+	}
+}
+
+func TestRunner_Shell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	tmpDir := t.TempDir()
+	name := filepath.Join(tmpDir, "a $(touch pwned).go")
+
+	tests := []struct {
+		name  string
+		shell string
+		cmd   config.Command
+		want  string
+	}{
+		{"script", config.ShellSh, config.Command{Cmd: []string{"echo one | tr a-z A-Z"}}, "ONE"},
+		{"joined words", config.ShellSh, config.Command{Cmd: []string{"echo", "{path}", "|", "tr", "a-z", "A-Z"}}, strings.ToUpper(name)},
+		{"quoted for the configured shell", config.ShellSh, config.Command{Cmd: []string{"printf '<%s>' {path:q}"}}, "<" + name + ">"},
+		{"command overrides the default", config.ShellSh, config.Command{Cmd: []string{"echo", "a", "|", "b"}, Shell: config.ShellNone}, "a | b"},
+		{"direct by default", "", config.Command{Cmd: []string{"echo", "$HOME"}}, "$HOME"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			cfg := &config.Config{Shell: tt.shell}
+			r := New(cfg, Options{Logger: logger.NewWriter(&out, logger.LevelInfo, false)})
+			tt.cmd.Cwd = tmpDir
+			result := r.executeCommand(context.Background(), tt.cmd, Trigger{Path: name, Event: "WRITE"})
+			if result.ExitCode != 0 {
+				t.Fatalf("command failed: %v\n%s", result.Error, out.String())
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "pwned")); err == nil {
+		t.Error("the file name ran as a command")
+	}
+}
+
+func TestRunner_CommandEvents(t *testing.T) {
+	cfg := &config.Config{
+		MaxConcurrency: 2,
+		OnChange: config.OnChange{
+			Commands: []config.Command{
+				{Cmd: []string{"echo", "created"}, Events: []string{"create"}},
+				{Cmd: []string{"echo", "always"}},
+			},
+		},
+	}
+	r := New(cfg, Options{Logger: logger.New(logger.LevelInfo, false), DryRun: true})
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		trigger  Trigger
+		expected int
+	}{
+		{"write skips create-only command", Trigger{Path: "/tmp/a.go", Event: "WRITE"}, 1},
+		{"create runs both", Trigger{Path: "/tmp/a.go", Event: "CREATE"}, 2},
+		{"combined op", Trigger{Path: "/tmp/a.go", Event: "CREATE|WRITE"}, 2},
+		{"batch ops", Trigger{Path: "/tmp/a.go", Event: "WRITE", Ops: []string{"CREATE", "WRITE"}}, 2},
+		{"manual runs everything", Trigger{Event: "MANUAL"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if results := r.RunTrigger(ctx, tt.trigger); len(results) != tt.expected {
+				t.Errorf("expected %d commands to run, got %d", tt.expected, len(results))
+			}
+		})
+	}
+}
+
+func TestRunner_CommandMatch(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		MaxConcurrency: 2,
+		OnChange: config.OnChange{
+			Commands: []config.Command{
+				{Name: "protoc", Cmd: []string{"echo", "protoc"}, Match: []string{"*.proto"}},
+				{Name: "test", Cmd: []string{"echo", "test"}, Match: []string{"*.go", "!*_test.go", "/testdata/"}},
+				{Name: "always", Cmd: []string{"echo", "always"}},
+			},
+		},
+	}
+	r := New(cfg, Options{DryRun: true})
+
+	tests := []struct {
+		name    string
+		trigger Trigger
+		want    int
+	}{
+		{"proto", Trigger{Path: filepath.Join(wd, "api", "v1.proto"), Event: "WRITE"}, 2},
+		{"go source", Trigger{Path: "main.go", Event: "WRITE"}, 2},
+		{"negated", Trigger{Path: "main_test.go", Event: "WRITE"}, 1},
+		{"anchored directory", Trigger{Path: filepath.Join(wd, "testdata", "in.txt"), Event: "WRITE"}, 2},
+		{"any file of the batch", Trigger{Path: "README.md", Event: "WRITE", Files: []string{"README.md", "a.proto", "b.go"}}, 3},
+		{"manual runs everything", Trigger{Event: "MANUAL"}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if results := r.RunTrigger(context.Background(), tt.trigger); len(results) != tt.want {
+				t.Errorf("expected %d commands to run, got %d", tt.want, len(results))
+			}
+		})
+	}
+}
+
+func TestRunner_CommandTags(t *testing.T) {
+	cfg := &config.Config{
+		MaxConcurrency: 2,
+		OnChange: config.OnChange{
+			Commands: []config.Command{
+				{Name: "api", Cmd: []string{"echo", "api"}, WhenTags: []string{"backend"}},
+				{Name: "web", Cmd: []string{"echo", "web"}, WhenTags: []string{"frontend", "assets"}},
+				{Name: "always", Cmd: []string{"echo", "always"}},
+			},
+		},
+	}
+	r := New(cfg, Options{DryRun: true})
+
+	tests := []struct {
+		name    string
+		trigger Trigger
+		want    int
+	}{
+		{"backend change", Trigger{Path: "api/main.go", Event: "WRITE", Tags: []string{"backend"}}, 2},
+		{"any tag of the command", Trigger{Path: "web/logo.svg", Event: "WRITE", Tags: []string{"assets"}}, 2},
+		{"batch under both", Trigger{Path: "web/app.ts", Event: "WRITE", Tags: []string{"backend", "frontend"}}, 3},
+		{"untagged change", Trigger{Path: "README.md", Event: "WRITE"}, 1},
+		{"manual runs everything", Trigger{Event: "MANUAL"}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if results := r.RunTrigger(context.Background(), tt.trigger); len(results) != tt.want {
+				t.Errorf("expected %d commands to run, got %d", tt.want, len(results))
+			}
+		})
+	}
+}
+
+func TestRunner_Plan(t *testing.T) {
+	cfg := &config.Config{
+		MaxConcurrency: 2,
+		OnChange: config.OnChange{
+			Commands: []config.Command{
+				{Cmd: []string{"go", "test", "./{dir}"}, Cwd: "{dir}"},
+				{Cmd: []string{"gofmt", "-l", "{files}"}, Match: []string{"*.go"}},
+				{Cmd: []string{"go", "generate"}, Events: []string{"create"}},
+				{RestartService: "api"},
+			},
+		},
+	}
+	r := New(cfg, Options{})
+
+	plan := r.Plan(Trigger{Path: "pkg/a.go", Event: "WRITE", Files: []string{"pkg/a.go", "README.md"}})
+	want := []PlannedCommand{
+		{Index: 0, Command: []string{"go", "test", "./pkg"}, Dir: "pkg"},
+		{Index: 1, Command: []string{"gofmt", "-l", "pkg/a.go", "README.md"}},
+		{Index: 2, Command: []string{"go", "generate"}, Skipped: "only runs on create events"},
+		{Index: 3, Command: []string{dockerCommand, "compose", "restart", "api"}},
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("Plan() = %+v, want %+v", plan, want)
+	}
+
+	plan = r.Plan(Trigger{Path: "README.md", Event: "WRITE"})
+	if got := plan[1].Skipped; got != "no changed file matches *.go" {
+		t.Errorf("Skipped = %q, want the match rule", got)
+	}
+}
+
+func TestRunner_Cooldown(t *testing.T) {
+	cfg := &config.Config{
+		MaxConcurrency: 2,
+		OnChange: config.OnChange{
+			Commands: []config.Command{
+				{Name: "deploy", Cmd: []string{"echo", "deploy"}, Cooldown: "200ms"},
+				{Name: "test", Cmd: []string{"echo", "test"}},
+			},
+		},
+	}
+	r := New(cfg, Options{DryRun: true})
+	ctx := context.Background()
+
+	if results := r.RunTrigger(ctx, Trigger{Path: "a.go", Event: "WRITE"}); len(results) != 2 {
+		t.Fatalf("expected 2 commands on the first run, got %d", len(results))
+	}
+	if _, ok := r.NextPending(); ok {
+		t.Error("expected nothing pending after the first run")
+	}
+
+	// Changes during the cooldown only run the other commands
+	for _, path := range []string{"b.go", "c.go"} {
+		if results := r.RunTrigger(ctx, Trigger{Path: path, Event: "WRITE"}); len(results) != 1 {
+			t.Fatalf("expected 1 command during the cooldown, got %d", len(results))
+		}
+	}
+	at, ok := r.NextPending()
+	if !ok {
+		t.Fatal("expected pending changes")
+	}
+	if _, ok := r.TakePending(); ok {
+		t.Error("TakePending() returned changes before the cooldown ended")
+	}
+
+	time.Sleep(time.Until(at) + 10*time.Millisecond)
+	pending, ok := r.TakePending()
+	if !ok {
+		t.Fatal("expected pending changes after the cooldown")
+	}
+	if !slices.Equal(pending.Files, []string{"b.go", "c.go"}) || pending.Path != "c.go" {
+		t.Errorf("expected coalesced changes to b.go and c.go, got %v (path %s)", pending.Files, pending.Path)
+	}
+	results := r.RunTrigger(ctx, pending)
+	if len(results) != 1 || results[0].Command[1] != "deploy" {
+		t.Errorf("expected only deploy to run, got %v", results)
+	}
+	if _, ok := r.TakePending(); ok {
+		t.Error("expected pending changes to be taken once")
+	}
+}
+
+func TestReport_WantsReload(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []RunResult
+		want    bool
+	}{
+		{"no reload command", []RunResult{{ExitCode: 0}}, false},
+		{"reload command succeeded", []RunResult{{ExitCode: 0, Reload: true}, {ExitCode: 0}}, true},
+		{"reload command failed", []RunResult{{ExitCode: 1, Reload: true}}, false},
+		{"other command failed", []RunResult{{ExitCode: 0, Reload: true}, {ExitCode: 2}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Report{Results: tt.results}).WantsReload(); got != tt.want {
+				t.Errorf("WantsReload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunner_OnResult(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		started  []string
+		received = make(map[int]int64)
+	)
+	cfg := &config.Config{
+		MaxConcurrency: 2,
+		OnChange: config.OnChange{Commands: []config.Command{
+			{Cmd: []string{"sh", "-c", "exit 0"}},
+			{Cmd: []string{"sh", "-c", "exit 3 # {path}"}},
+		}},
+	}
+	r := New(cfg, Options{
+		Logger: logger.New(logger.LevelError, false),
+		OnStart: func(t Trigger, command []string) {
+			mu.Lock()
+			defer mu.Unlock()
+			started = append(started, command[2])
+		},
+		OnResult: func(t Trigger, result RunResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			received[result.ExitCode] = t.RunID
+		},
+	})
+
+	r.RunTrigger(context.Background(), Trigger{Path: "main.go", Event: "WRITE", RunID: 42})
+
+	if len(received) != 2 || received[0] != 42 || received[3] != 42 {
+		t.Errorf("OnResult received %v, want exit codes 0 and 3 for run 42", received)
+	}
+	slices.Sort(started)
+	if want := []string{"exit 0", "exit 3 # main.go"}; !slices.Equal(started, want) {
+		t.Errorf("OnStart received %q, want %q", started, want)
+	}
+}
+
+func TestRunner_OnOutput(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	cfg := &config.Config{
+		MaxConcurrency: 1,
+		OnChange: config.OnChange{Commands: []config.Command{
+			{Cmd: []string{"sh", "-c", "echo first"}},
+			{Cmd: []string{"sh", "-c", "echo second >&2"}},
+		}},
+		OnSuccess: []config.Command{{Cmd: []string{"sh", "-c", "echo hook"}}},
+	}
+	r := New(cfg, Options{
+		Logger:     logger.New(logger.LevelError, false),
+		Sequential: true,
+		OnOutput: func(t Trigger, line string, isError bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			lines = append(lines, fmt.Sprintf("%d %s %v", t.CommandIndex(), line, isError))
+		},
+	})
+
+	r.RunTrigger(context.Background(), Trigger{Path: "main.go", Event: "WRITE"})
+
+	// Hooks report an index of -1
+	want := []string{"0 first false", "1 second true", "-1 hook false"}
+	if !slices.Equal(lines, want) {
+		t.Errorf("OnOutput received %q, want %q", lines, want)
+	}
+}
+
+func TestRunner_KillSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals cannot be delivered to child processes on Windows")
+	}
+
+	out := filepath.Join(t.TempDir(), "signal.txt")
+	r := New(&config.Config{MaxConcurrency: 1}, Options{Logger: logger.New(logger.LevelError, false)})
+
+	cmd := config.Command{
+		Cmd:        []string{"sh", "-c", "trap 'echo term > " + out + "; exit 0' TERM; sleep 10 >/dev/null 2>&1 & wait"},
+		Timeout:    "200ms",
+		KillSignal: "term",
+		KillGrace:  "5s",
+	}
+
+	start := time.Now()
+	result := r.executeCommand(context.Background(), cmd, Trigger{Path: "main.go", Event: "WRITE"})
+
+	if result.Error == nil {
+		t.Error("expected an error for the timed-out command")
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("command took %s, expected it to exit on SIGTERM", elapsed)
+	}
+	if data, err := os.ReadFile(out); err != nil || strings.TrimSpace(string(data)) != "term" {
+		t.Errorf("trap did not run: %q, %v", data, err)
+	}
+}
+
+func TestRunner_KillsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	// Background jobs of sh ignore SIGINT, so the child outlives a shell
+	// that exits on it unless the rest of its group is killed too
+	tests := []struct {
+		name   string
+		script string
+		signal string
+		grace  string
+	}{
+		{"killed", "(sleep 0.5; touch orphan) & wait", "", "0s"},
+		{"signalled", "(sleep 0.5; touch orphan) & wait", "term", "5s"},
+		{"shell exits on the signal", "trap 'exit 0' INT; (sleep 0.5; touch orphan) >/dev/null 2>&1 & wait", "", "5s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			r := New(&config.Config{MaxConcurrency: 1}, Options{Logger: logger.New(logger.LevelError, false)})
+			cmd := config.Command{
+				Cmd:        []string{"sh", "-c", tt.script},
+				Cwd:        dir,
+				Timeout:    "100ms",
+				KillSignal: tt.signal,
+				KillGrace:  tt.grace,
+			}
+			result := r.executeCommand(context.Background(), cmd, Trigger{Path: "main.go", Event: "WRITE"})
+			if !result.TimedOut {
+				t.Fatalf("expected the command to time out: %v", result.Error)
+			}
+
+			time.Sleep(time.Second)
+			if _, err := os.Stat(filepath.Join(dir, "orphan")); err == nil {
+				t.Error("a child of the timed-out command kept running")
+			}
+		})
+	}
+}
+
+func TestRunner_Nice(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	var out strings.Builder
+	r := New(&config.Config{MaxConcurrency: 1}, Options{Logger: logger.NewWriter(&out, logger.LevelInfo, false)})
+	// The processes the command starts run at its priority too
+	cmd := config.Command{Cmd: []string{"sh", "-c", "sleep 0.1; nice"}, Nice: config.MaxNice, IONice: config.IONiceIdle}
+	result := r.executeCommand(context.Background(), cmd, Trigger{Path: "main.go", Event: "WRITE"})
+	if result.ExitCode != 0 {
+		t.Fatalf("command failed: %v\n%s", result.Error, out.String())
+	}
+	if want := fmt.Sprintf("│ %d\n", config.MaxNice); !strings.Contains(out.String(), want) {
+		t.Errorf("output = %q, want niceness %d", out.String(), config.MaxNice)
+	}
+	if strings.Contains(out.String(), "Failed to set the priority") {
+		t.Errorf("priority not set: %s", out.String())
+	}
+}
+
+func TestOwnCgroup(t *testing.T) {
+	v2 := "0::/user.slice/user-1000.slice/session-2.scope\n"
+	if got, err := ownCgroup(v2); err != nil || got != "/user.slice/user-1000.slice/session-2.scope" {
+		t.Errorf("ownCgroup() = %q, %v", got, err)
+	}
+	if _, err := ownCgroup("4:memory:/user.slice\n1:name=systemd:/\n"); err == nil {
+		t.Error("expected an error without cgroup v2")
+	}
+}
+
+func TestCreateCgroup(t *testing.T) {
+	mount := t.TempDir()
+	cgroup := func(path, subtree string) string {
+		dir := filepath.Join(mount, path)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for file, content := range map[string]string{"cgroup.subtree_control": subtree, "cgroup.procs": ""} {
+			if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	cgroup(".", "cpu memory pids")
+	user := cgroup("user.slice", "memory pids")
+	cgroup("user.slice/app.scope", "")
+
+	// gowatch's own cgroup has processes and so no controllers to give
+	dir, err := createCgroup(mount, "/user.slice/app.scope", []string{"memory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(dir) != user {
+		t.Errorf("created %s, want it under %s", dir, user)
+	}
+	// The first ancestor enabling cpu is the root
+	if dir, err = createCgroup(mount, "/user.slice/app.scope", []string{"memory", "cpu"}); err != nil || filepath.Dir(dir) != mount {
+		t.Errorf("created %s, %v, want it under the root", dir, err)
+	}
+	if _, err := createCgroup(mount, "/user.slice/app.scope", []string{"io"}); err == nil {
+		t.Error("expected an error without the io controller")
+	}
+
+	limits := config.Limits{Memory: "2GiB", CPU: 1.5}
+	if err := writeLimits(dir, limits); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{"memory.max": "2147483648", "memory.oom.group": "1", "cpu.max": "150000 100000"} {
+		if data, err := os.ReadFile(filepath.Join(dir, file)); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", file, data, err, want)
+		}
+	}
+	if got := limitControllers(limits); !reflect.DeepEqual(got, []string{"memory", "cpu"}) {
+		t.Errorf("limitControllers() = %v", got)
+	}
+}
+
+func TestRunner_OutputPane(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script for tmux")
+	}
+	dir := t.TempDir()
+	tty := filepath.Join(dir, "tty")
+	if err := os.WriteFile(tty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fake := func(script string) {
+		path := filepath.Join(dir, "tmux")
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		tmuxCommand = path
+	}
+	t.Cleanup(func() { tmuxCommand = "tmux" })
+
+	tests := []struct {
+		name     string
+		tmux     string
+		wantPane bool
+		wantLog  string
+	}{
+		{"pane", "echo '%3 " + tty + "'", true, "goes to tmux pane %3"},
+		{"no tmux server", "echo 'no server running' >&2; exit 1", false, "tmux: no server running"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake(tt.tmux)
+			os.Truncate(tty, 0)
+			var out strings.Builder
+			r := New(&config.Config{}, Options{Logger: logger.NewWriter(&out, logger.LevelInfo, false)})
+			defer r.Close()
+			cmd := config.Command{Name: "server", Cmd: []string{"echo", "listening"}, Output: "tmux:1.2"}
+			if result := r.executeCommand(context.Background(), cmd, Trigger{Path: "main.go", Event: "WRITE"}); result.ExitCode != 0 {
+				t.Fatalf("command failed: %v", result.Error)
+			}
+
+			data, err := os.ReadFile(tty)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(string(data), "listening\n"); got != tt.wantPane {
+				t.Errorf("pane shows the output: %v, want %v (%q)", got, tt.wantPane, data)
+			}
+			if tt.wantPane && !strings.HasPrefix(string(data), "── server ") {
+				t.Errorf("pane output = %q, want it to start with a header", data)
+			}
+			if got := strings.Contains(out.String(), "│ [server] listening"); got == tt.wantPane {
+				t.Errorf("terminal shows the output: %v, want %v (%q)", got, !tt.wantPane, out.String())
+			}
+			if !strings.Contains(out.String(), tt.wantLog) {
+				t.Errorf("log = %q, want %q", out.String(), tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestRunner_OutputDir(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		MaxConcurrency: 1,
+		OutputDir:      dir,
+		OutputKeep:     2,
+		OnChange: config.OnChange{Commands: []config.Command{
+			{Name: "greet", Cmd: []string{"echo", "hello {base}"}},
+			{Cmd: []string{"sh", "-c", "echo oops >&2; exit 3"}},
+		}},
+	}
+	r := New(cfg, Options{})
+
+	start := time.Now()
+	for i, name := range []string{"a.go", "b.go", "c.go"} {
+		at := start.Add(time.Duration(i) * time.Second)
+		r.RunTrigger(context.Background(), Trigger{Path: name, Event: "WRITE", Time: at})
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d run directories, want 2", len(entries))
+	}
+	latest := filepath.Join(dir, entries[1].Name())
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"greet.log", "$ echo hello c.go\nhello c.go\n\n[exit 0 after "},
+		{"sh.log", "$ sh -c echo oops >&2; exit 3\noops\n\n[exit 3 after "},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join(latest, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), tt.want) {
+			t.Errorf("%s = %q, want prefix %q", tt.file, data, tt.want)
+		}
+	}
+}
+
+func TestRunner_OutputFilter(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		MaxConcurrency: 1,
+		OutputDir:      dir,
+		OnChange: config.OnChange{Commands: []config.Command{{
+			Name:         "install",
+			Cmd:          []string{"sh", "-c", "echo ' 10% done'; echo 'Downloading x' >&2; echo installed"},
+			OutputFilter: []string{`^\s*\d+%`, "^Downloading "},
+		}}},
+	}
+	var out strings.Builder
+	var streamed []string
+	r := New(cfg, Options{
+		Logger:   logger.NewWriter(&out, logger.LevelInfo, false),
+		OnOutput: func(t Trigger, line string, isError bool) { streamed = append(streamed, line) },
+	})
+	r.RunTrigger(context.Background(), Trigger{Path: "go.mod", Event: "WRITE"})
+
+	if !strings.Contains(out.String(), "│ [install] installed") {
+		t.Errorf("output = %q, want the installed line", out.String())
+	}
+	for _, line := range []string{"10% done", "Downloading x"} {
+		if strings.Contains(out.String(), "[install] "+line) {
+			t.Errorf("output = %q, want %q filtered", out.String(), line)
+		}
+	}
+	if !slices.Equal(streamed, []string{"installed"}) {
+		t.Errorf("streamed %q, want only the installed line", streamed)
+	}
+
+	// The saved output keeps every line
+	runs, err := os.ReadDir(dir)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("run directories = %v, %v", runs, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, runs[0].Name(), "install.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"\n 10% done\n", "Downloading x\n", "installed\n"} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("install.log = %q, want %q", data, line)
+		}
+	}
+}
+
+func TestRunner_Excerpt(t *testing.T) {
+	cfg := &config.Config{
+		MaxConcurrency: 1,
+		OnChange: config.OnChange{Commands: []config.Command{
+			{Cmd: []string{"sh", "-c", "echo ok"}},
+			{
+				Cmd:          []string{"sh", "-c", "for i in $(seq 25); do echo line $i; echo noise; done; exit 1"},
+				OutputFilter: []string{"^noise$"},
+			},
+		}},
+	}
+	r := New(cfg, Options{Logger: logger.New(logger.LevelError, false)})
+	results := r.RunTrigger(context.Background(), Trigger{Path: "main.go", Event: "WRITE"})
+	if len(results) != 2 {
+		t.Fatalf("results = %+v, want 2", results)
+	}
+
+	if results[0].Excerpt != nil {
+		t.Errorf("excerpt of a command that succeeded = %q, want none", results[0].Excerpt)
+	}
+	// The last lines shown, without the filtered ones
+	var want []string
+	for i := 6; i <= 25; i++ {
+		want = append(want, fmt.Sprintf("line %d", i))
+	}
+	if got := results[1].Excerpt; !slices.Equal(got, want) {
+		t.Errorf("excerpt = %q, want %q", got, want)
+	}
+}
+
+func TestRunner_Hyperlinks(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := config.Command{
+		Cmd: []string{"sh", "-c", "echo './main.go:3:5: undefined: x'; echo 'missing.go:1: gone'; echo 'at 12:30:00'"},
+		Cwd: dir,
+	}
+	var out strings.Builder
+	r := New(&config.Config{Hyperlinks: config.HyperlinksVSCode}, Options{Logger: logger.NewWriter(&out, logger.LevelInfo, true)})
+	if result := r.executeCommand(context.Background(), cmd, Trigger{}); result.ExitCode != 0 {
+		t.Fatalf("command failed: %v", result.Error)
+	}
+
+	url := "vscode://file" + filepath.ToSlash(filepath.Join(dir, "main.go")) + ":3:5"
+	if want := "\x1b]8;;" + url + "\x1b\\./main.go:3:5\x1b]8;;\x1b\\: undefined: x"; !strings.Contains(out.String(), want) {
+		t.Errorf("output = %q, want the reference linked as %q", out.String(), want)
+	}
+	// References to files that aren't there and times stay plain
+	if n := strings.Count(out.String(), "\x1b]8;;\x1b\\"); n != 1 {
+		t.Errorf("output = %q, want 1 link, got %d", out.String(), n)
+	}
+}
+
+func TestTestParsers(t *testing.T) {
+	tests := []struct {
+		parser    string
+		output    []string
+		wantShown []string
+		want      TestSummary
+	}{
+		{
+			parser: config.ParserGoTest,
+			output: []string{
+				`{"Action":"run","Test":"TestA"}`,
+				`{"Action":"output","Test":"TestA","Output":"=== RUN   TestA\n"}`,
+				`{"Action":"pass","Test":"TestA"}`,
+				`{"Action":"output","Test":"TestB/sub","Output":"    b_test.go:9: got 1\n"}`,
+				`{"Action":"fail","Test":"TestB/sub"}`,
+				`{"Action":"fail","Test":"TestB"}`,
+				`{"Action":"skip","Test":"TestC"}`,
+				`{"Action":"fail","Package":"pkg"}`,
+				`# pkg [build failed]`,
+			},
+			wantShown: []string{"=== RUN   TestA", "    b_test.go:9: got 1", "# pkg [build failed]"},
+			want:      TestSummary{Passed: 1, Failed: 1, Skipped: 1, FailedTests: []string{"TestB/sub"}},
+		},
+		{
+			parser: config.ParserPytest,
+			output: []string{
+				"FAILED tests/test_app.py::test_login - AssertionError",
+				"ERROR tests/test_db.py::test_connect",
+				"===== 1 failed, 5 passed, 2 skipped, 1 error in 0.42s =====",
+			},
+			wantShown: []string{
+				"FAILED tests/test_app.py::test_login - AssertionError",
+				"ERROR tests/test_db.py::test_connect",
+				"===== 1 failed, 5 passed, 2 skipped, 1 error in 0.42s =====",
+			},
+			want: TestSummary{Passed: 5, Failed: 2, Skipped: 2, FailedTests: []string{"tests/test_app.py::test_login", "tests/test_db.py::test_connect"}},
+		},
+		{
+			parser:    config.ParserPytest,
+			output:    []string{"3 passed in 0.01s"},
+			wantShown: []string{"3 passed in 0.01s"},
+			want:      TestSummary{Passed: 3},
+		},
+		{
+			parser: config.ParserJest,
+			output: []string{
+				"  ● login › rejects a wrong password",
+				"  ● Console",
+				"  ● login › rejects a wrong password",
+				"Tests:       1 failed, 1 todo, 3 passed, 5 total",
+			},
+			wantShown: []string{
+				"  ● login › rejects a wrong password",
+				"  ● Console",
+				"  ● login › rejects a wrong password",
+				"Tests:       1 failed, 1 todo, 3 passed, 5 total",
+			},
+			want: TestSummary{Passed: 3, Failed: 1, Skipped: 1, FailedTests: []string{"login › rejects a wrong password"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.parser, func(t *testing.T) {
+			p := newTestOutput(config.Command{Parser: tt.parser})
+			var shown []string
+			for _, line := range tt.output {
+				if line, ok := p.parse(line); ok {
+					shown = append(shown, line)
+				}
+			}
+			if !slices.Equal(shown, tt.wantShown) {
+				t.Errorf("shown = %q, want %q", shown, tt.wantShown)
+			}
+			got := p.summary()
+			if got == nil {
+				t.Fatal("summary = nil")
+			}
+			if got.Passed != tt.want.Passed || got.Failed != tt.want.Failed || got.Skipped != tt.want.Skipped || !slices.Equal(got.FailedTests, tt.want.FailedTests) {
+				t.Errorf("summary = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTestSummary_String(t *testing.T) {
+	tests := []struct {
+		summary TestSummary
+		want    string
+	}{
+		{TestSummary{Passed: 12}, "12 tests passed"},
+		{TestSummary{Passed: 1, Skipped: 2}, "1 test passed (2 skipped)"},
+		{TestSummary{Failed: 1, FailedTests: []string{"TestA"}}, "1 test failed: TestA"},
+		{
+			TestSummary{Passed: 10, Failed: 4, Skipped: 2, FailedTests: []string{"TestA", "TestB", "TestC", "TestD"}},
+			"4 tests failed: TestA, TestB, TestC and 1 more (10 passed, 2 skipped)",
+		},
+		{TestSummary{Passed: 3, Failed: 2}, "2 tests failed (3 passed)"},
+	}
+	for _, tt := range tests {
+		if got := tt.summary.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestRunner_Parser(t *testing.T) {
+	script := `printf '%s\n' '{"Action":"output","Test":"TestA","Output":"--- FAIL: TestA\n"}' '{"Action":"fail","Test":"TestA"}'; exit 1`
+	var streamed []string
+	r := New(&config.Config{}, Options{
+		Logger:   logger.New(logger.LevelError, false),
+		OnOutput: func(t Trigger, line string, isError bool) { streamed = append(streamed, line) },
+	})
+	result := r.executeCommand(context.Background(), config.Command{Cmd: []string{"sh", "-c", script}, Parser: config.ParserGoTest}, Trigger{})
+	if result.Tests == nil || result.Tests.Failed != 1 || !slices.Equal(result.Tests.FailedTests, []string{"TestA"}) {
+		t.Errorf("Tests = %+v, want TestA failed", result.Tests)
+	}
+	if want := []string{"--- FAIL: TestA"}; !slices.Equal(streamed, want) {
+		t.Errorf("output = %q, want %q", streamed, want)
+	}
+
+	result = r.executeCommand(context.Background(), config.Command{Cmd: []string{"sh", "-c", "echo ok"}}, Trigger{})
+	if result.Tests != nil {
+		t.Errorf("Tests of a command without a parser = %+v, want nil", result.Tests)
+	}
+}
+
+func TestRunner_Highlight(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	cmd := config.Command{
+		Cmd: []string{"sh", "-c", "echo 'ok  pkg'; echo 'FAIL pkg'; echo 'a.go:1:2: warning: unused'"},
+		Highlight: []config.Highlight{
+			{Match: "^FAIL", Style: "red bold"},
+			{Match: `warning:`, Style: "yellow"},
+			{Match: "pkg", Style: "green"},
+		},
+	}
+	var out strings.Builder
+	var streamed []string
+	r := New(&config.Config{}, Options{
+		Logger:   logger.NewWriter(&out, logger.LevelInfo, true),
+		OnOutput: func(t Trigger, line string, isError bool) { streamed = append(streamed, line) },
+	})
+	if result := r.executeCommand(context.Background(), cmd, Trigger{}); result.ExitCode != 0 {
+		t.Fatalf("command failed: %v", result.Error)
+	}
+
+	for _, want := range []string{
+		"\x1b[31;1mFAIL pkg\x1b[",
+		"\x1b[33ma.go:1:2: warning: unused\x1b[0m",
+		"\x1b[32mok  pkg\x1b[0m",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output = %q, want %q", out.String(), want)
+		}
+	}
+	// Only the terminal gets the styles
+	for _, line := range streamed {
+		if strings.Contains(line, "\x1b[") {
+			t.Errorf("streamed line %q is styled", line)
+		}
+	}
+}
+
+func TestRunner_PTY(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("pseudo-terminals are Unix only")
+	}
+	script := `if [ -t 1 ]; then printf '\033[32mterminal\033[0m\n'; else echo pipe; fi; printf '10%%\r100%%\n'; if [ -t 2 ]; then echo stderr >&2; fi`
+	tests := []struct {
+		name string
+		pty  bool
+		want []string
+	}{
+		{"pipes", false, []string{"pipe", "10%\r100%"}},
+		{"pty", true, []string{"terminal", "100%", "stderr"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			var streamed []string
+			r := New(&config.Config{}, Options{
+				Logger:   logger.NewWriter(&out, logger.LevelInfo, false),
+				OnOutput: func(t Trigger, line string, isError bool) { streamed = append(streamed, line) },
+			})
+			cmd := config.Command{Cmd: []string{"sh", "-c", script}, PTY: tt.pty}
+			if result := r.executeCommand(context.Background(), cmd, Trigger{}); result.ExitCode != 0 {
+				t.Fatalf("command failed: %v", result.Error)
+			}
+			if !slices.Equal(streamed, tt.want) {
+				t.Errorf("output lines = %q, want %q", streamed, tt.want)
+			}
+			if tt.pty && !strings.Contains(out.String(), "│ terminal\n") {
+				t.Errorf("output = %q, want the line without colors", out.String())
+			}
+		})
+	}
+}
+
+func TestRunner_DependsOn(t *testing.T) {
+	tests := []struct {
+		name      string
+		failBuild bool
+		wantOrder []string
+		wantRan   int
+	}{
+		{"all succeed", false, []string{"generate", "build", "test"}, 4},
+		{"dependents of a failure skipped", true, []string{"generate", "build"}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := filepath.Join(t.TempDir(), "order.log")
+			step := func(name string, fail bool) []string {
+				script := "sleep 0.05; echo " + name + " >> " + log
+				if fail {
+					script += "; exit 1"
+				}
+				return []string{"sh", "-c", script}
+			}
+
+			cfg := &config.Config{
+				MaxConcurrency: 4,
+				OnChange: config.OnChange{Commands: []config.Command{
+					{Name: "test", Cmd: step("test", false), DependsOn: []string{"build"}},
+					{Name: "build", Cmd: step("build", tt.failBuild), DependsOn: []string{"generate"}},
+					{Name: "generate", Cmd: step("generate", false)},
+					{Name: "lint", Cmd: step("lint", false)},
+				}},
+			}
+			r := New(cfg, Options{})
+
+			results := r.RunTrigger(context.Background(), Trigger{Path: "main.go", Event: "WRITE"})
+			if len(results) != tt.wantRan {
+				t.Errorf("got %d results, want %d", len(results), tt.wantRan)
+			}
+
+			data, err := os.ReadFile(log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var order []string
+			for _, line := range strings.Fields(string(data)) {
+				if line != "lint" {
+					order = append(order, line)
+				}
+			}
+			if !slices.Equal(order, tt.wantOrder) {
+				t.Errorf("order = %v, want %v", order, tt.wantOrder)
+			}
+			if !strings.Contains(string(data), "lint") {
+				t.Error("independent command did not run")
+			}
+		})
+	}
+}
+
+func TestRunner_ContainerPaths(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "b.go")
+	cfg := &config.Config{
+		Watch:          []config.WatchPath{{Path: root}},
+		MaxConcurrency: 1,
+		OnChange: config.OnChange{Commands: []config.Command{{
+			Cmd:            []string{"lint", "{path}", "{dir}", "{files}", "{relpath}"},
+			Container:      "dev",
+			ContainerPaths: []string{root + ":/workspace"},
+		}}},
+	}
+	r := New(cfg, Options{DryRun: true})
+
+	path := filepath.Join(root, "pkg", "a.go")
+	results := r.RunTrigger(context.Background(), Trigger{Path: path, Event: "WRITE", Files: []string{path, outside}})
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	want := []string{"lint", "/workspace/pkg/a.go", "/workspace/pkg", "/workspace/pkg/a.go", outside, filepath.Join("pkg", "a.go")}
+	if !slices.Equal(results[0].Command, want) {
+		t.Errorf("got %q, want %q", results[0].Command, want)
+	}
+}
+
+func TestRunner_Container(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake docker client is a shell script")
+	}
+
+	// A stand-in for docker that runs the command locally and records how
+	// it was called
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls.log")
+	script := `#!/bin/sh
+echo "$@" >> ` + calls + `
+shift
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-w) shift 2 ;;
+	-e) export "$2"; shift 2 ;;
+	*) break ;;
+	esac
+done
+shift
+exec "$@"
+`
+	fakeDocker := filepath.Join(bin, "docker")
+	if err := os.WriteFile(fakeDocker, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(prev string) { dockerCommand = prev }(dockerCommand)
+	dockerCommand = fakeDocker
+
+	root := t.TempDir()
+	outDir := t.TempDir()
+	cfg := &config.Config{
+		MaxConcurrency: 1,
+		OutputDir:      outDir,
+		OnChange: config.OnChange{Commands: []config.Command{{
+			Name:           "server",
+			Cmd:            []string{"sh", "-c", "echo $GOWATCH_EVENT $GOWATCH_PATH $GREETING; exec sleep 30"},
+			Mode:           config.ModeRestart,
+			Env:            []string{"GREETING=hi"},
+			KillSignal:     "term",
+			Container:      "dev",
+			ContainerPaths: []string{root + ":/workspace"},
+		}}},
+	}
+	r := New(cfg, Options{})
+	r.RunTrigger(context.Background(), Trigger{Path: filepath.Join(root, "main.go"), Event: "WRITE"})
+
+	// The PID line is held back from the output
+	want := "$ sh -c echo $GOWATCH_EVENT $GOWATCH_PATH $GREETING; exec sleep 30\nWRITE /workspace/main.go hi\n"
+	var got string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		runs, _ := os.ReadDir(outDir)
+		if len(runs) == 1 {
+			data, _ := os.ReadFile(filepath.Join(outDir, runs[0].Name(), "server.log"))
+			if got = string(data); got == want {
+				break
+			}
+		}
+	}
+	if got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+
+	// Stopping signals the command inside the container
+	start := time.Now()
+	r.Close()
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("stopping took %s", elapsed)
+	}
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`(?m)^exec dev kill -s TERM \d+$`).Match(data) {
+		t.Errorf("expected a kill in the container, docker calls:\n%s", data)
+	}
+}
+
+func TestParseComposePS(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []serviceContainer
+	}{
+		{
+			name:   "array",
+			output: `[{"Name":"app-api-1","State":"running","Health":"healthy"}]`,
+			want:   []serviceContainer{{Name: "app-api-1", State: "running", Health: "healthy"}},
+		},
+		{
+			name:   "one object per line",
+			output: "{\"Name\":\"app-api-1\",\"State\":\"running\",\"Health\":\"starting\"}\n{\"Name\":\"app-api-2\",\"State\":\"restarting\",\"Health\":\"\"}\n",
+			want: []serviceContainer{
+				{Name: "app-api-1", State: "running", Health: "starting"},
+				{Name: "app-api-2", State: "restarting"},
+			},
+		},
+		{
+			name:   "empty",
+			output: "\n",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseComposePS([]byte(tt.output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseComposePS() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseComposePS([]byte("not json")); err == nil {
+		t.Error("expected an error for unexpected output")
+	}
+}
+
+func TestRunner_RestartService(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake docker client is a shell script")
+	}
+
+	tests := []struct {
+		name     string
+		health   string
+		wantExit bool
+	}{
+		{name: "healthy", health: "healthy"},
+		{name: "unhealthy", health: "unhealthy", wantExit: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A stand-in for docker compose whose service reports "starting"
+			// before settling on the test's health
+			bin := t.TempDir()
+			calls := filepath.Join(bin, "calls.log")
+			seen := filepath.Join(bin, "seen")
+			script := `#!/bin/sh
+echo "$@" >> ` + calls + `
+case "$4" in
+ps)
+	if [ -f ` + seen + ` ]; then
+		echo '{"Name":"app-api-1","State":"running","Health":"` + tt.health + `"}'
+	else
+		touch ` + seen + `
+		echo '{"Name":"app-api-1","State":"running","Health":"starting"}'
+	fi ;;
+esac
+`
+			fakeDocker := filepath.Join(bin, "docker")
+			if err := os.WriteFile(fakeDocker, []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+			defer func(prev string) { dockerCommand = prev }(dockerCommand)
+			dockerCommand = fakeDocker
+
+			cfg := &config.Config{
+				MaxConcurrency: 1,
+				OnChange: config.OnChange{Commands: []config.Command{{
+					RestartService: "api",
+					ComposeFile:    "dev.yml",
+				}}},
+			}
+			r := New(cfg, Options{})
+			defer r.Close()
+			results := r.RunTrigger(context.Background(), Trigger{Event: "WRITE"})
+
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			want := []string{fakeDocker, "compose", "-f", "dev.yml", "restart", "api"}
+			if !slices.Equal(results[0].Command, want) {
+				t.Errorf("command = %v, want %v", results[0].Command, want)
+			}
+			if failed := results[0].ExitCode != 0; failed != tt.wantExit {
+				t.Errorf("exit code = %d, error = %v", results[0].ExitCode, results[0].Error)
+			}
+
+			data, err := os.ReadFile(calls)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantCalls := "compose -f dev.yml restart api\n" +
+				"compose -f dev.yml ps --all --format json api\n" +
+				"compose -f dev.yml ps --all --format json api\n"
+			if string(data) != wantCalls {
+				t.Errorf("docker calls:\n%s\nwant:\n%s", data, wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package runner
+
+import (
+	"context"
+	"os/exec"
+)
+
+// shellCommand runs a command line through cmd.exe, which only Windows has
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd.exe", "/S", "/C", line)
+}
@@ -0,0 +1,16 @@
+package runner
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand runs a command line through cmd.exe. The line is handed over
+// as is, since cmd.exe doesn't parse the quoting Go gives arguments; /S
+// strips just the outer quotes.
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	command := exec.CommandContext(ctx, "cmd.exe")
+	command.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd.exe /S /C "` + line + `"`}
+	return command
+}
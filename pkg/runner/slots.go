@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// slots limits how many commands of a run execute at once. A command takes
+// as many slots as its weight. Waiting commands are let in by priority, then
+// in the order they asked; a command that doesn't fit yet holds back those
+// behind it so that heavy commands aren't starved.
+type slots struct {
+	mu   sync.Mutex
+	size int
+	used int
+	// waiting is ordered by descending priority, then arrival
+	waiting []*slotWaiter
+}
+
+// slotWaiter is a command waiting for slots
+type slotWaiter struct {
+	weight   int
+	priority int
+	ready    chan struct{}
+}
+
+func newSlots(size int) *slots {
+	return &slots{size: max(size, 1)}
+}
+
+// acquire waits for weight slots and returns the function that frees them.
+// A weight above the size takes every slot.
+func (s *slots) acquire(ctx context.Context, weight, priority int) (func(), error) {
+	weight = min(max(weight, 1), s.size)
+	w := &slotWaiter{weight: weight, priority: priority, ready: make(chan struct{})}
+	release := func() { s.release(weight) }
+
+	s.mu.Lock()
+	i := slices.IndexFunc(s.waiting, func(o *slotWaiter) bool { return o.priority < priority })
+	if i < 0 {
+		i = len(s.waiting)
+	}
+	s.waiting = slices.Insert(s.waiting, i, w)
+	s.admit()
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return release, nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	select {
+	case <-w.ready:
+		// Let in while giving up
+		s.mu.Unlock()
+		release()
+	default:
+		s.waiting = slices.DeleteFunc(s.waiting, func(o *slotWaiter) bool { return o == w })
+		// Those behind it may fit now
+		s.admit()
+		s.mu.Unlock()
+	}
+	return nil, ctx.Err()
+}
+
+// release frees slots and lets in the commands that now fit
+func (s *slots) release(weight int) {
+	s.mu.Lock()
+	s.used -= weight
+	s.admit()
+	s.mu.Unlock()
+}
+
+// admit lets in waiting commands, in order, while they fit. s.mu must be
+// held.
+func (s *slots) admit() {
+	for len(s.waiting) > 0 && s.used+s.waiting[0].weight <= s.size {
+		w := s.waiting[0]
+		s.waiting = s.waiting[1:]
+		s.used += w.weight
+		close(w.ready)
+	}
+}
@@ -0,0 +1,49 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// terminal is held by the interactive command reading stdin. There is a
+// single terminal, so the commands of all runners take turns.
+var terminal = make(chan struct{}, 1)
+
+// errTerminalBusy is returned for an interactive command that can't wait
+// for the terminal
+var errTerminalBusy = errors.New("the terminal is in use by another interactive command")
+
+// attachTerminal waits for the terminal and connects the command's stdin to
+// it. A context that is never cancelled doesn't wait: long-running commands
+// would hold the terminal forever. The returned function gives the terminal
+// back once the command has exited.
+func (r *Runner) attachTerminal(ctx context.Context, command *exec.Cmd, cmdString string) (func(), error) {
+	select {
+	case terminal <- struct{}{}:
+	default:
+		if ctx.Done() == nil {
+			return nil, errTerminalBusy
+		}
+		r.log.Runner("Waiting for the terminal: %s", cmdString)
+		select {
+		case terminal <- struct{}{}:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for the terminal: %w", context.Cause(ctx))
+		}
+	}
+
+	stdin, release := os.Stdin, func() {}
+	if r.terminal != nil {
+		stdin, release = r.terminal()
+	}
+	if stdin != nil {
+		command.Stdin = stdin
+	}
+	return func() {
+		release()
+		<-terminal
+	}, nil
+}
@@ -0,0 +1,257 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gowatch/pkg/config"
+)
+
+// summaryNames is how many failed tests a summary names
+const summaryNames = 3
+
+// TestSummary counts the tests a command ran, as its parser read them from
+// its output
+type TestSummary struct {
+	Passed  int
+	Failed  int
+	Skipped int
+	// FailedTests names the tests that failed, in the order they failed
+	FailedTests []string
+}
+
+// Total is the number of tests counted
+func (s TestSummary) Total() int {
+	return s.Passed + s.Failed + s.Skipped
+}
+
+// Failures names the first tests that failed, e.g. "3 tests failed:
+// TestFoo, TestBar, TestBaz", or returns "" if none did
+func (s TestSummary) Failures() string {
+	if s.Failed == 0 {
+		return ""
+	}
+	text := plural(s.Failed, "test") + " failed"
+	if len(s.FailedTests) == 0 {
+		return text
+	}
+	names := s.FailedTests[:min(len(s.FailedTests), summaryNames)]
+	text += ": " + strings.Join(names, ", ")
+	if more := len(s.FailedTests) - len(names); more > 0 {
+		text += fmt.Sprintf(" and %d more", more)
+	}
+	return text
+}
+
+// String summarizes the tests on one line, e.g. "3 tests failed: TestFoo,
+// TestBar, TestBaz (10 passed, 2 skipped)" or "12 tests passed"
+func (s TestSummary) String() string {
+	var counts []string
+	text := s.Failures()
+	if text == "" {
+		text = plural(s.Passed, "test") + " passed"
+	} else if s.Passed > 0 {
+		counts = append(counts, fmt.Sprintf("%d passed", s.Passed))
+	}
+	if s.Skipped > 0 {
+		counts = append(counts, fmt.Sprintf("%d skipped", s.Skipped))
+	}
+	if len(counts) > 0 {
+		text += " (" + strings.Join(counts, ", ") + ")"
+	}
+	return text
+}
+
+// testParser reads the results of a test runner from its output
+type testParser interface {
+	// parse reads a line of output and returns the text to show for it,
+	// or false if it isn't to be shown
+	parse(line string) (string, bool)
+	summary() TestSummary
+}
+
+// testParsers create a parser for each parser setting
+var testParsers = map[string]func() testParser{
+	config.ParserGoTest: func() testParser { return &goTestParser{} },
+	config.ParserPytest: func() testParser { return &pytestParser{} },
+	config.ParserJest:   func() testParser { return &jestParser{} },
+}
+
+// testOutput feeds the output of a command to its parser, from stdout and
+// stderr at once
+type testOutput struct {
+	mu     sync.Mutex
+	parser testParser
+}
+
+// newTestOutput returns the parser of a command, or nil if it has none
+func newTestOutput(cmd config.Command) *testOutput {
+	newParser, ok := testParsers[cmd.Parser]
+	if !ok {
+		return nil
+	}
+	return &testOutput{parser: newParser()}
+}
+
+func (t *testOutput) parse(line string) (string, bool) {
+	if t == nil {
+		return line, true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.parser.parse(line)
+}
+
+// summary returns the tests counted, or nil if there were none
+func (t *testOutput) summary() *TestSummary {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.parser.summary()
+	if s.Total() == 0 {
+		return nil
+	}
+	return &s
+}
+
+// goTestParser reads the JSON events of go test -json, showing the output
+// they carry. Lines that aren't events, such as build errors, are shown as
+// they are.
+type goTestParser struct {
+	s TestSummary
+}
+
+// goTestEvent is the part of a go test -json event that is read
+type goTestEvent struct {
+	Action string
+	Test   string
+	Output string
+}
+
+func (p *goTestParser) parse(line string) (string, bool) {
+	var ev goTestEvent
+	if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &ev) != nil || ev.Action == "" {
+		return line, true
+	}
+	switch ev.Action {
+	case "output", "build-output":
+		return strings.TrimSuffix(ev.Output, "\n"), true
+	}
+	if ev.Test == "" {
+		return "", false
+	}
+	switch ev.Action {
+	case "pass":
+		p.s.Passed++
+	case "skip":
+		p.s.Skipped++
+	case "fail":
+		// A test fails along with its subtests, which name the failures
+		if !slices.ContainsFunc(p.s.FailedTests, func(name string) bool { return strings.HasPrefix(name, ev.Test+"/") }) {
+			p.s.Failed++
+			p.s.FailedTests = append(p.s.FailedTests, ev.Test)
+		}
+	}
+	return "", false
+}
+
+func (p *goTestParser) summary() TestSummary {
+	return p.s
+}
+
+var (
+	// pytestTotals is the last line of pytest, e.g. "=== 1 failed, 2 passed
+	// in 0.12s ===", with or without the rules around it
+	pytestTotals = regexp.MustCompile(`^=*\s*((?:\d+ \w+,? ?)+) in [\d.]+s\b`)
+	// pytestFailure is a line of pytest's short test summary, e.g.
+	// "FAILED tests/test_app.py::test_login - AssertionError"
+	pytestFailure = regexp.MustCompile(`^(?:FAILED|ERROR) (\S+)`)
+	// jestTotals is jest's count of tests, e.g. "Tests: 1 failed, 2 passed,
+	// 3 total"
+	jestTotals = regexp.MustCompile(`^Tests:\s+(.*\d+ total)`)
+	// jestFailure heads the report of a failed test, e.g. "● login ›
+	// rejects a wrong password"
+	jestFailure = regexp.MustCompile(`^\s*● (.+)$`)
+	// counts are the "<n> <outcome>" parts of the totals
+	counts = regexp.MustCompile(`(\d+) (\w+)`)
+)
+
+// pytestParser reads the summary pytest ends with
+type pytestParser struct {
+	s TestSummary
+}
+
+func (p *pytestParser) parse(line string) (string, bool) {
+	if m := pytestFailure.FindStringSubmatch(line); m != nil {
+		p.s.FailedTests = appendNew(p.s.FailedTests, m[1])
+	} else if m := pytestTotals.FindStringSubmatch(line); m != nil {
+		failed := p.s.FailedTests
+		p.s = TestSummary{FailedTests: failed}
+		for _, c := range counts.FindAllStringSubmatch(m[1], -1) {
+			n, _ := strconv.Atoi(c[1])
+			switch c[2] {
+			case "passed", "xpassed":
+				p.s.Passed += n
+			case "failed", "error", "errors":
+				p.s.Failed += n
+			case "skipped", "xfailed":
+				p.s.Skipped += n
+			}
+		}
+	}
+	return line, true
+}
+
+func (p *pytestParser) summary() TestSummary {
+	return p.s
+}
+
+// jestParser reads jest's summary and the names of the tests it reports as
+// failed
+type jestParser struct {
+	s TestSummary
+}
+
+func (p *jestParser) parse(line string) (string, bool) {
+	if m := jestFailure.FindStringSubmatch(line); m != nil {
+		// Console output and suites that didn't run aren't tests
+		if name := m[1]; name != "Console" && name != "Test suite failed to run" {
+			p.s.FailedTests = appendNew(p.s.FailedTests, name)
+		}
+	} else if m := jestTotals.FindStringSubmatch(line); m != nil {
+		failed := p.s.FailedTests
+		p.s = TestSummary{FailedTests: failed}
+		for _, c := range counts.FindAllStringSubmatch(m[1], -1) {
+			n, _ := strconv.Atoi(c[1])
+			switch c[2] {
+			case "passed":
+				p.s.Passed += n
+			case "failed":
+				p.s.Failed += n
+			case "skipped", "todo":
+				p.s.Skipped += n
+			}
+		}
+	}
+	return line, true
+}
+
+func (p *jestParser) summary() TestSummary {
+	return p.s
+}
+
+// appendNew appends a name unless it is there already, for runners that
+// name a failure more than once
+func appendNew(names []string, name string) []string {
+	if slices.Contains(names, name) {
+		return names
+	}
+	return append(names, name)
+}
@@ -0,0 +1,89 @@
+//go:build !windows
+
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"gowatch/pkg/config"
+)
+
+// groups holds the commands running in a process group of their own, so
+// that signals reach the processes they start too, such as the server
+// behind sh -c "go run ./cmd/server"
+var groups sync.Map
+
+// prepareTree starts a command in a process group of its own. Interactive
+// commands stay in gowatch's, which has the terminal: they couldn't read it
+// from another. A command given a pseudo-terminal as its stdin leads a
+// session of its own, and with it a group, with that as its terminal.
+func prepareTree(command *exec.Cmd, cmd config.Command) {
+	if cmd.Interactive {
+		return
+	}
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if cmd.PTY {
+		command.SysProcAttr.Setsid = true
+		command.SysProcAttr.Setctty = true
+		command.SysProcAttr.Ctty = 0
+		return
+	}
+	command.SysProcAttr.Setpgid = true
+}
+
+// trackTree records the process group of a started command
+func trackTree(command *exec.Cmd) error {
+	if attr := command.SysProcAttr; attr != nil && (attr.Setpgid || attr.Setsid) {
+		groups.Store(command.Process, struct{}{})
+	}
+	return nil
+}
+
+// untrackTree forgets the process group of a command once it has been
+// waited on. Processes it left running keep running.
+func untrackTree(proc *os.Process) {
+	groups.Delete(proc)
+}
+
+// killTree kills a command along with every process in its group
+func killTree(proc *os.Process) error {
+	return signalTree(proc, os.Kill)
+}
+
+// signalTree sends a signal to every process in the group of a command, or
+// to the command alone if it has no group of its own
+func signalTree(proc *os.Process, sig os.Signal) error {
+	if _, ok := groups.Load(proc); ok {
+		if s, ok := sig.(syscall.Signal); ok && syscall.Kill(-proc.Pid, s) == nil {
+			return nil
+		}
+	}
+	return proc.Signal(sig)
+}
+
+// setPriority applies the nice value and I/O class of a started command to
+// its process group, or to it alone if it has none
+func setPriority(proc *os.Process, cmd config.Command) error {
+	_, group := groups.Load(proc)
+	if cmd.Nice != 0 {
+		which := syscall.PRIO_PROCESS
+		if group {
+			which = syscall.PRIO_PGRP
+		}
+		if err := syscall.Setpriority(which, proc.Pid, cmd.Nice); err != nil {
+			return fmt.Errorf("nice %d: %w", cmd.Nice, err)
+		}
+	}
+	if cmd.IONice != "" {
+		if err := setIONice(proc.Pid, group, cmd); err != nil {
+			return fmt.Errorf("ionice %s: %w", cmd.IONice, err)
+		}
+	}
+	return nil
+}
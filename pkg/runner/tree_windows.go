@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"gowatch/pkg/config"
+
+	"golang.org/x/sys/windows"
+)
+
+// jobs holds the job object of each running command by process, so that
+// killing a command kills the processes it started too, such as those of a
+// batch file or an npm script
+var jobs sync.Map
+
+// prepareTree has a command start suspended, so that it is in its job
+// object before it can start processes of its own, and in the priority
+// class of its nice value, which the processes it starts inherit
+func prepareTree(command *exec.Cmd, cmd config.Command) {
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.CreationFlags |= windows.CREATE_SUSPENDED | priorityClass(cmd.Nice)
+}
+
+// priorityClass maps a nice value to the nearest priority class, or 0 to
+// keep the default
+func priorityClass(nice int) uint32 {
+	switch {
+	case nice >= 15:
+		return windows.IDLE_PRIORITY_CLASS
+	case nice > 0:
+		return windows.BELOW_NORMAL_PRIORITY_CLASS
+	case nice <= -15:
+		return windows.HIGH_PRIORITY_CLASS
+	case nice < 0:
+		return windows.ABOVE_NORMAL_PRIORITY_CLASS
+	}
+	return 0
+}
+
+// setPriority is a no-op: commands start in their priority class, and
+// ionice is Linux only
+func setPriority(proc *os.Process, cmd config.Command) error {
+	return nil
+}
+
+// trackTree puts a started command in a job object of its own, which the
+// processes it starts join, and resumes it. Without a job object the
+// command still runs, and is killed alone. An error means that it couldn't
+// be resumed.
+func trackTree(command *exec.Cmd) error {
+	proc := command.Process
+	if job, err := assignJob(proc.Pid); err == nil {
+		jobs.Store(proc, job)
+	}
+	if err := resumeProcess(proc.Pid); err != nil {
+		untrackTree(proc)
+		return fmt.Errorf("failed to resume process %d: %w", proc.Pid, err)
+	}
+	return nil
+}
+
+// assignJob creates a job object holding the process
+func assignJob(pid int) (windows.Handle, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		return 0, err
+	}
+	defer windows.CloseHandle(process)
+	if err := windows.AssignProcessToJobObject(job, process); err != nil {
+		windows.CloseHandle(job)
+		return 0, err
+	}
+	return job, nil
+}
+
+// resumeProcess resumes the main thread of a process started suspended
+func resumeProcess(pid int) error {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(snapshot)
+
+	entry := windows.ThreadEntry32{Size: uint32(unsafe.Sizeof(windows.ThreadEntry32{}))}
+	for err = windows.Thread32First(snapshot, &entry); err == nil; err = windows.Thread32Next(snapshot, &entry) {
+		if entry.OwnerProcessID != uint32(pid) {
+			continue
+		}
+		thread, err := windows.OpenThread(windows.THREAD_SUSPEND_RESUME, false, entry.ThreadID)
+		if err != nil {
+			return err
+		}
+		defer windows.CloseHandle(thread)
+		_, err = windows.ResumeThread(thread)
+		return err
+	}
+	return fmt.Errorf("no thread found")
+}
+
+// untrackTree closes the job object of a command once it has been waited
+// on. Processes it left running keep running.
+func untrackTree(proc *os.Process) {
+	if job, ok := jobs.LoadAndDelete(proc); ok {
+		windows.CloseHandle(job.(windows.Handle))
+	}
+}
+
+// killTree kills a command along with every process in its job object
+func killTree(proc *os.Process) error {
+	job, ok := jobs.Load(proc)
+	if !ok {
+		return proc.Kill()
+	}
+	if err := windows.TerminateJobObject(job.(windows.Handle), 1); err != nil {
+		return proc.Kill()
+	}
+	return nil
+}
+
+// signalTree sends a signal to a command, which Windows can only do for
+// os.Kill
+func signalTree(proc *os.Process, sig os.Signal) error {
+	return proc.Signal(sig)
+}
@@ -0,0 +1,286 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gowatch/internal/ignore"
+	"gowatch/pkg/config"
+)
+
+// watchCacheVersion changes whenever the cache file format does; caches of
+// another version are ignored
+const watchCacheVersion = 2
+
+// watchCache is the file recording the directories of recursive watch
+// paths, so that a restart can watch them without walking the trees first
+type watchCache struct {
+	Version int `json:"version"`
+	// Roots holds the directories walked under each watch root, by
+	// absolute path
+	Roots map[string]*cachedRoot `json:"roots"`
+}
+
+// cachedRoot is the tree under a watch root, as of its last walk
+type cachedRoot struct {
+	MaxDepth       int  `json:"max_depth"`
+	FollowSymlinks bool `json:"follow_symlinks,omitempty"`
+	// Dirs holds every directory walked, by slash-separated path relative
+	// to the root, "." for the root itself
+	Dirs map[string]cachedDir `json:"dirs"`
+}
+
+// cachedDir is a directory as its last walk found it
+type cachedDir struct {
+	// ModTime is the modification time of the directory when it was
+	// listed, in nanoseconds. Adding, removing or renaming an entry
+	// changes it, so an unchanged directory has the same subdirectories.
+	ModTime int64 `json:"mtime"`
+	// Subdirs names its subdirectories, ignored ones included, and Files
+	// counts its other entries
+	Subdirs []string `json:"subdirs,omitempty"`
+	Files   int      `json:"files,omitempty"`
+	// IgnoreFile is set when it holds a .gowatchignore
+	IgnoreFile bool `json:"ignore_file,omitempty"`
+}
+
+// matches reports whether the root was cached with the same options
+func (r *cachedRoot) matches(opts walkOptions) bool {
+	return r.MaxDepth == opts.maxDepth && r.FollowSymlinks == opts.followSymlinks
+}
+
+// cacheMu serializes the watchers of a process writing the cache file,
+// which each update their own roots
+var cacheMu sync.Mutex
+
+// readWatchCache reads a cache file; a missing file, or one of another
+// version, is an empty cache
+func readWatchCache(path string) (*watchCache, error) {
+	cache := &watchCache{Version: watchCacheVersion, Roots: map[string]*cachedRoot{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var read watchCache
+	if err := json.Unmarshal(data, &read); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if read.Version != watchCacheVersion || read.Roots == nil {
+		return cache, nil
+	}
+	return &read, nil
+}
+
+// saveWatchCache replaces the given roots in the cache file, keeping those
+// of other watchers. The file is replaced atomically.
+func saveWatchCache(path string, roots map[string]*cachedRoot) error {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	cache, err := readWatchCache(path)
+	if err != nil {
+		// Start over from a damaged cache
+		cache = &watchCache{Version: watchCacheVersion, Roots: map[string]*cachedRoot{}}
+	}
+	for root, r := range roots {
+		cache.Roots[root] = r
+	}
+
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// dirTree records the directories of a walk. Directories that haven't
+// changed since the previous walk are not listed again: their
+// subdirectories are taken from it.
+type dirTree struct {
+	root     string
+	previous map[string]cachedDir
+	mu       sync.Mutex
+	dirs     map[string]cachedDir
+}
+
+func newDirTree(root string, previous map[string]cachedDir) *dirTree {
+	return &dirTree{root: root, previous: previous, dirs: map[string]cachedDir{}}
+}
+
+// subdirs returns the names of the subdirectories of dir, listing it unless
+// it is unchanged since the previous walk, and records them
+func (t *dirTree) subdirs(dir string, opts walkOptions) ([]string, int, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	rel, err := filepath.Rel(t.root, dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	rel = filepath.ToSlash(rel)
+	mtime := info.ModTime().UnixNano()
+
+	// The targets of symlinks can change without their directory changing
+	d, ok := t.previous[rel]
+	if !ok || d.ModTime != mtime || opts.followSymlinks {
+		d = cachedDir{ModTime: mtime}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, entry := range entries {
+			if entry.Name() == ignore.FileName {
+				d.IgnoreFile = true
+			}
+			if isSubdir(dir, entry, opts) {
+				d.Subdirs = append(d.Subdirs, entry.Name())
+			} else if !entry.IsDir() {
+				d.Files++
+			}
+		}
+	}
+
+	t.mu.Lock()
+	t.dirs[rel] = d
+	t.mu.Unlock()
+	return d.Subdirs, d.Files, nil
+}
+
+// cached returns the tree as it stands after the walk
+func (t *dirTree) cached(opts walkOptions) *cachedRoot {
+	return &cachedRoot{MaxDepth: opts.maxDepth, FollowSymlinks: opts.followSymlinks, Dirs: t.dirs}
+}
+
+// cacheable reports whether a watch path is walked natively and so has its
+// directories cached
+func (w *Watcher) cacheable(wp config.WatchPath) bool {
+	if !wp.Recursive || w.backendFor(wp) != config.BackendFSNotify {
+		return false
+	}
+	absPath, err := filepath.Abs(wp.Path)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(absPath)
+	return err == nil && info.IsDir()
+}
+
+// addCached watches a recursive watch path. Its cached directories are
+// watched right away and left to verifyCache to check; without them the
+// tree is walked and the result cached.
+func (w *Watcher) addCached(wp config.WatchPath, cache *watchCache) error {
+	root, err := filepath.Abs(wp.Path)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	opts := walkOptionsFor(wp)
+
+	cached, ok := cache.Roots[root]
+	if !ok || !cached.matches(opts) {
+		tree := newDirTree(root, nil)
+		opts.tree = tree
+		if err := w.addRecursive(root, opts); err != nil {
+			return err
+		}
+		w.cached[root] = tree.cached(opts)
+		return nil
+	}
+
+	start := time.Now()
+	// Ignore files first, so that changes in the directories are filtered
+	// from the start
+	for rel, d := range cached.Dirs {
+		if d.IgnoreFile {
+			if err := w.ignore.AddFile(filepath.Join(root, filepath.FromSlash(rel), ignore.FileName)); err != nil {
+				w.log.Warn("%v", err)
+			}
+		}
+	}
+	for rel, d := range cached.Dirs {
+		dir := filepath.Join(root, filepath.FromSlash(rel))
+		err := w.addSingle(dir)
+		if err != nil && isWatchLimit(err) {
+			return w.handleWatchLimit(root, opts)
+		}
+		// Directories removed since are dropped when the cache is checked
+		w.cover(dir, d.Files)
+	}
+	w.log.Watch("Watching %d cached directories under %s in %s", len(cached.Dirs), root, time.Since(start).Round(time.Millisecond))
+	w.stale[root] = cached
+	return nil
+}
+
+// verifyCache walks the trees watched from the cache in the background,
+// watching the directories created since and dropping those removed or
+// ignored since, then saves the cache
+func (w *Watcher) verifyCache(ctx context.Context, path string) {
+	for root, cached := range w.stale {
+		if ctx.Err() != nil {
+			return
+		}
+		opts := walkOptions{maxDepth: cached.MaxDepth, followSymlinks: cached.FollowSymlinks}
+		tree := newDirTree(root, cached.Dirs)
+		opts.tree = tree
+		if err := w.addRecursive(root, opts); err != nil {
+			w.log.Warn("Failed to check the cached directories of %s: %v", root, err)
+			continue
+		}
+
+		dropped := 0
+		w.mu.Lock()
+		for rel := range cached.Dirs {
+			if _, ok := tree.dirs[rel]; ok {
+				continue
+			}
+			dir := filepath.Join(root, filepath.FromSlash(rel))
+			if w.watched[dir] {
+				w.fsWatcher.Remove(dir)
+				delete(w.watched, dir)
+				delete(w.files, dir)
+			}
+			dropped++
+		}
+		w.mu.Unlock()
+		added := 0
+		for rel := range tree.dirs {
+			if _, ok := cached.Dirs[rel]; !ok {
+				added++
+			}
+		}
+		if added > 0 || dropped > 0 {
+			w.log.Watch("Cached directories of %s checked: %d added, %d dropped", root, added, dropped)
+		} else {
+			w.log.Debug("Cached directories of %s are up to date", root)
+		}
+		w.cached[root] = tree.cached(opts)
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+	if err := saveWatchCache(path, w.cached); err != nil {
+		w.log.Warn("Failed to save the watch cache: %v", err)
+	}
+}
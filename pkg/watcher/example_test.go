@@ -0,0 +1,50 @@
+package watcher_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/runner"
+	"gowatch/pkg/watcher"
+)
+
+// Example embeds gowatch: run `go build` whenever a file under ./ changes
+func Example() {
+	cfg := &config.Config{
+		Watch:  []config.WatchPath{{Path: ".", Recursive: true}},
+		Ignore: []string{"vendor/", "*.tmp"},
+		OnChange: config.OnChange{
+			Commands: []config.Command{{Cmd: []string{"go", "build", "./..."}}},
+		},
+	}
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	w, err := watcher.New(cfg, watcher.Options{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer w.Close()
+
+	r := runner.New(cfg, runner.Options{Sequential: true})
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := w.Start(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for ev := range events {
+		results := r.RunTrigger(ctx, runner.Trigger{Path: ev.Path, Event: ev.Op, Files: ev.Files})
+		for _, result := range results {
+			fmt.Println(result.Command, result.ExitCode)
+		}
+	}
+}
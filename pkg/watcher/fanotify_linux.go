@@ -0,0 +1,291 @@
+package watcher
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/sys/unix"
+)
+
+// fanotifyMask selects the changes a fanotify mark reports. FAN_ONDIR adds
+// directory changes, which only invalidate the cached directory paths.
+const fanotifyMask = unix.FAN_CREATE | unix.FAN_DELETE | unix.FAN_MODIFY | unix.FAN_ATTRIB |
+	unix.FAN_MOVED_FROM | unix.FAN_MOVED_TO | unix.FAN_ONDIR
+
+// fanotifyCacheSize bounds the number of directory paths kept per mark
+const fanotifyCacheSize = 4096
+
+// Sizes of the records read from a fanotify group
+const (
+	fanotifyMetadataSize = 24 // struct fanotify_event_metadata
+	fanotifyInfoSize     = 4  // struct fanotify_event_info_header
+	fanotifyHandleOffset = 12 // info header and fsid before the file handle
+)
+
+// fanotifyWatcher receives the changes under a watch path from a fanotify
+// group marking the whole filesystem that contains it, so that no watch is
+// needed per directory. Events name the directory by file handle, which is
+// resolved to a path and filtered to the watch path.
+type fanotifyWatcher struct {
+	wp   config.WatchPath
+	root string
+	// resolved is the root with symlinks resolved, as paths are reported
+	resolved string
+	log      *logger.Logger
+
+	file  *os.File
+	mount int
+	// dirs caches the paths of directory handles; it is cleared whenever a
+	// directory is moved or removed
+	dirs map[string]string
+
+	closeOnce sync.Once
+}
+
+// newFanotifyWatcher marks the filesystem containing root. It fails when the
+// kernel is older than 5.9, lacks fanotify, or the process doesn't have
+// CAP_SYS_ADMIN and CAP_DAC_READ_SEARCH.
+func newFanotifyWatcher(wp config.WatchPath, root string, log *logger.Logger) (*fanotifyWatcher, error) {
+	resolved, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_NOTIF|unix.FAN_CLOEXEC|unix.FAN_NONBLOCK|unix.FAN_REPORT_DFID_NAME,
+		unix.O_RDONLY|unix.O_LARGEFILE|unix.O_CLOEXEC)
+	switch {
+	case errors.Is(err, unix.EPERM):
+		return nil, errors.New("fanotify needs CAP_SYS_ADMIN (run as root)")
+	case errors.Is(err, unix.EINVAL):
+		return nil, errors.New("the kernel doesn't report file names through fanotify (needs Linux 5.9 or newer)")
+	case errors.Is(err, unix.ENOSYS):
+		return nil, errors.New("the kernel was built without fanotify")
+	case err != nil:
+		return nil, fmt.Errorf("fanotify_init: %w", err)
+	}
+
+	if err := unix.FanotifyMark(fd, unix.FAN_MARK_ADD|unix.FAN_MARK_FILESYSTEM, fanotifyMask, unix.AT_FDCWD, resolved); err != nil {
+		unix.Close(fd)
+		if errors.Is(err, unix.EXDEV) || errors.Is(err, unix.ENODEV) || errors.Is(err, unix.EOPNOTSUPP) {
+			return nil, fmt.Errorf("the filesystem can't be marked: %w", err)
+		}
+		return nil, fmt.Errorf("fanotify_mark: %w", err)
+	}
+
+	mount, err := unix.Open(resolved, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	// Directory handles are resolved with open_by_handle_at, which needs
+	// CAP_DAC_READ_SEARCH; try it on the root rather than fail on events
+	handle, _, err := unix.NameToHandleAt(unix.AT_FDCWD, resolved, 0)
+	if err == nil {
+		var dir int
+		if dir, err = unix.OpenByHandleAt(mount, handle, unix.O_PATH|unix.O_CLOEXEC); err == nil {
+			unix.Close(dir)
+		}
+	}
+	if err != nil {
+		unix.Close(mount)
+		unix.Close(fd)
+		if errors.Is(err, unix.EPERM) {
+			return nil, errors.New("resolving fanotify events needs CAP_DAC_READ_SEARCH")
+		}
+		return nil, fmt.Errorf("the filesystem doesn't support file handles: %w", err)
+	}
+
+	return &fanotifyWatcher{
+		wp:       wp,
+		root:     root,
+		resolved: resolved,
+		log:      log,
+		file:     os.NewFile(uintptr(fd), "fanotify"),
+		mount:    mount,
+		dirs:     make(map[string]string),
+	}, nil
+}
+
+// CheckFanotify reports why the fanotify backend can't watch a directory,
+// if it can't
+func CheckFanotify(dir string) error {
+	m, err := newFanotifyWatcher(config.WatchPath{}, dir, nil)
+	if err != nil {
+		return err
+	}
+	m.close()
+	return nil
+}
+
+// run forwards the changes under the root until the context is cancelled
+func (m *fanotifyWatcher) run(ctx context.Context, out chan<- fsnotify.Event) {
+	defer m.close()
+	go func() {
+		// Unblocks the read below
+		<-ctx.Done()
+		m.file.Close()
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := m.file.Read(buf)
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, os.ErrClosed) {
+				m.log.Error("fanotify: reading events for %s failed: %v", m.root, err)
+			}
+			return
+		}
+		for _, ev := range m.parse(buf[:n]) {
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// parse turns a buffer of fanotify events into events under the root
+func (m *fanotifyWatcher) parse(buf []byte) []fsnotify.Event {
+	var events []fsnotify.Event
+	for len(buf) >= fanotifyMetadataSize {
+		eventLen := int(binary.NativeEndian.Uint32(buf[0:]))
+		metaLen := int(binary.NativeEndian.Uint16(buf[6:]))
+		mask := binary.NativeEndian.Uint64(buf[8:])
+		if buf[4] != unix.FANOTIFY_METADATA_VERSION || eventLen < metaLen || eventLen > len(buf) {
+			m.log.Debug("fanotify: unexpected event record")
+			break
+		}
+		info := buf[metaLen:eventLen]
+		buf = buf[eventLen:]
+
+		if mask&unix.FAN_Q_OVERFLOW != 0 {
+			m.log.Warn("fanotify: event queue overflowed, some changes under %s were missed", m.root)
+			continue
+		}
+		if mask&unix.FAN_ONDIR != 0 {
+			if mask&(unix.FAN_MOVED_FROM|unix.FAN_MOVED_TO|unix.FAN_DELETE) != 0 {
+				clear(m.dirs)
+			}
+			continue
+		}
+
+		path, ok := m.resolve(info)
+		if !ok {
+			continue
+		}
+		if op := fanotifyOp(mask); op != 0 {
+			events = append(events, fsnotify.Event{Name: path, Op: op})
+		}
+	}
+	return events
+}
+
+// resolve returns the path under the root named by the directory handle and
+// file name of an event, if it is within the watched depth
+func (m *fanotifyWatcher) resolve(info []byte) (string, bool) {
+	for len(info) >= fanotifyInfoSize {
+		infoType := info[0]
+		infoLen := int(binary.NativeEndian.Uint16(info[2:]))
+		if infoLen < fanotifyInfoSize || infoLen > len(info) {
+			return "", false
+		}
+		record := info[:infoLen]
+		info = info[infoLen:]
+		if infoType != unix.FAN_EVENT_INFO_TYPE_DFID_NAME || len(record) < fanotifyHandleOffset+8 {
+			continue
+		}
+
+		size := int(binary.NativeEndian.Uint32(record[fanotifyHandleOffset:]))
+		handleType := int32(binary.NativeEndian.Uint32(record[fanotifyHandleOffset+4:]))
+		start := fanotifyHandleOffset + 8
+		if start+size > len(record) {
+			return "", false
+		}
+		handle := record[start : start+size]
+		name, _, _ := strings.Cut(string(record[start+size:]), "\x00")
+		if name == "" || name == "." {
+			return "", false
+		}
+
+		dir, ok := m.dir(handleType, handle)
+		if !ok {
+			return "", false
+		}
+		rel, err := filepath.Rel(m.resolved, filepath.Join(dir, name))
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", false
+		}
+		if !withinDepth(m.wp, filepath.ToSlash(rel)) {
+			return "", false
+		}
+		return filepath.Join(m.root, rel), true
+	}
+	return "", false
+}
+
+// dir returns the path of a directory handle
+func (m *fanotifyWatcher) dir(handleType int32, handle []byte) (string, bool) {
+	key := strconv.Itoa(int(handleType)) + ":" + string(handle)
+	if dir, ok := m.dirs[key]; ok {
+		return dir, true
+	}
+
+	fd, err := unix.OpenByHandleAt(m.mount, unix.NewFileHandle(handleType, handle), unix.O_PATH|unix.O_CLOEXEC)
+	if err != nil {
+		// The directory is already gone
+		return "", false
+	}
+	dir, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(fd))
+	unix.Close(fd)
+	if err != nil {
+		return "", false
+	}
+
+	if len(m.dirs) >= fanotifyCacheSize {
+		clear(m.dirs)
+	}
+	m.dirs[key] = dir
+	return dir, true
+}
+
+// close releases the fanotify group
+func (m *fanotifyWatcher) close() {
+	m.closeOnce.Do(func() {
+		m.file.Close()
+		unix.Close(m.mount)
+	})
+}
+
+// fanotifyOp maps a fanotify event mask to fsnotify operations
+func fanotifyOp(mask uint64) fsnotify.Op {
+	var op fsnotify.Op
+	if mask&(unix.FAN_CREATE|unix.FAN_MOVED_TO) != 0 {
+		op |= fsnotify.Create
+	}
+	if mask&unix.FAN_MODIFY != 0 {
+		op |= fsnotify.Write
+	}
+	if mask&unix.FAN_DELETE != 0 {
+		op |= fsnotify.Remove
+	}
+	if mask&unix.FAN_MOVED_FROM != 0 {
+		op |= fsnotify.Rename
+	}
+	if mask&unix.FAN_ATTRIB != 0 {
+		op |= fsnotify.Chmod
+	}
+	return op
+}
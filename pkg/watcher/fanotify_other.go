@@ -0,0 +1,31 @@
+//go:build !linux
+
+package watcher
+
+import (
+	"context"
+	"errors"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fanotifyWatcher is only available on Linux
+type fanotifyWatcher struct{}
+
+// CheckFanotify reports why the fanotify backend can't watch a directory;
+// it never can outside Linux
+func CheckFanotify(dir string) error {
+	_, err := newFanotifyWatcher(config.WatchPath{}, dir, nil)
+	return err
+}
+
+func newFanotifyWatcher(config.WatchPath, string, *logger.Logger) (*fanotifyWatcher, error) {
+	return nil, errors.New("fanotify is only available on Linux")
+}
+
+func (m *fanotifyWatcher) run(context.Context, chan<- fsnotify.Event) {}
+
+func (m *fanotifyWatcher) close() {}
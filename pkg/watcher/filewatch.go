@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileWatchDelay coalesces the burst of events editors produce on save
+const fileWatchDelay = 100 * time.Millisecond
+
+// WatchFile notifies on the returned channel whenever the file is written,
+// created or replaced. The parent directory is watched rather than the file
+// itself so that editors which save via rename are handled. Watching stops
+// when the context is cancelled.
+func WatchFile(ctx context.Context, path string) (<-chan struct{}, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	absPath = filepath.Clean(absPath)
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fsnotify watcher: %w", err)
+	}
+
+	if err := fsw.Add(filepath.Dir(absPath)); err != nil {
+		fsw.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", absPath, err)
+	}
+
+	changes := make(chan struct{}, 1)
+	debouncer := NewDebouncer(fileWatchDelay)
+
+	go func() {
+		defer fsw.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case event, ok := <-fsw.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != absPath {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+
+				debouncer.Add(absPath, func() {
+					// Never block: one pending notification is enough
+					select {
+					case changes <- struct{}{}:
+					default:
+					}
+				})
+
+			case _, ok := <-fsw.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+
+	return changes, nil
+}
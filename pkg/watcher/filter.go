@@ -0,0 +1,174 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gowatch/internal/ignore"
+	"gowatch/pkg/config"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// pathFilter restricts which changes under a watch path produce events: by
+// file (include patterns, extensions) and by event type
+type pathFilter struct {
+	root       string
+	include    *ignore.Matcher
+	extensions []string
+	events     []string
+	// strategy is the debounce strategy for changes under root
+	strategy string
+	// maxSize drops changes to larger files; 0 means no limit
+	maxSize int64
+	// bufferSize is the change buffer of each watched directory on
+	// Windows; 0 means the default
+	bufferSize int
+	// counts feed RootStats
+	counts rootCounters
+	// tags label the changes under root
+	tags []string
+}
+
+// newPathFilter builds the filter for a watch path
+func newPathFilter(i int, wp config.WatchPath) (*pathFilter, error) {
+	path := wp.Path
+	if wp.Remote != "" {
+		_, path = wp.RemoteTarget()
+	}
+	root, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	f := &pathFilter{root: filepath.Clean(root), events: wp.Events, tags: wp.Tags}
+	if len(wp.Include) > 0 {
+		f.include = ignore.New()
+		f.include.Add(f.root, fmt.Sprintf("include[%d]", i), wp.Include)
+	}
+	for _, ext := range wp.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		f.extensions = append(f.extensions, ext)
+	}
+
+	return f, nil
+}
+
+// restricted reports whether the filter limits which files produce events
+func (f *pathFilter) restricted() bool {
+	return f.include != nil || len(f.extensions) > 0
+}
+
+// matches reports whether a file passes the filter
+func (f *pathFilter) matches(path string) bool {
+	if !f.restricted() {
+		return true
+	}
+
+	ext := filepath.Ext(path)
+	for _, want := range f.extensions {
+		if strings.EqualFold(ext, want) {
+			return true
+		}
+	}
+
+	return f.include != nil && f.include.Match(path, false)
+}
+
+// tooLarge reports whether a file of the given size exceeds the limit
+func (f *pathFilter) tooLarge(size int64) bool {
+	return f.maxSize > 0 && size > f.maxSize
+}
+
+// accepts reports whether the event type passes the filter. Without an
+// events list everything but CHMOD is accepted.
+func (f *pathFilter) accepts(op fsnotify.Op) bool {
+	if len(f.events) == 0 {
+		return op&^fsnotify.Chmod != 0
+	}
+	return config.MatchEvents(f.events, opNames(op))
+}
+
+// contains reports whether path is the filter's root or below it
+func (f *pathFilter) contains(path string) bool {
+	rel, err := filepath.Rel(f.root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// filterFor returns the filter of the most specific watch path containing
+// path, or nil if none does
+func (w *Watcher) filterFor(path string) *pathFilter {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	if i := w.rootOf(absPath); i >= 0 {
+		return w.filters[i]
+	}
+	return nil
+}
+
+// isIncluded applies the include patterns and extensions of the watch path
+// containing path. Directories are never included in a restricted path.
+func (w *Watcher) isIncluded(path string, isDir bool) bool {
+	f := w.filterFor(path)
+	if f == nil || !f.restricted() {
+		return true
+	}
+	if isDir {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return true
+	}
+	return f.matches(absPath)
+}
+
+// acceptsOp applies the events list of the watch path containing path
+func (w *Watcher) acceptsOp(path string, op fsnotify.Op) bool {
+	f := w.filterFor(path)
+	if f == nil {
+		return op&^fsnotify.Chmod != 0
+	}
+	return f.accepts(op)
+}
+
+// isTooLarge applies the max_file_size of the watch path containing path
+func (w *Watcher) isTooLarge(path string, size int64) bool {
+	f := w.filterFor(path)
+	return f != nil && f.tooLarge(size)
+}
+
+// exceedsMaxSize checks the current size of a file against the
+// max_file_size of its watch path
+func (w *Watcher) exceedsMaxSize(path string) bool {
+	f := w.filterFor(path)
+	if f == nil || f.maxSize == 0 {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && f.tooLarge(info.Size())
+}
+
+// opNames splits an op into the names of its individual event types
+func opNames(op fsnotify.Op) []string {
+	var names []string
+	for _, o := range []fsnotify.Op{fsnotify.Create, fsnotify.Write, fsnotify.Remove, fsnotify.Rename, fsnotify.Chmod} {
+		if op.Has(o) {
+			names = append(names, o.String())
+		}
+	}
+	return names
+}
+
+// withinDepth applies recursive and max_depth to a file, given by its
+// slash-separated path relative to the watch path
+func withinDepth(wp config.WatchPath, rel string) bool {
+	limit := wp.DepthLimit()
+	return limit < 0 || strings.Count(rel, "/") <= limit
+}
@@ -0,0 +1,48 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// minSuggestedLimit is the smallest watch limit suggested when the current
+// one is too low
+const minSuggestedLimit = 524288
+
+// WatchLimitError reports that the OS ran out of file watches, as happens
+// with large trees on Linux when fs.inotify.max_user_watches is too low
+type WatchLimitError struct {
+	// Needed is the number of watches the watch paths need, at least
+	Needed int
+	// Limit is the configured per-user limit, 0 if unknown
+	Limit int
+}
+
+func (e *WatchLimitError) Error() string {
+	var b strings.Builder
+	if e.Limit > 0 {
+		fmt.Fprintf(&b, "inotify watch limit reached: at least %d watches needed, fs.inotify.max_user_watches is %d", e.Needed, e.Limit)
+	} else {
+		fmt.Fprintf(&b, "watch limit reached: at least %d watches needed", e.Needed)
+	}
+	fmt.Fprintf(&b, "\n  raise it with: sudo sysctl fs.inotify.max_user_watches=%d", SuggestedLimit(e.Needed))
+	b.WriteString("\n  (add it to /etc/sysctl.conf to keep it), ignore large directories, or set poll_fallback: true")
+	return b.String()
+}
+
+// SuggestedLimit returns a watch limit with headroom for needed watches,
+// since other programs (editors, file indexers) share the same limit
+func SuggestedLimit(needed int) int {
+	limit := minSuggestedLimit
+	for limit < 2*needed {
+		limit *= 2
+	}
+	return limit
+}
+
+// isWatchLimit reports whether err means the OS has no watches left
+func isWatchLimit(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
@@ -0,0 +1,20 @@
+package watcher
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WatchLimit returns the per-user inotify watch limit
+func WatchLimit() (int, bool) {
+	data, err := os.ReadFile("/proc/sys/fs/inotify/max_user_watches")
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package watcher
+
+// WatchLimit returns the per-user watch limit; only Linux has one to report
+func WatchLimit() (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package watcher
+
+// isNetworkPath reports whether path is on a network share. Only Windows
+// tells them apart cheaply; elsewhere `gowatch doctor` points them out.
+func isNetworkPath(string) bool {
+	return false
+}
@@ -0,0 +1,28 @@
+package watcher
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// isNetworkPath reports whether path is on a UNC share or a mapped network
+// drive
+func isNetworkPath(path string) bool {
+	volume := filepath.VolumeName(path)
+	switch upper := strings.ToUpper(volume); {
+	case strings.HasPrefix(upper, `\\?\UNC\`):
+		return true
+	case strings.HasPrefix(upper, `\\?\`), strings.HasPrefix(upper, `\\.\`):
+		// Local device paths such as \\?\C:
+	case strings.HasPrefix(upper, `\\`):
+		return true
+	}
+
+	root, err := windows.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return false
+	}
+	return windows.GetDriveType(root) == windows.DRIVE_REMOTE
+}
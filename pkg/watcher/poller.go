@@ -0,0 +1,167 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Poller detects changes by periodically scanning for mtime/size differences.
+// It is used for filesystems that don't deliver native notifications, such
+// as network shares and Docker bind mounts. Events are emitted in the same
+// shape as fsnotify so both backends share the same processing pipeline.
+type Poller struct {
+	interval time.Duration
+	ignore   func(path string, isDir bool) bool
+	Events   chan fsnotify.Event
+	Errors   chan error
+
+	mu       sync.Mutex
+	roots    map[string]int
+	snapshot map[string]fileState
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func NewPoller(interval time.Duration, ignore func(path string, isDir bool) bool) *Poller {
+	return &Poller{
+		interval: interval,
+		ignore:   ignore,
+		Events:   make(chan fsnotify.Event, 100),
+		Errors:   make(chan error, 10),
+		roots:    make(map[string]int),
+		snapshot: make(map[string]fileState),
+	}
+}
+
+// Add registers a file or directory to be polled. Directories are scanned
+// maxDepth levels of subdirectories deep, or entirely if maxDepth is
+// negative.
+func (p *Poller) Add(path string, maxDepth int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if current, ok := p.roots[path]; ok && (current < 0 || (maxDepth >= 0 && current > maxDepth)) {
+		maxDepth = current
+	}
+	p.roots[path] = maxDepth
+
+	// Record the current state so existing files don't fire on first scan
+	for file, state := range p.scanRoot(path, maxDepth) {
+		p.snapshot[file] = state
+	}
+}
+
+// Start polls until the context is cancelled
+func (p *Poller) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.poll(ctx)
+			}
+		}
+	}()
+}
+
+func (p *Poller) poll(ctx context.Context) {
+	p.mu.Lock()
+	current := make(map[string]fileState, len(p.snapshot))
+	for root, maxDepth := range p.roots {
+		for file, state := range p.scanRoot(root, maxDepth) {
+			current[file] = state
+		}
+	}
+	previous := p.snapshot
+	p.snapshot = current
+	p.mu.Unlock()
+
+	var events []fsnotify.Event
+	for file, state := range current {
+		old, existed := previous[file]
+		switch {
+		case !existed:
+			events = append(events, fsnotify.Event{Name: file, Op: fsnotify.Create})
+		case !old.modTime.Equal(state.modTime) || old.size != state.size:
+			events = append(events, fsnotify.Event{Name: file, Op: fsnotify.Write})
+		}
+	}
+	for file := range previous {
+		if _, exists := current[file]; !exists {
+			events = append(events, fsnotify.Event{Name: file, Op: fsnotify.Remove})
+		}
+	}
+
+	for _, ev := range events {
+		select {
+		case p.Events <- ev:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// scanRoot returns the state of every non-ignored file under root
+func (p *Poller) scanRoot(root string, maxDepth int) map[string]fileState {
+	files := make(map[string]fileState)
+
+	info, err := os.Stat(root)
+	if err != nil {
+		// The root may be temporarily unavailable (e.g. an unmounted share);
+		// its files will show up as removed and re-created once it returns
+		if !os.IsNotExist(err) {
+			p.reportError(err)
+		}
+		return files
+	}
+
+	if !info.IsDir() {
+		files[root] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return files
+	}
+
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Files can vanish mid-scan; that's picked up on the next poll
+			return nil
+		}
+
+		if info.IsDir() {
+			if path == root {
+				return nil
+			}
+			if tooDeep(root, path, maxDepth) || p.ignore(path, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if p.ignore(path, false) {
+			return nil
+		}
+
+		files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+
+	return files
+}
+
+func (p *Poller) reportError(err error) {
+	select {
+	case p.Errors <- err:
+	default:
+	}
+}
@@ -0,0 +1,103 @@
+package watcher
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"gowatch/pkg/config"
+)
+
+// overflowActions describe the queue overflow policies in warnings
+var overflowActions = map[string]string{
+	config.OverflowCoalesce:   "merging the queued events",
+	config.OverflowDropOldest: "dropping the oldest events",
+	config.OverflowBlock:      "holding back further changes",
+}
+
+// send queues an event on output for the runner. When the queue is full
+// the queue_overflow policy applies; a warning marks the start of an
+// overflow, and another its end once the runner has emptied the queue. It
+// reports whether the event was queued. w.sendMu must be held.
+func (w *Watcher) send(ctx context.Context, output chan Event, ev Event) bool {
+	if w.overflows > 0 && len(output) == 0 {
+		w.log.Warn("Event queue caught up after %d overflow(s)", w.overflows)
+		w.overflows = 0
+	}
+	select {
+	case output <- ev:
+		return true
+	default:
+	}
+
+	policy := w.cfg.GetQueueOverflow()
+	if w.overflows == 0 {
+		w.log.Warn("Event queue full (%d events), %s", cap(output), overflowActions[policy])
+	}
+	w.overflows++
+	w.counts.overflowed.Add(1)
+
+	switch policy {
+	case config.OverflowDropOldest:
+		select {
+		case old := <-output:
+			w.log.Debug("Dropped queued event: %s %s", old.Op, old.Path)
+		default:
+			// Received meanwhile
+		}
+	case config.OverflowCoalesce:
+		ev = coalesce(output, ev)
+	}
+
+	// Only block waits here: the other policies made room, and only this
+	// goroutine sends
+	select {
+	case output <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// coalesce takes the events queued on output and merges them into ev,
+// which comes last
+func coalesce(output chan Event, ev Event) Event {
+	var batch []Event
+	var ops, tags []string
+	add := func(e Event) {
+		files := e.Files
+		if len(files) == 0 {
+			files = []string{e.Path}
+		}
+		for i, f := range files {
+			batch = appendBatch(batch, Event{Path: f, Timestamp: e.ChangedAt(i), Ops: e.OpsAt(i), Tags: e.TagsAt(i)})
+		}
+		for _, op := range e.Ops {
+			if !slices.Contains(ops, op) {
+				ops = append(ops, op)
+			}
+		}
+		tags = mergeTags(tags, e.Tags)
+	}
+	for drained := false; !drained; {
+		select {
+		case queued := <-output:
+			add(queued)
+		default:
+			drained = true
+		}
+	}
+	add(ev)
+
+	ev.Files = make([]string, len(batch))
+	ev.Changed = make([]time.Time, len(batch))
+	ev.FileOps = make([][]string, len(batch))
+	ev.FileTags = make([][]string, len(batch))
+	for i, e := range batch {
+		ev.Files[i], ev.Changed[i] = e.Path, e.Timestamp
+		ev.FileOps[i], ev.FileTags[i] = e.Ops, e.Tags
+	}
+	ev.Ops = ops
+	ev.Tags = tags
+	return ev
+}
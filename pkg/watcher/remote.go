@@ -0,0 +1,240 @@
+package watcher
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gowatch/internal/ignore"
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// sshCommand is the ssh client used to reach remote watch paths
+var sshCommand = "ssh"
+
+// remoteRetry is how long to wait before reconnecting to a remote host
+const remoteRetry = 5 * time.Second
+
+// listingEnd ends each listing of a remote directory; file lines always
+// start with a timestamp, so it can't be mistaken for one
+const listingEnd = "--"
+
+// remoteEvent is a change on a remote watch path, named
+// "[user@]host:/path", with the debounce strategy of that path
+type remoteEvent struct {
+	event    fsnotify.Event
+	strategy string
+}
+
+// remoteWatcher detects changes to a directory on another machine. It keeps
+// one SSH connection open that lists the files under the directory with
+// their mtime and size every interval, and turns the differences between
+// listings into events like the Poller does for local paths. The remote
+// host needs a POSIX shell and GNU find.
+type remoteWatcher struct {
+	wp       config.WatchPath
+	host     string
+	dir      string
+	interval time.Duration
+	filter   *pathFilter
+	ignore   *ignore.Matcher
+	log      *logger.Logger
+
+	snapshot map[string]fileState
+}
+
+func newRemoteWatcher(wp config.WatchPath, filter *pathFilter, interval time.Duration, log *logger.Logger) *remoteWatcher {
+	host, dir := wp.RemoteTarget()
+	dir = strings.TrimSuffix(dir, "/")
+	if dir == "" {
+		dir = "/"
+	}
+	r := &remoteWatcher{
+		wp:       wp,
+		host:     host,
+		dir:      dir,
+		interval: interval,
+		filter:   filter,
+		ignore:   ignore.New(),
+		log:      log,
+	}
+	r.ignore.Add(dir, "remote", wp.Ignore)
+	return r
+}
+
+// run watches until the context is cancelled, reconnecting whenever the
+// connection drops. Changes made while disconnected are reported once the
+// next listing arrives.
+func (r *remoteWatcher) run(ctx context.Context, out chan<- remoteEvent) {
+	for {
+		err := r.listen(ctx, out)
+		if ctx.Err() != nil {
+			return
+		}
+		r.log.Warn("Lost connection to %s: %v (reconnecting in %s)", r.wp.Remote, err, remoteRetry)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(remoteRetry):
+		}
+	}
+}
+
+// listen runs the listing loop on the remote host and processes listings
+// until the connection ends
+func (r *remoteWatcher) listen(ctx context.Context, out chan<- remoteEvent) error {
+	cmd := exec.CommandContext(ctx, sshCommand,
+		"-T",
+		"-o", "BatchMode=yes",
+		"-o", "ServerAliveInterval=15",
+		"-o", "ServerAliveCountMax=3",
+		r.host, r.script())
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	r.log.Debug("Connected to %s", r.host)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	current := make(map[string]fileState)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != listingEnd {
+			if path, state, ok := parseListing(line); ok && !r.ignored(path) {
+				current[path] = state
+			}
+			continue
+		}
+
+		if r.snapshot == nil {
+			r.log.Watch("Watching %s (%d files, every %s)", r.wp.Remote, len(current), r.interval)
+		} else if !r.compare(ctx, current, out) {
+			break
+		}
+		r.snapshot = current
+		current = make(map[string]fileState)
+	}
+
+	err = cmd.Wait()
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("%s", msg)
+	}
+	if err == nil {
+		err = fmt.Errorf("connection closed")
+	}
+	return err
+}
+
+// script returns the shell loop run on the remote host. Hidden files and
+// directories are skipped there, as they are for local paths.
+func (r *remoteWatcher) script() string {
+	dir := shellQuote(r.dir)
+	args := []string{"find"}
+	if r.wp.FollowSymlinks {
+		args = append(args, "-L")
+	}
+	args = append(args, dir)
+	if limit := r.wp.DepthLimit(); limit >= 0 {
+		args = append(args, "-maxdepth", strconv.Itoa(limit+1))
+	}
+	args = append(args, `\(`, "-name", "'.*'", "!", "-path", dir, `\)`, "-prune",
+		"-o", "-type", "f", "-printf", `'%T@ %s %p\n'`)
+
+	sleep := strconv.FormatFloat(r.interval.Seconds(), 'f', -1, 64)
+	return fmt.Sprintf("while :; do %s 2>/dev/null; echo %s; sleep %s; done", strings.Join(args, " "), listingEnd, sleep)
+}
+
+// ignored applies the ignore, include and extension settings of the watch
+// path to a remote file
+func (r *remoteWatcher) ignored(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return true
+	}
+	return r.ignore.Match(abs, false) || !r.filter.matches(abs)
+}
+
+// compare emits events for the differences between the last listing and
+// current. It returns false if the context was cancelled.
+func (r *remoteWatcher) compare(ctx context.Context, current map[string]fileState, out chan<- remoteEvent) bool {
+	var events []fsnotify.Event
+	for file, state := range current {
+		old, existed := r.snapshot[file]
+		switch {
+		case r.filter.tooLarge(state.size):
+			continue
+		case !existed:
+			events = append(events, fsnotify.Event{Name: file, Op: fsnotify.Create})
+		case !old.modTime.Equal(state.modTime) || old.size != state.size:
+			events = append(events, fsnotify.Event{Name: file, Op: fsnotify.Write})
+		}
+	}
+	for file := range r.snapshot {
+		if _, exists := current[file]; !exists {
+			events = append(events, fsnotify.Event{Name: file, Op: fsnotify.Remove})
+		}
+	}
+
+	for _, ev := range events {
+		if !r.filter.accepts(ev.Op) {
+			continue
+		}
+		ev.Name = r.host + ":" + ev.Name
+		select {
+		case out <- remoteEvent{event: ev, strategy: r.filter.strategy}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// parseListing parses a "<mtime> <size> <path>" line of a listing
+func parseListing(line string) (string, fileState, bool) {
+	mtime, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return "", fileState{}, false
+	}
+	sizeStr, path, ok := strings.Cut(rest, " ")
+	if !ok || path == "" {
+		return "", fileState{}, false
+	}
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil {
+		return "", fileState{}, false
+	}
+
+	secStr, fracStr, _ := strings.Cut(mtime, ".")
+	sec, err := strconv.ParseInt(secStr, 10, 64)
+	if err != nil {
+		return "", fileState{}, false
+	}
+	var nsec int64
+	if fracStr != "" {
+		fracStr = (fracStr + "000000000")[:9]
+		if nsec, err = strconv.ParseInt(fracStr, 10, 64); err != nil {
+			return "", fileState{}, false
+		}
+	}
+	return path, fileState{modTime: time.Unix(sec, nsec), size: size}, true
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,134 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gowatch/internal/ignore"
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// simulatedOps maps the event types of a simulated change to fsnotify ops
+var simulatedOps = map[string]fsnotify.Op{
+	config.EventCreate: fsnotify.Create,
+	config.EventWrite:  fsnotify.Write,
+	config.EventRemove: fsnotify.Remove,
+	config.EventRename: fsnotify.Rename,
+	config.EventChmod:  fsnotify.Chmod,
+}
+
+// Simulation is what the watcher would do with a change
+type Simulation struct {
+	// Path is the absolute path of the change, as the watcher reports it
+	Path string
+	// WatchPath is the index of the most specific watch path containing
+	// the change, -1 if none does
+	WatchPath int
+	// Ignored names what ignores the change: a rule and where it came from,
+	// such as "watch[0]: vendor/**", or a built-in rule
+	Ignored string
+	// Dropped tells why a change that isn't ignored triggers nothing
+	Dropped string
+	// Strategy is the debounce strategy the change is delivered under
+	Strategy string
+}
+
+// Triggers reports whether the change would trigger a run
+func (s Simulation) Triggers() bool {
+	return s.Ignored == "" && s.Dropped == ""
+}
+
+// Simulate decides what a watcher for cfg would do with a change of the
+// given type ("write", "create", ...) to path, without watching anything.
+// A trailing slash marks a directory. The ignore files on the way to the
+// path are read, but file sizes aren't checked and remote watch paths are
+// left out.
+func Simulate(cfg *config.Config, path, event string) (Simulation, error) {
+	op, ok := simulatedOps[strings.ToLower(event)]
+	if !ok {
+		return Simulation{}, fmt.Errorf("invalid event %q (expected create, write, remove, rename or chmod)", event)
+	}
+	isDir := strings.HasSuffix(path, "/") || strings.HasSuffix(path, string(filepath.Separator))
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Simulation{}, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	w := &Watcher{cfg: cfg, log: logger.Discard(), ignore: ignore.New()}
+	if err := w.loadIgnoreRules(); err != nil {
+		return Simulation{}, err
+	}
+
+	sim := Simulation{Path: abs, WatchPath: -1}
+	var filter *pathFilter
+	for i, wp := range cfg.Watch {
+		if wp.Remote != "" {
+			continue
+		}
+		f, err := newPathFilter(i, wp)
+		if err != nil {
+			return Simulation{}, err
+		}
+		if f.contains(abs) && (filter == nil || len(f.root) > len(filter.root)) {
+			filter, sim.WatchPath = f, i
+		}
+	}
+	if filter == nil {
+		sim.Dropped = "not under any watch path"
+		return sim, nil
+	}
+	wp := cfg.Watch[sim.WatchPath]
+	sim.Strategy = cfg.DebounceStrategyFor(wp)
+
+	// Walk down to the change as the watcher does, loading ignore files
+	// and stopping at directories it skips
+	rel, err := filepath.Rel(filter.root, abs)
+	if err != nil {
+		return Simulation{}, err
+	}
+	dir := filter.root
+	if err := w.ignore.AddFile(filepath.Join(dir, ignore.FileName)); err != nil {
+		return Simulation{}, err
+	}
+	if parent := filepath.Dir(rel); parent != "." {
+		for _, name := range strings.Split(parent, string(filepath.Separator)) {
+			dir = filepath.Join(dir, name)
+			if reason := builtinIgnored(dir); reason != "" {
+				sim.Ignored = fmt.Sprintf("%s (%s)", reason, dir)
+				return sim, nil
+			}
+			if err := w.ignore.AddFile(filepath.Join(dir, ignore.FileName)); err != nil {
+				return Simulation{}, err
+			}
+		}
+	}
+
+	if reason := builtinIgnored(abs); reason != "" {
+		sim.Ignored = reason
+		return sim, nil
+	}
+	if ignored, rule := w.ignore.Explain(abs, isDir); ignored {
+		sim.Ignored = rule
+		return sim, nil
+	}
+
+	switch {
+	case !withinDepth(wp, filepath.ToSlash(rel)):
+		if wp.Recursive {
+			sim.Dropped = fmt.Sprintf("deeper than max_depth %d", wp.MaxDepth)
+		} else {
+			sim.Dropped = "in a subdirectory of a non-recursive watch path"
+		}
+	case !filter.accepts(op):
+		sim.Dropped = fmt.Sprintf("%s events don't trigger runs", strings.ToLower(event))
+	case isDir && filter.restricted():
+		sim.Dropped = "directories don't match include or extensions"
+	case !isDir && !filter.matches(abs):
+		sim.Dropped = "not matched by include or extensions"
+	}
+	return sim, nil
+}
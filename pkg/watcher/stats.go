@@ -0,0 +1,138 @@
+package watcher
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Stats counts what a watcher did with the changes reported to it, since it
+// was created
+type Stats struct {
+	// Received is the number of changes reported by the backends
+	Received int64
+	// Ignored were dropped by ignore rules or built-in ones
+	Ignored int64
+	// Filtered were dropped by their event type, include, extensions or
+	// max_file_size
+	Filtered int64
+	// Debounced were folded into an event delivered along with others, or
+	// suppressed by the leading strategy
+	Debounced int64
+	// Emitted is the number of events delivered
+	Emitted int64
+	// Overflowed is the number of events that found the event queue full
+	Overflowed int64
+	// Watched is the number of directories and files watched natively
+	Watched int
+}
+
+// Add returns the sum of two watchers' stats
+func (s Stats) Add(o Stats) Stats {
+	return Stats{
+		Received:   s.Received + o.Received,
+		Ignored:    s.Ignored + o.Ignored,
+		Filtered:   s.Filtered + o.Filtered,
+		Debounced:  s.Debounced + o.Debounced,
+		Emitted:    s.Emitted + o.Emitted,
+		Overflowed: s.Overflowed + o.Overflowed,
+		Watched:    s.Watched + o.Watched,
+	}
+}
+
+// counters are the running counts behind Stats, updated by the event loop
+// and debounce timers
+type counters struct {
+	received, ignored, filtered, debounced, emitted, overflowed atomic.Int64
+}
+
+// Stats returns what the watcher has done so far
+func (w *Watcher) Stats() Stats {
+	return Stats{
+		Received:   w.counts.received.Load(),
+		Ignored:    w.counts.ignored.Load(),
+		Filtered:   w.counts.filtered.Load(),
+		Debounced:  w.counts.debounced.Load(),
+		Emitted:    w.counts.emitted.Load(),
+		Overflowed: w.counts.overflowed.Load(),
+		Watched:    w.WatchCount(),
+	}
+}
+
+// RootStats counts the activity under one local watch path
+type RootStats struct {
+	// Path is the absolute watch path
+	Path string
+	// Watched is the number of directories watched natively under it, or
+	// 1 for a watched file. Files is the number of files they held when
+	// they were scanned, ignored ones included.
+	Watched int
+	Files   int
+	// Events is the number of changes reported under it. Ignored were
+	// dropped by ignore rules or built-in ones, counted by rule in
+	// IgnoredBy.
+	Events    int64
+	Ignored   int64
+	IgnoredBy map[string]int64
+}
+
+// rootCounters are the running counts behind RootStats, updated by the
+// event loop
+type rootCounters struct {
+	events, ignored atomic.Int64
+	mu              sync.Mutex
+	ignoredBy       map[string]int64
+}
+
+// ignore counts a change ignored by rule
+func (c *rootCounters) ignore(rule string) {
+	c.ignored.Add(1)
+	c.mu.Lock()
+	if c.ignoredBy == nil {
+		c.ignoredBy = make(map[string]int64)
+	}
+	c.ignoredBy[rule]++
+	c.mu.Unlock()
+}
+
+// RootStats returns the stats of each local watch path, in the order of the
+// config
+func (w *Watcher) RootStats() []RootStats {
+	stats := make([]RootStats, len(w.filters))
+	for i, f := range w.filters {
+		stats[i] = RootStats{
+			Path:    f.root,
+			Events:  f.counts.events.Load(),
+			Ignored: f.counts.ignored.Load(),
+		}
+		f.counts.mu.Lock()
+		if len(f.counts.ignoredBy) > 0 {
+			stats[i].IgnoredBy = make(map[string]int64, len(f.counts.ignoredBy))
+			for rule, n := range f.counts.ignoredBy {
+				stats[i].IgnoredBy[rule] = n
+			}
+		}
+		f.counts.mu.Unlock()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for path := range w.watched {
+		if i := w.rootOf(path); i >= 0 {
+			stats[i].Watched++
+			stats[i].Files += w.files[path]
+		}
+	}
+	return stats
+}
+
+// rootOf returns the index in w.filters of the most specific local watch
+// path containing an absolute path, -1 if none does
+func (w *Watcher) rootOf(path string) int {
+	best := -1
+	for i, f := range w.filters {
+		if f.contains(path) && (best < 0 || len(f.root) > len(w.filters[best].root)) {
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,1366 @@
+// Package watcher watches the paths of a config for changes and delivers
+// debounced batches of them as events on a channel
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gowatch/internal/ignore"
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/sync/errgroup"
+)
+
+type Watcher struct {
+	cfg       *config.Config
+	log       *logger.Logger
+	fsWatcher *fsnotify.Watcher
+	poller    *Poller
+	ignore    *ignore.Matcher
+	filters   []*pathFilter
+	remotes   []*remoteWatcher
+	debouncer *Debouncer
+	mu        sync.Mutex
+	watched   map[string]bool
+	// files holds the number of files covered by each native watch, as
+	// of when it was added
+	files map[string]int
+	// batches collects the changes of each debounce strategy until they
+	// are delivered
+	batches map[string]*batch
+	// missing holds watch roots that were removed, keyed by absolute path,
+	// until they reappear; recovered carries their re-creation events
+	missing   map[string]config.WatchPath
+	recovered chan fsnotify.Event
+	// remoteEvents carries the changes found by the remote watchers
+	remoteEvents chan remoteEvent
+	// watchmen and marks follow the watch paths of the watchman and
+	// fanotify backends and send their changes on backendEvents
+	watchmen      []*watchmanWatcher
+	marks         []*fanotifyWatcher
+	backendEvents chan fsnotify.Event
+	// fallback holds the backend used instead of the configured one by
+	// absolute watch path: poll for network shares, fsnotify for fanotify
+	// paths that couldn't be marked
+	fallback map[string]string
+	// lastEvent is when the latest native event was received; a rescan
+	// after an overflow looks for files modified since
+	lastEvent time.Time
+	// limitWarned is set once the watch limit has been reported
+	limitWarned bool
+	// counts feed Stats
+	counts counters
+	// cached holds the trees of the recursive watch paths to save in the
+	// watch cache; stale those watched from it, until verifyCache has
+	// checked them
+	cached map[string]*cachedRoot
+	stale  map[string]*cachedRoot
+
+	// sendMu guards output against sends from debounce timers racing with
+	// the channel being closed on shutdown
+	sendMu sync.Mutex
+	closed bool
+	// overflows counts the events that found the output queue full since
+	// it last had room, guarded by sendMu
+	overflows int
+}
+
+type Event struct {
+	Path      string
+	Op        string
+	Timestamp time.Time
+	// Files lists every path that changed within the debounce window, ordered
+	// by most recent change. Path and Op describe the last one.
+	Files []string
+	// Changed holds when each of Files last changed, in the same order
+	Changed []time.Time
+	// Ops lists every event type seen within the debounce window
+	Ops []string
+	// Tags are the tags of the watch paths the files changed under
+	Tags []string
+	// FileOps and FileTags hold the event types and tags of each of Files,
+	// in the same order
+	FileOps  [][]string
+	FileTags [][]string
+}
+
+// ChangedAt returns when the i-th of Files last changed, or Timestamp for
+// events that don't record it
+func (e Event) ChangedAt(i int) time.Time {
+	if i < len(e.Changed) {
+		return e.Changed[i]
+	}
+	return e.Timestamp
+}
+
+// OpsAt returns the event types of the i-th of Files, or Ops for events
+// that don't record them
+func (e Event) OpsAt(i int) []string {
+	if i < len(e.FileOps) {
+		return e.FileOps[i]
+	}
+	return e.Ops
+}
+
+// TagsAt returns the tags of the i-th of Files, or Tags for events that
+// don't record them
+func (e Event) TagsAt(i int) []string {
+	if i < len(e.FileTags) {
+		return e.FileTags[i]
+	}
+	return e.Tags
+}
+
+// rootCheckInterval is how often removed watch roots are checked for
+const rootCheckInterval = 500 * time.Millisecond
+
+// scanProgressInterval is how often the progress of adding a large tree is
+// reported
+const scanProgressInterval = 2 * time.Second
+
+// walkWorkers is how many directories walkDirs reads at once
+var walkWorkers = max(runtime.GOMAXPROCS(0), 4)
+
+// rescanSlack widens the window of a rescan after an overflow, to allow for
+// the resolution of file modification times
+const rescanSlack = 2 * time.Second
+
+// batch holds the changes collected for one debounce strategy. Changes to
+// paths that share a strategy are delivered together, so the strategy is
+// also the debouncer key.
+type batch struct {
+	events []Event
+	ops    []string
+	// count is the number of changes collected, including repeated ones
+	count int
+}
+
+// Options configures a Watcher
+type Options struct {
+	// Logger receives watch activity; nil discards it
+	Logger *logger.Logger
+}
+
+// New creates a watcher for the watch paths of cfg. Call Start to begin
+// receiving events and Close to release it.
+func New(cfg *config.Config, opts Options) (*Watcher, error) {
+	log := opts.Logger
+	if log == nil {
+		log = logger.Discard()
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fsnotify watcher: %w", err)
+	}
+
+	debouncer := NewDebouncer(cfg.GetDebounceDuration())
+
+	w := &Watcher{
+		cfg:           cfg,
+		log:           log,
+		fsWatcher:     fsw,
+		ignore:        ignore.New(),
+		debouncer:     debouncer,
+		watched:       make(map[string]bool),
+		files:         make(map[string]int),
+		batches:       make(map[string]*batch),
+		missing:       make(map[string]config.WatchPath),
+		recovered:     make(chan fsnotify.Event, 16),
+		remoteEvents:  make(chan remoteEvent, 100),
+		backendEvents: make(chan fsnotify.Event, 100),
+		fallback:      make(map[string]string),
+		cached:        make(map[string]*cachedRoot),
+		stale:         make(map[string]*cachedRoot),
+	}
+
+	if err := w.loadIgnoreRules(); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+
+	for i, wp := range cfg.Watch {
+		f, err := newPathFilter(i, wp)
+		if err != nil {
+			fsw.Close()
+			return nil, err
+		}
+		f.strategy = cfg.DebounceStrategyFor(wp)
+		f.maxSize = cfg.MaxFileSizeFor(wp)
+		f.bufferSize = cfg.BufferSizeFor(wp)
+		if wp.Remote != "" {
+			w.remotes = append(w.remotes, newRemoteWatcher(wp, f, cfg.GetPollInterval(), log))
+			continue
+		}
+		w.filters = append(w.filters, f)
+
+		// Network shares rarely deliver notifications; poll them unless a
+		// backend was chosen explicitly
+		if wp.Backend == "" && cfg.Backend == "" && isNetworkPath(f.root) {
+			w.fallback[f.root] = config.BackendPoll
+		}
+	}
+
+	needPoller := cfg.PollFallback
+	for _, wp := range cfg.Watch {
+		if w.backendFor(wp) == config.BackendPoll {
+			needPoller = true
+			break
+		}
+	}
+	if needPoller {
+		w.poller = NewPoller(cfg.GetPollInterval(), w.isIgnored)
+	}
+
+	return w, nil
+}
+
+// loadIgnoreRules registers the config ignore patterns and the project-level
+// .gowatchignore. Ignore files in subdirectories are picked up while walking.
+func (w *Watcher) loadIgnoreRules() error {
+	cwd, err := w.cfg.WorkDir()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	w.ignore.Add(cwd, "config", w.cfg.Ignore)
+
+	for i, wp := range w.cfg.Watch {
+		// Remote watchers apply their own ignore patterns
+		if wp.Remote != "" {
+			continue
+		}
+		absPath, err := filepath.Abs(wp.Path)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		w.ignore.Add(absPath, fmt.Sprintf("watch[%d]", i), wp.Ignore)
+	}
+
+	// Saved command output would otherwise trigger the next run
+	if w.cfg.OutputDir != "" {
+		absPath, err := filepath.Abs(w.cfg.OutputDir)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		w.ignore.Add(filepath.Dir(absPath), "output_dir", []string{"/" + filepath.Base(absPath) + "/"})
+	}
+	// Likewise the run history, recorded after every run
+	if path := w.cfg.HistoryFile(); path != "" {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		w.ignore.Add(filepath.Dir(absPath), "history", []string{"/" + filepath.Base(absPath), "/" + filepath.Base(absPath) + ".tmp"})
+	}
+	// And the status file, written as commands start and finish
+	if path := w.cfg.StatusPath(); path != "" {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		w.ignore.Add(filepath.Dir(absPath), "status_file", []string{"/" + filepath.Base(absPath), "/" + filepath.Base(absPath) + ".tmp"})
+	}
+	// And the JUnit reports, those of the other tasks included
+	if path := w.cfg.JUnitReport(); path != "" {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		ext := filepath.Ext(absPath)
+		name := strings.TrimSuffix(filepath.Base(absPath), ext)
+		if w.cfg.Task != "" {
+			name = strings.TrimSuffix(name, "-"+w.cfg.Task)
+		}
+		w.ignore.Add(filepath.Dir(absPath), "report", []string{"/" + name + "*" + ext, "/" + name + "*" + ext + ".tmp"})
+	}
+
+	if err := w.ignore.AddFile(filepath.Join(cwd, ignore.FileName)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (w *Watcher) Start(ctx context.Context) (<-chan Event, error) {
+	events := make(chan Event, w.cfg.GetQueueSize())
+	w.lastEvent = time.Now()
+
+	cachePath := w.cfg.WatchCacheFile()
+	var cache *watchCache
+	if cachePath != "" {
+		var err error
+		if cache, err = readWatchCache(cachePath); err != nil {
+			w.log.Warn("Ignoring the watch cache: %v", err)
+			cache = &watchCache{Roots: map[string]*cachedRoot{}}
+		}
+	}
+
+	// Add watch paths
+	for _, wp := range w.cfg.Watch {
+		if wp.Remote != "" {
+			continue
+		}
+		var err error
+		if cache != nil && w.cacheable(wp) {
+			err = w.addCached(wp, cache)
+		} else {
+			err = w.addPath(wp)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range w.remotes {
+		go r.run(ctx, w.remoteEvents)
+	}
+	for _, m := range w.watchmen {
+		go m.run(ctx, w.backendEvents)
+	}
+	for _, m := range w.marks {
+		go m.run(ctx, w.backendEvents)
+	}
+
+	// Start event processing
+	if w.poller != nil {
+		w.poller.Start(ctx)
+	}
+	go w.processEvents(ctx, events)
+	go w.recoverRoots(ctx)
+	if cache != nil {
+		go w.verifyCache(ctx, cachePath)
+	}
+
+	w.log.Watch("Started watching %d path(s)", len(w.cfg.Watch))
+	for _, rs := range w.RootStats() {
+		w.log.Debug("Watch path %s: %d watched, %d file(s)", rs.Path, rs.Watched, rs.Files)
+	}
+	return events, nil
+}
+
+func (w *Watcher) addPath(wp config.WatchPath) error {
+	absPath, err := filepath.Abs(wp.Path)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat path %s: %w", absPath, err)
+	}
+
+	if w.backendFor(wp) == config.BackendPoll {
+		if w.cfg.BackendFor(wp) != config.BackendPoll {
+			w.log.Info("%s is a network share; polling it (set backend: fsnotify to watch it natively)", absPath)
+		}
+		if info.IsDir() && wp.Recursive {
+			// Load nested ignore files up front; the poller only scans
+			if err := w.walkDirs(absPath, walkOptionsFor(wp), func(string) error { return nil }); err != nil {
+				return err
+			}
+		}
+		w.poller.Add(absPath, wp.DepthLimit())
+		w.log.Debug("Polling: %s (every %s)", absPath, w.cfg.GetPollInterval())
+		return nil
+	}
+
+	if w.cfg.BackendFor(wp) == config.BackendWatchman {
+		if !info.IsDir() {
+			return fmt.Errorf("backend watchman needs a directory: %s", absPath)
+		}
+		if wp.Recursive {
+			// Load nested ignore files up front, as for the poller
+			if err := w.walkDirs(absPath, walkOptionsFor(wp), func(string) error { return nil }); err != nil {
+				return err
+			}
+		}
+		w.watchmen = append(w.watchmen, newWatchmanWatcher(wp, absPath, w.log))
+		return nil
+	}
+
+	if w.backendFor(wp) == config.BackendFanotify {
+		// Files, and directories that can't be marked, are watched through
+		// fsnotify, also when they are re-added after being removed
+		if !info.IsDir() {
+			w.fallback[absPath] = config.BackendFSNotify
+		} else if m, err := newFanotifyWatcher(wp, absPath, w.log); err != nil {
+			w.log.Warn("fanotify unavailable for %s, using fsnotify: %v", absPath, err)
+			w.fallback[absPath] = config.BackendFSNotify
+		} else {
+			if wp.Recursive {
+				// Load nested ignore files up front, as for the poller
+				if err := w.walkDirs(absPath, walkOptionsFor(wp), func(string) error { return nil }); err != nil {
+					m.close()
+					return err
+				}
+			}
+			w.marks = append(w.marks, m)
+			w.log.Watch("Watching %s through a fanotify filesystem mark", absPath)
+			return nil
+		}
+	}
+
+	if info.IsDir() {
+		if wp.Recursive {
+			return w.addRecursive(absPath, walkOptionsFor(wp))
+		}
+		if err := w.addSingle(absPath); err != nil {
+			return err
+		}
+		if _, files, err := subdirs(absPath, walkOptions{}); err == nil {
+			w.cover(absPath, files)
+		}
+		return nil
+	}
+
+	if err := w.addSingle(absPath); err != nil {
+		return err
+	}
+	w.cover(absPath, 1)
+	return nil
+}
+
+// backendFor returns the backend watching a path: the configured one
+// unless it fell back to another
+func (w *Watcher) backendFor(wp config.WatchPath) string {
+	if wp.Remote == "" {
+		if absPath, err := filepath.Abs(wp.Path); err == nil {
+			if backend, ok := w.fallback[absPath]; ok {
+				return backend
+			}
+		}
+	}
+	return w.cfg.BackendFor(wp)
+}
+
+func (w *Watcher) addSingle(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.watched[path] {
+		return nil
+	}
+
+	var err error
+	if f := w.filterFor(path); f != nil && f.bufferSize > 0 {
+		err = w.fsWatcher.AddWith(path, fsnotify.WithBufferSize(f.bufferSize))
+	} else {
+		err = w.fsWatcher.Add(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+
+	w.watched[path] = true
+	w.log.Debug("Watching: %s", path)
+	return nil
+}
+
+// cover records the number of files covered by the watch of path, if it is
+// watched
+func (w *Watcher) cover(path string, files int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watched[path] {
+		w.files[path] = files
+	}
+}
+
+// addRecursive watches root and its subdirectories, reporting how many
+// have been added while a large tree is scanned
+func (w *Watcher) addRecursive(root string, opts walkOptions) error {
+	var added atomic.Int64
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(scanProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				w.log.Watch("Scanning %s: %d directories added", root, added.Load())
+			}
+		}
+	}()
+
+	err := w.walkDirs(root, opts, func(dir string) error {
+		if err := w.addSingle(dir); err != nil {
+			return err
+		}
+		added.Add(1)
+		return nil
+	})
+	close(done)
+	if elapsed := time.Since(start); err == nil && elapsed >= scanProgressInterval {
+		w.log.Watch("Scanned %s: %d directories added in %s", root, added.Load(), elapsed.Round(time.Millisecond))
+	}
+	if err != nil && isWatchLimit(err) {
+		return w.handleWatchLimit(root, opts)
+	}
+	return err
+}
+
+// handleWatchLimit is called when the OS ran out of watches while adding
+// root. The directories left unwatched are polled if poll_fallback is set;
+// otherwise a WatchLimitError tells how many watches were needed.
+func (w *Watcher) handleWatchLimit(root string, opts walkOptions) error {
+	var remaining []string
+	w.walkDirs(root, opts, func(dir string) error {
+		w.mu.Lock()
+		if !w.watched[dir] {
+			remaining = append(remaining, dir)
+		}
+		w.mu.Unlock()
+		return nil
+	})
+
+	w.mu.Lock()
+	limitErr := &WatchLimitError{Needed: len(w.watched) + len(remaining)}
+	warned := w.limitWarned
+	w.limitWarned = true
+	w.mu.Unlock()
+	limitErr.Limit, _ = WatchLimit()
+
+	if !w.cfg.PollFallback {
+		return limitErr
+	}
+
+	if !warned {
+		w.log.Warn("%v", limitErr)
+	}
+	w.log.Warn("Polling %d directories under %s that could not be watched", len(remaining), root)
+	for _, dir := range remaining {
+		w.poller.Add(dir, 0)
+	}
+	return nil
+}
+
+// WatchCount returns how many directories and files are currently watched
+// natively
+func (w *Watcher) WatchCount() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.watched)
+}
+
+// CountWatches returns how many native watches the fsnotify watch paths
+// need: one per directory, or per file for file paths
+func (w *Watcher) CountWatches() (int, error) {
+	var count atomic.Int64
+	for _, wp := range w.cfg.Watch {
+		if w.backendFor(wp) != config.BackendFSNotify {
+			continue
+		}
+		absPath, err := filepath.Abs(wp.Path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		info, err := os.Stat(absPath)
+		if err != nil {
+			return 0, fmt.Errorf("failed to stat path %s: %w", absPath, err)
+		}
+		if !info.IsDir() || !wp.Recursive {
+			count.Add(1)
+			continue
+		}
+		err = w.walkDirs(absPath, walkOptionsFor(wp), func(string) error {
+			count.Add(1)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int(count.Load()), nil
+}
+
+// unwatch forgets the watches of a removed or renamed path and everything
+// below it, so they are re-established if it is created again. A watch root
+// is checked for periodically until it reappears.
+func (w *Watcher) unwatch(path string) {
+	path = filepath.Clean(path)
+	prefix := path + string(filepath.Separator)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for p := range w.watched {
+		if p == path || strings.HasPrefix(p, prefix) {
+			// The backend usually dropped the watch already
+			w.fsWatcher.Remove(p)
+			delete(w.watched, p)
+			delete(w.files, p)
+		}
+	}
+
+	for _, wp := range w.cfg.Watch {
+		if w.backendFor(wp) != config.BackendFSNotify {
+			continue
+		}
+		if abs, err := filepath.Abs(wp.Path); err == nil && abs == path {
+			if _, ok := w.missing[abs]; !ok {
+				w.missing[abs] = wp
+				w.log.Warn("Watched path removed: %s (waiting for it to reappear)", abs)
+			}
+		}
+	}
+}
+
+// recoverRoots re-adds removed watch roots once they exist again and reports
+// them as created
+func (w *Watcher) recoverRoots(ctx context.Context) {
+	ticker := time.NewTicker(rootCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		w.mu.Lock()
+		var found []string
+		for abs := range w.missing {
+			if _, err := os.Stat(abs); err == nil {
+				found = append(found, abs)
+			}
+		}
+		w.mu.Unlock()
+
+		for _, abs := range found {
+			w.mu.Lock()
+			wp := w.missing[abs]
+			delete(w.missing, abs)
+			w.mu.Unlock()
+
+			if err := w.addPath(wp); err != nil {
+				// Gone again, or not ready yet; retry on the next tick
+				w.mu.Lock()
+				w.missing[abs] = wp
+				w.mu.Unlock()
+				continue
+			}
+			w.log.Watch("Watching again: %s", abs)
+
+			select {
+			case w.recovered <- fsnotify.Event{Name: abs, Op: fsnotify.Create}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// walkOptions limits how far walkDirs descends
+type walkOptions struct {
+	// maxDepth is how many levels of subdirectories are walked, all of them
+	// if negative
+	maxDepth int
+	// followSymlinks descends into symlinked directories
+	followSymlinks bool
+	// tree, if set, records the directories walked and lists those that
+	// are unchanged since its previous walk
+	tree *dirTree
+}
+
+func walkOptionsFor(wp config.WatchPath) walkOptions {
+	return walkOptions{maxDepth: wp.DepthLimit(), followSymlinks: wp.FollowSymlinks}
+}
+
+// walkDirs calls fn for root and every directory below it that isn't
+// ignored, loading each directory's .gowatchignore before descending into
+// it. Up to walkWorkers directories are read at once, so fn may be called
+// concurrently; the walk stops at the first error. Symlinked directories
+// are walked under their link path when following symlinks; directories
+// already visited through another path are skipped so that link cycles
+// terminate.
+func (w *Watcher) walkDirs(root string, opts walkOptions, fn func(dir string) error) error {
+	g, ctx := errgroup.WithContext(context.Background())
+	g.SetLimit(walkWorkers)
+
+	var mu sync.Mutex
+	visited := make(map[string]bool)
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		if ctx.Err() != nil {
+			// Another directory failed
+			return nil
+		}
+		if opts.followSymlinks {
+			real, err := filepath.EvalSymlinks(dir)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			seen := visited[real]
+			visited[real] = true
+			mu.Unlock()
+			if seen {
+				w.log.Debug("Skipping symlink cycle: %s -> %s", dir, real)
+				return nil
+			}
+		}
+
+		if err := w.ignore.AddFile(filepath.Join(dir, ignore.FileName)); err != nil {
+			w.log.Warn("%v", err)
+		}
+
+		if err := fn(dir); err != nil {
+			return err
+		}
+
+		names, files, err := subdirs(dir, opts)
+		if err != nil {
+			return err
+		}
+		w.cover(dir, files)
+
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			if tooDeep(root, path, opts.maxDepth) {
+				continue
+			}
+
+			// Check ignore patterns
+			if w.isIgnored(path, true) {
+				w.log.Debug("Ignoring: %s", path)
+				continue
+			}
+
+			// Hand the directory to an idle worker, or walk it here when
+			// all of them are busy
+			if g.TryGo(func() error { return walk(path) }) {
+				continue
+			}
+			if err := walk(path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	g.Go(func() error { return walk(root) })
+	return g.Wait()
+}
+
+// subdirs returns the names of the subdirectories of dir, including
+// symlinked ones when following symlinks, and the number of other entries
+func subdirs(dir string, opts walkOptions) ([]string, int, error) {
+	if opts.tree != nil {
+		return opts.tree.subdirs(dir, opts)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	var names []string
+	files := 0
+	for _, entry := range entries {
+		if isSubdir(dir, entry, opts) {
+			names = append(names, entry.Name())
+		} else if !entry.IsDir() {
+			files++
+		}
+	}
+	return names, files, nil
+}
+
+// isSubdir reports whether an entry of dir is a directory to walk
+func isSubdir(dir string, entry os.DirEntry, opts walkOptions) bool {
+	if opts.followSymlinks && entry.Type()&os.ModeSymlink != 0 {
+		info, err := os.Stat(filepath.Join(dir, entry.Name()))
+		return err == nil && info.IsDir()
+	}
+	return entry.IsDir()
+}
+
+// isSymlink reports whether path itself is a symbolic link
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// depth returns how many directory levels path is below root
+func depth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// tooDeep reports whether dir is more than maxDepth levels below root; a
+// negative maxDepth never is
+func tooDeep(root, dir string, maxDepth int) bool {
+	return maxDepth >= 0 && depth(root, dir) > maxDepth
+}
+
+// shouldIgnore reports whether an event path is ignored, checking the
+// filesystem to see whether it is a directory
+func (w *Watcher) shouldIgnore(path string) bool {
+	info, err := os.Stat(path)
+	return w.isIgnored(path, err == nil && info.IsDir())
+}
+
+// ignoreRule returns what ignores an event path, checking the filesystem to
+// see whether it is a directory: a built-in reason or a rule and where it
+// came from. It returns "" if the path isn't ignored.
+func (w *Watcher) ignoreRule(path string) string {
+	if reason := builtinIgnored(path); reason != "" {
+		return reason
+	}
+	info, err := os.Stat(path)
+	if ignored, rule := w.ignore.Explain(path, err == nil && info.IsDir()); ignored {
+		return rule
+	}
+	return ""
+}
+
+func (w *Watcher) isIgnored(path string, isDir bool) bool {
+	if builtinIgnored(path) != "" {
+		return true
+	}
+
+	// Config patterns and .gowatchignore files
+	return w.ignore.Match(path, isDir)
+}
+
+// builtinIgnored returns why a path is ignored whatever the config says, or
+// "" if it isn't
+func builtinIgnored(path string) string {
+	base := filepath.Base(path)
+
+	// Common ignore patterns
+	if strings.HasPrefix(base, ".") && base != "." {
+		return "hidden path"
+	}
+
+	// Windows-specific ignores
+	if runtime.GOOS == "windows" {
+		// Ignore Windows temp files
+		if strings.HasPrefix(base, "~$") {
+			return "Windows temporary file"
+		}
+		// Ignore Windows shortcuts
+		if strings.HasSuffix(base, ".lnk") {
+			return "Windows shortcut"
+		}
+		// Ignore system folders
+		systemFolders := []string{
+			"$RECYCLE.BIN",
+			"System Volume Information",
+			"$Recycle.Bin",
+		}
+		for _, folder := range systemFolders {
+			if strings.Contains(path, folder) {
+				return "Windows system folder"
+			}
+		}
+	}
+
+	return ""
+}
+
+func (w *Watcher) processEvents(ctx context.Context, output chan Event) {
+	defer func() {
+		w.sendMu.Lock()
+		w.closed = true
+		close(output)
+		w.sendMu.Unlock()
+	}()
+
+	// A nil channel blocks forever, so the poller cases are inert unless a
+	// watch path uses the polling backend
+	var pollEvents <-chan fsnotify.Event
+	var pollErrors <-chan error
+	if w.poller != nil {
+		pollEvents = w.poller.Events
+		pollErrors = w.poller.Errors
+	}
+
+	for {
+		var event fsnotify.Event
+
+		select {
+		case <-ctx.Done():
+			w.log.Watch("Stopping watcher")
+			w.fsWatcher.Close()
+			return
+
+		case ev, ok := <-w.fsWatcher.Events:
+			if !ok {
+				w.log.Debug("Event channel closed")
+				return
+			}
+			w.lastEvent = time.Now()
+			event = ev
+
+		case ev := <-pollEvents:
+			event = ev
+
+		case ev := <-w.backendEvents:
+			event = ev
+
+		case ev := <-w.recovered:
+			event = ev
+
+		case re := <-w.remoteEvents:
+			w.counts.received.Add(1)
+			w.log.Debug("Remote event: %s %s", re.event.Op, re.event.Name)
+			w.enqueue(ctx, output, re.event, re.strategy)
+			continue
+
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				w.log.Debug("Error channel closed")
+				return
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				w.rescan(ctx, output)
+				continue
+			}
+			w.log.Error("Watcher error: %v", err)
+			continue
+
+		case err := <-pollErrors:
+			w.log.Error("Poller error: %v", err)
+			continue
+		}
+
+		w.handleEvent(ctx, event, output)
+	}
+}
+
+// rescan recovers from an overflow of the native event queue, after which
+// changes were lost: watches missing for new directories are added and files
+// modified since the last event received are reported as written. Removed
+// files can't be found this way.
+func (w *Watcher) rescan(ctx context.Context, output chan Event) {
+	w.log.Warn("Too many changes at once, events were lost; rescanning the watch paths")
+	since := w.lastEvent.Add(-rescanSlack)
+
+	found := 0
+	report := func(path string, info os.FileInfo) {
+		if info.Mode().IsRegular() && info.ModTime().After(since) && !w.shouldIgnore(path) {
+			found++
+			w.handleEvent(ctx, fsnotify.Event{Name: path, Op: fsnotify.Write}, output)
+		}
+	}
+	for _, wp := range w.cfg.Watch {
+		if wp.Remote != "" || w.backendFor(wp) != config.BackendFSNotify {
+			continue
+		}
+		absPath, err := filepath.Abs(wp.Path)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(absPath)
+		if err != nil {
+			// Removed roots are recovered once they reappear
+			continue
+		}
+		if !info.IsDir() {
+			report(absPath, info)
+			continue
+		}
+
+		opts := walkOptionsFor(wp)
+		if err := w.addRecursive(absPath, opts); err != nil {
+			w.log.Error("Failed to watch %s again: %v", absPath, err)
+		}
+		// Directories are walked in parallel; the files found are
+		// reported from here
+		var mu sync.Mutex
+		var files []string
+		var infos []os.FileInfo
+		w.walkDirs(absPath, opts, func(dir string) error {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				return nil
+			}
+			for _, entry := range entries {
+				if entry.IsDir() {
+					continue
+				}
+				if info, err := entry.Info(); err == nil {
+					mu.Lock()
+					files = append(files, filepath.Join(dir, entry.Name()))
+					infos = append(infos, info)
+					mu.Unlock()
+				}
+			}
+			return nil
+		})
+		for i, path := range files {
+			report(path, infos[i])
+		}
+	}
+	w.log.Info("Rescan found %d recently modified file(s)", found)
+}
+
+// handleEvent filters a raw backend event, keeps recursive watches up to date
+// and forwards the event through the debouncer
+func (w *Watcher) handleEvent(ctx context.Context, event fsnotify.Event, output chan Event) {
+	w.counts.received.Add(1)
+
+	// Edits to an ignore file take effect immediately
+	if filepath.Base(event.Name) == ignore.FileName {
+		if err := w.ignore.AddFile(event.Name); err != nil {
+			w.log.Error("Failed to reload ignore rules: %v", err)
+		} else {
+			w.log.Watch("Reloaded ignore rules: %s", event.Name)
+		}
+		return
+	}
+
+	// Removed or renamed directories lose their watches
+	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		w.unwatch(event.Name)
+	}
+
+	// Filter out ignored paths
+	root := w.filterFor(event.Name)
+	if root != nil {
+		root.counts.events.Add(1)
+	}
+	if rule := w.ignoreRule(event.Name); rule != "" {
+		w.counts.ignored.Add(1)
+		if root != nil {
+			root.counts.ignore(rule)
+		}
+		w.log.Debug("Ignored: %s (%s)", event.Name, rule)
+		return
+	}
+
+	w.log.Debug("Raw event: %s %s", event.Op, event.Name)
+
+	// Handle directory creation (add to watch list)
+	if event.Op&fsnotify.Create == fsnotify.Create {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			for _, wp := range w.cfg.Watch {
+				absWatchPath, _ := filepath.Abs(wp.Path)
+				absEventPath, _ := filepath.Abs(event.Name)
+
+				// Normalize paths for comparison (important on Windows)
+				absWatchPath = filepath.Clean(absWatchPath)
+				absEventPath = filepath.Clean(absEventPath)
+
+				// Walk the new directory: subdirectories may have been
+				// created before its watch was in place (mkdir -p)
+				if wp.Recursive && w.backendFor(wp) == config.BackendFSNotify &&
+					strings.HasPrefix(absEventPath, absWatchPath) {
+					opts := walkOptionsFor(wp)
+					if opts.maxDepth >= 0 {
+						opts.maxDepth -= depth(absWatchPath, absEventPath)
+						if opts.maxDepth < 0 {
+							continue
+						}
+					}
+					if !opts.followSymlinks && isSymlink(event.Name) {
+						continue
+					}
+					if err := w.addRecursive(event.Name, opts); err != nil {
+						w.log.Error("Failed to watch new directory: %v", err)
+					} else {
+						w.log.Debug("Added watch for new directory: %s", event.Name)
+					}
+				}
+			}
+		}
+	}
+
+	// Drop event types the watch path doesn't want (CHMOD by default)
+	if !w.acceptsOp(event.Name, event.Op) {
+		w.counts.filtered.Add(1)
+		w.log.Debug("Skipping %s event: %s", event.Op, event.Name)
+		return
+	}
+
+	// Drop files outside the include patterns/extensions before debouncing
+	info, err := os.Stat(event.Name)
+	if !w.isIncluded(event.Name, err == nil && info.IsDir()) {
+		w.counts.filtered.Add(1)
+		w.log.Debug("Not included: %s", event.Name)
+		return
+	}
+
+	// Huge files such as datasets or video assets don't trigger runs
+	if err == nil && !info.IsDir() && w.isTooLarge(event.Name, info.Size()) {
+		w.counts.filtered.Add(1)
+		w.log.Debug("Too large (%d bytes): %s", info.Size(), event.Name)
+		return
+	}
+
+	w.enqueue(ctx, output, event, w.strategyFor(event.Name))
+}
+
+// enqueue adds an event to the batch of its debounce strategy and arms the
+// debouncer accordingly
+func (w *Watcher) enqueue(ctx context.Context, output chan Event, event fsnotify.Event, strategy string) {
+	if strategy == config.DebounceLeading && !w.debouncer.Leading(strategy) {
+		w.counts.debounced.Add(1)
+		w.log.Debug("Suppressed until changes stop: %s %s", event.Op, event.Name)
+		return
+	}
+
+	// Collect the event into the pending batch of its strategy
+	w.mu.Lock()
+	b := w.batches[strategy]
+	if b == nil {
+		b = &batch{}
+		w.batches[strategy] = b
+	}
+	b.events = appendBatch(b.events, Event{Path: event.Name, Op: event.Op.String(), Timestamp: time.Now(), Ops: opNames(event.Op)})
+	b.count++
+	for _, name := range opNames(event.Op) {
+		if !slices.Contains(b.ops, name) {
+			b.ops = append(b.ops, name)
+		}
+	}
+	w.mu.Unlock()
+
+	flush := func() {
+		w.flushBatch(ctx, output, strategy)
+	}
+	switch strategy {
+	case config.DebounceLeading:
+		go flush()
+	case config.DebounceThrottle:
+		w.debouncer.Throttle(strategy, flush)
+	default:
+		w.debouncer.Add(strategy, flush)
+	}
+}
+
+// strategyFor returns the debounce strategy of the watch path containing path
+func (w *Watcher) strategyFor(path string) string {
+	if f := w.filterFor(path); f != nil {
+		return f.strategy
+	}
+	return w.cfg.DebounceStrategyFor(config.WatchPath{})
+}
+
+// appendBatch records a change, replacing the earlier entry for the same path
+// so each file appears once, and moving it to the end as the latest change.
+// The entry keeps the event types and tags of the changes it replaces.
+func appendBatch(batch []Event, ev Event) []Event {
+	for i, existing := range batch {
+		if existing.Path == ev.Path {
+			ev.Ops = mergeTags(slices.Clone(existing.Ops), ev.Ops)
+			ev.Tags = mergeTags(slices.Clone(existing.Tags), ev.Tags)
+			batch = append(batch[:i], batch[i+1:]...)
+			break
+		}
+	}
+	return append(batch, ev)
+}
+
+// mergeTags adds the tags, or event types, missing from tags
+func mergeTags(tags, add []string) []string {
+	for _, tag := range add {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// flushBatch emits all changes collected for a strategy as one event
+func (w *Watcher) flushBatch(ctx context.Context, output chan Event, strategy string) {
+	w.mu.Lock()
+	var batch []Event
+	var ops []string
+	count := 0
+	if b := w.batches[strategy]; b != nil {
+		batch, ops, count = b.events, b.ops, b.count
+		delete(w.batches, strategy)
+	}
+	w.mu.Unlock()
+
+	// Files that grew past max_file_size after their first event, such as a
+	// large file still being copied, are dropped
+	collected := len(batch)
+	batch = slices.DeleteFunc(batch, func(e Event) bool {
+		return w.exceedsMaxSize(e.Path)
+	})
+	w.counts.filtered.Add(int64(collected - len(batch)))
+	if len(batch) == 0 {
+		return
+	}
+
+	files := make([]string, len(batch))
+	changed := make([]time.Time, len(batch))
+	fileOps := make([][]string, len(batch))
+	fileTags := make([][]string, len(batch))
+	var tags []string
+	for i, e := range batch {
+		files[i], changed[i], fileOps[i] = e.Path, e.Timestamp, e.Ops
+		if f := w.filterFor(e.Path); f != nil {
+			fileTags[i] = f.tags
+			tags = mergeTags(tags, f.tags)
+		}
+	}
+
+	last := batch[len(batch)-1]
+	ev := Event{
+		Path:      last.Path,
+		Op:        last.Op,
+		Timestamp: time.Now(),
+		Files:     files,
+		Changed:   changed,
+		Ops:       ops,
+		Tags:      tags,
+		FileOps:   fileOps,
+		FileTags:  fileTags,
+	}
+
+	w.sendMu.Lock()
+	defer w.sendMu.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	if !w.send(ctx, output, ev) {
+		return
+	}
+	w.counts.emitted.Add(1)
+	// The changes of a batch beyond the one delivered
+	w.counts.debounced.Add(int64(count - (collected - len(batch)) - 1))
+	if len(files) > 1 {
+		w.log.Watch("%s → %s (+%d more)", ev.Op, ev.Path, len(files)-1)
+	} else {
+		w.log.Watch("%s → %s", ev.Op, ev.Path)
+	}
+}
+
+// Close stops watching and releases the underlying OS resources. Pending
+// debounced events are dropped.
+func (w *Watcher) Close() error {
+	w.debouncer.Close()
+	for _, m := range w.marks {
+		m.close()
+	}
+	return w.fsWatcher.Close()
+}
+
+// Debouncer prevents rapid-fire events. Add debounces on the trailing edge,
+// Leading on the leading edge and Throttle limits calls to one per delay;
+// a key should only be used with one of them.
+type Debouncer struct {
+	delay   time.Duration
+	mu      sync.Mutex
+	timers  map[string]*time.Timer
+	pending map[string]func()
+	// last holds the time of the latest Leading call per key
+	last map[string]time.Time
+}
+
+func NewDebouncer(delay time.Duration) *Debouncer {
+	return &Debouncer{
+		delay:   delay,
+		timers:  make(map[string]*time.Timer),
+		pending: make(map[string]func()),
+		last:    make(map[string]time.Time),
+	}
+}
+
+func (d *Debouncer) Add(key string, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Cancel existing timer for this key
+	if timer, exists := d.timers[key]; exists {
+		timer.Stop()
+	}
+
+	// Store the function
+	d.pending[key] = fn
+
+	// Create new timer
+	d.timers[key] = time.AfterFunc(d.delay, func() {
+		d.mu.Lock()
+		fn := d.pending[key]
+		delete(d.pending, key)
+		delete(d.timers, key)
+		d.mu.Unlock()
+
+		if fn != nil {
+			fn()
+		}
+	})
+}
+
+// Leading reports whether a call for key should run now: the first call
+// does, and every call until none has been made for the delay doesn't
+func (d *Debouncer) Leading(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	last, seen := d.last[key]
+	d.last[key] = now
+	return !seen || now.Sub(last) >= d.delay
+}
+
+// Throttle runs fn right away unless a call for key ran within the delay.
+// Otherwise the latest fn is kept and runs when the delay has passed, which
+// starts a new delay.
+func (d *Debouncer) Throttle(key string, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, waiting := d.timers[key]; waiting {
+		d.pending[key] = fn
+		return
+	}
+	d.throttled(key)
+	go fn()
+}
+
+// throttled starts the delay for key; d.mu must be held
+func (d *Debouncer) throttled(key string) {
+	d.timers[key] = time.AfterFunc(d.delay, func() {
+		d.mu.Lock()
+		fn, ok := d.pending[key]
+		delete(d.pending, key)
+		delete(d.timers, key)
+		if ok {
+			d.throttled(key)
+		}
+		d.mu.Unlock()
+
+		if fn != nil {
+			fn()
+		}
+	})
+}
+
+// Close cancels every pending call
+func (d *Debouncer) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, timer := range d.timers {
+		timer.Stop()
+		delete(d.timers, key)
+		delete(d.pending, key)
+	}
+	clear(d.last)
+}
@@ -0,0 +1,1615 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"gowatch/internal/ignore"
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestDebouncer(t *testing.T) {
+	d := NewDebouncer(100 * time.Millisecond)
+
+	called := make(chan string, 10)
+
+	// Add multiple events for the same key rapidly
+	for i := 0; i < 5; i++ {
+		d.Add("test-key", func() {
+			called <- "executed"
+		})
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Wait for debounce period
+	time.Sleep(150 * time.Millisecond)
+
+	// Should only be called once
+	select {
+	case <-called:
+		// Expected
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("debounced function was not called")
+	}
+
+	// Should not be called again
+	select {
+	case <-called:
+		t.Fatal("debounced function was called multiple times")
+	case <-time.After(100 * time.Millisecond):
+		// Expected
+	}
+}
+
+func TestDebouncer_MultipleKeys(t *testing.T) {
+	d := NewDebouncer(50 * time.Millisecond)
+
+	called := make(chan string, 10)
+
+	// Add events for different keys
+	d.Add("key1", func() {
+		called <- "key1"
+	})
+
+	d.Add("key2", func() {
+		called <- "key2"
+	})
+
+	// Wait for debounce period
+	time.Sleep(100 * time.Millisecond)
+
+	// Both should be called
+	results := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case key := <-called:
+			results[key] = true
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("timeout waiting for debounced functions")
+		}
+	}
+
+	if !results["key1"] || !results["key2"] {
+		t.Errorf("not all keys were called: %v", results)
+	}
+}
+
+func TestDebouncer_Leading(t *testing.T) {
+	d := NewDebouncer(100 * time.Millisecond)
+
+	// Only the first of a burst of calls runs
+	var ran []bool
+	for i := 0; i < 4; i++ {
+		ran = append(ran, d.Leading("key"))
+		time.Sleep(30 * time.Millisecond)
+	}
+	if !slices.Equal(ran, []bool{true, false, false, false}) {
+		t.Errorf("expected only the first call to run, got %v", ran)
+	}
+
+	// Once calls stop for the delay the next one runs again
+	time.Sleep(120 * time.Millisecond)
+	if !d.Leading("key") {
+		t.Error("expected the first call after a quiet delay to run")
+	}
+	if !d.Leading("other") {
+		t.Error("expected keys to be independent")
+	}
+}
+
+func TestDebouncer_Throttle(t *testing.T) {
+	d := NewDebouncer(100 * time.Millisecond)
+	defer d.Close()
+
+	called := make(chan int, 10)
+	for i := 1; i <= 8; i++ {
+		d.Throttle("key", func() {
+			called <- i
+		})
+		time.Sleep(30 * time.Millisecond)
+	}
+
+	// The first call runs right away, then the latest call once per delay
+	// while calls keep coming, and the last one after they stopped
+	var got []int
+	for done := false; !done; {
+		select {
+		case i := <-called:
+			got = append(got, i)
+		case <-time.After(250 * time.Millisecond):
+			done = true
+		}
+	}
+	if len(got) < 3 || len(got) > 5 || got[0] != 1 || got[len(got)-1] != 8 {
+		t.Errorf("expected calls 1, about one per delay and 8, got %v", got)
+	}
+}
+
+func TestWatcher_ShouldIgnore(t *testing.T) {
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{
+				Path: "/tmp",
+				Ignore: []string{
+					"*.tmp",
+					"vendor/**",
+					".git/**",
+				},
+			},
+		},
+		Debounce:   "100ms",
+		OutputDir:  "/tmp/gowatch-output",
+		History:    "/tmp/runs.jsonl",
+		StatusFile: "/tmp/status.json",
+		Report:     "junit:/tmp/reports/gowatch.xml",
+	}
+
+	log := logger.New(logger.LevelInfo, false)
+	w, err := New(cfg, Options{Logger: log})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	tests := []struct {
+		path   string
+		ignore bool
+	}{
+		{"/tmp/test.go", false},
+		{"/tmp/gowatch-output/2024-05-01T14-03-07.512_run1/go.log", true},
+		{"/tmp/gowatch-output.go", false},
+		{"/tmp/runs.jsonl", true},
+		{"/tmp/runs.jsonl.tmp", true},
+		{"/tmp/status.json", true},
+		{"/tmp/status.json.tmp", true},
+		{"/tmp/reports/gowatch.xml", true},
+		{"/tmp/reports/gowatch-test.xml.tmp", true},
+		{"/tmp/reports/other.xml", false},
+		{"/tmp/test.tmp", true},
+		{"/tmp/.hidden", true},
+		{"/tmp/vendor/pkg", true},
+		{"/tmp/.git/config", true},
+		{"/tmp/normal/file.go", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result := w.shouldIgnore(tt.path)
+			if result != tt.ignore {
+				t.Errorf("shouldIgnore(%s) = %v, want %v", tt.path, result, tt.ignore)
+			}
+		})
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "src"), 0755)
+	os.WriteFile(filepath.Join(root, "src", ignore.FileName), []byte("gen/\n"), 0644)
+	docs := filepath.Join(root, "docs")
+
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{Path: root, Recursive: true, MaxDepth: 3, Ignore: []string{"vendor/**"}},
+			{Path: docs, Extensions: []string{"md"}, DebounceStrategy: config.DebounceLeading},
+		},
+		Debounce: "100ms",
+	}
+
+	tests := []struct {
+		path      string
+		event     string
+		watchPath int
+		ignored   string
+		dropped   string
+	}{
+		{"src/main.go", "write", 0, "", ""},
+		{"vendor/x.go", "write", 0, "watch[0]: vendor/**", ""},
+		{"src/gen/a.go", "create", 0, filepath.Join(root, "src", ignore.FileName) + ": gen/", ""},
+		{"src/.cache/z", "write", 0, "hidden path (" + filepath.Join(root, "src", ".cache") + ")", ""},
+		{"src/main.go", "chmod", 0, "", "chmod events don't trigger runs"},
+		{"a/b/c/d/e.go", "write", 0, "", "deeper than max_depth 3"},
+		{"docs/guide.md", "write", 1, "", ""},
+		{"docs/x.txt", "write", 1, "", "not matched by include or extensions"},
+		{"docs/sub/y.md", "write", 1, "", "in a subdirectory of a non-recursive watch path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.event, func(t *testing.T) {
+			sim, err := Simulate(cfg, filepath.Join(root, tt.path), tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sim.WatchPath != tt.watchPath || sim.Ignored != tt.ignored || sim.Dropped != tt.dropped {
+				t.Errorf("Simulate() = %+v, want watch path %d, ignored %q, dropped %q", sim, tt.watchPath, tt.ignored, tt.dropped)
+			}
+			if sim.Triggers() != (tt.ignored == "" && tt.dropped == "") {
+				t.Errorf("Triggers() = %v", sim.Triggers())
+			}
+		})
+	}
+
+	// Relative paths are reported as the watcher would, absolute
+	t.Chdir(root)
+	if sim, _ := Simulate(cfg, filepath.Join("src", "main.go"), "write"); sim.Path != filepath.Join(root, "src", "main.go") {
+		t.Errorf("Path = %q, want %q", sim.Path, filepath.Join(root, "src", "main.go"))
+	}
+	if sim, _ := Simulate(cfg, filepath.Join(root, "docs", "guide.md"), "write"); sim.Strategy != config.DebounceLeading {
+		t.Errorf("Strategy = %q, want %q", sim.Strategy, config.DebounceLeading)
+	}
+	if sim, _ := Simulate(cfg, filepath.Dir(root), "write"); sim.Dropped != "not under any watch path" {
+		t.Errorf("Simulate() outside the watch paths = %+v", sim)
+	}
+	if _, err := Simulate(cfg, filepath.Join(root, "main.go"), "touch"); err == nil {
+		t.Error("Simulate() accepted an unknown event type")
+	}
+}
+
+func TestWatcher_Integration(t *testing.T) {
+	// Create temporary directory
+	tmpDir, err := os.MkdirTemp("", "gowatch-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{
+				Path:      tmpDir,
+				Recursive: true,
+			},
+		},
+		Debounce:       "50ms",
+		MaxConcurrency: 1,
+	}
+
+	log := logger.New(logger.LevelInfo, false)
+	w, err := New(cfg, Options{Logger: log})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+
+	// Give watcher time to initialize
+	time.Sleep(100 * time.Millisecond)
+
+	// Create a test file
+	testFile := filepath.Join(tmpDir, "test.txt")
+	if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	// Wait for event
+	select {
+	case event := <-events:
+		if event.Path != testFile {
+			t.Errorf("expected event for %s, got %s", testFile, event.Path)
+		}
+		if event.Op == "" {
+			t.Error("expected non-empty operation")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for file event")
+	}
+}
+
+func TestWatcher_RecursiveWatch(t *testing.T) {
+	// Create temporary directory structure
+	tmpDir, err := os.MkdirTemp("", "gowatch-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	subDir := filepath.Join(tmpDir, "subdir")
+	if err := os.Mkdir(subDir, 0755); err != nil {
+		t.Fatalf("failed to create subdir: %v", err)
+	}
+
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{
+				Path:      tmpDir,
+				Recursive: true,
+			},
+		},
+		Debounce:       "50ms",
+		MaxConcurrency: 1,
+	}
+
+	log := logger.New(logger.LevelInfo, false)
+	w, err := New(cfg, Options{Logger: log})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+
+	// Give watcher time to initialize
+	time.Sleep(100 * time.Millisecond)
+
+	// Create a test file in subdirectory
+	testFile := filepath.Join(subDir, "test.txt")
+	if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	// Wait for event
+	select {
+	case event := <-events:
+		if event.Path != testFile {
+			t.Errorf("expected event for %s, got %s", testFile, event.Path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for recursive watch event")
+	}
+}
+
+func TestWatcher_RecreatedRoot(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gowatch-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	root := filepath.Join(tmpDir, "build")
+	if err := os.MkdirAll(filepath.Join(root, "out"), 0755); err != nil {
+		t.Fatalf("failed to create root: %v", err)
+	}
+
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{
+				Path:      root,
+				Recursive: true,
+			},
+		},
+		Debounce:       "50ms",
+		MaxConcurrency: 1,
+	}
+
+	log := logger.New(logger.LevelError, false)
+	w, err := New(cfg, Options{Logger: log})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// rm -rf build && mkdir -p build/out/nested
+	if err := os.RemoveAll(root); err != nil {
+		t.Fatalf("failed to remove root: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	nested := filepath.Join(root, "out", "nested")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("failed to recreate root: %v", err)
+	}
+
+	// Wait for the root to be watched again
+	time.Sleep(2 * rootCheckInterval)
+
+	testFile := filepath.Join(nested, "test.txt")
+	if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			for _, f := range event.Files {
+				if f == testFile {
+					return
+				}
+			}
+		case <-timeout:
+			t.Fatal("timeout waiting for event in recreated root")
+		}
+	}
+}
+
+func TestWatcher_PollBackend(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gowatch-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	existing := filepath.Join(tmpDir, "existing.txt")
+	if err := os.WriteFile(existing, []byte("old"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{
+				Path:      tmpDir,
+				Recursive: true,
+				Backend:   config.BackendPoll,
+			},
+		},
+		Debounce:       "50ms",
+		PollInterval:   "50ms",
+		MaxConcurrency: 1,
+	}
+
+	log := logger.New(logger.LevelInfo, false)
+	w, err := New(cfg, Options{Logger: log})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+
+	// Existing files must not fire on the first scan
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event before any change: %+v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(existing, []byte("changed"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Path != existing {
+			t.Errorf("expected event for %s, got %s", existing, event.Path)
+		}
+		if event.Op != "WRITE" {
+			t.Errorf("expected WRITE, got %s", event.Op)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for poll event")
+	}
+}
+
+func TestWatcher_Remote(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the remote listing needs GNU find")
+	}
+
+	// A stand-in for ssh that runs the remote script locally
+	bin := t.TempDir()
+	fakeSSH := filepath.Join(bin, "ssh")
+	script := "#!/bin/sh\nfor arg; do last=$arg; done\nexec sh -c \"$last\"\n"
+	if err := os.WriteFile(fakeSSH, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(prev string) { sshCommand = prev }(sshCommand)
+	sshCommand = fakeSSH
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(existing, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := "dev@build:" + dir
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{Remote: remote, Recursive: true, Ignore: []string{"*.log"}},
+		},
+		Debounce:       "50ms",
+		PollInterval:   "50ms",
+		MaxConcurrency: 1,
+	}
+
+	w, err := New(cfg, Options{})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+
+	// Existing files must not fire on the first listing
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event before any change: %+v", event)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	for name, content := range map[string]string{
+		"main.go":    "changed",
+		"debug.log":  "ignored",
+		".hidden":    "ignored",
+		"pkg/new.go": "new",
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case event := <-events:
+		want := []string{remote + "/main.go", remote + "/pkg/new.go"}
+		slices.Sort(event.Files)
+		if !slices.Equal(event.Files, want) {
+			t.Errorf("expected changes %v, got %v", want, event.Files)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for remote event")
+	}
+}
+
+func TestWatcher_Watchman(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake watchman client is a shell script")
+	}
+
+	project := t.TempDir()
+	root := filepath.Join(project, "src")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	subscribed := filepath.Join(t.TempDir(), "subscribe.json")
+
+	// A stand-in for the watchman client that reports one batch of changes
+	bin := t.TempDir()
+	fake := filepath.Join(bin, "watchman")
+	script := `#!/bin/sh
+case "$*" in
+*watch-project*)
+	echo '{"version":"2024.01.01","watch":"` + project + `","relative_path":"src"}' ;;
+*--json-command*)
+	read -r command
+	echo "$command" > ` + subscribed + `
+	echo '{"version":"2024.01.01","subscribe":"gowatch","clock":"c:1"}'
+	echo '{"subscription":"gowatch","clock":"c:2","is_fresh_instance":false,"files":[` +
+		`{"name":"main.go","exists":true,"new":false},` +
+		`{"name":"pkg/new.go","exists":true,"new":true},` +
+		`{"name":"old.go","exists":false,"new":false},` +
+		`{"name":"deep/er/x.go","exists":true,"new":false},` +
+		`{"name":".hidden","exists":true,"new":false}]}'
+	exec sleep 10 ;;
+esac
+`
+	if err := os.WriteFile(fake, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(prev string) { watchmanCommand = prev }(watchmanCommand)
+	watchmanCommand = fake
+
+	cfg := &config.Config{
+		Watch:    []config.WatchPath{{Path: root, Recursive: true, MaxDepth: 1, Backend: config.BackendWatchman}},
+		Debounce: "50ms",
+	}
+	w, err := New(cfg, Options{})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+
+	select {
+	case event := <-events:
+		want := []string{
+			filepath.Join(root, "main.go"),
+			filepath.Join(root, "old.go"),
+			filepath.Join(root, "pkg", "new.go"),
+		}
+		slices.Sort(event.Files)
+		if !slices.Equal(event.Files, want) {
+			t.Errorf("expected changes %v, got %v", want, event.Files)
+		}
+		if !slices.Contains(event.Ops, "REMOVE") || !slices.Contains(event.Ops, "CREATE") {
+			t.Errorf("ops = %v", event.Ops)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for watchman event")
+	}
+
+	data, err := os.ReadFile(subscribed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var command []any
+	if err := json.Unmarshal(data, &command); err != nil {
+		t.Fatalf("subscribe command %s: %v", data, err)
+	}
+	if len(command) != 4 || command[0] != "subscribe" || command[1] != project {
+		t.Fatalf("subscribe command = %s", data)
+	}
+	if query := command[3].(map[string]any); query["relative_root"] != "src" || query["since"] != nil {
+		t.Errorf("subscription query = %v", query)
+	}
+}
+
+func TestWatcher_Fanotify(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	wp := config.WatchPath{Path: tmpDir, Recursive: true, MaxDepth: 1, Backend: config.BackendFanotify}
+	m, err := newFanotifyWatcher(wp, tmpDir, logger.New(logger.LevelInfo, false))
+	if err != nil {
+		t.Skipf("fanotify unavailable: %v", err)
+	}
+	m.close()
+
+	cfg := &config.Config{Watch: []config.WatchPath{wp}, Debounce: "50ms"}
+	w, err := New(cfg, Options{Logger: logger.New(logger.LevelInfo, false)})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+	if len(w.marks) != 1 || w.backendFor(wp) != config.BackendFanotify {
+		t.Fatalf("watch path was not marked")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// The mark covers the whole filesystem; only changes under the watch
+	// path and within max_depth are reported
+	outside := filepath.Join(t.TempDir(), "outside.go")
+	deep := filepath.Join(tmpDir, "a", "b", "deep.go")
+	file := filepath.Join(tmpDir, "a", "main.go")
+	for _, path := range []string{outside, deep, file} {
+		if err := os.WriteFile(path, []byte("package main"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case event := <-events:
+		if event.Path != file || len(event.Files) != 1 {
+			t.Errorf("got event for %v, want only %s", event.Files, file)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for fanotify event")
+	}
+}
+
+func TestWatcher_FanotifyFile(t *testing.T) {
+	// Single files are watched through fsnotify on every platform
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(file, []byte("package main"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wp := config.WatchPath{Path: file, Backend: config.BackendFanotify}
+	cfg := &config.Config{Watch: []config.WatchPath{wp}, Debounce: "50ms"}
+	w, err := New(cfg, Options{Logger: logger.New(logger.LevelInfo, false)})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+	if len(w.marks) != 0 || w.backendFor(wp) != config.BackendFSNotify {
+		t.Fatalf("file path should fall back to fsnotify")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := os.WriteFile(file, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if event.Path != file {
+			t.Errorf("got event for %s, want %s", event.Path, file)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for file event")
+	}
+}
+
+func TestWatcher_OverflowRescan(t *testing.T) {
+	tmpDir := t.TempDir()
+	old := filepath.Join(tmpDir, "old.go")
+	changed := filepath.Join(tmpDir, "sub", "changed.go")
+	if err := os.MkdirAll(filepath.Dir(changed), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{old, changed, filepath.Join(tmpDir, "changed.log")} {
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only files modified after the last event received are reported
+	if err := os.Chtimes(old, time.Now(), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Watch:    []config.WatchPath{{Path: tmpDir, Recursive: true, Ignore: []string{"*.log"}}},
+		Debounce: "50ms",
+	}
+	w, err := New(cfg, Options{Logger: logger.New(logger.LevelInfo, false)})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+
+	w.fsWatcher.Errors <- fsnotify.ErrEventOverflow
+
+	select {
+	case event := <-events:
+		if event.Path != changed || len(event.Files) != 1 {
+			t.Errorf("got event for %v, want only %s", event.Files, changed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for rescan event")
+	}
+}
+
+func TestIsNetworkPath(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("network shares are only detected on Windows")
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{`\\fileserver\builds\app`, true},
+		{`\\?\UNC\fileserver\builds\app`, true},
+		{`\\?\C:\src`, false},
+		{os.TempDir(), false},
+	}
+	for _, tt := range tests {
+		if got := isNetworkPath(tt.path); got != tt.want {
+			t.Errorf("isNetworkPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestWatcher_BatchesEvents(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gowatch-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{
+				Path:      tmpDir,
+				Recursive: true,
+			},
+		},
+		Debounce:       "200ms",
+		MaxConcurrency: 1,
+	}
+
+	log := logger.New(logger.LevelInfo, false)
+	w, err := New(cfg, Options{Logger: log})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("test"), 0644); err != nil {
+			t.Fatalf("failed to write test file: %v", err)
+		}
+	}
+
+	select {
+	case event := <-events:
+		if len(event.Files) != 3 {
+			t.Errorf("expected 3 files in batch, got %v", event.Files)
+		}
+		if event.Path != filepath.Join(tmpDir, "c.txt") {
+			t.Errorf("expected last change to be c.txt, got %s", event.Path)
+		}
+		// Every change is timed when it was seen, before the batch is flushed
+		if len(event.Changed) != len(event.Files) {
+			t.Errorf("expected a change time per file, got %v", event.Changed)
+		}
+		for i, at := range event.Changed {
+			if at.IsZero() || at.After(event.Timestamp) || i > 0 && at.Before(event.Changed[i-1]) {
+				t.Errorf("change times %v out of order with flush at %v", event.Changed, event.Timestamp)
+				break
+			}
+		}
+		// Each file was created, whatever else happened to the others
+		if len(event.FileOps) != len(event.Files) || slices.ContainsFunc(event.FileOps, func(ops []string) bool { return !slices.Contains(ops, "CREATE") }) {
+			t.Errorf("expected every file's event types to include CREATE, got %v", event.FileOps)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for batched event")
+	}
+
+	select {
+	case event := <-events:
+		t.Fatalf("expected a single batched event, got another: %+v", event)
+	case <-time.After(400 * time.Millisecond):
+	}
+}
+
+func TestWatcher_Tags(t *testing.T) {
+	tmpDir := t.TempDir()
+	apiDir := filepath.Join(tmpDir, "api")
+	webDir := filepath.Join(tmpDir, "web")
+	for _, dir := range []string{apiDir, webDir} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{Path: tmpDir, Recursive: true},
+			{Path: apiDir, Recursive: true, Tags: []string{"backend"}},
+			{Path: webDir, Recursive: true, Tags: []string{"frontend", "assets"}},
+		},
+		Debounce:       "200ms",
+		MaxConcurrency: 1,
+	}
+	w, err := New(cfg, Options{Logger: logger.Discard()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{"untagged path", []string{filepath.Join(tmpDir, "README.md")}, nil},
+		{"tagged path", []string{filepath.Join(apiDir, "main.go")}, []string{"backend"}},
+		{"batch across paths", []string{filepath.Join(webDir, "app.ts"), filepath.Join(apiDir, "api.go"), filepath.Join(tmpDir, "go.mod")}, []string{"frontend", "assets", "backend"}},
+	}
+	for _, tt := range tests {
+		for _, f := range tt.files {
+			if err := os.WriteFile(f, []byte("test"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		select {
+		case event := <-events:
+			if !slices.Equal(event.Tags, tt.want) {
+				t.Errorf("%s: tags = %v, want %v", tt.name, event.Tags, tt.want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: timeout waiting for event", tt.name)
+		}
+	}
+}
+
+func TestWatcher_IncludeFilter(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gowatch-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	docsDir := filepath.Join(tmpDir, "docs")
+	if err := os.Mkdir(docsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{
+				Path:       tmpDir,
+				Recursive:  true,
+				Include:    []string{"api/**/*.proto"},
+				Extensions: []string{"go", ".MOD"},
+			},
+			// A more specific watch path without filters accepts everything
+			{Path: docsDir, Recursive: true},
+		},
+		Debounce: "100ms",
+	}
+
+	w, err := New(cfg, Options{Logger: logger.New(logger.LevelInfo, false)})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"main.go", false, true},
+		{"go.mod", false, true},
+		{"README.md", false, false},
+		{"api/v1/service.proto", false, true},
+		{"service.proto", false, false},
+		{"pkg", true, false},
+		{"docs/guide.md", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			path := filepath.Join(tmpDir, filepath.FromSlash(tt.path))
+			if got := w.isIncluded(path, tt.isDir); got != tt.expected {
+				t.Errorf("isIncluded(%s) = %v, want %v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWatcher_EventFilter(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gowatch-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	scriptsDir := filepath.Join(tmpDir, "scripts")
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{Path: tmpDir, Recursive: true},
+			{Path: scriptsDir, Recursive: true, Events: []string{"CREATE", "chmod"}},
+		},
+		Debounce: "100ms",
+	}
+
+	w, err := New(cfg, Options{Logger: logger.New(logger.LevelInfo, false)})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		op       fsnotify.Op
+		expected bool
+	}{
+		{"write by default", "main.go", fsnotify.Write, true},
+		{"chmod dropped by default", "main.go", fsnotify.Chmod, false},
+		{"chmod opted in", "scripts/run.sh", fsnotify.Chmod, true},
+		{"create listed", "scripts/run.sh", fsnotify.Create, true},
+		{"write not listed", "scripts/run.sh", fsnotify.Write, false},
+		{"combined op", "scripts/run.sh", fsnotify.Write | fsnotify.Create, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, filepath.FromSlash(tt.path))
+			if got := w.acceptsOp(path, tt.op); got != tt.expected {
+				t.Errorf("acceptsOp(%s, %s) = %v, want %v", tt.path, tt.op, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWatcher_MaxFileSize(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Watch:       []config.WatchPath{{Path: tmpDir, Recursive: true}},
+		Debounce:    "50ms",
+		MaxFileSize: "1KB",
+	}
+
+	w, err := New(cfg, Options{Logger: logger.New(logger.LevelInfo, false)})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	large := filepath.Join(tmpDir, "dataset.bin")
+	if err := os.WriteFile(large, make([]byte, 2048), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The large file's CREATE may be seen while it is still empty; it is
+	// dropped from the batch when it is flushed
+	small := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(small, []byte("package main"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Path != small || len(event.Files) != 1 {
+			t.Errorf("got event for %v, want only %s", event.Files, small)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for file event")
+	}
+}
+
+func TestWatcher_MaxDepth(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gowatch-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, "a", "b", "c"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{Path: tmpDir, Recursive: true, MaxDepth: 1},
+		},
+		Debounce: "50ms",
+	}
+
+	w, err := New(cfg, Options{Logger: logger.New(logger.LevelError, false)})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	if n, err := w.CountWatches(); err != nil || n != 2 {
+		t.Errorf("CountWatches() = %d, %v, want 2", n, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := w.Start(ctx); err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Directories created later are limited the same way
+	if err := os.MkdirAll(filepath.Join(tmpDir, "n", "m"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{".", true},
+		{"a", true},
+		{"a/b", false},
+		{"a/b/c", false},
+		{"n", true},
+		{"n/m", false},
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, tt := range tests {
+		path := filepath.Join(tmpDir, filepath.FromSlash(tt.path))
+		if got := w.watched[path]; got != tt.expected {
+			t.Errorf("watched[%s] = %v, want %v", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestWatcher_ParallelWalk(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gowatch-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Enough directories to keep every worker busy, some of them under
+	// ignored ones
+	want := []string{tmpDir}
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 5; j++ {
+			dir := filepath.Join(tmpDir, fmt.Sprintf("d%d", i), fmt.Sprintf("s%d", j))
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if j == 0 {
+				want = append(want, filepath.Dir(dir))
+			}
+			if i%5 != 0 && j != 3 {
+				want = append(want, dir)
+			}
+		}
+		if i%5 == 0 {
+			// Ignored below d0, d5, ...; the ignore file must be loaded
+			// before their subdirectories are looked at
+			if err := os.WriteFile(filepath.Join(tmpDir, fmt.Sprintf("d%d", i), ".gowatchignore"), []byte("s*/\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{Path: tmpDir, Recursive: true, Ignore: []string{"s3/"}},
+		},
+	}
+	w, err := New(cfg, Options{Logger: logger.New(logger.LevelError, false)})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	var mu sync.Mutex
+	var got []string
+	err = w.walkDirs(tmpDir, walkOptions{maxDepth: -1}, func(dir string) error {
+		mu.Lock()
+		got = append(got, dir)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walkDirs() error = %v", err)
+	}
+	slices.Sort(got)
+	slices.Sort(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("walkDirs() visited %d dirs, want %d:\n%v\nwant\n%v", len(got), len(want), got, want)
+	}
+
+	// The first error stops the walk and is returned
+	stop := errors.New("stop")
+	err = w.walkDirs(tmpDir, walkOptions{maxDepth: -1}, func(dir string) error {
+		if filepath.Base(dir) == "d7" {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("walkDirs() error = %v, want %v", err, stop)
+	}
+}
+
+func TestWatcher_WatchCache(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// The temporary directory may be reached through a symlink
+	tmpDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"a/b", "c", "skip/x"} {
+		if err := os.MkdirAll(filepath.FromSlash(dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(ignore.FileName, []byte("skip/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Watch:      []config.WatchPath{{Path: ".", Recursive: true}},
+		Debounce:   "50ms",
+		WatchCache: true,
+	}
+	// start runs a watcher until its cache holds want
+	start := func(want []string, check func(w *Watcher)) {
+		t.Helper()
+		w, err := New(cfg, Options{Logger: logger.New(logger.LevelError, false)})
+		if err != nil {
+			t.Fatalf("failed to create watcher: %v", err)
+		}
+		defer w.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if _, err := w.Start(ctx); err != nil {
+			t.Fatalf("failed to start watcher: %v", err)
+		}
+		if check != nil {
+			check(w)
+		}
+
+		var got []string
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+			cache, err := readWatchCache(config.DefaultWatchCacheFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = got[:0]
+			if root := cache.Roots[tmpDir]; root != nil {
+				for rel := range root.Dirs {
+					got = append(got, rel)
+				}
+			}
+			slices.Sort(got)
+			if slices.Equal(got, want) {
+				return
+			}
+		}
+		t.Fatalf("cached dirs = %v, want %v", got, want)
+	}
+	watched := func(w *Watcher, dir string) bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return w.watched[filepath.Join(tmpDir, filepath.FromSlash(dir))]
+	}
+
+	start([]string{".", "a", "a/b", "c"}, nil)
+
+	if err := os.RemoveAll("c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join("a", "b", "n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	start([]string{".", "a", "a/b", "a/b/n"}, func(w *Watcher) {
+		// Watched from the cache before the tree is checked
+		if !watched(w, "a/b") {
+			t.Error("a/b not watched from the cache")
+		}
+		if watched(w, "skip/x") {
+			t.Error("ignored skip/x watched")
+		}
+	})
+}
+
+func TestWatcher_FollowSymlinks(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gowatch-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	root := filepath.Join(tmpDir, "service")
+	shared := filepath.Join(tmpDir, "shared")
+	if err := os.MkdirAll(filepath.Join(shared, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(shared, filepath.Join(root, "shared")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	// A link back up the tree must not be followed forever
+	if err := os.Symlink(shared, filepath.Join(shared, "pkg", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{Path: root, Recursive: true, FollowSymlinks: true},
+		},
+		Debounce:       "50ms",
+		MaxConcurrency: 1,
+	}
+
+	w, err := New(cfg, Options{Logger: logger.New(logger.LevelError, false)})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+
+	linked := filepath.Join(root, "shared", "pkg")
+	w.mu.Lock()
+	watched := w.watched[linked]
+	loop := w.watched[filepath.Join(linked, "loop")]
+	w.mu.Unlock()
+	if !watched {
+		t.Fatalf("symlinked directory %s not watched", linked)
+	}
+	if loop {
+		t.Error("symlink cycle was followed")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err := os.WriteFile(filepath.Join(shared, "pkg", "lib.go"), []byte("package pkg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-events:
+		if want := filepath.Join(linked, "lib.go"); event.Path != want {
+			t.Errorf("expected event for %s, got %s", want, event.Path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for event in symlinked directory")
+	}
+}
+
+func TestWatchLimitError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     WatchLimitError
+		want    []string
+		suggest int
+	}{
+		{
+			name:    "known limit",
+			err:     WatchLimitError{Needed: 9000, Limit: 8192},
+			want:    []string{"at least 9000 watches needed", "max_user_watches is 8192", "max_user_watches=524288"},
+			suggest: 524288,
+		},
+		{
+			name:    "huge tree",
+			err:     WatchLimitError{Needed: 600000},
+			want:    []string{"watch limit reached: at least 600000", "max_user_watches=2097152"},
+			suggest: 2097152,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.err.Error()
+			for _, want := range tt.want {
+				if !strings.Contains(msg, want) {
+					t.Errorf("Error() = %q, want it to contain %q", msg, want)
+				}
+			}
+			if got := SuggestedLimit(tt.err.Needed); got != tt.suggest {
+				t.Errorf("SuggestedLimit(%d) = %d, want %d", tt.err.Needed, got, tt.suggest)
+			}
+		})
+	}
+
+	if !isWatchLimit(fmt.Errorf("failed to watch x: %w", syscall.ENOSPC)) {
+		t.Error("isWatchLimit(ENOSPC) = false")
+	}
+}
+
+func TestWatcher_Stats(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "notes.txt", "debug.log"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		Watch:    []config.WatchPath{{Path: tmpDir, Recursive: true, Extensions: []string{"go"}, Ignore: []string{"*.log"}}},
+		Debounce: "50ms",
+	}
+	w, err := New(cfg, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	output := make(chan Event, 1)
+	for _, ev := range []fsnotify.Event{
+		{Name: filepath.Join(tmpDir, "a.go"), Op: fsnotify.Write},
+		{Name: filepath.Join(tmpDir, "b.go"), Op: fsnotify.Write},
+		{Name: filepath.Join(tmpDir, "a.go"), Op: fsnotify.Write},
+		{Name: filepath.Join(tmpDir, "debug.log"), Op: fsnotify.Write},
+		{Name: filepath.Join(tmpDir, "notes.txt"), Op: fsnotify.Write},
+		{Name: filepath.Join(tmpDir, "b.go"), Op: fsnotify.Chmod},
+	} {
+		w.handleEvent(ctx, ev, output)
+	}
+
+	select {
+	case <-output:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+	want := Stats{Received: 6, Ignored: 1, Filtered: 2, Debounced: 2, Emitted: 1}
+	if got := w.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWatcher_RootStats(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src")
+	notes := filepath.Join(tmpDir, "notes.txt")
+	for _, name := range []string{"src/a.go", "src/debug.log", "src/.hidden", "src/sub/b.go", "src/sub/c.txt", "notes.txt"} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		Watch: []config.WatchPath{
+			{Path: src, Recursive: true, Ignore: []string{"*.log"}},
+			{Path: notes},
+		},
+		Debounce: "50ms",
+	}
+	w, err := New(cfg, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	for _, wp := range cfg.Watch {
+		if err := w.addPath(wp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	output := make(chan Event, 10)
+	for _, path := range []string{"src/a.go", "src/debug.log", "src/debug.log", "src/.hidden", "notes.txt"} {
+		w.handleEvent(ctx, fsnotify.Event{Name: filepath.Join(tmpDir, filepath.FromSlash(path)), Op: fsnotify.Write}, output)
+	}
+
+	want := []RootStats{
+		{Path: src, Watched: 2, Files: 5, Events: 4, Ignored: 3, IgnoredBy: map[string]int64{"watch[0]: *.log": 2, "hidden path": 1}},
+		{Path: notes, Watched: 1, Files: 1, Events: 1},
+	}
+	if got := w.RootStats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RootStats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWatcher_QueueOverflow(t *testing.T) {
+	at := func(sec int) time.Time { return time.Unix(int64(sec), 0) }
+	queued := []Event{
+		{Path: "a.go", Op: "WRITE", Files: []string{"a.go"}, Changed: []time.Time{at(1)}, Ops: []string{"WRITE"}, Tags: []string{"api"},
+			FileOps: [][]string{{"WRITE"}}, FileTags: [][]string{{"api"}}},
+		{Path: "b.go", Op: "CREATE", Files: []string{"c.go", "b.go"}, Changed: []time.Time{at(2), at(3)}, Ops: []string{"CREATE", "WRITE"}, Tags: []string{"web"},
+			FileOps: [][]string{{"CREATE"}, {"CREATE", "WRITE"}}, FileTags: [][]string{{"web"}, {"web"}}},
+	}
+	next := Event{Path: "a.go", Op: "REMOVE", Files: []string{"a.go"}, Changed: []time.Time{at(4)}, Ops: []string{"REMOVE"}, Tags: []string{"api"},
+		FileOps: [][]string{{"REMOVE"}}, FileTags: [][]string{{"api"}}}
+
+	tests := []struct {
+		policy string
+		want   []Event
+	}{
+		// Each file keeps the time of its latest change, and the event types
+		// and tags of all of them
+		{config.OverflowCoalesce, []Event{
+			{Path: "a.go", Op: "REMOVE", Files: []string{"c.go", "b.go", "a.go"}, Changed: []time.Time{at(2), at(3), at(4)},
+				Ops: []string{"WRITE", "CREATE", "REMOVE"}, Tags: []string{"api", "web"},
+				FileOps: [][]string{{"CREATE"}, {"CREATE", "WRITE"}, {"WRITE", "REMOVE"}}, FileTags: [][]string{{"web"}, {"web"}, {"api"}}},
+		}},
+		{config.OverflowDropOldest, []Event{queued[1], next}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			w := &Watcher{cfg: &config.Config{QueueOverflow: tt.policy}, log: logger.Discard()}
+			output := make(chan Event, len(queued))
+			for _, ev := range queued {
+				output <- ev
+			}
+			if !w.send(context.Background(), output, next) {
+				t.Fatal("send() = false")
+			}
+
+			var got []Event
+			for len(output) > 0 {
+				got = append(got, <-output)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("queue = %+v, want %+v", got, tt.want)
+			}
+			if s := w.Stats(); s.Overflowed != 1 {
+				t.Errorf("Overflowed = %d, want 1", s.Overflowed)
+			}
+		})
+	}
+
+	t.Run(config.OverflowBlock, func(t *testing.T) {
+		w := &Watcher{cfg: &config.Config{QueueOverflow: config.OverflowBlock}, log: logger.Discard()}
+		output := make(chan Event, 1)
+		output <- queued[0]
+
+		ctx, cancel := context.WithCancel(context.Background())
+		sent := make(chan bool)
+		go func() { sent <- w.send(ctx, output, next) }()
+		select {
+		case <-sent:
+			t.Fatal("send() returned while the queue was full")
+		case <-time.After(50 * time.Millisecond):
+		}
+		if got := <-output; got.Path != "a.go" || got.Op != "WRITE" {
+			t.Errorf("first event = %+v", got)
+		}
+		if !<-sent {
+			t.Error("send() = false once there was room")
+		}
+		cancel()
+
+		// Cancelled while waiting
+		go func() { sent <- w.send(ctx, output, next) }()
+		if <-sent {
+			t.Error("send() = true after cancellation")
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package watcher
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gowatch/pkg/config"
+	"gowatch/pkg/logger"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchmanCommand is the Watchman client used by the watchman backend
+var watchmanCommand = "watchman"
+
+// watchmanRetry is how long to wait before subscribing again after the
+// connection to the Watchman daemon was lost
+const watchmanRetry = 2 * time.Second
+
+// watchmanWatcher receives the changes under a watch path from a running
+// Watchman daemon. It watches the project containing the path and keeps a
+// subscription open through the watchman client; Watchman handles recrawls
+// and its clock lets a new subscription pick up where a lost one stopped.
+type watchmanWatcher struct {
+	wp   config.WatchPath
+	root string
+	log  *logger.Logger
+
+	// clock is the Watchman clock of the last change received
+	clock string
+}
+
+// watchmanFile is a changed file of a subscription update
+type watchmanFile struct {
+	Name   string `json:"name"`
+	Exists bool   `json:"exists"`
+	New    bool   `json:"new"`
+}
+
+// watchmanResponse is any message of the Watchman JSON protocol
+type watchmanResponse struct {
+	Error        string         `json:"error"`
+	Warning      string         `json:"warning"`
+	Watch        string         `json:"watch"`
+	RelativePath string         `json:"relative_path"`
+	Subscription string         `json:"subscription"`
+	Clock        string         `json:"clock"`
+	Fresh        bool           `json:"is_fresh_instance"`
+	Files        []watchmanFile `json:"files"`
+}
+
+func newWatchmanWatcher(wp config.WatchPath, root string, log *logger.Logger) *watchmanWatcher {
+	return &watchmanWatcher{wp: wp, root: root, log: log}
+}
+
+// run follows the watch path until the context is cancelled, subscribing
+// again whenever the connection drops
+func (m *watchmanWatcher) run(ctx context.Context, out chan<- fsnotify.Event) {
+	for {
+		err := m.subscribe(ctx, out)
+		if ctx.Err() != nil {
+			return
+		}
+		m.log.Warn("Lost Watchman subscription for %s: %v (retrying in %s)", m.root, err, watchmanRetry)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchmanRetry):
+		}
+	}
+}
+
+// subscribe watches the project containing the root and forwards the
+// changes of one subscription until it ends
+func (m *watchmanWatcher) subscribe(ctx context.Context, out chan<- fsnotify.Event) error {
+	project, err := m.watchProject(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := map[string]any{
+		"expression":              []any{"anyof", []any{"type", "f"}, []any{"type", "l"}},
+		"fields":                  []string{"name", "exists", "new"},
+		"empty_on_fresh_instance": true,
+	}
+	if project.RelativePath != "" {
+		query["relative_root"] = project.RelativePath
+	}
+	if m.clock != "" {
+		query["since"] = m.clock
+	}
+	command, err := json.Marshal([]any{"subscribe", project.Watch, "gowatch", query})
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, watchmanCommand, "--no-pretty", "--persistent", "--json-command")
+	cmd.Stdin = strings.NewReader(string(command) + "\n")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var resp watchmanResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			m.log.Debug("Unexpected Watchman output: %s", scanner.Text())
+			continue
+		}
+		if resp.Error != "" {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return errors.New(resp.Error)
+		}
+		if resp.Warning != "" {
+			m.log.Warn("Watchman: %s", resp.Warning)
+		}
+		if resp.Subscription == "" {
+			m.log.Watch("Watching %s through Watchman", m.root)
+			continue
+		}
+
+		if resp.Clock != "" {
+			m.clock = resp.Clock
+		}
+		if resp.Fresh {
+			m.log.Debug("Watchman recrawled %s", m.root)
+		}
+		for _, f := range resp.Files {
+			if !withinDepth(m.wp, f.Name) {
+				continue
+			}
+			ev := fsnotify.Event{Name: filepath.Join(m.root, filepath.FromSlash(f.Name)), Op: fsnotify.Write}
+			switch {
+			case !f.Exists:
+				ev.Op = fsnotify.Remove
+			case f.New:
+				ev.Op = fsnotify.Create
+			}
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+
+	err = cmd.Wait()
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("%s", msg)
+	}
+	if err == nil {
+		err = errors.New("subscription ended")
+	}
+	return err
+}
+
+// watchProject asks Watchman to watch the project containing the root,
+// returning the project root and the root's path inside it
+func (m *watchmanWatcher) watchProject(ctx context.Context) (watchmanResponse, error) {
+	var resp watchmanResponse
+	output, err := exec.CommandContext(ctx, watchmanCommand, "--no-pretty", "watch-project", m.root).Output()
+	if jsonErr := json.Unmarshal(output, &resp); jsonErr != nil {
+		if err == nil {
+			err = jsonErr
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			err = errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return resp, fmt.Errorf("watchman watch-project: %w", err)
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("watchman watch-project: %s", resp.Error)
+	}
+	return resp, nil
+}